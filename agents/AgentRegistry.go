@@ -0,0 +1,259 @@
+package agents
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/MattSScott/basePlatformSOMAS/v2/pkg/agent"
+
+	common "github.com/ADimoska/SOMASExtended/common"
+)
+
+/*
+* Registry of every agent implementation that can be created by name, e.g.
+* from a scenario file. Each team registers its agents from an init function in
+* the file that defines them. Strategies living in other packages can do the
+* same by calling RegisterAgentFactory from their own init function; importing
+* that package (even as `import _ "..."`) is then enough to make them available.
+*
+* Names are of the form "team:variant", e.g. "team1:cheat_long_term". The
+* variant can be left out for a team's default agent, e.g. "team2".
+ */
+
+type ParamKind int
+
+const (
+	ParamInt ParamKind = iota
+	ParamFloat
+	ParamString
+	ParamBool
+)
+
+func (k ParamKind) String() string {
+	switch k {
+	case ParamInt:
+		return "int"
+	case ParamFloat:
+		return "float"
+	case ParamString:
+		return "string"
+	case ParamBool:
+		return "bool"
+	default:
+		return fmt.Sprintf("ParamKind(%d)", int(k))
+	}
+}
+
+// Describes one of the free-form parameters a factory accepts
+type ParamSpec struct {
+	Name        string
+	Kind        ParamKind
+	Description string
+	Default     any      // used when the parameter is not given, nil for no default
+	Min, Max    float64  // allowed range for ParamInt and ParamFloat, ignored unless Max > Min
+	Options     []string // allowed values for ParamString, any value if empty
+}
+
+// Free-form parameters passed to a factory, keyed by ParamSpec.Name
+type AgentParams map[string]any
+
+type AgentConstructor func(funcs agent.IExposedServerFunctions[common.IExtendedAgent], config AgentConfig, params AgentParams) common.IExtendedAgent
+
+type AgentFactory struct {
+	Name        string
+	Description string
+	Params      []ParamSpec
+	Create      AgentConstructor
+}
+
+// The reason a parameter was rejected by a factory
+type ParamError struct {
+	Factory string
+	Param   string
+	Problem string
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("agent factory %s: parameter %s: %s", e.Factory, e.Param, e.Problem)
+}
+
+var (
+	factoriesMutex sync.RWMutex
+	agentFactories = make(map[string]AgentFactory)
+)
+
+// Make a factory available by name. Panics if the name is already taken, as
+// this is a programming error that should be caught at start up.
+func RegisterAgentFactory(factory AgentFactory) {
+	if factory.Name == "" || factory.Create == nil {
+		panic("agents: RegisterAgentFactory needs a name and a constructor")
+	}
+
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+	if _, exists := agentFactories[factory.Name]; exists {
+		panic("agents: factory registered twice: " + factory.Name)
+	}
+	agentFactories[factory.Name] = factory
+}
+
+func GetAgentFactory(name string) (AgentFactory, bool) {
+	factoriesMutex.RLock()
+	defer factoriesMutex.RUnlock()
+	factory, exists := agentFactories[name]
+	return factory, exists
+}
+
+// Names of all registered factories, in alphabetical order
+func AgentFactoryNames() []string {
+	factoriesMutex.RLock()
+	defer factoriesMutex.RUnlock()
+	names := make([]string, 0, len(agentFactories))
+	for name := range agentFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Look up a factory by name and use it to create an agent
+func CreateAgentByName(name string, funcs agent.IExposedServerFunctions[common.IExtendedAgent], config AgentConfig, params AgentParams) (common.IExtendedAgent, error) {
+	factory, exists := GetAgentFactory(name)
+	if !exists {
+		return nil, fmt.Errorf("agents: unknown factory %q, expected one of [%s]", name, strings.Join(AgentFactoryNames(), ", "))
+	}
+	return factory.New(funcs, config, params)
+}
+
+// Validate the parameters, fill in defaults and create the agent
+func (f AgentFactory) New(funcs agent.IExposedServerFunctions[common.IExtendedAgent], config AgentConfig, params AgentParams) (common.IExtendedAgent, error) {
+	if errs := f.ValidateParams(params); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return f.Create(funcs, config, f.withDefaults(params)), nil
+}
+
+// Check every given parameter against the schema. One error is returned per
+// offending parameter, in alphabetical order.
+func (f AgentFactory) ValidateParams(params AgentParams) []*ParamError {
+	specs := make(map[string]ParamSpec, len(f.Params))
+	for _, spec := range f.Params {
+		specs[spec.Name] = spec
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []*ParamError
+	for _, name := range names {
+		spec, known := specs[name]
+		if !known {
+			errs = append(errs, &ParamError{f.Name, name, "not a parameter of this factory"})
+			continue
+		}
+		if problem := spec.check(params[name]); problem != "" {
+			errs = append(errs, &ParamError{f.Name, name, problem})
+		}
+	}
+	return errs
+}
+
+func (f AgentFactory) withDefaults(params AgentParams) AgentParams {
+	filled := make(AgentParams, len(f.Params))
+	for _, spec := range f.Params {
+		if spec.Default != nil {
+			filled[spec.Name] = spec.Default
+		}
+	}
+	for name, value := range params {
+		filled[name] = value
+	}
+	return filled
+}
+
+func (spec ParamSpec) check(value any) string {
+	var number float64
+	switch spec.Kind {
+	case ParamInt:
+		n, ok := value.(int)
+		if !ok {
+			return fmt.Sprintf("must be an int, got %v", value)
+		}
+		number = float64(n)
+	case ParamFloat:
+		switch n := value.(type) {
+		case float64:
+			number = n
+		case int:
+			number = float64(n)
+		default:
+			return fmt.Sprintf("must be a number, got %v", value)
+		}
+	case ParamString:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Sprintf("must be a string, got %v", value)
+		}
+		if len(spec.Options) > 0 && !contains(spec.Options, s) {
+			return fmt.Sprintf("must be one of [%s], got %q", strings.Join(spec.Options, ", "), s)
+		}
+		return ""
+	case ParamBool:
+		if _, ok := value.(bool); !ok {
+			return fmt.Sprintf("must be a bool, got %v", value)
+		}
+		return ""
+	}
+
+	if spec.Max > spec.Min && (number < spec.Min || number > spec.Max) {
+		return fmt.Sprintf("must be between %v and %v, got %v", spec.Min, spec.Max, value)
+	}
+	return ""
+}
+
+func contains(options []string, s string) bool {
+	for _, option := range options {
+		if option == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Typed accessors for use inside constructors, where the parameters have
+// already been validated against the schema
+
+func (p AgentParams) Int(name string) int {
+	n, _ := p[name].(int)
+	return n
+}
+
+func (p AgentParams) Float(name string) float64 {
+	switch n := p[name].(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	}
+	return 0
+}
+
+func (p AgentParams) String(name string) string {
+	s, _ := p[name].(string)
+	return s
+}
+
+func (p AgentParams) Bool(name string) bool {
+	b, _ := p[name].(bool)
+	return b
+}
+
+func (p AgentParams) Has(name string) bool {
+	_, exists := p[name]
+	return exists
+}
@@ -1,9 +1,11 @@
 package agents
 
 import (
+	"encoding/json"
 	"log"
+	"math"
 	"math/rand"
-	"time"
+	"slices"
 
 	"github.com/google/uuid"
 
@@ -44,6 +46,12 @@ type ExtendedAgent struct {
 
 	// Team3 AoA Agent Memory
 	currentStrategy common.Strategy
+
+	// opinions the agent holds and has been told, see common/Trust.go
+	trust *common.TrustNetwork
+
+	// the agent's own random stream, derived from the game's master seed
+	rng *rand.Rand
 }
 
 type AgentConfig struct {
@@ -51,10 +59,24 @@ type AgentConfig struct {
 	VerboseLevel int
 }
 
+func init() {
+	RegisterAgentFactory(AgentFactory{
+		Name:        "base",
+		Description: "Base agent with random dice decisions and no team strategy",
+		Create: func(funcs agent.IExposedServerFunctions[common.IExtendedAgent], config AgentConfig, params AgentParams) common.IExtendedAgent {
+			return GetBaseAgents(funcs, config)
+		},
+	})
+}
+
 func GetBaseAgents(funcs agent.IExposedServerFunctions[common.IExtendedAgent], configParam AgentConfig) *ExtendedAgent {
 	aoaRanking := []int{1, 2, 3, 4, 5, 6}
 
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	baseAgent := agent.CreateBaseAgent(funcs)
+	if bound, ok := funcs.(common.IAgentBoundServer); ok {
+		bound.BindAgent(baseAgent.GetID())
+	}
+	rng := common.NewRandStream("agent/" + baseAgent.GetID().String())
 
 	// Shuffle the slice to create a random order.
 	rng.Shuffle(len(aoaRanking), func(i, j int) {
@@ -62,11 +84,13 @@ func GetBaseAgents(funcs agent.IExposedServerFunctions[common.IExtendedAgent], c
 	})
 
 	return &ExtendedAgent{
-		BaseAgent:    agent.CreateBaseAgent(funcs),
+		BaseAgent:    baseAgent,
 		Server:       funcs.(common.IServer), // Type assert the server functions to IServer interface
 		Score:        configParam.InitScore,
 		VerboseLevel: configParam.VerboseLevel,
 		AoARanking:   aoaRanking,
+		trust:        common.NewTrustNetwork(),
+		rng:          rng,
 	}
 }
 
@@ -102,53 +126,24 @@ func (mi *ExtendedAgent) SetName(name int) {
 	mi.Name = name
 }
 
-// custom function: ask for rolling the dice
-func (mi *ExtendedAgent) StartRollingDice(instance common.IExtendedAgent) {
-	if mi.VerboseLevel > 10 {
-		log.Printf("%s is rolling the Dice\n", mi.GetID())
-	}
-	if mi.VerboseLevel > 9 {
+// The server has rolled the dice for this agent
+func (mi *ExtendedAgent) HandleDiceRoll(roll common.DiceRoll) {
+	if roll.Throw == 1 && mi.VerboseLevel > 9 {
 		log.Println("---------------------")
 	}
-	// TODO: implement the logic in environment, do a random of 3d6 now with 50% chance to stick
-	mi.LastScore = -1
-	rounds := 1
-	turnScore := 0
-
-	willStick := false
-
-	// loop until not stick
-	for !willStick {
-		// debug add score directly
-		currentScore := Roll3Dice()
-
-		// check if currentScore is higher than lastScore
-		if currentScore > mi.LastScore {
-			turnScore += currentScore
-			mi.LastScore = currentScore
-			willStick = instance.StickOrAgain(turnScore, currentScore)
-			if willStick {
-				mi.DecideStick() //used just for debugging
-				break
-			}
-			mi.DecideRollAgain() //used just for debugging
-		} else {
-			// burst, lose all turn score
-			if mi.VerboseLevel > 4 {
-				log.Printf("%s **BURSTED!** round: %v, current score: %v\n", mi.GetID(), rounds, currentScore)
-			}
-			turnScore = 0
-			break
+	if roll.Bust {
+		if mi.VerboseLevel > 4 {
+			log.Printf("%s **BURSTED!** round: %v, current score: %v\n", mi.GetID(), roll.Throw, roll.Roll)
 		}
-
-		rounds++
+		return
 	}
+	mi.LastScore = roll.Roll
+}
 
-	// add turn score to total score
-	mi.Score += turnScore
-
+// The server has finished rolling for this agent and credited its turn score
+func (mi *ExtendedAgent) HandleRollingComplete(turnScore int, score int) {
 	if mi.VerboseLevel > 4 {
-		log.Printf("%s's turn score: %v, total score: %v\n", mi.GetID(), turnScore, mi.Score)
+		log.Printf("%s's turn score: %v, total score: %v\n", mi.GetID(), turnScore, score)
 	}
 }
 
@@ -157,7 +152,7 @@ func (mi *ExtendedAgent) StickOrAgain(accumulatedScore int, prevRoll int) bool {
 	// if mi.verboseLevel > 8 {
 	// 	log.Printf("%s is deciding to stick or again\n", mi.GetID())
 	// }
-	return rand.Intn(2) == 0
+	return mi.rng.Intn(2) == 0
 }
 
 // decide to stick
@@ -179,12 +174,12 @@ func (mi *ExtendedAgent) DecideRollAgain() {
 // This function MUST return the same value when called multiple times in the same turn
 func (mi *ExtendedAgent) GetActualContribution(instance common.IExtendedAgent) int {
 	if mi.HasTeam() {
-		contribution := mi.Server.GetTeam(mi.GetID()).TeamAoA.GetExpectedContribution(mi.GetID(), mi.GetTrueScore())
+		contribution := mi.Server.GetTeam(mi.GetID()).GetExpectedContribution(mi.GetID(), mi.GetTrueScore())
 		if mi.GetTrueScore() < contribution {
 			contribution = mi.GetTrueScore() // give all score if less than expected
 		}
 		if mi.VerboseLevel > 6 {
-			log.Printf("%s is contributing %d to the common pool and thinks the common pool size is %d\n", mi.GetID(), contribution, mi.Server.GetTeam(mi.GetID()).GetCommonPool())
+			log.Printf("%s is contributing %d to the common pool and thinks the common pool size is %d\n", mi.GetID(), contribution, mi.Server.GetTeam(mi.GetID()).CommonPool)
 		}
 		return contribution
 	} else {
@@ -215,8 +210,8 @@ func (mi *ExtendedAgent) GetActualWithdrawal(instance common.IExtendedAgent) int
 	if !mi.HasTeam() {
 		return 0
 	}
-	commonPool := mi.Server.GetTeam(mi.GetID()).GetCommonPool()
-	withdrawal := mi.Server.GetTeam(mi.GetID()).TeamAoA.GetExpectedWithdrawal(mi.GetID(), mi.GetTrueScore(), commonPool)
+	commonPool := mi.Server.GetTeam(mi.GetID()).CommonPool
+	withdrawal := mi.Server.GetTeam(mi.GetID()).GetExpectedWithdrawal(mi.GetID(), mi.GetTrueScore(), commonPool)
 	if commonPool < withdrawal {
 		withdrawal = commonPool
 	}
@@ -245,12 +240,9 @@ func (mi *ExtendedAgent) GetName() int {
  * are currently being punished as a result of an audit.
  */
 func (mi *ExtendedAgent) GetLeaveOpinion(agentID uuid.UUID) bool {
-	// Recursion block
-	if mi.GetID() == agentID {
-		return false
-	}
-	// Get the underlying agent's opinion
-	return mi.Server.AccessAgentByID(agentID).GetLeaveOpinion(mi.GetID())
+	// Only the agent itself can say whether it wants to leave, other agents'
+	// opinions are private to them
+	return false
 }
 
 /*
@@ -260,7 +252,7 @@ And previous roll in case relevant
 */
 func (mi *ExtendedAgent) StickOrAgainFor(agentId uuid.UUID, accumulatedScore int, prevRoll int) int {
 	// random chance, to simulate what is already implemented
-	return rand.Intn(2)
+	return mi.rng.Intn(2)
 }
 
 // dev function
@@ -273,7 +265,7 @@ func (mi *ExtendedAgent) LogSelfInfo() {
 // 1: Prefer audit
 // -1: Prefer no audit
 func (mi *ExtendedAgent) GetContributionAuditVote() common.Vote {
-	return common.CreateVote(0, mi.GetID(), uuid.Nil)
+	return common.CreateVote(0, mi.GetID(), uuid.Nil).WithAuditQuality(0, mi.auditDetectionWanted())
 }
 
 // Agent returns their preference for an audit on withdrawal
@@ -281,12 +273,47 @@ func (mi *ExtendedAgent) GetContributionAuditVote() common.Vote {
 // 1: Prefer audit
 // -1: Prefer no audit
 func (mi *ExtendedAgent) GetWithdrawalAuditVote() common.Vote {
-	return common.CreateVote(0, mi.GetID(), uuid.Nil)
+	return common.CreateVote(0, mi.GetID(), uuid.Nil).WithAuditQuality(0, mi.auditDetectionWanted())
+}
+
+// Agents ask for audits as thorough as they are suspicious of their team:
+// detection falls from 1 to 0.5, and audits get cheaper, as they come to trust
+// their teammates more than they would a stranger. They leave the lookback to
+// the AoA.
+func (mi *ExtendedAgent) auditDetectionWanted() float64 {
+	if !mi.HasTeam() {
+		return 1
+	}
+	trust, teammates := 0.0, 0
+	for _, agentID := range mi.Server.GetAgentsInTeam(mi.TeamID) {
+		if agentID == mi.GetID() {
+			continue
+		}
+		trust += mi.GetTrust(agentID)
+		teammates++
+	}
+	if teammates == 0 {
+		return 1
+	}
+	return math.Max(0.5, math.Min(1.5-trust/float64(teammates), 1))
 }
 
-func (mi *ExtendedAgent) SetAgentContributionAuditResult(agentID uuid.UUID, result bool) {}
+func (mi *ExtendedAgent) SetAgentContributionAuditResult(agentID uuid.UUID, result bool) {
+	mi.formAuditOpinion(agentID, result)
+}
 
-func (mi *ExtendedAgent) SetAgentWithdrawalAuditResult(agentID uuid.UUID, result bool) {}
+func (mi *ExtendedAgent) SetAgentWithdrawalAuditResult(agentID uuid.UUID, result bool) {
+	mi.formAuditOpinion(agentID, result)
+}
+
+// Trust an agent no further than its last audit
+func (mi *ExtendedAgent) formAuditOpinion(agentID uuid.UUID, guilty bool) {
+	trust := 1.0
+	if guilty {
+		trust = 0
+	}
+	mi.formOpinion(common.NewOpinion(agentID, trust, 1, common.EvidenceAudit))
+}
 
 // ----Withdrawal------- Messaging functions -----------------------
 
@@ -340,17 +367,41 @@ func (mi *ExtendedAgent) HandleWithdrawalMessage(msg *common.WithdrawalMessage)
 }
 
 func (mi *ExtendedAgent) HandleAgentOpinionRequestMessage(msg *common.AgentOpinionRequestMessage) {
-	// Team's agent should implement logic to respond to opinion request as desired
-	log.Printf("Agent %s received opinion request from %s\n", mi.GetID(), msg.AgentID)
-	opinion := 70
-	opinionResponseMsg := mi.CreateAgentOpinionResponseMessage(msg.AgentID, opinion)
-	log.Printf("Sending opinion response to %s\n", msg.AgentID)
-	mi.SendMessage(opinionResponseMsg, msg.AgentID) // Sent asynchronously, because this is "extra information"
+	mi.answerOpinionRequest(msg, mi.GetOpinions())
+}
+
+// Tell the agent asking the opinion of its subject out of the given ones, if
+// there is one. Agents that keep their opinions elsewhere pass their own.
+func (mi *ExtendedAgent) answerOpinionRequest(msg *common.AgentOpinionRequestMessage, opinions []common.Opinion) {
+	log.Printf("Agent %s received opinion request about %s from %s\n", mi.GetID(), msg.AgentID, msg.GetSender())
+	for _, opinion := range opinions {
+		if opinion.Subject == msg.AgentID {
+			mi.SendMessage(mi.CreateAgentOpinionResponseMessage(opinion), msg.GetSender()) // Sent asynchronously, because this is "extra information"
+			return
+		}
+	}
 }
 
 func (mi *ExtendedAgent) HandleAgentOpinionResponseMessage(msg *common.AgentOpinionResponseMessage) {
-	// Team's agent should implement logic to store or process opinion response as desired
-	log.Printf("Agent %s received opinion response from %s: opinion=%d\n", mi.GetID(), msg.GetSender(), msg.AgentOpinion)
+	log.Printf("Agent %s received opinion response from %s: %+v\n", mi.GetID(), msg.GetSender(), msg.Opinion)
+	if msg.GetSender() == mi.GetID() {
+		return
+	}
+	mi.trustNetwork().Observe(msg.GetSender(), msg.Opinion)
+}
+
+/*
+* Messages sent asynchronously arrive in whatever order the goroutines get
+* scheduled, and may be dropped if the bandwidth limit is hit. When the game is
+* seeded they are delivered inline instead, so that every run handles them in
+* the same order.
+ */
+func (mi *ExtendedAgent) SendMessage(msg message.IMessage[common.IExtendedAgent], receiver uuid.UUID) {
+	if common.IsSeeded() {
+		mi.SendSynchronousMessage(msg, receiver)
+		return
+	}
+	mi.BaseAgent.SendMessage(msg, receiver)
 }
 
 func (mi *ExtendedAgent) BroadcastSyncMessageToTeam(msg message.IMessage[common.IExtendedAgent]) {
@@ -406,6 +457,15 @@ func (mi *ExtendedAgent) CreateWithdrawalMessage(statedAmount int) *common.Withd
 	}
 }
 
+func (mi *ExtendedAgent) CreateWhistleblowerMessage(accused uuid.UUID, phase common.AuditPhase, evidence common.WhistleblowerEvidence) *common.WhistleblowerMessage {
+	return &common.WhistleblowerMessage{
+		BaseMessage: mi.CreateBaseMessage(),
+		Accused:     accused,
+		Phase:       phase,
+		Evidence:    evidence,
+	}
+}
+
 func (mi *ExtendedAgent) Team4_CreateProposedWithdrawalMessage(statedAmount int) *common.Team4_ProposedWithdrawalMessage {
 	return &common.Team4_ProposedWithdrawalMessage{
 		BaseMessage:  mi.CreateBaseMessage(),
@@ -427,25 +487,15 @@ func (mi *ExtendedAgent) CreateAgentOpinionRequestMessage(agentID uuid.UUID) *co
 	}
 }
 
-func (mi *ExtendedAgent) CreateAgentOpinionResponseMessage(agentID uuid.UUID, opinion int) *common.AgentOpinionResponseMessage {
+func (mi *ExtendedAgent) CreateAgentOpinionResponseMessage(opinion common.Opinion) *common.AgentOpinionResponseMessage {
 	return &common.AgentOpinionResponseMessage{
-		BaseMessage:  mi.CreateBaseMessage(),
-		AgentID:      agentID,
-		AgentOpinion: opinion,
+		BaseMessage: mi.CreateBaseMessage(),
+		Opinion:     opinion,
 	}
 }
 
 // ----------------------- Debug functions -----------------------
 
-func Roll3Dice() int {
-	// row 3d6
-	total := 0
-	for i := 0; i < 3; i++ {
-		total += rand.Intn(6) + 1
-	}
-	return total
-}
-
 // func Debug_StickOrAgainJudgement() bool {
 // 	// 50% chance to stick
 // 	return rand.Intn(2) == 0
@@ -476,7 +526,7 @@ func (mi *ExtendedAgent) DecideTeamForming(agentInfoList []common.ExposedAgentIn
 	}
 
 	// random choice from the invitation list
-	rand.Shuffle(len(invitationList), func(i, j int) { invitationList[i], invitationList[j] = invitationList[j], invitationList[i] })
+	mi.rng.Shuffle(len(invitationList), func(i, j int) { invitationList[i], invitationList[j] = invitationList[j], invitationList[i] })
 	if len(invitationList) == 0 {
 		return []uuid.UUID{}
 	}
@@ -575,25 +625,66 @@ func (mi *ExtendedAgent) RecordAgentStatus(instance common.IExtendedAgent) gameR
 	return record
 }
 
-// ----------------------- Team 2 AoA Functions -----------------------
+// ----------------------- Leadership Functions -----------------------
 
-func (mi *ExtendedAgent) Team2_GetLeaderVote() common.Vote {
-	log.Printf("[WARNING] Base Leader Vote Function Called")
-	// Shouldn't happen, but if it does, then vote for yourself
-	if mi.TeamID == uuid.Nil || mi.TeamID == (uuid.UUID{}) {
-		return common.CreateVote(1, mi.GetID(), mi.GetID())
-	}
+// Rank the candidates for leader in a random order
+func (mi *ExtendedAgent) GetLeaderBallot(candidates []uuid.UUID) []uuid.UUID {
+	ballot := slices.Clone(candidates)
+	mi.rng.Shuffle(len(ballot), func(i, j int) {
+		ballot[i], ballot[j] = ballot[j], ballot[i]
+	})
+	return ballot
+}
 
-	agentsInTeam := mi.Server.GetAgentsInTeam(mi.TeamID)
+// Leave the leader be
+func (mi *ExtendedAgent) GetRecallVote(leader uuid.UUID) bool {
+	return false
+}
+
+// Accept the verdict
+func (mi *ExtendedAgent) DecideAppeal(phase common.AuditPhase) bool {
+	return false
+}
+
+// Let the verdict stand
+func (mi *ExtendedAgent) GetAppealVote(accused uuid.UUID, phase common.AuditPhase) bool {
+	return false
+}
+
+// ----------------------- Trust Functions -----------------------
+
+// The agent's trust network, creating it on first use
+func (mi *ExtendedAgent) trustNetwork() *common.TrustNetwork {
+	if mi.trust == nil {
+		mi.trust = common.NewTrustNetwork()
+	}
+	return mi.trust
+}
 
-	if len(agentsInTeam) == 0 {
-		return common.CreateVote(1, mi.GetID(), mi.GetID())
+// Hold an opinion of another agent, in place of any held of it before
+func (mi *ExtendedAgent) formOpinion(opinion common.Opinion) {
+	if opinion.Subject == mi.GetID() {
+		return
 	}
+	mi.trustNetwork().Observe(mi.GetID(), opinion)
+}
+
+// The opinions the agent has formed itself
+func (mi *ExtendedAgent) GetOpinions() []common.Opinion {
+	return mi.trustNetwork().OpinionsOf(mi.GetID())
+}
 
-	// Randomly select a leader
-	leader := agentsInTeam[rand.Intn(len(agentsInTeam))]
+// How far the agent trusts the subject, combining its own opinions with those
+// it has been told with the server's trust model
+func (mi *ExtendedAgent) GetTrust(subject uuid.UUID) float64 {
+	return mi.trustIn(subject, mi.GetOpinions())
+}
 
-	return common.CreateVote(1, mi.GetID(), leader)
+// How far the agent trusts the subject, given its own opinions. Agents that
+// keep their opinions elsewhere pass their own.
+func (mi *ExtendedAgent) trustIn(subject uuid.UUID, opinions []common.Opinion) float64 {
+	edges := mi.trustNetwork().EdgesWith(mi.GetID(), opinions)
+	return mi.Server.GetTrustModel().Trust(mi.GetID(), subject, edges)
 }
 
 // ----------------------- Team 3 AoA Functions -----------------------
@@ -615,7 +706,7 @@ func (mi *ExtendedAgent) Team3_GetStrategyVote() []common.Strategy {
 	// Random second choice
 	var secondChoice common.Strategy
 	for {
-		randStrategy := common.Strategy(rand.Intn(3))
+		randStrategy := common.Strategy(mi.rng.Intn(3))
 		if randStrategy != firstChoice {
 			secondChoice = randStrategy
 			break
@@ -634,3 +725,67 @@ func (mi *ExtendedAgent) Team3_SetStrategy(strategy common.Strategy) {
 func (mi *ExtendedAgent) Team3_GetCurrentStrategy() common.Strategy {
 	return mi.currentStrategy
 }
+
+// ----------------------- Checkpointing -----------------------
+
+// The agent memory saved in checkpoints. The server restores the score and team
+// itself, so they are not included here.
+type extendedAgentState struct {
+	LastScore                  int
+	LastTeamID                 uuid.UUID
+	AoARanking                 []int
+	Team1RankBoundaryProposals [][5]int
+	Team1Ballots               [][3]int
+	CurrentStrategy            common.Strategy
+	Trust                      []common.TrustEdge `json:",omitempty"`
+	Rand                       common.RandState
+}
+
+func (mi *ExtendedAgent) baseState() (extendedAgentState, error) {
+	randState, err := common.GetRandState(mi.rng)
+	if err != nil {
+		return extendedAgentState{}, err
+	}
+	return extendedAgentState{
+		LastScore:                  mi.LastScore,
+		LastTeamID:                 mi.LastTeamID,
+		AoARanking:                 mi.AoARanking,
+		Team1RankBoundaryProposals: mi.team1RankBoundaryProposals,
+		Team1Ballots:               mi.team1Ballots,
+		CurrentStrategy:            mi.currentStrategy,
+		Trust:                      mi.trustNetwork().Edges(),
+		Rand:                       randState,
+	}, nil
+}
+
+func (mi *ExtendedAgent) restoreBaseState(state extendedAgentState) error {
+	mi.LastScore = state.LastScore
+	mi.LastTeamID = state.LastTeamID
+	mi.AoARanking = state.AoARanking
+	mi.team1RankBoundaryProposals = state.Team1RankBoundaryProposals
+	mi.team1Ballots = state.Team1Ballots
+	mi.currentStrategy = state.CurrentStrategy
+	mi.trust = common.NewTrustNetwork()
+	for _, edge := range state.Trust {
+		mi.trust.Observe(edge.Truster, edge.Opinion)
+	}
+	return common.SetRandState(mi.rng, state.Rand)
+}
+
+// Agents that embed ExtendedAgent and keep memory of their own should override
+// these, embedding extendedAgentState in their own state
+func (mi *ExtendedAgent) SnapshotState() (json.RawMessage, error) {
+	state, err := mi.baseState()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(state)
+}
+
+func (mi *ExtendedAgent) RestoreState(data json.RawMessage) error {
+	var state extendedAgentState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	return mi.restoreBaseState(state)
+}
@@ -1,9 +1,9 @@
 package agents
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
-	"math/rand"
 
 	common "github.com/ADimoska/SOMASExtended/common"
 
@@ -75,6 +75,29 @@ func (mi *MI_256_v1) print_alignment() {
 	fmt.Println(mi.GetID(), " has been created. Chaoticness:", mi.chaoticness, "Evilness:", mi.evilness)
 }
 
+func init() {
+	RegisterAgentFactory(AgentFactory{
+		Name:        "team4",
+		Description: "Team 4 agent (MI_256_v1) with a chaotic/evil alignment, randomised unless given",
+		Params: []ParamSpec{
+			{Name: "chaoticness", Kind: ParamInt, Description: "1 = lawful, 2 = neutral, 3 = chaotic", Min: 1, Max: 3},
+			{Name: "evilness", Kind: ParamInt, Description: "1 = good, 2 = neutral, 3 = evil", Min: 1, Max: 3},
+		},
+		Create: func(funcs agent.IExposedServerFunctions[common.IExtendedAgent], config AgentConfig, params AgentParams) common.IExtendedAgent {
+			mi := Team4_CreateAgent(funcs, config)
+			chaoticness, evilness := mi.GetCharacter()
+			if params.Has("chaoticness") {
+				chaoticness = params.Int("chaoticness")
+			}
+			if params.Has("evilness") {
+				evilness = params.Int("evilness")
+			}
+			mi.SetCharacter(chaoticness, evilness)
+			return mi
+		},
+	})
+}
+
 // constructor for MI_256_v1
 func Team4_CreateAgent(funcs agent.IExposedServerFunctions[common.IExtendedAgent], agentConfig AgentConfig) *MI_256_v1 {
 	mi_256 := &MI_256_v1{
@@ -157,17 +180,17 @@ func (mi *MI_256_v1) CalcAOAContibution() int {
 
 	// mi.AoAExpectedContribution = int(0.5 * float64(mi.Score))
 
-	mi.AoAExpectedContribution = mi.Server.GetTeam(mi.GetID()).TeamAoA.GetExpectedContribution(mi.GetID(), mi.GetTrueScore())
+	mi.AoAExpectedContribution = mi.Server.GetTeam(mi.GetID()).GetExpectedContribution(mi.GetID(), mi.GetTrueScore())
 	fmt.Println(mi.GetID(), " the expected contribution is:", mi.AoAExpectedContribution)
 	mi.isAoAContributionFixed = true
 	return mi.AoAExpectedContribution
 
 }
 func (mi *MI_256_v1) CalcAOAWithdrawal() int {
-	// common_pool := mi.Server.GetTeam(mi.GetID()).GetCommonPool()
+	// common_pool := mi.Server.GetTeam(mi.GetID()).CommonPool
 
 	// mi.AoAExpectedWithdrawal = int(common_pool / (len(mi.Server.GetTeam(mi.GetID()).Agents) + 1))
-	mi.AoAExpectedWithdrawal = mi.Server.GetTeam(mi.GetID()).TeamAoA.GetExpectedWithdrawal(mi.GetID(), mi.GetTrueScore(), mi.Server.GetTeamCommonPool(mi.GetTeamID()))
+	mi.AoAExpectedWithdrawal = mi.Server.GetTeam(mi.GetID()).GetExpectedWithdrawal(mi.GetID(), mi.GetTrueScore(), mi.Server.GetTeamCommonPool(mi.GetTeamID()))
 
 	fmt.Println(mi.GetID(), " the expected withdrawal is:", mi.AoAExpectedWithdrawal)
 	mi.isAoAWithdrawalFixed = true
@@ -190,7 +213,7 @@ func (mi *MI_256_v1) SetAoARanking(Preferences []int) {
 // ----------------------- Strategies -----------------------
 
 func (mi *MI_256_v1) AnyoneCheatedAfterContribute() {
-	common_pool := mi.Server.GetTeam(mi.GetID()).GetCommonPool()
+	common_pool := mi.Server.GetTeam(mi.GetID()).CommonPool
 	change := common_pool - mi.last_common_pool
 	mi.last_common_pool = common_pool
 	sum := 0
@@ -209,7 +232,7 @@ func (mi *MI_256_v1) AnyoneCheatedAfterContribute() {
 
 }
 func (mi *MI_256_v1) AnyoneCheatedAfterWithdrawal() {
-	common_pool := mi.Server.GetTeam(mi.GetID()).GetCommonPool()
+	common_pool := mi.Server.GetTeam(mi.GetID()).CommonPool
 	change := common_pool - mi.last_common_pool
 	mi.last_common_pool = common_pool
 	sum := 0
@@ -242,7 +265,7 @@ func (mi *MI_256_v1) DecideTeamForming(agentInfoList []common.ExposedAgentInfo)
 
 	// TODO: implement team forming logic
 	// random choice from the invitation list
-	rand.Shuffle(len(invitationList), func(i, j int) { invitationList[i], invitationList[j] = invitationList[j], invitationList[i] })
+	mi.rng.Shuffle(len(invitationList), func(i, j int) { invitationList[i], invitationList[j] = invitationList[j], invitationList[i] })
 	chosenAgent := invitationList[0]
 
 	// Return a slice containing the chosen agent
@@ -341,39 +364,39 @@ func (mi *MI_256_v1) DecideContribution() int {
 	if mi.evilness == 2 { // if agent has neutral evilness, use neutral mean
 		if mi.chaoticness == 1 { // if lawful neutral
 
-			contribute_percentage = (rand.NormFloat64() * float64(lawful_standard_deviation)) + float64(neutral_mean)
+			contribute_percentage = (mi.rng.NormFloat64() * float64(lawful_standard_deviation)) + float64(neutral_mean)
 
 		} else if mi.chaoticness == 2 { // abs neutral
-			contribute_percentage = (rand.NormFloat64() * float64(neutral_standard_deviation)) + float64(neutral_mean)
+			contribute_percentage = (mi.rng.NormFloat64() * float64(neutral_standard_deviation)) + float64(neutral_mean)
 
 		} else if mi.chaoticness == 3 { //chaotic neutral
-			contribute_percentage = (rand.NormFloat64() * float64(chaotic_standard_deviation)) + float64(neutral_mean)
+			contribute_percentage = (mi.rng.NormFloat64() * float64(chaotic_standard_deviation)) + float64(neutral_mean)
 
 		}
 
 	} else if mi.evilness == 1 { // if agent is good
 		if mi.chaoticness == 1 { // if lawful good
 
-			contribute_percentage = (rand.NormFloat64() * float64(lawful_good_standard_deviation)) + float64(lawful_good_mean)
+			contribute_percentage = (mi.rng.NormFloat64() * float64(lawful_good_standard_deviation)) + float64(lawful_good_mean)
 
 		} else if mi.chaoticness == 2 { // neutral good
-			contribute_percentage = (rand.NormFloat64() * float64(neutral_good_standard_deviation)) + float64(neutral_good_mean)
+			contribute_percentage = (mi.rng.NormFloat64() * float64(neutral_good_standard_deviation)) + float64(neutral_good_mean)
 
 		} else if mi.chaoticness == 3 { //chaotic good
-			contribute_percentage = (rand.NormFloat64() * float64(chaotic_good_standard_deviation)) + float64(neutral_good_mean)
+			contribute_percentage = (mi.rng.NormFloat64() * float64(chaotic_good_standard_deviation)) + float64(neutral_good_mean)
 
 		}
 
 	} else if mi.evilness == 3 { // if agent is evil
 		if mi.chaoticness == 1 { // if lawful evil
 
-			contribute_percentage = (rand.NormFloat64() * float64(lawful_evil_standard_deviation)) + float64(lawful_evil_mean)
+			contribute_percentage = (mi.rng.NormFloat64() * float64(lawful_evil_standard_deviation)) + float64(lawful_evil_mean)
 
 		} else if mi.chaoticness == 2 { // neutral evil
-			contribute_percentage = (rand.NormFloat64() * float64(neutral_evil_standard_deviation)) + float64(neutral_evil_mean)
+			contribute_percentage = (mi.rng.NormFloat64() * float64(neutral_evil_standard_deviation)) + float64(neutral_evil_mean)
 
 		} else if mi.chaoticness == 3 { //chaotic evil
-			contribute_percentage = (rand.NormFloat64() * float64(chaotic_evil_standard_deviation)) + float64(neutral_evil_mean)
+			contribute_percentage = (mi.rng.NormFloat64() * float64(chaotic_evil_standard_deviation)) + float64(neutral_evil_mean)
 
 		}
 	}
@@ -390,7 +413,7 @@ func (mi *MI_256_v1) DecideContribution() int {
 		if mi.intendedContribution > 0 {
 			mi.declaredcontribution = mi.intendedContribution
 		} else {
-			mi.declaredcontribution = rand.Intn(11) + 2
+			mi.declaredcontribution = mi.rng.Intn(11) + 2
 		}
 	}
 	if mi.intendedContribution < mi.AoAExpectedContribution {
@@ -457,39 +480,39 @@ func (mi *MI_256_v1) DecideWithdrawal(upperbound int) int {
 	if mi.evilness == 2 { // if agent has neutral evilness, use neutral mean
 		if mi.chaoticness == 1 { // if lawful neutral
 
-			Withdrawal_percentage = (rand.NormFloat64() * float64(lawful_standard_deviation)) + float64(neutral_mean)
+			Withdrawal_percentage = (mi.rng.NormFloat64() * float64(lawful_standard_deviation)) + float64(neutral_mean)
 
 		} else if mi.chaoticness == 2 { // abs neutral
-			Withdrawal_percentage = (rand.NormFloat64() * float64(neutral_standard_deviation)) + float64(neutral_mean)
+			Withdrawal_percentage = (mi.rng.NormFloat64() * float64(neutral_standard_deviation)) + float64(neutral_mean)
 
 		} else if mi.chaoticness == 3 { //chaotic neutral
-			Withdrawal_percentage = (rand.NormFloat64() * float64(chaotic_standard_deviation)) + float64(neutral_mean)
+			Withdrawal_percentage = (mi.rng.NormFloat64() * float64(chaotic_standard_deviation)) + float64(neutral_mean)
 
 		}
 
 	} else if mi.evilness == 1 { // if agent is good
 		if mi.chaoticness == 1 { // if lawful good
 
-			Withdrawal_percentage = (rand.NormFloat64() * float64(lawful_good_standard_deviation)) + float64(lawful_good_mean)
+			Withdrawal_percentage = (mi.rng.NormFloat64() * float64(lawful_good_standard_deviation)) + float64(lawful_good_mean)
 
 		} else if mi.chaoticness == 2 { // neutral good
-			Withdrawal_percentage = (rand.NormFloat64() * float64(neutral_good_standard_deviation)) + float64(neutral_good_mean)
+			Withdrawal_percentage = (mi.rng.NormFloat64() * float64(neutral_good_standard_deviation)) + float64(neutral_good_mean)
 
 		} else if mi.chaoticness == 3 { //chaotic good
-			Withdrawal_percentage = (rand.NormFloat64() * float64(chaotic_good_standard_deviation)) + float64(neutral_good_mean)
+			Withdrawal_percentage = (mi.rng.NormFloat64() * float64(chaotic_good_standard_deviation)) + float64(neutral_good_mean)
 
 		}
 
 	} else if mi.evilness == 3 { // if agent is evil
 		if mi.chaoticness == 1 { // if lawful evil
 
-			Withdrawal_percentage = (rand.NormFloat64() * float64(lawful_evil_standard_deviation)) + float64(lawful_evil_mean)
+			Withdrawal_percentage = (mi.rng.NormFloat64() * float64(lawful_evil_standard_deviation)) + float64(lawful_evil_mean)
 
 		} else if mi.chaoticness == 2 { // neutral evil
-			Withdrawal_percentage = (rand.NormFloat64() * float64(neutral_evil_standard_deviation)) + float64(neutral_evil_mean)
+			Withdrawal_percentage = (mi.rng.NormFloat64() * float64(neutral_evil_standard_deviation)) + float64(neutral_evil_mean)
 
 		} else if mi.chaoticness == 3 { //chaotic evil
-			Withdrawal_percentage = (rand.NormFloat64() * float64(chaotic_evil_standard_deviation)) + float64(neutral_evil_mean)
+			Withdrawal_percentage = (mi.rng.NormFloat64() * float64(chaotic_evil_standard_deviation)) + float64(neutral_evil_mean)
 
 		}
 	}
@@ -518,15 +541,15 @@ func (mi *MI_256_v1) GetActualWithdrawal(instance common.IExtendedAgent) int {
 	if !mi.HasTeam() {
 		return 0
 	}
-	commonPool := mi.Server.GetTeam(mi.GetID()).GetCommonPool()
+	commonPool := mi.Server.GetTeam(mi.GetID()).CommonPool
 	mi.AoAExpectedWithdrawal = mi.CalcAOAWithdrawal()
 	if mi.Score < mi.lastThreshold+5 {
 		mi.IntendedWithdrawal = mi.DecideWithdrawal((commonPool))
 	} else {
 		mi.IntendedWithdrawal = mi.DecideWithdrawal((mi.AoAExpectedWithdrawal * 2))
 	}
-	// commonPool := mi.Server.GetTeam(mi.GetID()).GetCommonPool()
-	// withdrawal := mi.Server.GetTeam(mi.GetID()).TeamAoA.GetExpectedWithdrawal(mi.GetID(), mi.GetTrueScore(), commonPool)
+	// commonPool := mi.Server.GetTeam(mi.GetID()).CommonPool
+	// withdrawal := mi.Server.GetTeam(mi.GetID()).GetExpectedWithdrawal(mi.GetID(), mi.GetTrueScore(), commonPool)
 	// if commonPool < withdrawal {
 	// 	withdrawal = commonPool
 	// }
@@ -676,39 +699,39 @@ func (mi *MI_256_v1) BuildVotemap(IsHarmfulIntent bool) map[uuid.UUID]float64 {
 		if mi.evilness == 2 { // if agent has neutral evilness, use neutral mean
 			if mi.chaoticness == 1 { // if lawful neutral
 
-				audit_percentage = (rand.NormFloat64() * float64(lawful_neutral_standard_deviation)) + float64(lawful_neutral_mean)
+				audit_percentage = (mi.rng.NormFloat64() * float64(lawful_neutral_standard_deviation)) + float64(lawful_neutral_mean)
 
 			} else if mi.chaoticness == 2 { // abs neutral
-				audit_percentage = (rand.NormFloat64() * float64(abs_neutral_standard_deviation)) + float64(abs_neutral_mean)
+				audit_percentage = (mi.rng.NormFloat64() * float64(abs_neutral_standard_deviation)) + float64(abs_neutral_mean)
 
 			} else if mi.chaoticness == 3 { //chaotic neutral
-				audit_percentage = (rand.NormFloat64() * float64(chaotic_neutral_standard_deviation)) + float64(chaotic_neutral_mean)
+				audit_percentage = (mi.rng.NormFloat64() * float64(chaotic_neutral_standard_deviation)) + float64(chaotic_neutral_mean)
 
 			}
 
 		} else if mi.evilness == 1 { // if agent is good
 			if mi.chaoticness == 1 { // if lawful good
 
-				audit_percentage = (rand.NormFloat64() * float64(lawful_good_standard_deviation)) + float64(lawful_good_mean)
+				audit_percentage = (mi.rng.NormFloat64() * float64(lawful_good_standard_deviation)) + float64(lawful_good_mean)
 
 			} else if mi.chaoticness == 2 { // neutral good
-				audit_percentage = (rand.NormFloat64() * float64(neutral_good_standard_deviation)) + float64(neutral_good_mean)
+				audit_percentage = (mi.rng.NormFloat64() * float64(neutral_good_standard_deviation)) + float64(neutral_good_mean)
 
 			} else if mi.chaoticness == 3 { //chaotic good
-				audit_percentage = (rand.NormFloat64() * float64(chaotic_good_standard_deviation)) + float64(chaotic_good_mean)
+				audit_percentage = (mi.rng.NormFloat64() * float64(chaotic_good_standard_deviation)) + float64(chaotic_good_mean)
 
 			}
 
 		} else if mi.evilness == 3 { // if agent is evil
 			if mi.chaoticness == 1 { // if lawful evil
 
-				audit_percentage = (rand.NormFloat64() * float64(lawful_evil_standard_deviation)) + float64(lawful_evil_mean)
+				audit_percentage = (mi.rng.NormFloat64() * float64(lawful_evil_standard_deviation)) + float64(lawful_evil_mean)
 
 			} else if mi.chaoticness == 2 { // neutral evil
-				audit_percentage = (rand.NormFloat64() * float64(neutral_evil_standard_deviation)) + float64(neutral_evil_mean)
+				audit_percentage = (mi.rng.NormFloat64() * float64(neutral_evil_standard_deviation)) + float64(neutral_evil_mean)
 
 			} else if mi.chaoticness == 3 { //chaotic evil
-				audit_percentage = (rand.NormFloat64() * float64(chaotic_evil_standard_deviation)) + float64(chaotic_evil_mean)
+				audit_percentage = (mi.rng.NormFloat64() * float64(chaotic_evil_standard_deviation)) + float64(chaotic_evil_mean)
 
 			}
 		}
@@ -744,14 +767,15 @@ func (mi *MI_256_v1) GetWithdrawalAuditVote() common.Vote {
 	votemap := mi.BuildVotemap(true)
 	var max_agent uuid.UUID
 	max_audit_perentage := 0.0
-	for agent, audit_percentage := range votemap {
+	for _, agent := range common.SortedIDs(votemap) {
+		audit_percentage := votemap[agent]
 		if audit_percentage > float64(max_audit_perentage) {
 			max_agent = agent
 			max_audit_perentage = audit_percentage
 		}
 	}
 	var vote common.Vote
-	random := rand.Float64()
+	random := mi.rng.Float64()
 	if random <= max_audit_perentage {
 		vote = common.CreateVote(1, mi.GetID(), max_agent)
 
@@ -770,8 +794,9 @@ func (mi *MI_256_v1) Team4_GetRankUpVote() map[uuid.UUID]int {
 	// log.Printf("Called overriden GetRankUpVote()")
 	votePercentmap := mi.BuildVotemap(false)
 	votemap := make(map[uuid.UUID]int)
-	for agent, percentage := range votePercentmap {
-		random := rand.Float64()
+	for _, agent := range common.SortedIDs(votePercentmap) {
+		percentage := votePercentmap[agent]
+		random := mi.rng.Float64()
 		if random <= percentage {
 			votemap[agent] = 1
 		} else if random >= percentage+(1-percentage)/2 {
@@ -786,7 +811,7 @@ func (mi *MI_256_v1) Team4_GetRankUpVote() map[uuid.UUID]int {
 }
 
 func (mi *MI_256_v1) Team4_GetConfession() bool {
-	chance := rand.Intn(2)
+	chance := mi.rng.Intn(2)
 	if chance != 0 {
 		return true
 	}
@@ -797,8 +822,9 @@ func (mi *MI_256_v1) Team4_GetProposedWithdrawalVote() map[uuid.UUID]int {
 	// log.Printf("Called overriden GetProposedWithdrawalVote()")
 	votePercentmap := mi.BuildVotemap(false)
 	votemap := make(map[uuid.UUID]int)
-	for agent, percentage := range votePercentmap {
-		random := rand.Float64()
+	for _, agent := range common.SortedIDs(votePercentmap) {
+		percentage := votePercentmap[agent]
+		random := mi.rng.Float64()
 		if random <= percentage {
 			votemap[agent] = 1
 		} else if random >= percentage+(1-percentage)/2 {
@@ -817,7 +843,7 @@ func (mi *MI_256_v1) Team4_GetProposedWithdrawalVote() map[uuid.UUID]int {
 
 //get common pool resource
 
-// mi.Server.GetTeam(mi.GetID()).GetCommonPool()
+// mi.Server.GetTeam(mi.GetID()).CommonPool
 
 // //get ids of people in my team
 // mi.Server.GetTeam(mi.GetID()).Agents
@@ -828,17 +854,51 @@ func (mi *MI_256_v1) Team4_GetProposedWithdrawalVote() map[uuid.UUID]int {
 
 // ---------------------------------------------------------------
 func (mi *MI_256_v1) RandomizeCharacter() {
-	mi.chaoticness = rand.Intn(3) + 1
+	mi.chaoticness = mi.rng.Intn(3) + 1
 
-	mi.evilness = rand.Intn(3) + 1
+	mi.evilness = mi.rng.Intn(3) + 1
 	mi.haveIlied = false
 	mi.Initialize_opninions()
 	mi.AoARanking = []int{4, 1, 2, 3, 4, 5}
 	mi.SetAoARanking(mi.AoARanking)
 }
 
+// Fix the agent's alignment instead of using the randomised one, e.g. to set up
+// an experiment. Both values range from 1 to 3.
+func (mi *MI_256_v1) SetCharacter(chaoticness, evilness int) {
+	mi.chaoticness = chaoticness
+	mi.evilness = evilness
+}
+
+func (mi *MI_256_v1) GetCharacter() (chaoticness, evilness int) {
+	return mi.chaoticness, mi.evilness
+}
+
 // ----------- functions that update character opinions ---------------------------------
 
+// Affinity as opinions, squashed so that no affinity is complete trust or
+// distrust. It comes from what agents stated and is only half sure, as it
+// leans on the agent's character as much as on them.
+func (mi *MI_256_v1) GetOpinions() []common.Opinion {
+	opinions := []common.Opinion{}
+	for _, agentID := range common.SortedIDs(mi.affinity) {
+		if agentID == mi.GetID() {
+			continue
+		}
+		trust := 1 / (1 + math.Exp(-float64(mi.affinity[agentID])/10))
+		opinions = append(opinions, common.NewOpinion(agentID, trust, 0.5, common.EvidenceStatement))
+	}
+	return opinions
+}
+
+func (mi *MI_256_v1) GetTrust(subject uuid.UUID) float64 {
+	return mi.trustIn(subject, mi.GetOpinions())
+}
+
+func (mi *MI_256_v1) HandleAgentOpinionRequestMessage(msg *common.AgentOpinionRequestMessage) {
+	mi.answerOpinionRequest(msg, mi.GetOpinions())
+}
+
 func (mi *MI_256_v1) Initialize_opninions() {
 	mi.affinity = make(map[uuid.UUID]int)
 	for _, agent := range mi.Server.UpdateAndGetAgentExposedInfo() {
@@ -882,7 +942,7 @@ Mood needs to be updated at the following timepoints:
 func (mi *MI_256_v1) UpdateMoodTurnStart() {
 	//generate random float from -1 to 1 and multiply by chaoticness
 
-	randomUrge := (rand.Float64()*2 - 1) * float64(mi.chaoticness)
+	randomUrge := (mi.rng.Float64()*2 - 1) * float64(mi.chaoticness)
 	randomUrge = math.Round(randomUrge)
 	mi.mood += int(randomUrge)
 }
@@ -1018,7 +1078,7 @@ func (mi *MI_256_v1) UpdateAffinityAfterContribute() {
 func (mi *MI_256_v1) UpdateAffinityAfterWithdraw() {
 	//similar to contribution, there withdrawing same amount is fair, and satisfaction comes into play
 	// if there is no set distribution, we would assume the avarage amount in the pot would be a fair number
-	common_pool := mi.Server.GetTeam(mi.GetID()).GetCommonPool()
+	common_pool := mi.Server.GetTeam(mi.GetID()).CommonPool
 	agentExpected := int(common_pool / (len(mi.Server.GetTeam(mi.GetID()).Agents) + 1))
 
 	for _, agent := range mi.Server.GetTeam(mi.GetID()).Agents {
@@ -1150,8 +1210,8 @@ func (mi *MI_256_v1) UpdateAffinityAfterAudit() {
 }
 
 // ----------------------- Helper Functions -----------------------
-func GetAgentTeamAoA(mi *MI_256_v1) common.IArticlesOfAssociation {
-	return mi.Server.GetTeam(mi.GetID()).TeamAoA
+func GetAgentTeamView(mi *MI_256_v1) common.TeamView {
+	return mi.Server.GetTeam(mi.GetID())
 }
 
 func (mi *MI_256_v1) Team4_ProposeWithdrawal() int {
@@ -1159,15 +1219,150 @@ func (mi *MI_256_v1) Team4_ProposeWithdrawal() int {
 	if !mi.HasTeam() {
 		return 0
 	}
-	mi.DecideWithdrawal(mi.Server.GetTeam(mi.GetID()).GetCommonPool())
+	mi.DecideWithdrawal(mi.Server.GetTeam(mi.GetID()).CommonPool)
 	return mi.IntendedWithdrawal
 }
 func (mi *MI_256_v1) Team4_GetPunishmentVoteMap() map[int]int {
 	punishmentVoteMap := make(map[int]int)
 
 	for punishment := 0; punishment <= 4; punishment++ {
-		punishmentVoteMap[punishment] = min(4, rand.Intn(5)+max(mi.evilness-2, 0))
+		punishmentVoteMap[punishment] = min(4, mi.rng.Intn(5)+max(mi.evilness-2, 0))
 	}
 
 	return punishmentVoteMap
 }
+
+type mi256State struct {
+	extendedAgentState
+	Chaoticness int
+	Evilness    int
+
+	Affinity       map[uuid.UUID]int
+	Mood           int
+	AffinityChange map[uuid.UUID]int
+
+	TeamAgentsDeclaredRolls        map[uuid.UUID]int
+	TeamAgentsDeclaredContribution map[uuid.UUID]int
+	TeamAgentsDeclaredWithdraw     map[uuid.UUID]int
+	TeamAgentsExpectedScore        map[uuid.UUID]int
+	TeamAgentsExpectedContribution map[uuid.UUID]int
+	TeamAgentsExpectedWithdraw     map[uuid.UUID]int
+
+	LastAuditTarget  uuid.UUID
+	LastVotes        map[uuid.UUID]bool
+	LastAuditStarter uuid.UUID
+	LastAuditResult  bool
+
+	LastCommonPool int
+
+	AOAOpinion              map[uuid.UUID]int
+	AoAExpectedContribution int
+	AoAExpectedWithdrawal   int
+	AoAAuditCost            int
+	AoAPunishment           int
+	IsAoAContributionFixed  bool
+	IsAoAWithdrawalFixed    bool
+
+	IntendedWithdrawal   int
+	DeclaredWithdrawal   int
+	IntendedContribution int
+	DeclaredContribution int
+
+	IsThereCheatWithdrawal   bool
+	CheatWithdrawalDiff      int
+	IsThereCheatContribution bool
+	CheatContributeDiff      int
+	HaveIlied                bool
+	IcaughtLying             bool
+
+	LastThreshold int
+	LastTurnScore int
+}
+
+func (mi *MI_256_v1) SnapshotState() (json.RawMessage, error) {
+	base, err := mi.baseState()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mi256State{
+		extendedAgentState:             base,
+		Chaoticness:                    mi.chaoticness,
+		Evilness:                       mi.evilness,
+		Affinity:                       mi.affinity,
+		Mood:                           mi.mood,
+		AffinityChange:                 mi.affinityChange,
+		TeamAgentsDeclaredRolls:        mi.teamAgentsDeclaredRolls,
+		TeamAgentsDeclaredContribution: mi.teamAgentsDeclaredContribution,
+		TeamAgentsDeclaredWithdraw:     mi.teamAgentsDeclaredWithdraw,
+		TeamAgentsExpectedScore:        mi.teamAgentsExpectedScore,
+		TeamAgentsExpectedContribution: mi.teamAgentsExpectedContribution,
+		TeamAgentsExpectedWithdraw:     mi.teamAgentsExpectedWithdraw,
+		LastAuditTarget:                mi.lastAuditTarget,
+		LastVotes:                      mi.lastVotes,
+		LastAuditStarter:               mi.lastAuditStarter,
+		LastAuditResult:                mi.lastAuditResult,
+		LastCommonPool:                 mi.last_common_pool,
+		AOAOpinion:                     mi.AOAOpinion,
+		AoAExpectedContribution:        mi.AoAExpectedContribution,
+		AoAExpectedWithdrawal:          mi.AoAExpectedWithdrawal,
+		AoAAuditCost:                   mi.AoAAuditCost,
+		AoAPunishment:                  mi.AoAPunishment,
+		IsAoAContributionFixed:         mi.isAoAContributionFixed,
+		IsAoAWithdrawalFixed:           mi.isAoAWithdrawalFixed,
+		IntendedWithdrawal:             mi.IntendedWithdrawal,
+		DeclaredWithdrawal:             mi.declaredWithdrawal,
+		IntendedContribution:           mi.intendedContribution,
+		DeclaredContribution:           mi.declaredcontribution,
+		IsThereCheatWithdrawal:         mi.isThereCheatWithdrawal,
+		CheatWithdrawalDiff:            mi.cheatWithdrawalDiff,
+		IsThereCheatContribution:       mi.isThereCheatContribution,
+		CheatContributeDiff:            mi.cheatContributeDiff,
+		HaveIlied:                      mi.haveIlied,
+		IcaughtLying:                   mi.IcaughtLying,
+		LastThreshold:                  mi.lastThreshold,
+		LastTurnScore:                  mi.lastTurnScore,
+	})
+}
+
+func (mi *MI_256_v1) RestoreState(data json.RawMessage) error {
+	var state mi256State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	mi.chaoticness = state.Chaoticness
+	mi.evilness = state.Evilness
+	mi.affinity = state.Affinity
+	mi.mood = state.Mood
+	mi.affinityChange = state.AffinityChange
+	mi.teamAgentsDeclaredRolls = state.TeamAgentsDeclaredRolls
+	mi.teamAgentsDeclaredContribution = state.TeamAgentsDeclaredContribution
+	mi.teamAgentsDeclaredWithdraw = state.TeamAgentsDeclaredWithdraw
+	mi.teamAgentsExpectedScore = state.TeamAgentsExpectedScore
+	mi.teamAgentsExpectedContribution = state.TeamAgentsExpectedContribution
+	mi.teamAgentsExpectedWithdraw = state.TeamAgentsExpectedWithdraw
+	mi.lastAuditTarget = state.LastAuditTarget
+	mi.lastVotes = state.LastVotes
+	mi.lastAuditStarter = state.LastAuditStarter
+	mi.lastAuditResult = state.LastAuditResult
+	mi.last_common_pool = state.LastCommonPool
+	mi.AOAOpinion = state.AOAOpinion
+	mi.AoAExpectedContribution = state.AoAExpectedContribution
+	mi.AoAExpectedWithdrawal = state.AoAExpectedWithdrawal
+	mi.AoAAuditCost = state.AoAAuditCost
+	mi.AoAPunishment = state.AoAPunishment
+	mi.isAoAContributionFixed = state.IsAoAContributionFixed
+	mi.isAoAWithdrawalFixed = state.IsAoAWithdrawalFixed
+	mi.IntendedWithdrawal = state.IntendedWithdrawal
+	mi.declaredWithdrawal = state.DeclaredWithdrawal
+	mi.intendedContribution = state.IntendedContribution
+	mi.declaredcontribution = state.DeclaredContribution
+	mi.isThereCheatWithdrawal = state.IsThereCheatWithdrawal
+	mi.cheatWithdrawalDiff = state.CheatWithdrawalDiff
+	mi.isThereCheatContribution = state.IsThereCheatContribution
+	mi.cheatContributeDiff = state.CheatContributeDiff
+	mi.haveIlied = state.HaveIlied
+	mi.IcaughtLying = state.IcaughtLying
+	mi.lastThreshold = state.LastThreshold
+	mi.lastTurnScore = state.LastTurnScore
+	return mi.restoreBaseState(state.extendedAgentState)
+}
@@ -1,6 +1,7 @@
 package agents
 
 import (
+	"encoding/json"
 	// "fmt"
 	"log"
 	"strconv"
@@ -62,6 +63,47 @@ const (
 	CheatShortTerm
 )
 
+// Names used for each AgentType by the agent factory registry
+var team1AgentTypeNames = map[AgentType]string{
+	Rational:       "rational",
+	CheatLongTerm:  "cheat_long_term",
+	CheatShortTerm: "cheat_short_term",
+}
+
+func init() {
+	options := []string{}
+	for _, agentType := range []AgentType{Rational, CheatLongTerm, CheatShortTerm} {
+		options = append(options, team1AgentTypeNames[agentType])
+	}
+
+	RegisterAgentFactory(AgentFactory{
+		Name:        "team1",
+		Description: "Team 1 agent, behaving according to agentType",
+		Params: []ParamSpec{
+			{Name: "agentType", Kind: ParamString, Description: "how the agent cheats", Default: "rational", Options: options},
+		},
+		Create: func(funcs baseAgent.IExposedServerFunctions[common.IExtendedAgent], config AgentConfig, params AgentParams) common.IExtendedAgent {
+			for agentType, name := range team1AgentTypeNames {
+				if name == params.String("agentType") {
+					return Create_Team1Agent(funcs, config, agentType)
+				}
+			}
+			return Create_Team1Agent(funcs, config, Rational)
+		},
+	})
+
+	// Shorthand for each agent type, e.g. "team1:cheat_long_term"
+	for agentType, name := range team1AgentTypeNames {
+		RegisterAgentFactory(AgentFactory{
+			Name:        "team1:" + name,
+			Description: "Team 1 agent of type " + name,
+			Create: func(funcs baseAgent.IExposedServerFunctions[common.IExtendedAgent], config AgentConfig, params AgentParams) common.IExtendedAgent {
+				return Create_Team1Agent(funcs, config, agentType)
+			},
+		})
+	}
+}
+
 type Team1Agent struct {
 	*ExtendedAgent
 	memory    map[uuid.UUID]AgentMemory
@@ -74,7 +116,7 @@ const min_stated_withdrawal = 1    //minimum withdrawal stated by cheater
 const cheat_amount = 3             //how much stated & actually contributed or withdrawn if cheating
 
 func (a1 *Team1Agent) StickOrAgain(accumulatedScore int, prevRoll int) bool {
-	exp := getExpectedGain(accumulatedScore, prevRoll)
+	exp := a1.getExpectedGain(accumulatedScore, prevRoll)
 	if exp < 2.0 {
 		return true
 	} else {
@@ -83,42 +125,14 @@ func (a1 *Team1Agent) StickOrAgain(accumulatedScore int, prevRoll int) bool {
 
 }
 
-func getExpectedGain(accumulatedScore, prevRoll int) float64 {
-	lookup := make(map[int]int)
-	for i := 1; i <= 6; i++ {
-		for j := 1; j <= 6; j++ {
-			for k := 1; k <= 6; k++ {
-				sum := i + j + k
-				lookup[sum]++
-			}
-		}
-	}
-
-	prob := make([]float64, 19)
-	var totalCombinations int
-	for _, v := range lookup {
-		totalCombinations += v
-	}
-	for k, v := range lookup {
-		prob[k] = float64(v) / float64(totalCombinations)
-	}
-
-	var pLoss, eLoss, eGain float64
-
-	for i := 3; i <= prevRoll; i++ {
-		pLoss += prob[i]
-	}
-	eLoss = pLoss * float64(accumulatedScore) * -1
-
-	for i := prevRoll + 1; i < 19; i++ {
-		eGain += prob[i] * float64(i)
-	}
-
-	return eGain + eLoss
+// Expected gain from rolling again, under the dice rules the server rolls by
+// for this agent
+func (a1 *Team1Agent) getExpectedGain(accumulatedScore, prevRoll int) float64 {
+	return a1.Server.GetDiceRules(a1.GetID()).ExpectedGain(accumulatedScore, prevRoll)
 }
 
 func (a1 *Team1Agent) AmountToNextRank() int {
-	teamAoA, ok := a1.Server.GetTeam(a1.GetID()).TeamAoA.(*common.Team1AoA)
+	teamAoA, ok := a1.Server.GetTeam(a1.GetID()).Team1Ranks()
 	if !ok {
 		// If unable to access Team1AoA, just return 0 - this shouldn't happen
 		return 0
@@ -209,8 +223,8 @@ func (a1 *Team1Agent) GetActualContribution(instance common.IExtendedAgent) int
 
 func (a1 *Team1Agent) GetActualWithdrawal(instance common.IExtendedAgent) int {
 	if a1.HasTeam() {
-		commonPool := a1.Server.GetTeam(a1.GetID()).GetCommonPool()
-		aoaExpectedWithdrawal := a1.Server.GetTeam(a1.GetID()).TeamAoA.GetExpectedWithdrawal(a1.GetID(), a1.Score, commonPool)
+		commonPool := a1.Server.GetTeam(a1.GetID()).CommonPool
+		aoaExpectedWithdrawal := a1.Server.GetTeam(a1.GetID()).GetExpectedWithdrawal(a1.GetID(), a1.Score, commonPool)
 		currentRank := 0
 
 		decision := 0
@@ -219,7 +233,7 @@ func (a1 *Team1Agent) GetActualWithdrawal(instance common.IExtendedAgent) int {
 			decision = aoaExpectedWithdrawal
 		case CheatLongTerm:
 			// Perform type assertion to get Team1AoA
-			teamAoA, ok := a1.Server.GetTeam(a1.GetID()).TeamAoA.(*common.Team1AoA)
+			teamAoA, ok := a1.Server.GetTeam(a1.GetID()).Team1Ranks()
 			if ok {
 				currentRank = teamAoA.GetAgentRank(a1.GetID())
 				if currentRank > 1 {
@@ -266,7 +280,7 @@ func (a1 *Team1Agent) GetStatedContribution(instance common.IExtendedAgent) int
 		case Rational, CheatLongTerm:
 			return actualContribution
 		case CheatShortTerm:
-			_, ok := a1.Server.GetTeam(a1.GetID()).TeamAoA.(*common.Team1AoA)
+			_, ok := a1.Server.GetTeam(a1.GetID()).Team1Ranks()
 			if !ok {
 				// If unable to access Team1AoA, just use actual contribution with some fixed cheating value
 				return actualContribution + overstate_contribution
@@ -307,7 +321,7 @@ func (a *Team1Agent) GetAoARanking() []int {
 func (a1 *Team1Agent) hasClimbedRankAndWithdrawn() bool {
 	if a1.HasTeam() {
 		// Access Team1AoA and check rank changes or over-withdrawals
-		teamAoA, ok := a1.Server.GetTeam(a1.GetID()).TeamAoA.(*common.Team1AoA)
+		teamAoA, ok := a1.Server.GetTeam(a1.GetID()).Team1Ranks()
 		if !ok {
 			return false // If unable to access Team1AoA, assume no rank climb
 		}
@@ -359,7 +373,8 @@ func (a1 *Team1Agent) GetContributionAuditVote() common.Vote {
 
 		// Iterate over memory to find the agent with suspiciously high contributions
 		// Can be improved by adding a check to compare true common pool value with stated contribution
-		for agentID, memoryEntry := range a1.memory {
+		for _, agentID := range common.SortedIDs(a1.memory) {
+			memoryEntry := a1.memory[agentID]
 			// Limit by the last contributions
 			relevantContributions := memoryEntry.historyContribution[:memoryEntry.LastContributionCount]
 			for _, contribution := range relevantContributions {
@@ -414,7 +429,8 @@ func (a1 *Team1Agent) GetWithdrawalAuditVote() common.Vote {
 		}
 
 		// Iterate over memory to find the agent with the largest discrepancy
-		for agentID, memoryEntry := range a1.memory {
+		for _, agentID := range common.SortedIDs(a1.memory) {
+			memoryEntry := a1.memory[agentID]
 			relevantWithdrawals := memoryEntry.historyWithdrawal[:memoryEntry.LastWithdrawalCount]
 			for _, withdrawal := range relevantWithdrawals {
 				discrepancy := withdrawal.WithdrawalExpected - withdrawal.WithdrawalStated //expected - stated
@@ -470,6 +486,7 @@ func (a1 *Team1Agent) SetAgentContributionAuditResult(agentID uuid.UUID, result
 		// agent was honest
 		a1.memory[agentID].honestyScore.Push(1)
 	}
+	a1.formHonestyOpinion(agentID)
 }
 
 func (a1 *Team1Agent) SetAgentWithdrawalAuditResult(agentID uuid.UUID, result bool) {
@@ -489,6 +506,19 @@ func (a1 *Team1Agent) SetAgentWithdrawalAuditResult(agentID uuid.UUID, result bo
 		// agent was honest
 		a1.memory[agentID].honestyScore.Push(1)
 	}
+	a1.formHonestyOpinion(agentID)
+}
+
+// Trust an agent as far as its recent audits were honest, more surely the more
+// of them there are
+func (a1 *Team1Agent) formHonestyOpinion(agentID uuid.UUID) {
+	honesty := a1.memory[agentID].honestyScore
+	if honesty.Len() == 0 {
+		return
+	}
+	trust := (float64(honesty.Sum())/float64(honesty.Len()) + 1) / 2
+	confidence := float64(honesty.Len()) / float64(honesty.Cap())
+	a1.formOpinion(common.NewOpinion(agentID, trust, confidence, common.EvidenceAudit))
 }
 
 func (a1 *Team1Agent) VoteOnAgentEntry(candidateID uuid.UUID) bool {
@@ -498,6 +528,10 @@ func (a1 *Team1Agent) VoteOnAgentEntry(candidateID uuid.UUID) bool {
 	value, exists := a1.memory[candidateID]
 
 	if !exists {
+		// Never played with them, so go by their record in other teams
+		if a1.agentType == Rational {
+			return len(a1.Server.GetOffences(candidateID)) == 0
+		}
 		return true
 	}
 
@@ -511,11 +545,15 @@ func (a1 *Team1Agent) VoteOnAgentEntry(candidateID uuid.UUID) bool {
 }
 
 func Create_Team1Agent(funcs baseAgent.IExposedServerFunctions[common.IExtendedAgent], agentConfig AgentConfig, ag_type AgentType) *Team1Agent {
-	return &Team1Agent{
+	a1 := &Team1Agent{
 		ExtendedAgent: GetBaseAgents(funcs, agentConfig),
 		memory:        make(map[uuid.UUID]AgentMemory),
 		agentType:     ag_type,
 	}
+	if ag_type != Rational {
+		log.Printf("Team1 %v is of type %v", a1.GetID(), team1AgentTypeNames[ag_type])
+	}
+	return a1
 }
 
 // ----------------- Messaging functions -----------------------
@@ -613,9 +651,7 @@ func (mi *Team1Agent) RecordAgentStatus(instance common.IExtendedAgent) gameReco
 
 	specialNote := "-1"
 	if mi.HasTeam() {
-		teamAoA := mi.Server.GetTeam(instance.GetID()).TeamAoA
-		switch teamAoA := teamAoA.(type) {
-		case *common.Team1AoA:
+		if teamAoA, ok := mi.Server.GetTeam(instance.GetID()).Team1Ranks(); ok {
 			specialNote = "Rank: " + strconv.Itoa(teamAoA.GetAgentRank(instance.GetID()))
 		}
 
@@ -634,3 +670,64 @@ func (mi *Team1Agent) RecordAgentStatus(instance common.IExtendedAgent) gameReco
 	)
 	return record
 }
+
+type agentMemoryState struct {
+	HonestyScore          *common.LeakyQueue
+	LastContributionCount int
+	LastWithdrawalCount   int
+	LastScoreCount        int
+	HistoryContribution   []AgentContributionInfo
+	HistoryWithdrawal     []AgentWithdrawalInfo
+	HistoryScore          []AgentScoreInfo
+}
+
+type team1AgentState struct {
+	extendedAgentState
+	Memory    map[uuid.UUID]agentMemoryState
+	AgentType AgentType
+}
+
+func (a1 *Team1Agent) SnapshotState() (json.RawMessage, error) {
+	base, err := a1.baseState()
+	if err != nil {
+		return nil, err
+	}
+	state := team1AgentState{
+		extendedAgentState: base,
+		Memory:             make(map[uuid.UUID]agentMemoryState, len(a1.memory)),
+		AgentType:          a1.agentType,
+	}
+	for agentID, memory := range a1.memory {
+		state.Memory[agentID] = agentMemoryState{
+			HonestyScore:          memory.honestyScore,
+			LastContributionCount: memory.LastContributionCount,
+			LastWithdrawalCount:   memory.LastWithdrawalCount,
+			LastScoreCount:        memory.LastScoreCount,
+			HistoryContribution:   memory.historyContribution,
+			HistoryWithdrawal:     memory.historyWithdrawal,
+			HistoryScore:          memory.historyScore,
+		}
+	}
+	return json.Marshal(state)
+}
+
+func (a1 *Team1Agent) RestoreState(data json.RawMessage) error {
+	var state team1AgentState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	a1.memory = make(map[uuid.UUID]AgentMemory, len(state.Memory))
+	for agentID, memory := range state.Memory {
+		a1.memory[agentID] = AgentMemory{
+			honestyScore:          memory.HonestyScore,
+			LastContributionCount: memory.LastContributionCount,
+			LastWithdrawalCount:   memory.LastWithdrawalCount,
+			LastScoreCount:        memory.LastScoreCount,
+			historyContribution:   memory.HistoryContribution,
+			historyWithdrawal:     memory.HistoryWithdrawal,
+			historyScore:          memory.HistoryScore,
+		}
+	}
+	a1.agentType = state.AgentType
+	return a1.restoreBaseState(state.extendedAgentState)
+}
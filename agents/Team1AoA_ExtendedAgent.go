@@ -10,6 +10,7 @@ import (
 	"github.com/google/uuid"
 
 	common "github.com/ADimoska/SOMASExtended/common"
+	voting "github.com/ADimoska/SOMASExtended/voting"
 )
 
 func (mi *ExtendedAgent) Team1_ChairUpdateRanks(currentRanking map[uuid.UUID]int) map[uuid.UUID]int {
@@ -18,7 +19,8 @@ func (mi *ExtendedAgent) Team1_ChairUpdateRanks(currentRanking map[uuid.UUID]int
 	// according to AoA function
 	newRanking := make(map[uuid.UUID]int)
 	for agentUUID := range currentRanking {
-		newRank := mi.Server.GetTeam(agentUUID).TeamAoA.(*common.Team1AoA).GetAgentNewRank(agentUUID)
+		teamAoA, _ := mi.Server.GetTeam(agentUUID).Team1Ranks()
+		newRank := teamAoA.GetAgentNewRank(agentUUID)
 		newRanking[agentUUID] = newRank
 	}
 
@@ -147,44 +149,17 @@ func (mi *ExtendedAgent) team1_VoteOnRankBoundaries(cands [3][5]int) [5]int {
 		mi.SendSynchronousMessage(req, agentID)
 	}
 
-	// Compute pairwise relations for Condorcet winner algorithm
-	pairwise := [3][3]int{
-		{0, 0, 0},
-		{0, 0, 0},
-		{0, 0, 0},
-	}
-
-	for _, vote := range mi.team1Ballots {
-		for i := 0; i < 3; i++ {
-			for j := 0; j < 3; j++ {
-				if vote[i] > vote[j] {
-					pairwise[i][j]++
-				}
-			}
-		}
-	}
-
-	condorcet := -1
-
-	// Check for a Condorcet winner
-	for i := 0; i < 3; i++ {
-		winner := true
-		for j := 0; j < 3; j++ {
-			if i != j && pairwise[i][j] <= pairwise[j][i] {
-				winner = false
-				break
-			}
-		}
-		if winner {
-			condorcet = i
-			break
-		}
+	// Each ballot grades the three candidates, higher being better
+	ballots := make([]voting.Grades[int], len(mi.team1Ballots))
+	for i, vote := range mi.team1Ballots {
+		ballots[i] = voting.Grades[int]{0: vote[0], 1: vote[1], 2: vote[2]}
 	}
+	condorcet, found := voting.CondorcetWinner([]int{0, 1, 2}, ballots)
 
 	/* If there is no condorcet winner, we return the median. This is deemed
 	 * acceptable by voluntary association - Using a mean here could be
 	 * influenced heavily by outliers */
-	if condorcet != -1 {
+	if found {
 		log.Printf("Chair %v identified Condorcet winner %v", mi.GetID(), cands[condorcet])
 		return cands[condorcet]
 	} else {
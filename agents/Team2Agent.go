@@ -1,8 +1,10 @@
 package agents
 
 import (
+	"encoding/json"
 	"log"
 	"math"
+	"slices"
 	"sort"
 
 	// "math/rand"
@@ -25,6 +27,16 @@ type Team2Agent struct {
 	commonPoolEstimate int
 }
 
+func init() {
+	RegisterAgentFactory(AgentFactory{
+		Name:        "team2",
+		Description: "Team 2 agent, tracking trust scores of its teammates",
+		Create: func(funcs agent.IExposedServerFunctions[common.IExtendedAgent], config AgentConfig, params AgentParams) common.IExtendedAgent {
+			return Team2_CreateAgent(funcs, config)
+		},
+	})
+}
+
 // constructor for team2agent - initialised as all followers
 func Team2_CreateAgent(funcs agent.IExposedServerFunctions[common.IExtendedAgent], agentConfig AgentConfig) *Team2Agent {
 	extendedAgent := GetBaseAgents(funcs, agentConfig)
@@ -47,8 +59,9 @@ func Team2_CreateAgent(funcs agent.IExposedServerFunctions[common.IExtendedAgent
 
 // ---------- TRUST SCORE SYSTEM ----------
 func (t2a *Team2Agent) SetTrustScore(agentID uuid.UUID) {
-	// Initialize trust score for this agent
-	t2a.trustScore[agentID] = 70
+	// Initialize trust score for this agent, less for every offence on its record
+	offences := t2a.Server.GetOffences(agentID)
+	t2a.trustScore[agentID] = 70 - 10*len(offences)
 }
 
 func (t2a *Team2Agent) getAverageTeamTrustScore(teamID uuid.UUID) int {
@@ -155,38 +168,38 @@ func (t2a *Team2Agent) SetAgentWithdrawalAuditResult(agentID uuid.UUID, result b
 
 // ----------- RANKING SYSTEM ----------
 
-func (t2a *Team2Agent) Team2_GetLeaderVote() common.Vote {
-	// Experiment with this - it is our threshold to decide leader worthiness
-	var leaderThreshold int = 60
-
-	// Get list of UUIDs in our team
-	var agentsInTeam []uuid.UUID = t2a.Server.GetAgentsInTeam(t2a.TeamID)
-
-	var highestTrustScore int = math.MinInt // Start with the minimum possible int
-	var mostTrustedAgent uuid.UUID
+// Experiment with this - it is our threshold to decide leader worthiness
+const team2LeaderThreshold = 60
 
-	// Iterate over our team, finding the agent with the highest trust score
-	for _, agentID := range agentsInTeam {
-		agentTrustScore := t2a.trustScore[agentID]
-		// Initialize trust score map if it hasn't been initialized yet
-		if t2a.trustScore == nil {
-			t2a.SetTrustScore(agentID)
-		}
-
-		if agentTrustScore > highestTrustScore {
-			mostTrustedAgent = agentID
-			highestTrustScore = agentTrustScore
+// Rank the candidates we trust enough to lead, most trusted first
+func (t2a *Team2Agent) GetLeaderBallot(candidates []uuid.UUID) []uuid.UUID {
+	ballot := []uuid.UUID{}
+	for _, agentID := range candidates {
+		if t2a.trustScore[agentID] > team2LeaderThreshold {
+			ballot = append(ballot, agentID)
 		}
 	}
+	// stable, so that candidates we trust equally stay in the server's order
+	slices.SortStableFunc(ballot, func(a, b uuid.UUID) int {
+		return t2a.trustScore[b] - t2a.trustScore[a]
+	})
+	return ballot
+}
 
-	// If the most trusted agent is above the threshold, vote for them as leader
-	if highestTrustScore > leaderThreshold {
-		// 1 means vote for this agent as leader
-		return common.CreateVote(1, t2a.GetID(), mostTrustedAgent)
-	} else {
-		// 0 means abstain / no preference
-		return common.CreateVote(0, t2a.GetID(), uuid.Nil)
-	}
+// Recall a leader we no longer trust enough to have voted for
+func (t2a *Team2Agent) GetRecallVote(leader uuid.UUID) bool {
+	return t2a.trustScore[leader] <= team2LeaderThreshold
+}
+
+// Under our own AoA we always do what is expected of us, so a guilty verdict
+// can only be a mistake
+func (t2a *Team2Agent) DecideAppeal(phase common.AuditPhase) bool {
+	return t2a.HasTeam() && t2a.Server.GetTeam(t2a.GetID()).AoAID == common.Team2AoAID
+}
+
+// Overturn the verdicts of agents we would trust to lead
+func (t2a *Team2Agent) GetAppealVote(accused uuid.UUID, phase common.AuditPhase) bool {
+	return t2a.trustScore[accused] > team2LeaderThreshold
 }
 
 func (t2a *Team2Agent) ToggleLeader() {
@@ -244,31 +257,48 @@ func (t2a *Team2Agent) HandleTeamFormationMessage(msg *common.TeamFormationMessa
 
 func (t2a *Team2Agent) HandleAgentOpinionRequestMessage(msg *common.AgentOpinionRequestMessage) {
 	// Respond to opinion requests from other agents
-	agentID, trustScore := msg.AgentID, 0
-
-	if _, ok := t2a.trustScore[agentID]; ok {
-		t2a.SetTrustScore(agentID)
+	if _, ok := t2a.trustScore[msg.AgentID]; !ok {
+		t2a.SetTrustScore(msg.AgentID)
 		return // Ignore if you don't have an opinion, but set it in the map
 	}
 
-	trustScore = t2a.trustScore[agentID]
-	agentOpinionResponseMessage := t2a.CreateAgentOpinionResponseMessage(agentID, trustScore)
-
-	t2a.SendMessage(agentOpinionResponseMessage, msg.GetSender()) // Respond to the person asking with a trust score, asynchronously
+	t2a.answerOpinionRequest(msg, t2a.GetOpinions()) // Respond to the person asking with a trust score, asynchronously
 }
 
 func (t2a *Team2Agent) HandleAgentOpinionResponseMessage(msg *common.AgentOpinionResponseMessage) {
-	agentID, trustScore := msg.AgentID, msg.AgentOpinion
+	t2a.ExtendedAgent.HandleAgentOpinionResponseMessage(msg)
+	agentID, opinion := msg.Opinion.Subject, msg.Opinion
 
 	if _, ok := t2a.trustScore[agentID]; !ok {
 		t2a.SetTrustScore(agentID)
 		return
 	}
+	if opinion.Confidence == 0 {
+		return
+	}
 
 	// Update trust score to be the weighted average of the two opinions
+	trustScore := int(opinion.Trust * 100)
 	t2a.trustScore[agentID] = ((trustScore * 2) + t2a.trustScore[agentID]) / 3
 }
 
+// Trust scores as opinions, with 100 as complete trust. They come from what
+// agents stated, and are only half sure as every agent starts off trusted.
+func (t2a *Team2Agent) GetOpinions() []common.Opinion {
+	opinions := []common.Opinion{}
+	for _, agentID := range common.SortedIDs(t2a.trustScore) {
+		if agentID == t2a.GetID() {
+			continue
+		}
+		opinions = append(opinions, common.NewOpinion(agentID, float64(t2a.trustScore[agentID])/100, 0.5, common.EvidenceStatement))
+	}
+	return opinions
+}
+
+func (t2a *Team2Agent) GetTrust(subject uuid.UUID) float64 {
+	return t2a.trustIn(subject, t2a.GetOpinions())
+}
+
 // ---------- VOTE ON ORPHANS ----------
 
 func (t2a *Team2Agent) VoteOnAgentEntry(candidateID uuid.UUID) bool {
@@ -318,27 +348,13 @@ func (t2a *Team2Agent) GetDeadTeammates() []struct {
 }
 
 // Function to determine the probability of improvement of the next re-roll compared to previous roll
-func (t2a *Team2Agent) probabilityOfImprovement(prevRoll int) float64 {
+func (t2a *Team2Agent) probabilityOfImprovement(rules common.DiceRules, accumulatedScore int, prevRoll int) float64 {
 	if prevRoll == 0 { // First roll of the iteration so guaranteed probability of improvement
 		return 1
 	}
 
-	// Probabilities of sums with 3 dice (precomputed distribution of 3d6 outcomes)
-	probabilities := map[int]float64{
-		3: 1.0 / 216, 4: 3.0 / 216, 5: 6.0 / 216, 6: 10.0 / 216,
-		7: 15.0 / 216, 8: 21.0 / 216, 9: 25.0 / 216, 10: 27.0 / 216,
-		11: 27.0 / 216, 12: 25.0 / 216, 13: 21.0 / 216, 14: 15.0 / 216,
-		15: 10.0 / 216, 16: 6.0 / 216, 17: 3.0 / 216, 18: 1.0 / 216,
-	}
-
-	// Calculate the cumulative probability of rolling higher than `prevRoll`
-	cumulativeProbability := 0.0
-	for outcome := prevRoll + 1; outcome <= 18; outcome++ {
-		cumulativeProbability += probabilities[outcome]
-	}
-
-	return cumulativeProbability
-
+	// Cumulative probability of a roll that does not go bust, from the server's dice rules
+	return rules.ImprovementProbability(accumulatedScore, prevRoll)
 }
 
 // Function to determine risk tolerance which determines how risk averse or risky agent should be
@@ -381,7 +397,9 @@ func (t2a *Team2Agent) StickOrAgain(accumulatedScore int, prevRoll int) bool {
 	log.Printf("*****Prev Roll: %d\n", prevRoll)
 
 	// Determine cumulative probability of improvement
-	cumulativeProbability := t2a.probabilityOfImprovement(prevRoll)
+	rules := t2a.Server.GetDiceRules(t2a.GetID())
+	cumulativeProbability := t2a.probabilityOfImprovement(rules, accumulatedScore, prevRoll)
+	maxRoll := float64(rules.MaxRoll())
 	log.Printf("*****Cumulative Probability of Improvement: %.2f\n", cumulativeProbability)
 
 	log.Printf("*****Rank is: %t\n", t2a.rank) // true is leader, false is citizen
@@ -393,7 +411,7 @@ func (t2a *Team2Agent) StickOrAgain(accumulatedScore int, prevRoll int) bool {
 	if t2a.rank { // Leader is very risky and has a fixed riskTolerance of 0.8
 		riskTolerance = 0.8
 		threshold := float64(prevRoll) * (1.0 - riskTolerance)
-		if (cumulativeProbability * 18) > threshold {
+		if (cumulativeProbability * maxRoll) > threshold {
 			log.Printf("*****Decision: Re-roll\n")
 			return false // Re-roll
 		}
@@ -403,8 +421,8 @@ func (t2a *Team2Agent) StickOrAgain(accumulatedScore int, prevRoll int) bool {
 		// If low risk tolerance then higher threshold hence less likely to re-roll
 		threshold := float64(prevRoll) * (1.0 - riskTolerance)
 		log.Printf("*****Citizen threshold: %f\n", threshold)
-		log.Printf("*****Cumulative Probability * 18: %f\n", (cumulativeProbability * 18))
-		if (cumulativeProbability * 18) > threshold {
+		log.Printf("*****Cumulative Probability * max roll: %f\n", (cumulativeProbability * maxRoll))
+		if (cumulativeProbability * maxRoll) > threshold {
 			log.Printf("*****Decision: Re-roll\n")
 			return false // Re-roll
 		}
@@ -418,8 +436,9 @@ func (t2a *Team2Agent) StickOrAgainFor(agentId uuid.UUID, accumulatedScore int,
 
 	log.Printf("StickOrAgainFor called with agentId: %v, accumulatedScore: %d, prevRoll: %d", agentId, accumulatedScore, prevRoll)
 
+	rules := t2a.Server.GetDiceRules(agentId)
 	if prevRoll == -1 {
-		prevRoll = 3
+		prevRoll = rules.MinRoll()
 	}
 
 	log.Printf("*****Total Score before deciding to re-roll or stick: %d\n", accumulatedScore)
@@ -427,7 +446,8 @@ func (t2a *Team2Agent) StickOrAgainFor(agentId uuid.UUID, accumulatedScore int,
 	log.Printf("*****Prev Roll: %d\n", prevRoll)
 
 	// Determine cumulative probability of improvement
-	cumulativeProbability := t2a.probabilityOfImprovement(prevRoll)
+	cumulativeProbability := t2a.probabilityOfImprovement(rules, accumulatedScore, prevRoll)
+	maxRoll := float64(rules.MaxRoll())
 	log.Printf("*****Cumulative Probability of Improvement: %.2f\n", cumulativeProbability)
 
 	log.Printf("*****Rank is: %t\n", t2a.rank) // true is leader, false is citizen
@@ -439,7 +459,7 @@ func (t2a *Team2Agent) StickOrAgainFor(agentId uuid.UUID, accumulatedScore int,
 	// Leader is very risky and has a fixed riskTolerance of 0.8
 	riskTolerance = 0.8
 	threshold := float64(prevRoll) * (1.0 - riskTolerance)
-	if (cumulativeProbability * 18) > threshold {
+	if (cumulativeProbability * maxRoll) > threshold {
 		log.Printf("*****Decision: Re-roll\n")
 		return 0 // Re-roll
 	}
@@ -497,12 +517,13 @@ func (t2a *Team2Agent) GetActualContribution(instance common.IExtendedAgent) int
 		return 0
 	}
 
-	switch aoa := t2a.Server.GetTeam(t2a.GetID()).TeamAoA.(type) {
-	case *common.Team2AoA:
+	team := t2a.Server.GetTeam(t2a.GetID())
+	switch team.AoAID {
+	case common.Team2AoAID:
 		// under our own AoA, for now we just return what is expected of us.
 
 		// get the contribution we are expected to make
-		aoaExpectedContribution := aoa.GetExpectedContribution(t2a.GetID(), t2a.GetTrueScore())
+		aoaExpectedContribution := team.GetExpectedContribution(t2a.GetID(), t2a.GetTrueScore())
 
 		// if we have less than the expected, just contribute whats left
 		if t2a.GetTrueScore() < aoaExpectedContribution {
@@ -515,7 +536,7 @@ func (t2a *Team2Agent) GetActualContribution(instance common.IExtendedAgent) int
 		// under other aoas, adapt based on the average team trust score
 
 		// get the contribution we are expected to make
-		aoaExpectedContribution := aoa.GetExpectedContribution(t2a.GetID(), t2a.GetTrueScore())
+		aoaExpectedContribution := team.GetExpectedContribution(t2a.GetID(), t2a.GetTrueScore())
 
 		// if we have less than the expected, just contribute whats left
 		if t2a.GetTrueScore() < aoaExpectedContribution {
@@ -580,7 +601,7 @@ func (t2a *Team2Agent) GetContributionAuditVote() common.Vote {
 	// Step 2: If there is no one obvious to audit based on stated contributions, then:
 	// get the actual size of common pool post contributions, and the supposed size based on what agents have stated about their contributions.
 	// compare them to find the discrepancy.
-	var actualCommonPoolSize = t2a.Server.GetTeam(t2a.GetID()).GetCommonPool()
+	var actualCommonPoolSize = t2a.Server.GetTeam(t2a.GetID()).CommonPool
 	var discrepancy int = t2a.commonPoolEstimate - actualCommonPoolSize
 
 	// after finding discrepancy, set our common pool estimate to the actual size of the common pool in preparation for withdrawal stage
@@ -635,13 +656,14 @@ func (t2a *Team2Agent) GetActualWithdrawal(instance common.IExtendedAgent) int {
 		return 0
 	}
 
-	commonPool := t2a.Server.GetTeam(t2a.GetID()).GetCommonPool()
+	team := t2a.Server.GetTeam(t2a.GetID())
+	commonPool := team.CommonPool
 
-	switch aoa := t2a.Server.GetTeam(t2a.GetID()).TeamAoA.(type) {
-	case *common.Team2AoA:
+	switch team.AoAID {
+	case common.Team2AoAID:
 		// under our own AoA, for now we just withdraw what is expected of us.
 
-		aoaExpectedWithdrawal := aoa.GetExpectedWithdrawal(t2a.GetID(), t2a.GetTrueScore(), commonPool)
+		aoaExpectedWithdrawal := team.GetExpectedWithdrawal(t2a.GetID(), t2a.GetTrueScore(), commonPool)
 		if commonPool < aoaExpectedWithdrawal {
 			return commonPool
 		}
@@ -650,7 +672,7 @@ func (t2a *Team2Agent) GetActualWithdrawal(instance common.IExtendedAgent) int {
 	default:
 		// under other aoas, adapt based on the average team trust score
 
-		aoaExpectedWithdrawal := aoa.GetExpectedWithdrawal(t2a.GetID(), t2a.GetTrueScore(), commonPool)
+		aoaExpectedWithdrawal := team.GetExpectedWithdrawal(t2a.GetID(), t2a.GetTrueScore(), commonPool)
 		if commonPool < aoaExpectedWithdrawal {
 			return commonPool
 		}
@@ -712,11 +734,11 @@ func (t2a *Team2Agent) GetWithdrawalAuditVote() common.Vote {
 
 	// get the actual size of common pool after withdrawals, and the supposed size based on what agents have stated about their withdrawals.
 	// compare them to find the discrepancy.
-	var actualCommonPoolSize = t2a.Server.GetTeam(t2a.GetID()).GetCommonPool()
+	var actualCommonPoolSize = t2a.Server.GetTeam(t2a.GetID()).CommonPool
 	var discrepancy int = t2a.commonPoolEstimate - actualCommonPoolSize
 
 	// reset to commonpoolestimate after withdrawal
-	t2a.commonPoolEstimate = t2a.Server.GetTeam(t2a.GetID()).GetCommonPool()
+	t2a.commonPoolEstimate = t2a.Server.GetTeam(t2a.GetID()).CommonPool
 
 	// if there is a significant discrepancy, decrement all your teams trust scores by a suspicion factor.
 	// then check to see if the least trusted agent in your team is below the threshold
@@ -765,12 +787,12 @@ func (t2a *Team2Agent) sendOpinionMessages(agentID uuid.UUID, numAgents int) {
 
 	entries := make([]entry, 0, len(t2a.trustScore))
 
-	for id, score := range t2a.trustScore {
-		entries = append(entries, entry{Key: id, Value: score})
+	for _, id := range common.SortedIDs(t2a.trustScore) {
+		entries = append(entries, entry{Key: id, Value: t2a.trustScore[id]})
 	}
 
 	// Sort in decreasing order of trust score (most trusted first)
-	sort.Slice(entries, func(i, j int) bool {
+	sort.SliceStable(entries, func(i, j int) bool {
 		return entries[i].Value > entries[j].Value
 	})
 
@@ -812,12 +834,12 @@ func (t2a *Team2Agent) GetTeamRanking() []uuid.UUID {
 		Score int
 	}
 	var teamScores []teamScore
-	for id, score := range ranking {
-		teamScores = append(teamScores, teamScore{ID: id, Score: score})
+	for _, id := range common.SortedIDs(ranking) {
+		teamScores = append(teamScores, teamScore{ID: id, Score: ranking[id]})
 	}
 
 	// Sort the slice by composite score in descending order
-	sort.Slice(teamScores, func(i, j int) bool {
+	sort.SliceStable(teamScores, func(i, j int) bool {
 		return teamScores[i].Score > teamScores[j].Score
 	})
 
@@ -831,3 +853,46 @@ func (t2a *Team2Agent) GetTeamRanking() []uuid.UUID {
 
 	return sortedTeamIDs
 }
+
+type team2AgentState struct {
+	extendedAgentState
+	Rank               bool
+	TrustScore         map[uuid.UUID]int
+	StrikeCount        map[uuid.UUID]int
+	StatedContribution map[uuid.UUID]int
+	StatedWithdrawal   map[uuid.UUID]int
+	ThresholdBounds    []int
+	CommonPoolEstimate int
+}
+
+func (t2a *Team2Agent) SnapshotState() (json.RawMessage, error) {
+	base, err := t2a.baseState()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(team2AgentState{
+		extendedAgentState: base,
+		Rank:               t2a.rank,
+		TrustScore:         t2a.trustScore,
+		StrikeCount:        t2a.strikeCount,
+		StatedContribution: t2a.statedContribution,
+		StatedWithdrawal:   t2a.statedWithdrawal,
+		ThresholdBounds:    t2a.thresholdBounds,
+		CommonPoolEstimate: t2a.commonPoolEstimate,
+	})
+}
+
+func (t2a *Team2Agent) RestoreState(data json.RawMessage) error {
+	var state team2AgentState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	t2a.rank = state.Rank
+	t2a.trustScore = state.TrustScore
+	t2a.strikeCount = state.StrikeCount
+	t2a.statedContribution = state.StatedContribution
+	t2a.statedWithdrawal = state.StatedWithdrawal
+	t2a.thresholdBounds = state.ThresholdBounds
+	t2a.commonPoolEstimate = state.CommonPoolEstimate
+	return t2a.restoreBaseState(state.extendedAgentState)
+}
@@ -33,6 +33,7 @@ type Team3Agent struct {
 	contributionLies    map[uuid.UUID]int
 	withdrawalLies      map[uuid.UUID]int
 	numberOfLies        map[uuid.UUID]int
+	statedContributions map[uuid.UUID]int  // last contribution each agent stated to the team
 	invitationResponses map[uuid.UUID]bool // true if accepted, false if rejected
 	invitationsSent     map[uuid.UUID]bool // track if we've sent an invitation
 
@@ -92,6 +93,14 @@ func loadWeights(filename string) (*NetworkWeights, error) {
 	return &weights, nil
 }
 
+// The weights carried over from earlier games, which seeded games never use
+func loadStickRollWeights() (*NetworkWeights, error) {
+	if common.IsSeeded() {
+		return nil, fmt.Errorf("seeded games do not load %s", stickRollWeightsFile)
+	}
+	return loadWeights(stickRollWeightsFile)
+}
+
 // Helper function to convert mat.Dense to [][]float64
 func matrixToSlice(m *mat.Dense) [][]float64 {
 	rows, cols := m.Dims()
@@ -118,20 +127,32 @@ func sliceToMatrix(slice [][]float64) *mat.Dense {
 	return mat.NewDense(rows, cols, data)
 }
 
+func init() {
+	RegisterAgentFactory(AgentFactory{
+		Name:        "team3",
+		Description: "Team 3 agent, using neural networks to decide when to stick and cheat",
+		Create: func(funcs agent.IExposedServerFunctions[common.IExtendedAgent], config AgentConfig, params AgentParams) common.IExtendedAgent {
+			return Team3_CreateAgent(funcs, config)
+		},
+	})
+}
+
 // constructor for Team3Agent
 func Team3_CreateAgent(funcs agent.IExposedServerFunctions[common.IExtendedAgent], agentConfig AgentConfig) *Team3Agent {
+	extendedAgent := GetBaseAgents(funcs, agentConfig)
 	team3 := &Team3Agent{
 
-		ExtendedAgent:       GetBaseAgents(funcs, agentConfig),
+		ExtendedAgent:       extendedAgent,
 		RollHistory:         []int{},
 		TrainingHistory:     []TrainingData{},
 		learningRate:        0.5,
 		contributionLies:    make(map[uuid.UUID]int),
 		withdrawalLies:      make(map[uuid.UUID]int),
 		numberOfLies:        make(map[uuid.UUID]int),
+		statedContributions: make(map[uuid.UUID]int),
 		invitationResponses: make(map[uuid.UUID]bool),
 		invitationsSent:     make(map[uuid.UUID]bool),
-		cheatNN:             NewNeuralNetwork(4, 3), // 4 inputs, 3 hidden neurons
+		cheatNN:             NewNeuralNetwork(extendedAgent.rng, 4, 3), // 4 inputs, 3 hidden neurons
 		cheatHistory:        make([]CheatRecord, 0),
 	}
 	team3.initializeNeuralNetworkStickRoll()
@@ -140,12 +161,18 @@ func Team3_CreateAgent(funcs agent.IExposedServerFunctions[common.IExtendedAgent
 	return team3
 }
 
+/*
+* Unseeded games carry the stick-or-roll weights over from one game to the
+* next in this file, in the working directory. Seeded games neither read nor
+* write it, so that they start from the same weights every time, and keep what
+* they learn only in the agent's state (see SnapshotState).
+ */
+const stickRollWeightsFile = "neural_weights_stick_roll.json"
+
 // Neural Network initialization
 func (team3 *Team3Agent) initializeNeuralNetworkStickRoll() {
-	weightsFile := "neural_weights_stick_roll.json"
-
 	// Try to load existing weights
-	if weights, err := loadWeights(weightsFile); err == nil {
+	if weights, err := loadStickRollWeights(); err == nil {
 		// Use existing weights
 		team3.inputWeights = sliceToMatrix(weights.InputWeights)
 		team3.outputWeights = sliceToMatrix(weights.OutputWeights)
@@ -161,14 +188,14 @@ func (team3 *Team3Agent) initializeNeuralNetworkStickRoll() {
 		// Random initialization
 		for i := 0; i < 2; i++ {
 			for j := 0; j < 36; j++ {
-				team3.inputWeights.Set(i, j, rand.Float64()*2-1)
+				team3.inputWeights.Set(i, j, team3.rng.Float64()*2-1)
 			}
 		}
 		for i := 0; i < 36; i++ {
-			team3.outputWeights.Set(i, 0, rand.Float64()*2-1)
-			team3.hiddenBias.Set(0, i, rand.Float64()*2-1)
+			team3.outputWeights.Set(i, 0, team3.rng.Float64()*2-1)
+			team3.hiddenBias.Set(0, i, team3.rng.Float64()*2-1)
 		}
-		team3.outputBias.Set(0, 0, rand.Float64()*2-1)
+		team3.outputBias.Set(0, 0, team3.rng.Float64()*2-1)
 	}
 }
 
@@ -184,7 +211,8 @@ func (team3 *Team3Agent) MLModelPredictStickRoll(currentScore, previousRoll int)
 	if normalizedScore > 1.0 {
 		normalizedScore = 1.0
 	}
-	normalizedRoll := float64(previousRoll) / 18.0 // Three dice have max value of 18
+	maxRoll := max(team3.Server.GetDiceRules(team3.GetID()).MaxRoll(), 1)
+	normalizedRoll := float64(previousRoll) / float64(maxRoll) // 18 for the standard three dice
 
 	// Create input matrix
 	input := mat.NewDense(1, 2, []float64{normalizedScore, normalizedRoll})
@@ -223,7 +251,7 @@ func (team3 *Team3Agent) DecideTeamForming(agentInfoList []common.ExposedAgentIn
 
 	if len(invitationList) > 0 {
 		// Randomly choose an agent to invite
-		rand.Shuffle(len(invitationList), func(i, j int) {
+		team3.rng.Shuffle(len(invitationList), func(i, j int) {
 			invitationList[i], invitationList[j] = invitationList[j], invitationList[i]
 		})
 		chosenAgent := invitationList[0]
@@ -287,57 +315,32 @@ type TrainingData struct {
 	Reward       float64 // Add reward field
 }
 
-// StartRollingDice custom function: ask for rolling the dice
-func (team3 *Team3Agent) StartRollingDice(instance common.IExtendedAgent) {
-	if team3.VerboseLevel > 10 {
-		fmt.Printf("%s is rolling the Dice\n", team3.GetID())
-	}
-	if team3.VerboseLevel > 9 {
-		fmt.Println("---------------------")
+// HandleDiceRoll custom function: remember every roll the server makes for us
+func (team3 *Team3Agent) HandleDiceRoll(roll common.DiceRoll) {
+	if roll.Throw == 1 {
+		if team3.VerboseLevel > 9 {
+			fmt.Println("---------------------")
+		}
+		team3.Bust = false // Initialize Bust status
 	}
-	team3.LastScore = -1
-	rounds := 1
-	turnScore := 0
-
-	willStick := false
-	team3.Bust = false // Initialize Bust status
 
-	// Loop until not stick
-	for !willStick {
-		// Simulate rolling the dice
-		currentScore := Roll3Dice()
+	// Add this roll to history
+	team3.RollHistory = append(team3.RollHistory, roll.Roll)
 
-		// Add this roll to history
-		team3.RollHistory = append(team3.RollHistory, currentScore)
-
-		// Check if currentScore is higher than lastScore
-		if currentScore > team3.LastScore {
-			turnScore += currentScore
-			team3.LastScore = currentScore
-			willStick = team3.StickOrAgain(0, 0)
-
-			if willStick {
-				team3.DecideStick()
-				break
-			}
-			team3.DecideRollAgain()
-		} else {
-			team3.Bust = true // Set bust status
-			if team3.VerboseLevel > 4 {
-				fmt.Printf("%s *BURSTED!* round: %v, current score: %v\n", team3.GetID(), rounds, currentScore)
-			}
-			turnScore = 0
-			break
+	if roll.Bust {
+		team3.Bust = true // Set bust status
+		if team3.VerboseLevel > 4 {
+			fmt.Printf("%s *BURSTED!* round: %v, current score: %v\n", team3.GetID(), roll.Throw, roll.Roll)
 		}
-
-		rounds++
+		return
 	}
+	team3.LastScore = roll.Roll
+}
 
-	// Add turn score to total score
-	team3.Score += turnScore
-
+// HandleRollingComplete custom function: learn from how the turn went
+func (team3 *Team3Agent) HandleRollingComplete(turnScore int, score int) {
 	if team3.VerboseLevel > 4 {
-		fmt.Printf("%s's turn score: %v, total score: %v\n", team3.GetID(), turnScore, team3.Score)
+		fmt.Printf("%s's turn score: %v, total score: %v\n", team3.GetID(), turnScore, score)
 	}
 
 	// After the turn is complete, calculate reward and train the model
@@ -434,8 +437,10 @@ func (team3 *Team3Agent) trainModelStickRoll() {
 	}
 
 	// Save weights after training
-	if err := saveWeights("neural_weights_stick_roll.json", team3); err != nil {
-		fmt.Printf("Error saving weights: %v\n", err)
+	if !common.IsSeeded() {
+		if err := saveWeights(stickRollWeightsFile, team3); err != nil {
+			fmt.Printf("Error saving weights: %v\n", err)
+		}
 	}
 }
 
@@ -471,8 +476,9 @@ func (team3 *Team3Agent) DecidePunishment() int {
 
 // ----------------------- Memory Management -----------------------
 
-// Update agent memory with lies about contributions - only when auditing
-func (team3 *Team3Agent) UpdateContributionLies(agentID uuid.UUID) {
+// Update agent memory with lies about contributions - only when auditing. Other
+// agents' actual contributions are private, so this goes on what they stated.
+func (team3 *Team3Agent) UpdateContributionLies(agentID uuid.UUID, statedContribution int) {
 	if !team3.HasTeam() || !team3.DecideAudit() {
 		return
 	}
@@ -480,17 +486,17 @@ func (team3 *Team3Agent) UpdateContributionLies(agentID uuid.UUID) {
 	log.Printf("DEBUG [AUDIT START]: Agent %s is auditing Agent %s\n",
 		team3.GetID(), agentID)
 
-	expectedContribution := team3.Server.GetTeam(agentID).TeamAoA.GetExpectedContribution(agentID, team3.GetTrueScore())
-	actualContribution := team3.Server.AccessAgentByID(agentID).GetActualContribution(team3)
+	expectedContribution := team3.Server.GetTeam(agentID).GetExpectedContribution(agentID, team3.GetTrueScore())
 
-	// Record lie only when actual is less than expected (agent contributed less than they should)
-	if actualContribution < expectedContribution {
-		lieAmount := expectedContribution - actualContribution
+	// Record lie only when stated is less than expected (agent contributed less than they should)
+	if statedContribution < expectedContribution {
+		lieAmount := expectedContribution - statedContribution
 		team3.contributionLies[agentID] = lieAmount
 		team3.numberOfLies[agentID]++
+		team3.blowWhistle(agentID, common.ContributionPhase, statedContribution, expectedContribution)
 
-		log.Printf("DEBUG [AUDIT]: Agent %s LIED on contribution! Expected: %d, Actual: %d, Lie Amount: %d\n",
-			agentID, expectedContribution, actualContribution, lieAmount)
+		log.Printf("DEBUG [AUDIT]: Agent %s LIED on contribution! Expected: %d, Stated: %d, Lie Amount: %d\n",
+			agentID, expectedContribution, statedContribution, lieAmount)
 	} else {
 		// Reward honest contribution in memory
 		currentScore := team3.GetAgentMemoryScore(agentID)
@@ -520,8 +526,9 @@ func (team3 *Team3Agent) SetAgentMemoryScore(agentID uuid.UUID, score int) {
 	}
 }
 
-// Update agent memory with lies about withdrawals - only when auditing
-func (team3 *Team3Agent) UpdateWithdrawalLies(agentID uuid.UUID) {
+// Update agent memory with lies about withdrawals - only when auditing. As with
+// contributions, this goes on what the agent stated it withdrew.
+func (team3 *Team3Agent) UpdateWithdrawalLies(agentID uuid.UUID, statedWithdrawal int) {
 	if !team3.HasTeam() || !team3.DecideAudit() {
 		return // Only proceed if we have a team and decide to audit
 	}
@@ -529,24 +536,33 @@ func (team3 *Team3Agent) UpdateWithdrawalLies(agentID uuid.UUID) {
 	log.Printf("DEBUG [AUDIT START]: Agent %s is auditing Agent %s\n",
 		team3.GetID(), agentID)
 
-	commonPool := team3.Server.GetTeam(agentID).GetCommonPool()
-	expectedWithdrawal := team3.Server.GetTeam(agentID).TeamAoA.GetExpectedWithdrawal(agentID, team3.GetTrueScore(), commonPool)
-	actualWithdrawal := team3.Server.AccessAgentByID(agentID).GetActualWithdrawal(team3)
+	commonPool := team3.Server.GetTeam(agentID).CommonPool
+	expectedWithdrawal := team3.Server.GetTeam(agentID).GetExpectedWithdrawal(agentID, team3.GetTrueScore(), commonPool)
 
-	// Record lie only when actual is more than expected (agent withdrew more than allowed)
-	if actualWithdrawal > expectedWithdrawal {
-		lieAmount := actualWithdrawal - expectedWithdrawal
+	// Record lie only when stated is more than expected (agent withdrew more than allowed)
+	if statedWithdrawal > expectedWithdrawal {
+		lieAmount := statedWithdrawal - expectedWithdrawal
 		team3.withdrawalLies[agentID] = lieAmount
 		team3.numberOfLies[agentID]++
+		team3.blowWhistle(agentID, common.WithdrawalPhase, statedWithdrawal, expectedWithdrawal)
 
-		log.Printf("DEBUG [AUDIT]: Agent %s LIED on withdrawal! Expected: %d, Actual: %d, Lie Amount: %d\n",
-			agentID, expectedWithdrawal, actualWithdrawal, lieAmount)
+		log.Printf("DEBUG [AUDIT]: Agent %s LIED on withdrawal! Expected: %d, Stated: %d, Lie Amount: %d\n",
+			agentID, expectedWithdrawal, statedWithdrawal, lieAmount)
 	} else {
-		log.Printf("DEBUG [AUDIT]: Agent %s honest on withdrawal. Expected: %d, Actual: %d\n",
-			agentID, expectedWithdrawal, actualWithdrawal)
+		log.Printf("DEBUG [AUDIT]: Agent %s honest on withdrawal. Expected: %d, Stated: %d\n",
+			agentID, expectedWithdrawal, statedWithdrawal)
 	}
 }
 
+// Report a lie to the team's AoA, which decides whether to audit the liar
+func (team3 *Team3Agent) blowWhistle(agentID uuid.UUID, phase common.AuditPhase, stated int, expected int) {
+	if agentID == team3.GetID() {
+		return
+	}
+	evidence := common.WhistleblowerEvidence{StatedAmount: stated, ExpectedAmount: expected}
+	team3.Server.ReportCheating(team3.CreateWhistleblowerMessage(agentID, phase, evidence))
+}
+
 // DEBUGGING bellow
 // Add this debug function to print lies
 func (team3 *Team3Agent) PrintLies() {
@@ -565,14 +581,15 @@ func (team3 *Team3Agent) PrintLies() {
 
 // Add this to HandleContributionMessage
 func (team3 *Team3Agent) HandleContributionMessage(msg *common.ContributionMessage) {
-	team3.UpdateContributionLies(msg.GetSender())
+	team3.statedContributions[msg.GetSender()] = msg.StatedAmount
+	team3.UpdateContributionLies(msg.GetSender(), msg.StatedAmount)
 	team3.PrintLies()         // Print after updating contribution lies
 	team3.PrintMemoryReport() // Add memory report after each contribution
 }
 
 // Add this to HandleWithdrawalMessage
 func (team3 *Team3Agent) HandleWithdrawalMessage(msg *common.WithdrawalMessage) {
-	team3.UpdateWithdrawalLies(msg.GetSender())
+	team3.UpdateWithdrawalLies(msg.GetSender(), msg.StatedAmount)
 	team3.PrintLies()         // Print after updating withdrawal lies
 	team3.PrintMemoryReport() // Add memory report after each withdrawal
 }
@@ -786,16 +803,16 @@ type CheatRecord struct {
 }
 
 // Add these new methods
-func NewNeuralNetwork(inputSize, hiddenSize int) *NeuralNetwork {
+func NewNeuralNetwork(rng *rand.Rand, inputSize, hiddenSize int) *NeuralNetwork {
 	// Initialize weights with random values
 	w1Data := make([]float64, inputSize*hiddenSize)
 	w2Data := make([]float64, hiddenSize)
 
 	for i := range w1Data {
-		w1Data[i] = rand.Float64()*2 - 1
+		w1Data[i] = rng.Float64()*2 - 1
 	}
 	for i := range w2Data {
-		w2Data[i] = rand.Float64()*2 - 1
+		w2Data[i] = rng.Float64()*2 - 1
 	}
 
 	return &NeuralNetwork{
@@ -846,7 +863,7 @@ func (team3 *Team3Agent) GetActualContribution(instance common.IExtendedAgent) i
 		return 0
 	}
 
-	expectedContribution := team3.Server.GetTeam(team3.GetID()).TeamAoA.GetExpectedContribution(team3.GetID(), team3.GetTrueScore())
+	expectedContribution := team3.Server.GetTeam(team3.GetID()).GetExpectedContribution(team3.GetID(), team3.GetTrueScore())
 
 	// Get cheat probability from neural network
 	cheatInputs := team3.prepareCheatInputs()
@@ -876,7 +893,7 @@ func (team3 *Team3Agent) GetActualContribution(instance common.IExtendedAgent) i
 	// Decide whether to cheat based on probability
 	if cheatProbability > 0.5 {
 		// Cheat by contributing less
-		actualContribution := int(float64(expectedContribution) * (0.5 + (rand.Float64() * 0.3)))
+		actualContribution := int(float64(expectedContribution) * (0.5 + (team3.rng.Float64() * 0.3)))
 		if actualContribution > team3.Score {
 			actualContribution = team3.Score
 		}
@@ -942,16 +959,16 @@ type CheatNeuralNetwork struct {
 }
 
 // Add these new methods
-func NewCheatNeuralNetwork(inputSize, hiddenSize int) *CheatNeuralNetwork {
+func NewCheatNeuralNetwork(rng *rand.Rand, inputSize, hiddenSize int) *CheatNeuralNetwork {
 	// Initialize weights with random values
 	w1Data := make([]float64, inputSize*hiddenSize)
 	w2Data := make([]float64, hiddenSize)
 
 	for i := range w1Data {
-		w1Data[i] = rand.Float64()*2 - 1
+		w1Data[i] = rng.Float64()*2 - 1
 	}
 	for i := range w2Data {
-		w2Data[i] = rand.Float64()*2 - 1
+		w2Data[i] = rng.Float64()*2 - 1
 	}
 
 	return &CheatNeuralNetwork{
@@ -976,15 +993,22 @@ func (nn *CheatNeuralNetwork) Forward(inputs []float64) float64 {
 	return nn.outputLayer.At(0, 0)
 }
 
+// Other agents' scores are private, so the inequality in the team is judged by
+// the contributions teammates have stated, which AoAs mostly tie to score
 func calculateGiniIndex(team3 *Team3Agent) float64 {
 	team := team3.Server.GetTeam(team3.GetID())
-	if team == nil || len(team.Agents) < 2 {
+	if !team.Exists() || len(team.Agents) < 2 {
 		return 0.0
 	}
 
-	scores := make([]float64, len(team.Agents))
-	for i, agentID := range team.Agents {
-		scores[i] = float64(team3.Server.AccessAgentByID(agentID).GetTrueScore())
+	scores := []float64{}
+	for _, agentID := range team.Agents {
+		if stated, known := team3.statedContributions[agentID]; known {
+			scores = append(scores, float64(stated))
+		}
+	}
+	if len(scores) < 2 {
+		return 0.0
 	}
 
 	mean := 0.0
@@ -992,6 +1016,9 @@ func calculateGiniIndex(team3 *Team3Agent) float64 {
 		mean += score
 	}
 	mean /= float64(len(scores))
+	if mean == 0 {
+		return 0.0
+	}
 
 	sumDiffs := 0.0
 	for i := range scores {
@@ -1002,3 +1029,141 @@ func calculateGiniIndex(team3 *Team3Agent) float64 {
 
 	return sumDiffs / (2 * float64(len(scores)) * mean)
 }
+
+// Like matrixToSlice, but keeps weights that have become NaN
+func snapshotMatrix(m *mat.Dense) [][]common.SnapshotFloat {
+	rows := [][]common.SnapshotFloat{}
+	for _, row := range matrixToSlice(m) {
+		rows = append(rows, common.SnapshotFloats(row))
+	}
+	return rows
+}
+
+func restoreMatrix(rows [][]common.SnapshotFloat) *mat.Dense {
+	slice := [][]float64{}
+	for _, row := range rows {
+		slice = append(slice, common.RestoreFloats(row))
+	}
+	return sliceToMatrix(slice)
+}
+
+type cheatRecordState struct {
+	Inputs     []common.SnapshotFloat
+	Outcome    bool
+	WasAudited bool
+	Score      int
+	CommonPool int
+}
+
+type team3AgentState struct {
+	extendedAgentState
+	RollHistory          []int
+	Bust                 bool
+	TrainingHistory      []TrainingData
+	InputWeights         [][]common.SnapshotFloat
+	OutputWeights        [][]common.SnapshotFloat
+	HiddenBias           [][]common.SnapshotFloat
+	OutputBias           [][]common.SnapshotFloat
+	LearningRate         float64
+	ContributionLies     map[uuid.UUID]int
+	WithdrawalLies       map[uuid.UUID]int
+	NumberOfLies         map[uuid.UUID]int
+	StatedContributions  map[uuid.UUID]int
+	InvitationResponses  map[uuid.UUID]bool
+	InvitationsSent      map[uuid.UUID]bool
+	CheatWeights1        [][]common.SnapshotFloat
+	CheatWeights2        [][]common.SnapshotFloat
+	CheatHistory         []cheatRecordState
+	LastCheatProbability common.SnapshotFloat
+	WasAudited           bool
+	WasCaughtCheating    bool
+	CheatSuccessRate     common.SnapshotFloat
+	TotalAudits          int
+	SuccessfulCheats     int
+}
+
+func (team3 *Team3Agent) SnapshotState() (json.RawMessage, error) {
+	base, err := team3.baseState()
+	if err != nil {
+		return nil, err
+	}
+	state := team3AgentState{
+		extendedAgentState:   base,
+		RollHistory:          team3.RollHistory,
+		Bust:                 team3.Bust,
+		TrainingHistory:      team3.TrainingHistory,
+		InputWeights:         snapshotMatrix(team3.inputWeights),
+		OutputWeights:        snapshotMatrix(team3.outputWeights),
+		HiddenBias:           snapshotMatrix(team3.hiddenBias),
+		OutputBias:           snapshotMatrix(team3.outputBias),
+		LearningRate:         team3.learningRate,
+		ContributionLies:     team3.contributionLies,
+		WithdrawalLies:       team3.withdrawalLies,
+		NumberOfLies:         team3.numberOfLies,
+		StatedContributions:  team3.statedContributions,
+		InvitationResponses:  team3.invitationResponses,
+		InvitationsSent:      team3.invitationsSent,
+		CheatWeights1:        snapshotMatrix(team3.cheatNN.weights1),
+		CheatWeights2:        snapshotMatrix(team3.cheatNN.weights2),
+		CheatHistory:         make([]cheatRecordState, 0, len(team3.cheatHistory)),
+		LastCheatProbability: common.SnapshotFloat(team3.lastCheatProbability),
+		WasAudited:           team3.wasAudited,
+		WasCaughtCheating:    team3.wasCaughtCheating,
+		CheatSuccessRate:     common.SnapshotFloat(team3.cheatSuccessRate),
+		TotalAudits:          team3.totalAudits,
+		SuccessfulCheats:     team3.successfulCheats,
+	}
+	for _, record := range team3.cheatHistory {
+		state.CheatHistory = append(state.CheatHistory, cheatRecordState{
+			Inputs:     common.SnapshotFloats(record.inputs),
+			Outcome:    record.outcome,
+			WasAudited: record.wasAudited,
+			Score:      record.score,
+			CommonPool: record.commonPool,
+		})
+	}
+	return json.Marshal(state)
+}
+
+func (team3 *Team3Agent) RestoreState(data json.RawMessage) error {
+	var state team3AgentState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	team3.RollHistory = state.RollHistory
+	team3.Bust = state.Bust
+	team3.TrainingHistory = state.TrainingHistory
+	team3.inputWeights = restoreMatrix(state.InputWeights)
+	team3.outputWeights = restoreMatrix(state.OutputWeights)
+	team3.hiddenBias = restoreMatrix(state.HiddenBias)
+	team3.outputBias = restoreMatrix(state.OutputBias)
+	team3.learningRate = state.LearningRate
+	team3.contributionLies = state.ContributionLies
+	team3.withdrawalLies = state.WithdrawalLies
+	team3.numberOfLies = state.NumberOfLies
+	team3.statedContributions = state.StatedContributions
+	if team3.statedContributions == nil {
+		team3.statedContributions = make(map[uuid.UUID]int)
+	}
+	team3.invitationResponses = state.InvitationResponses
+	team3.invitationsSent = state.InvitationsSent
+	team3.cheatNN.weights1 = restoreMatrix(state.CheatWeights1)
+	team3.cheatNN.weights2 = restoreMatrix(state.CheatWeights2)
+	team3.cheatHistory = make([]CheatRecord, 0, len(state.CheatHistory))
+	for _, record := range state.CheatHistory {
+		team3.cheatHistory = append(team3.cheatHistory, CheatRecord{
+			inputs:     common.RestoreFloats(record.Inputs),
+			outcome:    record.Outcome,
+			wasAudited: record.WasAudited,
+			score:      record.Score,
+			commonPool: record.CommonPool,
+		})
+	}
+	team3.lastCheatProbability = float64(state.LastCheatProbability)
+	team3.wasAudited = state.WasAudited
+	team3.wasCaughtCheating = state.WasCaughtCheating
+	team3.cheatSuccessRate = float64(state.CheatSuccessRate)
+	team3.totalAudits = state.TotalAudits
+	team3.successfulCheats = state.SuccessfulCheats
+	return team3.restoreBaseState(state.extendedAgentState)
+}
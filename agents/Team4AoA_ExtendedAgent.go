@@ -4,8 +4,6 @@ package agents
 
 import (
 	"log"
-	"math/rand"
-	"time"
 
 	"github.com/google/uuid"
 
@@ -19,8 +17,7 @@ func (mi *ExtendedAgent) Team4_GetRankUpVote() map[uuid.UUID]int {
 }
 
 func (mi *ExtendedAgent) Team4_GetConfession() bool {
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
-	return rng.Intn(2) == 1
+	return mi.rng.Intn(2) == 1
 }
 
 func (mi *ExtendedAgent) Team4_StateConfessionToTeam() {
@@ -50,7 +47,7 @@ func (mi *ExtendedAgent) Team4_GetProposedWithdrawalVote() map[uuid.UUID]int {
 	proposedWithdrawals := make(map[uuid.UUID]int)
 
 	for _, agentId := range agentsInTeam {
-		proposedWithdrawals[agentId] = rand.Intn(2)
+		proposedWithdrawals[agentId] = mi.rng.Intn(2)
 	}
 
 	log.Println(proposedWithdrawals)
@@ -78,16 +75,13 @@ func (mi *ExtendedAgent) Team4_ProposeWithdrawal() int {
 	if !mi.HasTeam() {
 		return 0
 	}
-	if mi.Server.GetTeam(mi.GetID()).TeamAoA != nil {
+	if mi.Server.GetTeam(mi.GetID()).Exists() {
 		// double check if score in agent is sufficient (this should be handled by AoA though)
-		commonPool := mi.Server.GetTeam(mi.GetID()).GetCommonPool()
-		aoaExpectedWithdrawal := mi.Server.GetTeam(mi.GetID()).TeamAoA.GetExpectedWithdrawal(mi.GetID(), mi.GetTrueScore(), commonPool)
-
-		// Seed the random generator
-		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		commonPool := mi.Server.GetTeam(mi.GetID()).CommonPool
+		aoaExpectedWithdrawal := mi.Server.GetTeam(mi.GetID()).GetExpectedWithdrawal(mi.GetID(), mi.GetTrueScore(), commonPool)
 
 		// Generate a random number between 0 and 10
-		randomAddition := rng.Intn(11) // Intn(11) generates a number in [0, 10]
+		randomAddition := mi.rng.Intn(11) // Intn(11) generates a number in [0, 10]
 		// Add the random number to the expected withdrawal
 		aoaExpectedWithdrawal += randomAddition
 		if commonPool < aoaExpectedWithdrawal {
@@ -114,7 +108,7 @@ func (mi *ExtendedAgent) Team4_GetPunishmentVoteMap() map[int]int {
 	punishmentVoteMap := make(map[int]int)
 
 	for punishment := 0; punishment <= 4; punishment++ {
-		punishmentVoteMap[punishment] = rand.Intn(5)
+		punishmentVoteMap[punishment] = mi.rng.Intn(5)
 	}
 
 	return punishmentVoteMap
@@ -0,0 +1,38 @@
+// Rebuild the HTML and CSV output of a game from its event log, without
+// running any agents:
+//
+//	go run ./cmd/replay -events visualization_output/events.jsonl
+package main
+
+import (
+	"flag"
+	"log"
+
+	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+)
+
+func main() {
+	log.SetFlags(0)
+
+	argEvents := flag.String("events", "visualization_output/events.jsonl", "Path to the event log of the game to replay")
+	argCSV := flag.String("csv", "visualization_output/csv_data", "Directory to write the CSV output to")
+	argHTML := flag.Bool("html", true, "Also write visualization_output/game_visualization.html")
+	flag.Parse()
+
+	events, err := gameRecorder.ReadEventLog(*argEvents)
+	if err != nil {
+		log.Fatalf("Failed to read event log: %v", err)
+	}
+	recorder, err := gameRecorder.Replay(events)
+	if err != nil {
+		log.Fatalf("Failed to replay %s: %v", *argEvents, err)
+	}
+	log.Printf("Replayed %d events into %d turn records\n", len(events), len(recorder.TurnRecords))
+
+	if err := gameRecorder.ExportToCSV(recorder, *argCSV); err != nil {
+		log.Fatalf("Failed to export CSV: %v", err)
+	}
+	if *argHTML {
+		gameRecorder.CreatePlaybackHTML(recorder)
+	}
+}
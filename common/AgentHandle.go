@@ -0,0 +1,402 @@
+package common
+
+import (
+	"fmt"
+
+	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+
+	"github.com/MattSScott/basePlatformSOMAS/v2/pkg/agent"
+	"github.com/MattSScott/basePlatformSOMAS/v2/pkg/message"
+	"github.com/google/uuid"
+)
+
+/*
+* What an agent gets back when it looks up another agent (AccessAgentByID on
+* the server it was created with). It exposes the other agent's ID and public
+* profile, and nothing else: its score, its decisions and its messaging are
+* private to it, and calling any of them panics. To talk to the agent, send it
+* a message addressed to its ID.
+*
+* The server functions are those of the agent holding the handle, so looking
+* up an agent through the handle also gives a handle.
+ */
+type AgentHandle struct {
+	agent.IExposedServerFunctions[IExtendedAgent]
+	agent IExtendedAgent
+}
+
+// Wrap an agent so that it can be handed to other agents. Returns nil for a
+// nil agent, so that lookups of unknown agents still return nil.
+func NewAgentHandle(target IExtendedAgent, serv agent.IExposedServerFunctions[IExtendedAgent]) IExtendedAgent {
+	if target == nil {
+		return nil
+	}
+	if handle, ok := target.(*AgentHandle); ok {
+		target = handle.agent
+	}
+	return &AgentHandle{IExposedServerFunctions: serv, agent: target}
+}
+
+func (h *AgentHandle) private(method string) {
+	panic(fmt.Sprintf("agent %v: %s is private, other agents are only given its public handle", h.agent.GetID(), method))
+}
+
+// --------- Public profile ---------
+
+func (h *AgentHandle) GetID() uuid.UUID {
+	return h.agent.GetID()
+}
+
+func (h *AgentHandle) GetTeamID() uuid.UUID {
+	return h.agent.GetTeamID()
+}
+
+func (h *AgentHandle) HasTeam() bool {
+	return h.agent.HasTeam()
+}
+
+func (h *AgentHandle) GetName() int {
+	return h.agent.GetName()
+}
+
+func (h *AgentHandle) GetExposedInfo() ExposedAgentInfo {
+	return h.agent.GetExposedInfo()
+}
+
+// --------- Private to the agent ---------
+
+func (h *AgentHandle) CreateBaseMessage() message.BaseMessage {
+	h.private("CreateBaseMessage")
+	return message.BaseMessage{}
+}
+
+func (h *AgentHandle) SendMessage(msg message.IMessage[IExtendedAgent], recipient uuid.UUID) {
+	h.private("SendMessage")
+}
+
+func (h *AgentHandle) SendSynchronousMessage(msg message.IMessage[IExtendedAgent], recipient uuid.UUID) {
+	h.private("SendSynchronousMessage")
+}
+
+func (h *AgentHandle) BroadcastMessage(msg message.IMessage[IExtendedAgent]) {
+	h.private("BroadcastMessage")
+}
+
+func (h *AgentHandle) BroadcastSynchronousMessage(msg message.IMessage[IExtendedAgent]) {
+	h.private("BroadcastSynchronousMessage")
+}
+
+func (h *AgentHandle) SignalMessagingComplete() {
+	h.private("SignalMessagingComplete")
+}
+
+func (h *AgentHandle) GetLastTeamID() uuid.UUID {
+	h.private("GetLastTeamID")
+	return uuid.Nil
+}
+
+func (h *AgentHandle) GetTrueScore() int {
+	h.private("GetTrueScore")
+	return 0
+}
+
+func (h *AgentHandle) StartTeamForming(instance IExtendedAgent, agentInfoList []ExposedAgentInfo) {
+	h.private("StartTeamForming")
+}
+
+func (h *AgentHandle) GetActualContribution(instance IExtendedAgent) int {
+	h.private("GetActualContribution")
+	return 0
+}
+
+func (h *AgentHandle) GetActualWithdrawal(instance IExtendedAgent) int {
+	h.private("GetActualWithdrawal")
+	return 0
+}
+
+func (h *AgentHandle) GetStatedContribution(instance IExtendedAgent) int {
+	h.private("GetStatedContribution")
+	return 0
+}
+
+func (h *AgentHandle) GetStatedWithdrawal(instance IExtendedAgent) int {
+	h.private("GetStatedWithdrawal")
+	return 0
+}
+
+func (h *AgentHandle) GetLeaveOpinion(agentID uuid.UUID) bool {
+	h.private("GetLeaveOpinion")
+	return false
+}
+
+func (h *AgentHandle) SetName(name int) {
+	h.private("SetName")
+}
+
+func (h *AgentHandle) SetTeamID(teamID uuid.UUID) {
+	h.private("SetTeamID")
+}
+
+func (h *AgentHandle) SetTrueScore(score int) {
+	h.private("SetTrueScore")
+}
+
+func (h *AgentHandle) SetAgentContributionAuditResult(agentID uuid.UUID, result bool) {
+	h.private("SetAgentContributionAuditResult")
+}
+
+func (h *AgentHandle) SetAgentWithdrawalAuditResult(agentID uuid.UUID, result bool) {
+	h.private("SetAgentWithdrawalAuditResult")
+}
+
+func (h *AgentHandle) DecideStick() {
+	h.private("DecideStick")
+}
+
+func (h *AgentHandle) DecideRollAgain() {
+	h.private("DecideRollAgain")
+}
+
+func (h *AgentHandle) DecideTeamForming(agentInfoList []ExposedAgentInfo) []uuid.UUID {
+	h.private("DecideTeamForming")
+	return nil
+}
+
+func (h *AgentHandle) StickOrAgain(accumulatedScore int, prevRoll int) bool {
+	h.private("StickOrAgain")
+	return false
+}
+
+func (h *AgentHandle) VoteOnAgentEntry(candidateID uuid.UUID) bool {
+	h.private("VoteOnAgentEntry")
+	return false
+}
+
+func (h *AgentHandle) StickOrAgainFor(agentId uuid.UUID, accumulatedScore int, prevRoll int) int {
+	h.private("StickOrAgainFor")
+	return 0
+}
+
+func (h *AgentHandle) HandleTeamFormationMessage(msg *TeamFormationMessage) {
+	h.private("HandleTeamFormationMessage")
+}
+
+func (h *AgentHandle) HandleDiceRoll(roll DiceRoll) {
+	h.private("HandleDiceRoll")
+}
+
+func (h *AgentHandle) HandleRollingComplete(turnScore int, score int) {
+	h.private("HandleRollingComplete")
+}
+
+func (h *AgentHandle) HandleScoreReportMessage(msg *ScoreReportMessage) {
+	h.private("HandleScoreReportMessage")
+}
+
+func (h *AgentHandle) HandleWithdrawalMessage(msg *WithdrawalMessage) {
+	h.private("HandleWithdrawalMessage")
+}
+
+func (h *AgentHandle) BroadcastSyncMessageToTeam(msg message.IMessage[IExtendedAgent]) {
+	h.private("BroadcastSyncMessageToTeam")
+}
+
+func (h *AgentHandle) HandleContributionMessage(msg *ContributionMessage) {
+	h.private("HandleContributionMessage")
+}
+
+func (h *AgentHandle) HandleAgentOpinionRequestMessage(msg *AgentOpinionRequestMessage) {
+	h.private("HandleAgentOpinionRequestMessage")
+}
+
+func (h *AgentHandle) HandleAgentOpinionResponseMessage(msg *AgentOpinionResponseMessage) {
+	h.private("HandleAgentOpinionResponseMessage")
+}
+
+func (h *AgentHandle) StateContributionToTeam(instance IExtendedAgent) {
+	h.private("StateContributionToTeam")
+}
+
+func (h *AgentHandle) StateWithdrawalToTeam(instance IExtendedAgent) {
+	h.private("StateWithdrawalToTeam")
+}
+
+func (h *AgentHandle) CreateScoreReportMessage() *ScoreReportMessage {
+	h.private("CreateScoreReportMessage")
+	return nil
+}
+
+func (h *AgentHandle) CreateContributionMessage(statedAmount int) *ContributionMessage {
+	h.private("CreateContributionMessage")
+	return nil
+}
+
+func (h *AgentHandle) CreateWithdrawalMessage(statedAmount int) *WithdrawalMessage {
+	h.private("CreateWithdrawalMessage")
+	return nil
+}
+
+func (h *AgentHandle) CreateWhistleblowerMessage(accused uuid.UUID, phase AuditPhase, evidence WhistleblowerEvidence) *WhistleblowerMessage {
+	h.private("CreateWhistleblowerMessage")
+	return nil
+}
+
+func (h *AgentHandle) CreateAgentOpinionRequestMessage(agentID uuid.UUID) *AgentOpinionRequestMessage {
+	h.private("CreateAgentOpinionRequestMessage")
+	return nil
+}
+
+func (h *AgentHandle) CreateAgentOpinionResponseMessage(opinion Opinion) *AgentOpinionResponseMessage {
+	h.private("CreateAgentOpinionResponseMessage")
+	return nil
+}
+
+func (h *AgentHandle) LogSelfInfo() {
+	h.private("LogSelfInfo")
+}
+
+func (h *AgentHandle) GetAoARanking() []int {
+	h.private("GetAoARanking")
+	return nil
+}
+
+func (h *AgentHandle) SetAoARanking(Preferences []int) {
+	h.private("SetAoARanking")
+}
+
+func (h *AgentHandle) GetContributionAuditVote() Vote {
+	h.private("GetContributionAuditVote")
+	return Vote{}
+}
+
+func (h *AgentHandle) GetWithdrawalAuditVote() Vote {
+	h.private("GetWithdrawalAuditVote")
+	return Vote{}
+}
+
+func (h *AgentHandle) GetLeaderBallot(candidates []uuid.UUID) []uuid.UUID {
+	h.private("GetLeaderBallot")
+	return nil
+}
+
+func (h *AgentHandle) GetRecallVote(leader uuid.UUID) bool {
+	h.private("GetRecallVote")
+	return false
+}
+
+func (h *AgentHandle) DecideAppeal(phase AuditPhase) bool {
+	h.private("DecideAppeal")
+	return false
+}
+
+func (h *AgentHandle) GetAppealVote(accused uuid.UUID, phase AuditPhase) bool {
+	h.private("GetAppealVote")
+	return false
+}
+
+func (h *AgentHandle) GetOpinions() []Opinion {
+	h.private("GetOpinions")
+	return nil
+}
+
+func (h *AgentHandle) GetTrust(subject uuid.UUID) float64 {
+	h.private("GetTrust")
+	return 0
+}
+
+func (h *AgentHandle) GetTrueSomasTeamID() int {
+	h.private("GetTrueSomasTeamID")
+	return 0
+}
+
+func (h *AgentHandle) Team4_GetRankUpVote() map[uuid.UUID]int {
+	h.private("Team4_GetRankUpVote")
+	return nil
+}
+
+func (h *AgentHandle) Team4_GetConfession() bool {
+	h.private("Team4_GetConfession")
+	return false
+}
+
+func (h *AgentHandle) Team4_GetProposedWithdrawalVote() map[uuid.UUID]int {
+	h.private("Team4_GetProposedWithdrawalVote")
+	return nil
+}
+
+func (h *AgentHandle) Team4_GetProposedWithdrawal(instance IExtendedAgent) int {
+	h.private("Team4_GetProposedWithdrawal")
+	return 0
+}
+
+func (h *AgentHandle) Team4_ProposeWithdrawal() int {
+	h.private("Team4_ProposeWithdrawal")
+	return 0
+}
+
+func (h *AgentHandle) Team4_StateProposalToTeam() {
+	h.private("Team4_StateProposalToTeam")
+}
+
+func (h *AgentHandle) Team4_CreateProposedWithdrawalMessage(statedAmount int) *Team4_ProposedWithdrawalMessage {
+	h.private("Team4_CreateProposedWithdrawalMessage")
+	return nil
+}
+
+func (h *AgentHandle) Team4_HandleProposedWithdrawalMessage(msg *Team4_ProposedWithdrawalMessage) {
+	h.private("Team4_HandleProposedWithdrawalMessage")
+}
+
+func (h *AgentHandle) Team4_StateConfessionToTeam() {
+	h.private("Team4_StateConfessionToTeam")
+}
+
+func (h *AgentHandle) Team4_CreateConfessionMessage(confession bool) *Team4_ConfessionMessage {
+	h.private("Team4_CreateConfessionMessage")
+	return nil
+}
+
+func (h *AgentHandle) Team4_HandleConfessionMessage(msg *Team4_ConfessionMessage) {
+	h.private("Team4_HandleConfessionMessage")
+}
+
+func (h *AgentHandle) Team4_GetPunishmentVoteMap() map[int]int {
+	h.private("Team4_GetPunishmentVoteMap")
+	return nil
+}
+
+func (h *AgentHandle) RecordAgentStatus(instance IExtendedAgent) gameRecorder.AgentRecord {
+	h.private("RecordAgentStatus")
+	return gameRecorder.AgentRecord{}
+}
+
+func (h *AgentHandle) Team1_ChairUpdateRanks(rankMap map[uuid.UUID]int) map[uuid.UUID]int {
+	h.private("Team1_ChairUpdateRanks")
+	return nil
+}
+
+func (h *AgentHandle) Team1_AgreeRankBoundaries() [5]int {
+	h.private("Team1_AgreeRankBoundaries")
+	return [5]int{}
+}
+
+func (h *AgentHandle) Team1_BoundaryProposalRequestHandler(msg *Team1RankBoundaryRequestMessage) {
+	h.private("Team1_BoundaryProposalRequestHandler")
+}
+
+func (h *AgentHandle) Team1_BoundaryProposalResponseHandler(msg *Team1RankBoundaryResponseMessage) {
+	h.private("Team1_BoundaryProposalResponseHandler")
+}
+
+func (h *AgentHandle) Team1_BoundaryBallotRequestHandler(msg *Team1BoundaryBallotRequestMessage) {
+	h.private("Team1_BoundaryBallotRequestHandler")
+}
+
+func (h *AgentHandle) Team1_BoundaryBallotResponseHandler(msg *Team1BoundaryBallotResponseMessage) {
+	h.private("Team1_BoundaryBallotResponseHandler")
+}
+
+func (h *AgentHandle) Team3_GetStrategyVote() []Strategy {
+	h.private("Team3_GetStrategyVote")
+	return nil
+}
@@ -0,0 +1,79 @@
+package common
+
+import "github.com/google/uuid"
+
+/*
+* Optional interfaces an AoA can implement to take part in a phase of the turn
+* beyond what IArticlesOfAssociation requires. The server checks for each of
+* these when it runs the phase, so an AoA only implements the ones its rules
+* need and every other AoA gets the same default behaviour.
+*
+* The phases of a turn, in order, are: leadership, roll, contribute,
+* post-contribution, contribution audit, withdraw, withdrawal audit,
+* whistleblowing, appeals, sanctions and rewards. AoAs take part in the
+* leadership phase through ILeaderAoA (see Leadership.go) and the
+* whistleblowing phase through IWhistleblowerAoA (see Whistleblowing.go).
+ */
+
+// Roll phase: another agent rolls the dice on behalf of a member
+type IRollControlAoA interface {
+	// Returns the agent that rolls for agentId this turn, if anyone
+	GetRollController(agentId uuid.UUID) (controller uuid.UUID, controlled bool)
+}
+
+// Withdraw phase: runs before any member withdraws from the common pool
+type IPreWithdrawalAoA interface {
+	RunPreWithdrawalAoaLogic(team *Team, agentMap map[uuid.UUID]IExtendedAgent)
+}
+
+// Withdraw phase: the most each member may withdraw this turn, which the
+// withdrawal audit holds them to. Agents can look it up through TeamView.
+type IWithdrawalEntitlementAoA interface {
+	// Returns the agent's entitlement, if the AoA has set one this turn
+	GetWithdrawalEntitlement(agentId uuid.UUID) (entitlement int, set bool)
+}
+
+// Audit phases: how good the AoA's audits are, see Audit.go. Recorded with
+// each audit the AoA runs.
+type IAuditQualityAoA interface {
+	GetAuditQuality() AuditQuality
+}
+
+// Audit phases: how often the AoA's audits accuse an agent they found nothing
+// on, which the server sets from the scenario when the team takes the AoA up
+type IAuditNoiseAoA interface {
+	SetFalsePositive(rate float64)
+}
+
+// Audit phases: runs once an audit has been paid for and its result is known,
+// before the result is broadcast to the team
+type IAuditObserverAoA interface {
+	OnAudit(server IAoAHookServer, team *Team, agentMap map[uuid.UUID]IExtendedAgent, agentId uuid.UUID, guilty bool)
+}
+
+// Appeals phase: the standing of each member, higher first. Juries chosen by
+// rank are made up of the highest ranked members, and teams whose AoA does not
+// rank them go by score.
+type IRankedAoA interface {
+	GetMemberRank(agentId uuid.UUID) int
+}
+
+// Appeals phase: runs when a jury overturns the verdict of the agent's audit
+// in the given phase, so the AoA can undo what it did when it found them
+// guilty. The server then tells the team the agent was found innocent.
+type IAppealAoA interface {
+	OnVerdictOverturned(server IAoAHookServer, team *Team, agentMap map[uuid.UUID]IExtendedAgent, agentId uuid.UUID, phase AuditPhase)
+}
+
+// Sanctions phase: the AoA sanctions agents through a SanctionEngine. The
+// server moves the engine on a turn before the phase and records the changes
+// it made after it.
+type ISanctionEngineAoA interface {
+	GetSanctionEngine() *SanctionEngine
+}
+
+// Rewards phase: the bonuses the team pays its members from the common pool
+// this turn, see Reward.go
+type IRewardsAoA interface {
+	GetRewards(team *Team, agentMap map[uuid.UUID]IExtendedAgent) []Reward
+}
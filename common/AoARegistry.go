@@ -0,0 +1,99 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+/*
+* Registry of every Articles of Association that teams can vote for. Each AoA
+* registers itself from an init function in the file that defines it, so the
+* server can create any of them from the ID that won the team's vote without
+* knowing about the concrete types. Adding a new AoA only needs a new file in
+* this package with a call to RegisterAoA.
+ */
+
+// Parameters passed to an AoA constructor, e.g. "auditDuration"
+type AoAParams map[string]int
+
+// Server functions available to an AoA's post-creation hook
+type IAoAHookServer interface {
+	IServer
+	ElectNewLeader(teamID uuid.UUID)
+	ReinstateLeader(teamID uuid.UUID, leaderID uuid.UUID)
+	GetSanctionLadder() SanctionLadder // the ladder set in the scenario, see SanctionEngine.go
+}
+
+type AoAEntry struct {
+	ID            int    // the number agents use to rank this AoA
+	Name          string // display name used in logs
+	Create        func(team *Team, params AoAParams) IArticlesOfAssociation
+	DefaultParams AoAParams
+	// Optional, run once the AoA has been assigned to the team
+	PostCreate func(server IAoAHookServer, team *Team)
+}
+
+// The AoA used by teams that have not (yet) voted for one
+const FixedAoAID = 0
+
+var (
+	aoaMutex    sync.RWMutex
+	aoaRegistry = make(map[int]AoAEntry)
+)
+
+// Make an AoA available under its ID. Panics if the ID is already taken, as
+// this is a programming error that should be caught at start up.
+func RegisterAoA(entry AoAEntry) {
+	if entry.Create == nil {
+		panic(fmt.Sprintf("common: AoA %d (%s) registered without a constructor", entry.ID, entry.Name))
+	}
+
+	aoaMutex.Lock()
+	defer aoaMutex.Unlock()
+	if existing, exists := aoaRegistry[entry.ID]; exists {
+		panic(fmt.Sprintf("common: AoA ID %d registered by both %s and %s", entry.ID, existing.Name, entry.Name))
+	}
+	aoaRegistry[entry.ID] = entry
+}
+
+func GetAoAEntry(id int) (AoAEntry, bool) {
+	aoaMutex.RLock()
+	defer aoaMutex.RUnlock()
+	entry, exists := aoaRegistry[id]
+	return entry, exists
+}
+
+// IDs of all registered AoAs, in ascending order
+func RegisteredAoAIDs() []int {
+	aoaMutex.RLock()
+	defer aoaMutex.RUnlock()
+	ids := make([]int, 0, len(aoaRegistry))
+	for id := range aoaRegistry {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// Create the AoA with its default parameters
+func (entry AoAEntry) New(team *Team) IArticlesOfAssociation {
+	params := make(AoAParams, len(entry.DefaultParams))
+	for name, value := range entry.DefaultParams {
+		params[name] = value
+	}
+	return entry.Create(team, params)
+}
+
+// Check that every AoA in a ranking ballot (as returned by GetAoARanking) has
+// been registered
+func ValidateAoARanking(ranking []int) error {
+	for position, id := range ranking {
+		if _, exists := GetAoAEntry(id); !exists {
+			return fmt.Errorf("AoA ranking %v: position %d is AoA %d, which does not exist (registered AoAs: %v)", ranking, position, id, RegisteredAoAIDs())
+		}
+	}
+	return nil
+}
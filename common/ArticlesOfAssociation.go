@@ -1,13 +1,19 @@
 package common
 
-import "github.com/google/uuid"
-import gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+import (
+	"slices"
+
+	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+	"github.com/google/uuid"
+)
 
 type Vote struct {
 	IsVote        int
 	VoterID       uuid.UUID
 	VotedForID    uuid.UUID
-	AuditDuration int
+	AuditDuration int // rounds the voter wants the audit to look back over, 0 for no preference
+	// Chance of finding each infraction the voter wants the audit to have, 0 for no preference
+	AuditDetection float64
 }
 
 type IArticlesOfAssociation interface {
@@ -21,12 +27,8 @@ type IArticlesOfAssociation interface {
 	SetContributionAuditResult(agentId uuid.UUID, agentScore int, agentActualContribution int, agentStatedContribution int)
 	GetWithdrawalOrder(agentIDs []uuid.UUID) []uuid.UUID
 	RunPreIterationAoaLogic(team *Team, agentMap map[uuid.UUID]IExtendedAgent, dataRecorder *gameRecorder.ServerDataRecorder)
-	GetPunishment(agentScore int, agentId uuid.UUID) int
-
-	// Team 4 AoA Specific Functions
-	Team4_SetRankUp(map[uuid.UUID]map[uuid.UUID]int)
-	Team4_RunProposedWithdrawalVote(map[uuid.UUID]int, map[uuid.UUID]map[uuid.UUID]int)
-	Team4_HandlePunishmentVote(map[uuid.UUID]map[int]int) int
+	// What to do to an agent that has been found guilty in an audit, see Sanction.go
+	GetSanction(agentId uuid.UUID, agentScore int) Sanction
 	RunPostContributionAoaLogic(team *Team, agentMap map[uuid.UUID]IExtendedAgent)
 	ResourceAllocation(agentScores map[uuid.UUID]int, remainingResources int) map[uuid.UUID]int
 }
@@ -38,3 +40,36 @@ func CreateVote(isVote int, voterId uuid.UUID, votedForId uuid.UUID) Vote {
 		VotedForID: votedForId,
 	}
 }
+
+// The vote, asking for an audit of the given lookback and detection, see AuditRecord.VoteOnQuality
+func (v Vote) WithAuditQuality(lookback int, detection float64) Vote {
+	v.AuditDuration = lookback
+	v.AuditDetection = detection
+	return v
+}
+
+// The votes cast to audit an agent, leaving out abstentions and votes for
+// nobody, and the agents they name in the order they were first voted for
+func auditBallots(votes []Vote) ([]Vote, []uuid.UUID) {
+	cast := []Vote{}
+	candidates := []uuid.UUID{}
+	for _, vote := range votes {
+		if vote.IsVote != 1 || vote.VotedForID == uuid.Nil {
+			continue
+		}
+		cast = append(cast, vote)
+		if !slices.Contains(candidates, vote.VotedForID) {
+			candidates = append(candidates, vote.VotedForID)
+		}
+	}
+	return cast, candidates
+}
+
+// Who each vote was cast for
+func votedFor(votes []Vote) []uuid.UUID {
+	choices := make([]uuid.UUID, len(votes))
+	for i, vote := range votes {
+		choices[i] = vote.VotedForID
+	}
+	return choices
+}
@@ -1,21 +1,127 @@
 package common
 
-import "github.com/google/uuid"
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/google/uuid"
+)
+
+/*
+* Audits are not oracles. How good an audit is, and so how much it costs, is
+* set by its AuditQuality: how many past rounds it looks at, how likely it is to
+* find each infraction there (the rest are false negatives) and how likely it
+* is to accuse an agent it found nothing on (a false positive). Members vote on
+* the lookback and detection they want along with who to audit, see
+* AuditRecord.VoteOnQuality.
+ */
+
+// How an audit decides what it has found
+type DetectionModel string
+
+const (
+	DetectEach    DetectionModel = "each"    // every infraction is found with probability Detection
+	DetectPoisson DetectionModel = "poisson" // the evidence found is Poisson distributed, Detection pieces for each infraction
+)
+
+var DetectionModels = []DetectionModel{DetectEach, DetectPoisson}
+
+type AuditQuality struct {
+	Lookback      int            // past rounds the audit looks at
+	Detection     float64        // chance of finding each infraction, between 0 and 1
+	FalsePositive float64        // chance of finding an infraction when nothing else was found, between 0 and 1
+	Model         DetectionModel // empty for DetectEach
+}
+
+// An audit that finds every infraction in the last lookback rounds and nothing else
+func PerfectAuditQuality(lookback int) AuditQuality {
+	return AuditQuality{Lookback: lookback, Detection: 1, Model: DetectEach}
+}
+
+// Check the quality is one an audit can have
+func (q AuditQuality) Validate() error {
+	switch {
+	case q.Lookback < 0:
+		return fmt.Errorf("audit lookback must not be negative, got %d", q.Lookback)
+	case q.Detection < 0 || q.Detection > 1:
+		return fmt.Errorf("audit detection must be between 0 and 1, got %v", q.Detection)
+	case q.FalsePositive < 0 || q.FalsePositive > 1:
+		return fmt.Errorf("audit false positive rate must be between 0 and 1, got %v", q.FalsePositive)
+	case q.Model != "" && q.Model != DetectEach && q.Model != DetectPoisson:
+		return fmt.Errorf("audit detection model must be one of %v, got %q", DetectionModels, q.Model)
+	}
+	return nil
+}
+
+// One per round looked at, for a perfect audit. Audits that miss infractions
+// or accuse the innocent are cheaper.
+func (q AuditQuality) Cost() int {
+	return int(math.Ceil(float64(q.Lookback) * q.Detection * (1 - q.FalsePositive)))
+}
+
+// Anything that draws uniformly from [0, 1), such as a *rand.Rand from either
+// math/rand or golang.org/x/exp/rand
+type RandFloat interface {
+	Float64() float64
+}
+
+// How many of the given infractions an audit of this quality finds. An audit
+// that finds none may still wrongly find one.
+func (q AuditQuality) Detect(infractions int, rng RandFloat) int {
+	found := 0
+	switch q.Model {
+	case DetectPoisson:
+		found = drawPoisson(q.Detection*float64(infractions), rng)
+	default:
+		for range infractions {
+			if drawChance(q.Detection, rng) {
+				found++
+			}
+		}
+	}
+	if found == 0 && drawChance(q.FalsePositive, rng) {
+		found = 1
+	}
+	return found
+}
+
+// Only draws when the outcome is in doubt, so perfect audits use no randomness
+func drawChance(p float64, rng RandFloat) bool {
+	if p <= 0 {
+		return false
+	}
+	if p >= 1 {
+		return true
+	}
+	return rng.Float64() < p
+}
+
+// Knuth's method, fine for the small rates audits deal in
+func drawPoisson(lambda float64, rng RandFloat) int {
+	if lambda <= 0 {
+		return 0
+	}
+	limit := math.Exp(-lambda)
+	count, product := 0, rng.Float64()
+	for product > limit {
+		count++
+		product *= rng.Float64()
+	}
+	return count
+}
 
 type AuditRecord struct {
 	auditMap map[uuid.UUID][]int
-	duration int
-	cost     int
-	// reliability float64
+	quality  AuditQuality
+	rng      *rand.Rand
 }
 
 func NewAuditRecord(duration int) *AuditRecord {
-	cost := calculateCost(duration)
-
 	return &AuditRecord{
 		auditMap: make(map[uuid.UUID][]int),
-		duration: duration,
-		cost:     cost,
+		quality:  PerfectAuditQuality(duration),
+		rng:      NewRandStream("audit"),
 	}
 }
 
@@ -25,21 +131,61 @@ func (a *AuditRecord) GetAuditMap() map[uuid.UUID][]int {
 }
 
 func (a *AuditRecord) GetAuditDuration() int {
-	return a.duration
+	return a.quality.Lookback
 }
 
 func (a *AuditRecord) GetAuditCost() int {
-	return a.cost
+	return a.quality.Cost()
+}
+
+func (a *AuditRecord) GetAuditQuality() AuditQuality {
+	return a.quality
 }
 
 // Setters
 func (a *AuditRecord) SetAuditDuration(duration int) {
-	a.duration, a.cost = duration, calculateCost(duration)
+	a.quality.Lookback = duration
 }
 
-// Implement a more sophisticated cost calculation if needed, could compound with reliability if implemented
-func calculateCost(duration int) int {
-	return duration
+// Panics if the quality is not valid, as AoAs set it in code
+func (a *AuditRecord) SetAuditQuality(quality AuditQuality) {
+	if err := quality.Validate(); err != nil {
+		panic(err)
+	}
+	a.quality = quality
+}
+
+// Panics if the rate is not between 0 and 1, see SetAuditQuality
+func (a *AuditRecord) SetFalsePositive(rate float64) {
+	quality := a.quality
+	quality.FalsePositive = rate
+	a.SetAuditQuality(quality)
+}
+
+/*
+* Set the lookback and detection of the next audit to the average of what the
+* voters asked for. Votes of 0 are no preference, and leave it as it was.
+* Requests for a detection over 1 are taken as 1.
+ */
+func (a *AuditRecord) VoteOnQuality(votes []Vote) {
+	lookback, lookbackVotes := 0, 0
+	detection, detectionVotes := 0.0, 0
+	for _, vote := range votes {
+		if vote.AuditDuration > 0 {
+			lookback += vote.AuditDuration
+			lookbackVotes++
+		}
+		if vote.AuditDetection > 0 {
+			detection += math.Min(vote.AuditDetection, 1)
+			detectionVotes++
+		}
+	}
+	if lookbackVotes > 0 {
+		a.quality.Lookback = lookback / lookbackVotes
+	}
+	if detectionVotes > 0 {
+		a.quality.Detection = detection / float64(detectionVotes)
+	}
 }
 
 // Get the number of infractions in the last n rounds, given by the quality of the audit
@@ -47,7 +193,7 @@ func (a *AuditRecord) GetAllInfractions(agentId uuid.UUID) int {
 	infractions := 0
 	records := a.auditMap[agentId]
 
-	history := min(a.duration, len(records))
+	history := min(a.quality.Lookback, len(records))
 
 	for _, infraction := range records[len(records)-history:] {
 		infractions += infraction
@@ -56,6 +202,12 @@ func (a *AuditRecord) GetAllInfractions(agentId uuid.UUID) int {
 	return infractions
 }
 
+// Audit the agent, returning how many infractions the audit finds. This is not
+// necessarily how many it committed, see AuditQuality.
+func (a *AuditRecord) Audit(agentId uuid.UUID) int {
+	return a.quality.Detect(a.GetAllInfractions(agentId), a.rng)
+}
+
 /**
 * Clear all infractions for a given agent
 * This may/may not be called in case the audit system is converted into a probability-based hybrid.
@@ -0,0 +1,217 @@
+package common
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+/*
+* The rules of the dice game. The server rolls by them, and agents can ask the
+* server for them (GetDiceRules) to work out their odds rather than assuming
+* three six-sided dice. The defaults are the original game: roll 3d6 as often
+* as you like, and go bust as soon as a throw is not higher than the last one.
+ */
+
+// When a throw loses everything rolled so far in the turn
+type BustRule string
+
+const (
+	BustNotHigher BustRule = "not-higher" // the throw is not higher than the previous one
+	BustOnFace    BustRule = "face"       // any die shows BustFace
+	BustOverCap   BustRule = "cap"        // the turn score would go over Cap
+)
+
+var BustRules = []BustRule{BustNotHigher, BustOnFace, BustOverCap}
+
+type DiceRules struct {
+	Count      int // dice thrown at once
+	Faces      int
+	Bonus      int // added to every throw
+	Bust       BustRule
+	BustFace   int // the face that busts, for BustOnFace
+	Cap        int // the most a turn can score, for BustOverCap
+	MaxRerolls int // how many times an agent may roll again after its first throw, -1 for no limit
+}
+
+// Changes to the rules for a single agent, e.g. to give it a handicap
+type DiceModifier struct {
+	ExtraDice int
+	Bonus     int
+}
+
+// The result of one throw of the dice, which the server sends to the agent it
+// rolled for. Agents do not roll for themselves, so these are the only rolls
+// that count towards their score.
+type DiceRoll struct {
+	Throw     int  // 1 for the first throw of the turn
+	Roll      int  // sum of the dice, plus any bonus
+	TurnScore int  // score built up this turn, 0 once bust
+	Bust      bool // the throw lost the turn score
+}
+
+func DefaultDiceRules() DiceRules {
+	return DiceRules{Count: 3, Faces: 6, Bust: BustNotHigher, BustFace: 1, MaxRerolls: -1}
+}
+
+// The reason a set of dice rules was rejected, naming the field responsible
+type DiceRulesError struct {
+	Field   string
+	Problem string
+}
+
+func (e *DiceRulesError) Error() string {
+	return fmt.Sprintf("dice rules: %s: %s", e.Field, e.Problem)
+}
+
+// Check the rules make for a game that can be played. Returns a *DiceRulesError.
+func (r DiceRules) Validate() error {
+	if r.Count < 1 || r.Count > 10 {
+		return &DiceRulesError{"count", fmt.Sprintf("must be between 1 and 10, got %d", r.Count)}
+	}
+	if r.Faces < 2 || r.Faces > 20 {
+		return &DiceRulesError{"faces", fmt.Sprintf("must be between 2 and 20, got %d", r.Faces)}
+	}
+	switch r.Bust {
+	case BustNotHigher:
+	case BustOnFace:
+		if r.BustFace < 1 || r.BustFace > r.Faces {
+			return &DiceRulesError{"bustFace", fmt.Sprintf("must be between 1 and %d, got %d", r.Faces, r.BustFace)}
+		}
+	case BustOverCap:
+		if r.Cap < 1 {
+			return &DiceRulesError{"cap", fmt.Sprintf("must be positive, got %d", r.Cap)}
+		}
+	default:
+		return &DiceRulesError{"bust", fmt.Sprintf("must be one of %v, got %q", BustRules, r.Bust)}
+	}
+	if r.Count+r.Bonus < 1 {
+		return &DiceRulesError{"bonus", fmt.Sprintf("must leave a throw of all ones scoring at least 1, got %d with %d dice", r.Bonus, r.Count)}
+	}
+	if r.MaxRerolls < -1 {
+		return &DiceRulesError{"maxRerolls", fmt.Sprintf("must be -1 (no limit) or more, got %d", r.MaxRerolls)}
+	}
+	return nil
+}
+
+// The rules with an agent's modifier applied
+func (r DiceRules) With(modifier DiceModifier) DiceRules {
+	r.Count += modifier.ExtraDice
+	r.Bonus += modifier.Bonus
+	return r
+}
+
+// Throw every die. Returns the roll (including the bonus) and the faces shown.
+func (r DiceRules) Throw(rng *rand.Rand) (int, []int) {
+	roll := r.Bonus
+	faces := make([]int, r.Count)
+	for i := range faces {
+		faces[i] = rng.Intn(r.Faces) + 1
+		roll += faces[i]
+	}
+	return roll, faces
+}
+
+// Whether a throw goes bust, given the turn score and previous throw before it
+// (-1 before the first throw)
+func (r DiceRules) IsBust(roll int, faces []int, accumulatedScore int, prevRoll int) bool {
+	switch r.Bust {
+	case BustOnFace:
+		for _, face := range faces {
+			if face == r.BustFace {
+				return true
+			}
+		}
+		return false
+	case BustOverCap:
+		return accumulatedScore+roll > r.Cap
+	default:
+		return roll <= prevRoll
+	}
+}
+
+// Whether the agent may roll again after the given number of throws
+func (r DiceRules) MayRollAgain(throws int) bool {
+	return r.MaxRerolls < 0 || throws <= r.MaxRerolls
+}
+
+func (r DiceRules) MinRoll() int {
+	return r.Count + r.Bonus
+}
+
+func (r DiceRules) MaxRoll() int {
+	return r.Count*r.Faces + r.Bonus
+}
+
+// One possible throw and how likely it is
+type DiceOutcome struct {
+	Roll        int
+	Probability float64
+	Bust        bool
+}
+
+/*
+* Every possible next throw, in ascending order of roll, and whether it would
+* go bust. Under BustOnFace a roll can appear twice: once for the ways of
+* making it without the bust face and once for the ways with it.
+ */
+func (r DiceRules) Outcomes(accumulatedScore int, prevRoll int) []DiceOutcome {
+	// ways[sum][shows bust face] of making each sum from the dice thrown so far
+	ways := [][2]int64{{1, 0}}
+	for die := 0; die < r.Count; die++ {
+		next := make([][2]int64, len(ways)+r.Faces)
+		for sum, count := range ways {
+			for face := 1; face <= r.Faces; face++ {
+				hit := r.Bust == BustOnFace && face == r.BustFace
+				next[sum+face][1] += count[1]
+				if hit {
+					next[sum+face][1] += count[0]
+				} else {
+					next[sum+face][0] += count[0]
+				}
+			}
+		}
+		ways = next
+	}
+
+	total := int64(1)
+	for die := 0; die < r.Count; die++ {
+		total *= int64(r.Faces)
+	}
+
+	outcomes := []DiceOutcome{}
+	for sum, count := range ways {
+		roll := sum + r.Bonus
+		if count[0] > 0 {
+			outcomes = append(outcomes, DiceOutcome{roll, float64(count[0]) / float64(total), r.Bust != BustOnFace && r.IsBust(roll, nil, accumulatedScore, prevRoll)})
+		}
+		if count[1] > 0 {
+			outcomes = append(outcomes, DiceOutcome{roll, float64(count[1]) / float64(total), true})
+		}
+	}
+	return outcomes
+}
+
+// Probability that the next throw does not go bust
+func (r DiceRules) ImprovementProbability(accumulatedScore int, prevRoll int) float64 {
+	probability := 0.0
+	for _, outcome := range r.Outcomes(accumulatedScore, prevRoll) {
+		if !outcome.Bust {
+			probability += outcome.Probability
+		}
+	}
+	return probability
+}
+
+// Expected change in the turn score from rolling again: what the throw adds if
+// it does not go bust, less the turn score lost if it does
+func (r DiceRules) ExpectedGain(accumulatedScore int, prevRoll int) float64 {
+	var pLoss, eGain float64
+	for _, outcome := range r.Outcomes(accumulatedScore, prevRoll) {
+		if outcome.Bust {
+			pLoss += outcome.Probability
+		} else {
+			eGain += outcome.Probability * float64(outcome.Roll)
+		}
+	}
+	return eGain + pLoss*float64(accumulatedScore)*-1
+}
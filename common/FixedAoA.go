@@ -1,8 +1,8 @@
 package common
 
 import (
+	"encoding/json"
 	"math/rand"
-	"time"
 
 	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
 	"github.com/google/uuid"
@@ -10,6 +10,7 @@ import (
 
 type FixedAoA struct {
 	auditRecord *AuditRecord
+	rng         *rand.Rand
 }
 
 func (f *FixedAoA) GetExpectedContribution(agentId uuid.UUID, agentScore int) int {
@@ -27,7 +28,7 @@ func (f *FixedAoA) SetContributionAuditResult(agentId uuid.UUID, agentScore int,
 
 func (f *FixedAoA) GetContributionAuditResult(agentId uuid.UUID) bool {
 	// true means agent failed the audit (cheated)
-	infractions := f.auditRecord.GetAllInfractions(agentId) > 0
+	infractions := f.auditRecord.Audit(agentId) > 0
 	f.auditRecord.ClearAllInfractions(agentId)
 	return infractions
 }
@@ -45,7 +46,7 @@ func (f *FixedAoA) SetWithdrawalAuditResult(agentId uuid.UUID, agentScore int, a
 
 func (f *FixedAoA) GetWithdrawalAuditResult(agentId uuid.UUID) bool {
 	// true means agent failed the audit (cheated)
-	infractions := f.auditRecord.GetAllInfractions(agentId) > 0
+	infractions := f.auditRecord.Audit(agentId) > 0
 	f.auditRecord.ClearAllInfractions(agentId)
 	return infractions
 }
@@ -54,6 +55,14 @@ func (f *FixedAoA) GetAuditCost(commonPool int) int {
 	return f.auditRecord.GetAuditCost()
 }
 
+func (f *FixedAoA) GetAuditQuality() AuditQuality {
+	return f.auditRecord.GetAuditQuality()
+}
+
+func (f *FixedAoA) SetFalsePositive(rate float64) {
+	f.auditRecord.SetFalsePositive(rate)
+}
+
 // MUST return UUID nil if audit should not be executed
 // Otherwise, implement a voting mechanism to determine the agent to be audited
 // and return its UUID
@@ -62,26 +71,17 @@ func (f *FixedAoA) GetVoteResult(votes []Vote) uuid.UUID {
 		return uuid.Nil
 	}
 
-	duration := 0
-	for _, vote := range votes {
-		duration += vote.AuditDuration
-	}
-	duration /= len(votes)
-
-	f.auditRecord.SetAuditDuration(duration)
+	f.auditRecord.VoteOnQuality(votes)
 	return uuid.Nil
 }
 
 func (t *FixedAoA) GetWithdrawalOrder(agentIDs []uuid.UUID) []uuid.UUID {
-	// Seed the random number generator to ensure different shuffles each time
-	rand.Seed(time.Now().UnixNano())
-
 	// Create a copy of the agentIDs to avoid modifying the original list
 	shuffledAgents := make([]uuid.UUID, len(agentIDs))
 	copy(shuffledAgents, agentIDs)
 
 	// Shuffle the agent list
-	rand.Shuffle(len(shuffledAgents), func(i, j int) {
+	t.rng.Shuffle(len(shuffledAgents), func(i, j int) {
 		shuffledAgents[i], shuffledAgents[j] = shuffledAgents[j], shuffledAgents[i]
 	})
 
@@ -92,6 +92,10 @@ func (t *FixedAoA) GetPunishment(agentScore int, agentId uuid.UUID) int {
 	return (agentScore * 25) / 100
 }
 
+func (t *FixedAoA) GetSanction(agentId uuid.UUID, agentScore int) Sanction {
+	return Sanction{Fine: t.GetPunishment(agentScore, agentId)}
+}
+
 func (t *FixedAoA) RunPreIterationAoaLogic(team *Team, agentMap map[uuid.UUID]IExtendedAgent, dataRecorder *gameRecorder.ServerDataRecorder) {
 }
 func (t *FixedAoA) RunPostContributionAoaLogic(team *Team, agentMap map[uuid.UUID]IExtendedAgent) {}
@@ -100,18 +104,43 @@ func (f *FixedAoA) ResourceAllocation(agentScores map[uuid.UUID]int, remainingRe
 	return make(map[uuid.UUID]int)
 }
 
+func init() {
+	RegisterAoA(AoAEntry{
+		ID:            FixedAoAID,
+		Name:          "Fixed",
+		DefaultParams: AoAParams{"duration": 1},
+		Create: func(team *Team, params AoAParams) IArticlesOfAssociation {
+			return CreateFixedAoA(params["duration"])
+		},
+	})
+}
+
 func CreateFixedAoA(duration int) IArticlesOfAssociation {
 	auditRecord := NewAuditRecord(duration)
 	return &FixedAoA{
 		auditRecord: auditRecord,
+		rng:         NewRandStream("aoa/fixed"),
 	}
 }
 
-// Do nothing
-func (t *FixedAoA) Team4_SetRankUp(rankUpVoteMap map[uuid.UUID]map[uuid.UUID]int) {
+type fixedAoAState struct {
+	AuditRecord *AuditRecord
+	Rand        RandState
 }
-func (t *FixedAoA) Team4_RunProposedWithdrawalVote(map[uuid.UUID]int, map[uuid.UUID]map[uuid.UUID]int) {
+
+func (f *FixedAoA) SnapshotState() (json.RawMessage, error) {
+	randState, err := GetRandState(f.rng)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(fixedAoAState{AuditRecord: f.auditRecord, Rand: randState})
 }
-func (t *FixedAoA) Team4_HandlePunishmentVote(map[uuid.UUID]map[int]int) int {
-	return 0
+
+func (f *FixedAoA) RestoreState(data json.RawMessage) error {
+	state := fixedAoAState{AuditRecord: f.auditRecord}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	f.auditRecord = state.AuditRecord
+	return SetRandState(f.rng, state.Rand)
 }
@@ -0,0 +1,107 @@
+package common
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+
+	voting "github.com/ADimoska/SOMASExtended/voting"
+)
+
+/*
+* The fixed AoA's rules on contributing and withdrawing, but agents that a
+* majority of the team votes to audit are audited, and those found guilty
+* climb the sanction ladder set in the scenario (see SanctionEngine.go) rather
+* than being fined a flat rate. Members can also report each other for cheating
+* (see Whistleblowing.go).
+ */
+type GraduatedAoA struct {
+	*FixedAoA
+	sanctions *SanctionEngine
+}
+
+// The ID agents rank the graduated sanctions AoA by
+const GraduatedAoAID = 7
+
+func init() {
+	RegisterAoA(AoAEntry{
+		ID:            GraduatedAoAID,
+		Name:          "Graduated sanctions",
+		DefaultParams: AoAParams{"duration": 1},
+		Create: func(team *Team, params AoAParams) IArticlesOfAssociation {
+			return CreateGraduatedAoA(params["duration"], DefaultSanctionLadder())
+		},
+		PostCreate: func(server IAoAHookServer, team *Team) {
+			team.TeamAoA.(*GraduatedAoA).sanctions.SetLadder(server.GetSanctionLadder())
+		},
+	})
+}
+
+func CreateGraduatedAoA(duration int, ladder SanctionLadder) *GraduatedAoA {
+	return &GraduatedAoA{
+		FixedAoA: &FixedAoA{
+			auditRecord: NewAuditRecord(duration),
+			rng:         NewRandStream("aoa/graduated"),
+		},
+		sanctions: NewSanctionEngine(ladder),
+	}
+}
+
+// Audit the agent that more than half of the voters want audited, if any
+func (g *GraduatedAoA) GetVoteResult(votes []Vote) uuid.UUID {
+	g.FixedAoA.GetVoteResult(votes) // sets the audit duration
+
+	cast, candidates := auditBallots(votes)
+	result := voting.Plurality(candidates, votedFor(cast))
+	if agentID, elected := result.Winner(voting.NoTies()); elected && result.Scores[agentID]*2 > float64(len(votes)) {
+		return agentID
+	}
+	return uuid.Nil
+}
+
+func (g *GraduatedAoA) GetSanction(agentId uuid.UUID, agentScore int) Sanction {
+	return g.sanctions.Offend(agentId, agentScore)
+}
+
+// Every report is heard. Whistleblowers are paid for the audits they were right
+// to call for, and fined a tenth of their score for those they were wrong to.
+func (g *GraduatedAoA) ReviewReport(report *WhistleblowerMessage) bool {
+	return true
+}
+
+func (g *GraduatedAoA) GetReportReward(report *WhistleblowerMessage) int {
+	return g.GetAuditCost(0)
+}
+
+func (g *GraduatedAoA) GetFalseReportSanction(report *WhistleblowerMessage, agentScore int) Sanction {
+	return Sanction{Fine: agentScore / 10, Reason: "false report"}
+}
+
+func (g *GraduatedAoA) GetSanctionEngine() *SanctionEngine {
+	return g.sanctions
+}
+
+type graduatedAoAState struct {
+	Fixed     json.RawMessage
+	Sanctions sanctionEngineState
+}
+
+func (g *GraduatedAoA) SnapshotState() (json.RawMessage, error) {
+	fixed, err := g.FixedAoA.SnapshotState()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(graduatedAoAState{Fixed: fixed, Sanctions: g.sanctions.snapshot()})
+}
+
+func (g *GraduatedAoA) RestoreState(data json.RawMessage) error {
+	var state graduatedAoAState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	if err := g.FixedAoA.RestoreState(state.Fixed); err != nil {
+		return err
+	}
+	g.sanctions.restore(state.Sanctions)
+	return nil
+}
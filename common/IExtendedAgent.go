@@ -20,7 +20,6 @@ type IExtendedAgent interface {
 
 	// Functions that involve strategic decisions
 	StartTeamForming(instance IExtendedAgent, agentInfoList []ExposedAgentInfo)
-	StartRollingDice(instance IExtendedAgent)
 	GetActualContribution(instance IExtendedAgent) int
 	GetActualWithdrawal(instance IExtendedAgent) int
 	GetStatedContribution(instance IExtendedAgent) int
@@ -45,6 +44,8 @@ type IExtendedAgent interface {
 
 	// Messaging functions
 	HandleTeamFormationMessage(msg *TeamFormationMessage)
+	HandleDiceRoll(roll DiceRoll)
+	HandleRollingComplete(turnScore int, score int)
 	HandleScoreReportMessage(msg *ScoreReportMessage)
 	HandleWithdrawalMessage(msg *WithdrawalMessage)
 	BroadcastSyncMessageToTeam(msg message.IMessage[IExtendedAgent])
@@ -59,13 +60,23 @@ type IExtendedAgent interface {
 	CreateScoreReportMessage() *ScoreReportMessage
 	CreateContributionMessage(statedAmount int) *ContributionMessage
 	CreateWithdrawalMessage(statedAmount int) *WithdrawalMessage
+	CreateWhistleblowerMessage(accused uuid.UUID, phase AuditPhase, evidence WhistleblowerEvidence) *WhistleblowerMessage
 	CreateAgentOpinionRequestMessage(agentID uuid.UUID) *AgentOpinionRequestMessage
-	CreateAgentOpinionResponseMessage(agentID uuid.UUID, opinion int) *AgentOpinionResponseMessage
+	CreateAgentOpinionResponseMessage(opinion Opinion) *AgentOpinionResponseMessage
 	LogSelfInfo()
 	GetAoARanking() []int
 	SetAoARanking(Preferences []int)
 	GetContributionAuditVote() Vote
 	GetWithdrawalAuditVote() Vote
+	// Leadership, for teams whose AoA has a leader (see Leadership.go)
+	GetLeaderBallot(candidates []uuid.UUID) []uuid.UUID // candidates best first, any left out are not ranked
+	GetRecallVote(leader uuid.UUID) bool
+	// Appeals against guilty verdicts, by the agent found guilty and then its jury
+	DecideAppeal(phase AuditPhase) bool
+	GetAppealVote(accused uuid.UUID, phase AuditPhase) bool // true to overturn the verdict
+	// Trust, see Trust.go
+	GetOpinions() []Opinion             // the agent's own opinions of other agents
+	GetTrust(subject uuid.UUID) float64 // how far the agent trusts the subject, from everything it knows
 	GetTrueSomasTeamID() int
 	HasTeam() bool
 
@@ -96,9 +107,6 @@ type IExtendedAgent interface {
 	Team1_BoundaryBallotRequestHandler(msg *Team1BoundaryBallotRequestMessage)
 	Team1_BoundaryBallotResponseHandler(msg *Team1BoundaryBallotResponseMessage)
 
-	// Team 2 specific functions
-	Team2_GetLeaderVote() Vote
-
 	// Team 3 specific functions
 	Team3_GetStrategyVote() []Strategy
 }
@@ -18,12 +18,28 @@ type IServer interface {
 	GetAgentKilledScore(agentID uuid.UUID) int
 	StartAgentTeamForming()
 
-	GetTeam(agentID uuid.UUID) *Team
-	GetTeamFromTeamID(teamID uuid.UUID) *Team
+	// Read-only snapshots of teams, only the server can change a team
+	GetTeam(agentID uuid.UUID) TeamView
+	GetTeamFromTeamID(teamID uuid.UUID) TeamView
 	GetTeamIDs() []uuid.UUID
 	GetTeamCommonPool(teamID uuid.UUID) int
+	GetDiceRules(agentID uuid.UUID) DiceRules
+
+	// Report a teammate for cheating, see Whistleblowing.go
+	ReportCheating(report *WhistleblowerMessage)
+	// The offences on an agent's record that the calling agent may see, see Offences.go
+	GetOffences(agentID uuid.UUID) []Offence
+	// How agents combine opinions into trust, see Trust.go
+	GetTrustModel() ITrustModel
 
 	// Debug functions
 	LogAgentStatus()
 	PrintOrphanPool()
 }
+
+// Servers that answer each agent as itself, such as the one agents are created
+// with. An agent binds the server to its own ID as it is created, and the
+// binding cannot be changed afterwards.
+type IAgentBoundServer interface {
+	BindAgent(agentID uuid.UUID)
+}
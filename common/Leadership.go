@@ -0,0 +1,82 @@
+package common
+
+import (
+	"math/rand"
+	"slices"
+
+	"github.com/google/uuid"
+
+	voting "github.com/ADimoska/SOMASExtended/voting"
+)
+
+/*
+* Leadership that any AoA can give its team. The AoA declares that the team has
+* a leader by implementing ILeaderAoA, which also sets the rules for choosing
+* and keeping one. The server does the rest the same way for every AoA: it
+* holds the elections, asking each member for a ranked ballot
+* (GetLeaderBallot), and calls a new one when the leader's term is up, when the
+* team votes to recall them (GetRecallVote), when they are impeached for
+* failing an audit, or when they die or leave the team. Agents can find out
+* who leads their team from their TeamView.
+ */
+
+// How the ballots of an election are counted
+type ElectionMethod string
+
+const (
+	ElectPlurality      ElectionMethod = "plurality"       // the most first choices wins
+	ElectBorda          ElectionMethod = "borda"           // each place on a ballot is worth one point more than the next
+	ElectIRV            ElectionMethod = "irv"             // instant runoff: the fewest first choices is knocked out until someone has a majority
+	ElectRandomDictator ElectionMethod = "random-dictator" // the first choice of a ballot drawn at random
+)
+
+var ElectionMethods = []ElectionMethod{ElectPlurality, ElectBorda, ElectIRV, ElectRandomDictator}
+
+type LeadershipRules struct {
+	Method    ElectionMethod
+	TermTurns int // turns a leader serves before the team votes again, 0 for no limit
+	// share of the other members that must vote to recall the leader, between
+	// 0 and 1, 0 for no recall votes
+	Recall         float64
+	ImpeachOnAudit bool // a leader found guilty in an audit is replaced, and may not stand in the election
+}
+
+// An AoA whose team has a leader
+type ILeaderAoA interface {
+	GetLeadershipRules() LeadershipRules
+	GetLeader() uuid.UUID // uuid.Nil while there is none
+	SetLeader(leader uuid.UUID)
+}
+
+/*
+* Count ranked ballots, each listing candidates best first. Names that are not
+* candidates are ignored. Ties are broken at random. Returns uuid.Nil if no
+* ballot names a candidate.
+ */
+func CountLeaderBallots(method ElectionMethod, candidates []uuid.UUID, ballots [][]uuid.UUID, rng *rand.Rand) uuid.UUID {
+	rankings := make([]voting.Ranking[uuid.UUID], 0, len(ballots))
+	firstChoices := []uuid.UUID{}
+	for _, ballot := range ballots {
+		rankings = append(rankings, ballot)
+		if first := slices.IndexFunc(ballot, func(choice uuid.UUID) bool { return slices.Contains(candidates, choice) }); first >= 0 {
+			firstChoices = append(firstChoices, ballot[first])
+		}
+	}
+
+	var result voting.Result[uuid.UUID]
+	switch method {
+	case ElectBorda:
+		result = voting.Borda(candidates, rankings)
+	case ElectIRV:
+		result = voting.IRV(candidates, rankings, voting.AtRandom(rng))
+	case ElectRandomDictator:
+		if len(firstChoices) == 0 {
+			return uuid.Nil
+		}
+		return firstChoices[rng.Intn(len(firstChoices))]
+	default:
+		result = voting.Plurality(candidates, firstChoices)
+	}
+	leader, _ := result.Winner(voting.AtRandom(rng))
+	return leader
+}
@@ -34,10 +34,10 @@ type AgentOpinionRequestMessage struct {
 	AgentID uuid.UUID
 }
 
+// The sender's opinion of the agent it was asked about, see Trust.go
 type AgentOpinionResponseMessage struct {
 	message.BaseMessage
-	AgentID      uuid.UUID
-	AgentOpinion int
+	Opinion Opinion
 }
 
 type Team1RankBoundaryRequestMessage struct {
@@ -71,6 +71,20 @@ type Team4_ConfessionMessage struct {
 	Confession bool
 }
 
+// Whether the message is one of the types declared here. The server only
+// delivers these between agents, as a handler runs on the real recipient.
+func IsGameMessage(msg message.IMessage[IExtendedAgent]) bool {
+	switch msg.(type) {
+	case *TeamFormationMessage, *ScoreReportMessage, *ContributionMessage, *WithdrawalMessage,
+		*AgentOpinionRequestMessage, *AgentOpinionResponseMessage,
+		*Team1RankBoundaryRequestMessage, *Team1RankBoundaryResponseMessage,
+		*Team1BoundaryBallotRequestMessage, *Team1BoundaryBallotResponseMessage,
+		*Team4_ProposedWithdrawalMessage, *Team4_ConfessionMessage:
+		return true
+	}
+	return false
+}
+
 func (msg *TeamFormationMessage) InvokeMessageHandler(agent IExtendedAgent) {
 	agent.HandleTeamFormationMessage(msg)
 }
@@ -0,0 +1,22 @@
+package common
+
+import "github.com/google/uuid"
+
+/*
+* The server keeps every offence proven in an audit, whichever team the agent
+* was in at the time, so that an agent's record follows it when its team is
+* dissolved or it is kicked out and joins another. Agents look records up with
+* GetOffences on the server, and see as much of them as the server's
+* visibility rules allow.
+ */
+
+// An offence proven in an audit that stood after any appeal
+type Offence struct {
+	AgentID   uuid.UUID
+	Iteration int
+	Turn      int       // turn within the iteration
+	TeamID    uuid.UUID // the team the agent was in
+	AoAID     int       // the AoA that found it guilty
+	Phase     AuditPhase
+	Amount    int // how far it misstated what it contributed or withdrew that turn
+}
@@ -0,0 +1,229 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+/*
+* Every source of randomness in the game (server, agents, AoAs and ID
+* generation) draws from its own named stream. All streams are derived from a
+* single master seed, so two runs with the same seed and the same population
+* roll the same dice and make the same random choices.
+*
+* Streams are keyed by component name plus the number of streams already
+* handed out under that name, so components must be created in a
+* deterministic order for runs to be reproducible.
+ */
+
+var (
+	masterSeed  int64 = time.Now().UnixNano()
+	seeded      bool
+	streamMutex sync.Mutex
+	streamCount = make(map[string]int)
+	streams     = make(map[*rand.Rand]*countingSource)
+	uuidStream  *countingSource
+)
+
+// IDs are drawn from the master seed even if no seed was given, so that any
+// run can be re-created from its checkpoint
+func init() {
+	resetStreams(masterSeed, false)
+}
+
+// Set the master seed for the whole game. Must be called before the server and
+// any agents are created. Also makes uuid generation (agent and team IDs)
+// deterministic.
+func SetMasterSeed(seed int64) {
+	resetStreams(seed, true)
+}
+
+func resetStreams(seed int64, isSeeded bool) {
+	streamMutex.Lock()
+	masterSeed = seed
+	seeded = isSeeded
+	streamCount = make(map[string]int)
+	streams = make(map[*rand.Rand]*countingSource)
+	streamMutex.Unlock()
+
+	uuidStream = newCountingSource("uuid")
+	uuid.SetRand(streamReader{uuidStream})
+}
+
+func GetMasterSeed() int64 {
+	return masterSeed
+}
+
+// Returns true if the game was given an explicit seed and should be run reproducibly
+func IsSeeded() bool {
+	return seeded
+}
+
+// Create a new random stream for a component, e.g. "server" or "agent/<id>".
+func NewRandStream(component string) *rand.Rand {
+	src := newCountingSource(component)
+	r := rand.New(src)
+
+	streamMutex.Lock()
+	streams[r] = src
+	streamMutex.Unlock()
+	return r
+}
+
+func newCountingSource(component string) *countingSource {
+	streamMutex.Lock()
+	n := streamCount[component]
+	streamCount[component]++
+	streamMutex.Unlock()
+
+	h := fnv.New64a()
+	h.Write([]byte(fmt.Sprintf("%s#%d", component, n)))
+	src := &countingSource{}
+	src.Seed(masterSeed ^ int64(h.Sum64()))
+	return src
+}
+
+/*
+* The position of a random stream: its seed and how many values have been drawn
+* from it. Go's generator cannot be serialised, so it is restored by seeding a
+* new one and drawing the same number of values.
+ */
+type RandState struct {
+	Seed  int64
+	Draws uint64
+}
+
+type countingSource struct {
+	src   rand.Source64
+	seed  int64
+	draws uint64
+}
+
+func (s *countingSource) Int63() int64 {
+	s.draws++
+	return s.src.Int63()
+}
+
+func (s *countingSource) Uint64() uint64 {
+	s.draws++
+	return s.src.Uint64()
+}
+
+func (s *countingSource) Seed(seed int64) {
+	s.src = rand.NewSource(seed).(rand.Source64)
+	s.seed = seed
+	s.draws = 0
+}
+
+func (s *countingSource) state() RandState {
+	return RandState{Seed: s.seed, Draws: s.draws}
+}
+
+func (s *countingSource) restore(state RandState) {
+	s.Seed(state.Seed)
+	for s.draws < state.Draws {
+		s.Int63()
+	}
+}
+
+// Reads random bytes for uuid generation. Unlike rand.Rand.Read, no bytes are
+// carried over between calls, so the position of the stream is all there is
+// to save.
+type streamReader struct {
+	src *countingSource
+}
+
+func (r streamReader) Read(p []byte) (int, error) {
+	for i := 0; i < len(p); i += 8 {
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], r.src.Uint64())
+		copy(p[i:], buf[:])
+	}
+	return len(p), nil
+}
+
+// Get the position of a stream created by NewRandStream
+func GetRandState(r *rand.Rand) (RandState, error) {
+	streamMutex.Lock()
+	src, exists := streams[r]
+	streamMutex.Unlock()
+	if !exists {
+		return RandState{}, fmt.Errorf("random stream was not created by NewRandStream")
+	}
+	return src.state(), nil
+}
+
+// Move a stream created by NewRandStream back to a saved position
+func SetRandState(r *rand.Rand, state RandState) error {
+	streamMutex.Lock()
+	src, exists := streams[r]
+	streamMutex.Unlock()
+	if !exists {
+		return fmt.Errorf("random stream was not created by NewRandStream")
+	}
+	src.restore(state)
+	return nil
+}
+
+// Everything needed to carry on handing out the same streams after a restart
+type RandomSnapshot struct {
+	MasterSeed  int64
+	Seeded      bool
+	StreamCount map[string]int
+	UUID        RandState
+}
+
+func SnapshotRandom() RandomSnapshot {
+	streamMutex.Lock()
+	defer streamMutex.Unlock()
+	counts := make(map[string]int, len(streamCount))
+	for component, n := range streamCount {
+		counts[component] = n
+	}
+	return RandomSnapshot{
+		MasterSeed:  masterSeed,
+		Seeded:      seeded,
+		StreamCount: counts,
+		UUID:        uuidStream.state(),
+	}
+}
+
+// First step of a restore, to be called before the server and agents are
+// re-created so that they get the same IDs and streams as the original run
+func RestoreMasterSeed(snapshot RandomSnapshot) {
+	resetStreams(snapshot.MasterSeed, snapshot.Seeded)
+}
+
+// Last step of a restore, once everything has been re-created, so that
+// streams and IDs handed out from now on match the original run
+func RestoreRandomStreams(snapshot RandomSnapshot) {
+	streamMutex.Lock()
+	streamCount = make(map[string]int, len(snapshot.StreamCount))
+	for component, n := range snapshot.StreamCount {
+		streamCount[component] = n
+	}
+	streamMutex.Unlock()
+	uuidStream.restore(snapshot.UUID)
+}
+
+// Return the keys of a map in a fixed order. Go randomises map iteration
+// order, so any loop over a map that consumes randomness or breaks ties must
+// go through this to stay reproducible.
+func SortedIDs[V any](m map[uuid.UUID]V) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return bytes.Compare(ids[i][:], ids[j][:]) < 0
+	})
+	return ids
+}
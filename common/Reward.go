@@ -0,0 +1,16 @@
+package common
+
+import "github.com/google/uuid"
+
+/*
+* A bonus paid to a member of the team from its common pool, the carrot to a
+* Sanction's stick. AoAs declare the rewards they give by implementing
+* IRewardsAoA, and the server pays them at the end of the turn, once the
+* sanctions are done, in the order they are given. When the pool runs out the
+* rest are paid what is left, if anything.
+ */
+type Reward struct {
+	AgentID uuid.UUID
+	Amount  int
+	Reason  string // why, recorded with the reward, e.g. "honesty streak"
+}
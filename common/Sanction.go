@@ -0,0 +1,62 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+* What happens to an agent found guilty in an audit. The team's AoA decides the
+* sanction (IArticlesOfAssociation.GetSanction) and the server carries it out
+* the same way for every AoA, in this order: the fine is paid into the common
+* pool, the restrictions are put in place, and then the agent is kicked.
+ */
+type Sanction struct {
+	Fine         int // taken from the agent's score and paid into the common pool
+	Restrictions []RoleRestriction
+	Kick         bool   // remove the agent from the team
+	Reason       string // why, recorded with the sanction, e.g. "third offence"
+}
+
+// A role in the team an agent can be barred from for a while
+type Role string
+
+const (
+	RoleAuditVoter Role = "audit-voter" // its votes on who to audit are not asked for
+	RoleWithdrawer Role = "withdrawer"  // it does not withdraw from the common pool
+	RoleLeader     Role = "leader"      // it cannot be elected leader
+	RoleRoller     Role = "roller"      // another member of the team rolls its dice for it
+)
+
+var Roles = []Role{RoleAuditVoter, RoleWithdrawer, RoleLeader, RoleRoller}
+
+type RoleRestriction struct {
+	Role  Role
+	Turns int // turns the restriction lasts after the one it is given in, 0 for the rest of the iteration
+}
+
+// No sanction at all
+func (s Sanction) IsNone() bool {
+	return s.Fine == 0 && len(s.Restrictions) == 0 && !s.Kick
+}
+
+func (s Sanction) String() string {
+	parts := []string{}
+	if s.Fine != 0 {
+		parts = append(parts, fmt.Sprintf("fine %d", s.Fine))
+	}
+	for _, restriction := range s.Restrictions {
+		if restriction.Turns == 0 {
+			parts = append(parts, fmt.Sprintf("no %s for the rest of the iteration", restriction.Role))
+		} else {
+			parts = append(parts, fmt.Sprintf("no %s for %d turns", restriction.Role, restriction.Turns))
+		}
+	}
+	if s.Kick {
+		parts = append(parts, "kick")
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
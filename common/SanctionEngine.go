@@ -0,0 +1,269 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+/*
+* Graduated sanctions that any AoA can use instead of keeping its own offence
+* counts. The AoA describes a ladder of sanction levels, from a warning up to
+* expulsion, and hands every guilty verdict to the engine (Offend), which
+* keeps a registry of each agent's offences, climbs the ladder as they add up
+* and lets them decay again after a spell of good behaviour. The AoA returns
+* the resulting Sanction from GetSanction as usual.
+*
+* AoAs that use the engine implement ISanctionEngineAoA, so the server can
+* tell the engine when a turn has passed and record every change it makes to
+* an agent's standing.
+ */
+
+type SanctionLevel string
+
+const (
+	SanctionWarning       SanctionLevel = "warning"        // recorded, but nothing else happens
+	SanctionFine          SanctionLevel = "fine"           // Percent of the agent's score is paid into the common pool
+	SanctionNoWithdrawal  SanctionLevel = "no-withdrawal"  // the agent may not withdraw for Turns turns
+	SanctionNoVote        SanctionLevel = "no-vote"        // the agent may not vote on audits for Turns turns
+	SanctionDelegateRolls SanctionLevel = "delegate-rolls" // another member rolls for the agent for Turns turns
+	SanctionExpel         SanctionLevel = "expel"          // the agent is kicked out of the team
+)
+
+var SanctionLevels = []SanctionLevel{SanctionWarning, SanctionFine, SanctionNoWithdrawal, SanctionNoVote, SanctionDelegateRolls, SanctionExpel}
+
+// One rung of the ladder
+type SanctionStep struct {
+	Level   SanctionLevel `yaml:"level" json:"level"`
+	Percent int           `yaml:"percent" json:"percent,omitempty"` // of the agent's score, for fines
+	Turns   int           `yaml:"turns" json:"turns,omitempty"`     // how long a restriction lasts after the turn it is given in, 0 for the rest of the iteration
+}
+
+type SanctionLadder struct {
+	Steps []SanctionStep `yaml:"steps" json:"steps"` // the first offence gets the first step, offences past the end get the last
+	// rungs climbed for each offence after the first
+	Escalation int `yaml:"escalation" json:"escalation"`
+	// an agent drops one offence after this many turns without a new one, 0 to never forget
+	DecayTurns int `yaml:"decayTurns" json:"decayTurns"`
+}
+
+// Warn, fine, then bar from voting and withdrawing before expelling
+func DefaultSanctionLadder() SanctionLadder {
+	return SanctionLadder{
+		Steps: []SanctionStep{
+			{Level: SanctionWarning},
+			{Level: SanctionFine, Percent: 25},
+			{Level: SanctionNoVote, Turns: 3},
+			{Level: SanctionNoWithdrawal, Turns: 3},
+			{Level: SanctionExpel},
+		},
+		Escalation: 1,
+		DecayTurns: 10,
+	}
+}
+
+// The reason a sanction ladder was rejected, naming the field responsible
+type SanctionLadderError struct {
+	Field   string
+	Problem string
+}
+
+func (e *SanctionLadderError) Error() string {
+	return fmt.Sprintf("sanction ladder: %s: %s", e.Field, e.Problem)
+}
+
+// Check the ladder can be climbed. Returns a *SanctionLadderError.
+func (l SanctionLadder) Validate() error {
+	if len(l.Steps) == 0 {
+		return &SanctionLadderError{"steps", "must contain at least one step"}
+	}
+	for i, step := range l.Steps {
+		field := fmt.Sprintf("steps[%d]", i)
+		switch step.Level {
+		case SanctionWarning, SanctionExpel:
+		case SanctionFine:
+			if step.Percent < 1 || step.Percent > 100 {
+				return &SanctionLadderError{field + ".percent", fmt.Sprintf("must be between 1 and 100, got %d", step.Percent)}
+			}
+		case SanctionNoWithdrawal, SanctionNoVote, SanctionDelegateRolls:
+			if step.Turns < 0 {
+				return &SanctionLadderError{field + ".turns", fmt.Sprintf("must not be negative, got %d", step.Turns)}
+			}
+		default:
+			return &SanctionLadderError{field + ".level", fmt.Sprintf("must be one of %v, got %q", SanctionLevels, step.Level)}
+		}
+	}
+	if l.Escalation < 1 {
+		return &SanctionLadderError{"escalation", fmt.Sprintf("must be positive, got %d", l.Escalation)}
+	}
+	if l.DecayTurns < 0 {
+		return &SanctionLadderError{"decayTurns", fmt.Sprintf("must not be negative, got %d", l.DecayTurns)}
+	}
+	return nil
+}
+
+// The step an agent with this many offences is on
+func (l SanctionLadder) StepFor(offences int) SanctionStep {
+	rung := min((offences-1)*l.Escalation, len(l.Steps)-1)
+	return l.Steps[max(rung, 0)]
+}
+
+// The sanction a step gives an agent with the given score
+func (step SanctionStep) Sanction(agentScore int) Sanction {
+	switch step.Level {
+	case SanctionFine:
+		return Sanction{Fine: max(agentScore, 0) * step.Percent / 100}
+	case SanctionNoWithdrawal:
+		return Sanction{Restrictions: []RoleRestriction{{Role: RoleWithdrawer, Turns: step.Turns}}}
+	case SanctionNoVote:
+		return Sanction{Restrictions: []RoleRestriction{{Role: RoleAuditVoter, Turns: step.Turns}}}
+	case SanctionDelegateRolls:
+		return Sanction{Restrictions: []RoleRestriction{{Role: RoleRoller, Turns: step.Turns}}}
+	case SanctionExpel:
+		return Sanction{Kick: true}
+	}
+	return Sanction{}
+}
+
+// An agent's entry in the offence registry
+type OffenceRecord struct {
+	Offences    int // offences that have not yet decayed
+	Total       int // every offence, decayed or not
+	LastChanged int // the engine turn the record last went up or down
+}
+
+// What happened to an agent's standing
+type SanctionChangeKind string
+
+const (
+	SanctionEscalated SanctionChangeKind = "escalated" // a new offence
+	SanctionDecayed   SanctionChangeKind = "decayed"   // an offence was forgotten
+	SanctionCleared   SanctionChangeKind = "cleared"   // the agent was expelled and its record dropped
+)
+
+type SanctionChange struct {
+	AgentID  uuid.UUID
+	Kind     SanctionChangeKind
+	Offences int           // after the change
+	Level    SanctionLevel // the step the agent is now on, empty once it has no offences
+}
+
+type SanctionEngine struct {
+	ladder   SanctionLadder
+	records  map[uuid.UUID]*OffenceRecord
+	turn     int // turns the engine has been told about, see Tick
+	changes  []SanctionChange
+	agentIDs []uuid.UUID // the agents in records, in the order they first offended
+}
+
+// Panics if the ladder is not valid, as AoAs build their ladder in code
+func NewSanctionEngine(ladder SanctionLadder) *SanctionEngine {
+	if err := ladder.Validate(); err != nil {
+		panic(err)
+	}
+	return &SanctionEngine{ladder: ladder, records: make(map[uuid.UUID]*OffenceRecord)}
+}
+
+func (e *SanctionEngine) GetLadder() SanctionLadder {
+	return e.ladder
+}
+
+// Change the ladder, keeping every agent's record. Panics if it is not valid.
+func (e *SanctionEngine) SetLadder(ladder SanctionLadder) {
+	if err := ladder.Validate(); err != nil {
+		panic(err)
+	}
+	e.ladder = ladder
+}
+
+// An agent's record, the zero record if it has never offended
+func (e *SanctionEngine) GetRecord(agentID uuid.UUID) OffenceRecord {
+	if record, exists := e.records[agentID]; exists {
+		return *record
+	}
+	return OffenceRecord{}
+}
+
+// Record a new offence and return the sanction for it
+func (e *SanctionEngine) Offend(agentID uuid.UUID, agentScore int) Sanction {
+	record, exists := e.records[agentID]
+	if !exists {
+		record = &OffenceRecord{}
+		e.records[agentID] = record
+		e.agentIDs = append(e.agentIDs, agentID)
+	}
+	record.Offences++
+	record.Total++
+	record.LastChanged = e.turn
+
+	step := e.ladder.StepFor(record.Offences)
+	e.changes = append(e.changes, SanctionChange{AgentID: agentID, Kind: SanctionEscalated, Offences: record.Offences, Level: step.Level})
+
+	sanction := step.Sanction(agentScore)
+	sanction.Reason = fmt.Sprintf("offence %d: %s", record.Offences, step.Level)
+	if sanction.Kick {
+		e.forget(agentID)
+	}
+	return sanction
+}
+
+// Move the engine on by a turn, letting offences decay
+func (e *SanctionEngine) Tick() {
+	e.turn++
+	if e.ladder.DecayTurns == 0 {
+		return
+	}
+	for _, agentID := range e.agentIDs {
+		record := e.records[agentID]
+		if record.Offences == 0 || e.turn-record.LastChanged < e.ladder.DecayTurns {
+			continue
+		}
+		record.Offences--
+		record.LastChanged = e.turn
+		change := SanctionChange{AgentID: agentID, Kind: SanctionDecayed, Offences: record.Offences}
+		if record.Offences > 0 {
+			change.Level = e.ladder.StepFor(record.Offences).Level
+		}
+		e.changes = append(e.changes, change)
+	}
+}
+
+func (e *SanctionEngine) forget(agentID uuid.UUID) {
+	delete(e.records, agentID)
+	for i, id := range e.agentIDs {
+		if id == agentID {
+			e.agentIDs = append(e.agentIDs[:i], e.agentIDs[i+1:]...)
+			break
+		}
+	}
+	e.changes = append(e.changes, SanctionChange{AgentID: agentID, Kind: SanctionCleared})
+}
+
+// The changes made since the last call, oldest first
+func (e *SanctionEngine) TakeChanges() []SanctionChange {
+	changes := e.changes
+	e.changes = nil
+	return changes
+}
+
+type sanctionEngineState struct {
+	Ladder  SanctionLadder
+	Turn    int
+	Records map[uuid.UUID]*OffenceRecord
+	Order   []uuid.UUID
+}
+
+func (e *SanctionEngine) snapshot() sanctionEngineState {
+	return sanctionEngineState{Ladder: e.ladder, Turn: e.turn, Records: e.records, Order: e.agentIDs}
+}
+
+func (e *SanctionEngine) restore(state sanctionEngineState) {
+	e.ladder = state.Ladder
+	e.turn = state.Turn
+	e.records = state.Records
+	if e.records == nil {
+		e.records = make(map[uuid.UUID]*OffenceRecord)
+	}
+	e.agentIDs = state.Order
+	e.changes = nil
+}
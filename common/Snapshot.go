@@ -0,0 +1,119 @@
+package common
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+/*
+* Implemented by agents and AoAs whose internal state (memories, learnt
+* weights, audit histories...) should survive a checkpoint and resume. The
+* state is returned as JSON, in whatever shape suits the implementation, and
+* handed back unchanged to RestoreState on the re-created object.
+ */
+type ISnapshotter interface {
+	SnapshotState() (json.RawMessage, error)
+	RestoreState(state json.RawMessage) error
+}
+
+type auditRecordState struct {
+	Records  map[uuid.UUID][]int
+	Duration int
+	Quality  AuditQuality
+	Rand     RandState
+}
+
+func (a *AuditRecord) MarshalJSON() ([]byte, error) {
+	randState, err := GetRandState(a.rng)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(auditRecordState{Records: a.auditMap, Duration: a.quality.Lookback, Quality: a.quality, Rand: randState})
+}
+
+func (a *AuditRecord) UnmarshalJSON(data []byte) error {
+	var state auditRecordState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	a.auditMap = state.Records
+	if a.auditMap == nil {
+		a.auditMap = make(map[uuid.UUID][]int)
+	}
+	// Records saved before audits had a quality only have a duration
+	if state.Quality == (AuditQuality{}) {
+		state.Quality = PerfectAuditQuality(state.Duration)
+	}
+	a.quality = state.Quality
+	if a.rng == nil {
+		a.rng = NewRandStream("audit")
+	}
+	if state.Rand == (RandState{}) {
+		return nil
+	}
+	return SetRandState(a.rng, state.Rand)
+}
+
+type leakyQueueState struct {
+	Data     []int
+	Capacity int
+}
+
+func (q *LeakyQueue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(leakyQueueState{Data: q.data, Capacity: q.capacity})
+}
+
+func (q *LeakyQueue) UnmarshalJSON(data []byte) error {
+	var state leakyQueueState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	*q = *NewLeakyQueue(state.Capacity)
+	for _, value := range state.Data {
+		q.Push(value)
+	}
+	return nil
+}
+
+// A float64 that can be saved even if it is NaN or infinite, which plain JSON
+// numbers cannot represent (e.g. a neural network weight that has diverged)
+type SnapshotFloat float64
+
+func (f SnapshotFloat) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+		return json.Marshal(strconv.FormatFloat(float64(f), 'g', -1, 64))
+	}
+	return json.Marshal(float64(f))
+}
+
+func (f *SnapshotFloat) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		value, err := strconv.ParseFloat(s, 64)
+		*f = SnapshotFloat(value)
+		return err
+	}
+	var value float64
+	err := json.Unmarshal(data, &value)
+	*f = SnapshotFloat(value)
+	return err
+}
+
+func SnapshotFloats(values []float64) []SnapshotFloat {
+	snapshot := make([]SnapshotFloat, len(values))
+	for i, value := range values {
+		snapshot[i] = SnapshotFloat(value)
+	}
+	return snapshot
+}
+
+func RestoreFloats(snapshot []SnapshotFloat) []float64 {
+	values := make([]float64, len(snapshot))
+	for i, value := range snapshot {
+		values[i] = float64(value)
+	}
+	return values
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"encoding/json"
 	// "container/list"
 	// "errors"
 	"log"
@@ -10,6 +11,7 @@ import (
 
 	// "github.com/ADimoska/SOMASExtended/agents"
 	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+	voting "github.com/ADimoska/SOMASExtended/voting"
 	// "github.com/MattSScott/basePlatformSOMAS/v2/pkg/server"
 	"github.com/google/uuid"
 )
@@ -21,6 +23,9 @@ type Team1AoA struct {
 	agentLQueue           map[uuid.UUID]*LeakyQueue
 	minCommonPoolLeftover int
 	offenceMap            map[uuid.UUID]int //TODO: Might need to change to leaky queue
+	rng                   *rand.Rand
+	// Offences each agent's latest audit in each phase added, taken back if the verdict is overturned
+	auditOffences map[AuditPhase]map[uuid.UUID]int
 }
 
 // LeakyQueue represents a queue with a fixed capacity.
@@ -57,6 +62,16 @@ func (q *LeakyQueue) Sum() int {
 	return q.sum
 }
 
+// Len returns how many elements the queue holds, at most its capacity.
+func (q *LeakyQueue) Len() int {
+	return len(q.data)
+}
+
+// Cap returns the queue's capacity.
+func (q *LeakyQueue) Cap() int {
+	return q.capacity
+}
+
 // func (t *Team1AoA) ResetAuditMap() {
 // 	t.auditResult = make(map[uuid.UUID]*list.List)
 // }
@@ -135,35 +150,27 @@ func (t *Team1AoA) SetWithdrawalAuditResult(agentId uuid.UUID, agentScore int, a
 }
 
 func (t *Team1AoA) GetAuditCost(commonPool int) int {
-	// Need to get argument which agent being audited and then change cost?
-	return 5
+	return t.auditResult.GetAuditCost()
 }
 
+// The agent with the most votes is audited, ties going to the agent voted for
+// first. Nobody is audited if as many members prefer no audit as want one.
 func (t *Team1AoA) GetVoteResult(votes []Vote) uuid.UUID {
-	// Count total votes
+	t.auditResult.VoteOnQuality(votes)
 	totalVotes := 0
-	voteMap := make(map[uuid.UUID]int)
-	highestVotes := -1
-	highestVotedID := uuid.Nil
 	for _, vote := range votes {
 		totalVotes += vote.IsVote
-		if vote.IsVote == 1 { // Should agents who didnt want to vote, get a vote if majority wants to?
-			voteMap[vote.VotedForID]++
-		}
-		// Check if this ID has the highest votes
-		if voteMap[vote.VotedForID] > highestVotes {
-			highestVotedID = vote.VotedForID
-			highestVotes = voteMap[vote.VotedForID]
-		}
 	}
 	if totalVotes <= 0 {
 		return uuid.Nil // Majority does not want to vote
 	}
-	return highestVotedID
+	cast, candidates := auditBallots(votes)
+	agentID, _ := voting.Plurality(candidates, votedFor(cast)).Winner(voting.FirstListed())
+	return agentID
 }
 
 func (t *Team1AoA) GetAuditResult(agentId uuid.UUID) bool {
-	warnings := t.auditResult.GetAllInfractions(agentId)
+	warnings := t.auditResult.Audit(agentId)
 	offences := t.offenceMap[agentId]
 	offences += warnings
 
@@ -173,12 +180,42 @@ func (t *Team1AoA) GetAuditResult(agentId uuid.UUID) bool {
 	return offences > 0
 }
 
+func (t *Team1AoA) GetAuditQuality() AuditQuality {
+	return t.auditResult.GetAuditQuality()
+}
+
+func (t *Team1AoA) SetFalsePositive(rate float64) {
+	t.auditResult.SetFalsePositive(rate)
+}
+
+// Run the audit, remembering the offences it added in case the verdict is overturned
+func (t *Team1AoA) auditIn(phase AuditPhase, agentId uuid.UUID) bool {
+	before := t.offenceMap[agentId]
+	guilty := t.GetAuditResult(agentId)
+	if t.auditOffences == nil {
+		t.auditOffences = make(map[AuditPhase]map[uuid.UUID]int)
+	}
+	if t.auditOffences[phase] == nil {
+		t.auditOffences[phase] = make(map[uuid.UUID]int)
+	}
+	t.auditOffences[phase][agentId] = t.offenceMap[agentId] - before
+	return guilty
+}
+
 func (t *Team1AoA) GetContributionAuditResult(agentId uuid.UUID) bool {
-	return t.GetAuditResult(agentId)
+	return t.auditIn(ContributionPhase, agentId)
 }
 
 func (t *Team1AoA) GetWithdrawalAuditResult(agentId uuid.UUID) bool {
-	return t.GetAuditResult(agentId)
+	return t.auditIn(WithdrawalPhase, agentId)
+}
+
+// An overturned verdict takes back the offences the audit added
+func (t *Team1AoA) OnVerdictOverturned(server IAoAHookServer, team *Team, agentMap map[uuid.UUID]IExtendedAgent, agentId uuid.UUID, phase AuditPhase) {
+	if offences, audited := t.auditOffences[phase][agentId]; audited {
+		delete(t.auditOffences[phase], agentId)
+		t.offenceMap[agentId] -= offences
+	}
 }
 
 func (t *Team1AoA) GetWithdrawalOrder(agentIDs []uuid.UUID) []uuid.UUID {
@@ -213,7 +250,7 @@ func (t *Team1AoA) WeightedRandomSelection(agentIds []uuid.UUID) uuid.UUID {
 	}
 
 	// Generate a random number between 1 and totalWeight
-	randomNumber := rand.Intn(totalWeight) + 1
+	randomNumber := t.rng.Intn(totalWeight) + 1
 
 	// Select an agent based on the random number
 	cumulativeWeight := 0
@@ -456,6 +493,10 @@ func (t *Team1AoA) GetAgentRank(agentId uuid.UUID) int {
 	return t.ranking[agentId]
 }
 
+func (t *Team1AoA) GetMemberRank(agentId uuid.UUID) int {
+	return t.GetAgentRank(agentId)
+}
+
 // TO_CHECK - added new function
 func (t *Team1AoA) GetRankThresholds() [5]int {
 	return t.rankBoundary
@@ -487,12 +528,36 @@ func (t *Team1AoA) ResetNumberOfOffences(agentId uuid.UUID) {
 	t.offenceMap[agentId] = 0
 }
 
+// Agents are fined by rank, and kicked once they have committed two offences
+func (t *Team1AoA) GetSanction(agentId uuid.UUID, agentScore int) Sanction {
+	sanction := Sanction{Fine: t.GetPunishment(agentScore, agentId)}
+	if t.GetNumberOfOffences(agentId) >= 2 {
+		sanction.Kick = true
+		sanction.Reason = "two offences"
+		t.RemoveAgentFromTeam(agentId)
+		// reset the number of offences for the agent
+		t.ResetNumberOfOffences(agentId)
+	}
+	return sanction
+}
+
 func (t *Team1AoA) RemoveAgentFromTeam(agentId uuid.UUID) {
 	delete(t.ranking, agentId)
 	delete(t.agentLQueue, agentId)
 	delete(t.offenceMap, agentId)
 }
 
+func init() {
+	RegisterAoA(AoAEntry{
+		ID:            1,
+		Name:          "Team1 (ranked)",
+		DefaultParams: AoAParams{"auditDuration": 5},
+		Create: func(team *Team, params AoAParams) IArticlesOfAssociation {
+			return CreateTeam1AoA(team, params["auditDuration"])
+		},
+	})
+}
+
 func CreateTeam1AoA(team *Team, auditDuration int) IArticlesOfAssociation {
 	ranking := make(map[uuid.UUID]int)
 	agentLQueue := make(map[uuid.UUID]*LeakyQueue)
@@ -508,15 +573,46 @@ func CreateTeam1AoA(team *Team, auditDuration int) IArticlesOfAssociation {
 		agentLQueue:           agentLQueue,
 		minCommonPoolLeftover: 5,
 		offenceMap:            make(map[uuid.UUID]int),
+		rng:                   NewRandStream("aoa/team1"),
 	}
 }
 
-// Do nothing
-func (t *Team1AoA) Team4_SetRankUp(map[uuid.UUID]map[uuid.UUID]int) {
+type team1AoAState struct {
+	AuditRecord           *AuditRecord
+	Ranking               map[uuid.UUID]int
+	RankBoundary          [5]int
+	AgentLQueue           map[uuid.UUID]*LeakyQueue
+	MinCommonPoolLeftover int
+	OffenceMap            map[uuid.UUID]int
+	Rand                  RandState
 }
 
-func (t *Team1AoA) Team4_RunProposedWithdrawalVote(map[uuid.UUID]int, map[uuid.UUID]map[uuid.UUID]int) {
+func (t *Team1AoA) SnapshotState() (json.RawMessage, error) {
+	randState, err := GetRandState(t.rng)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(team1AoAState{
+		AuditRecord:           t.auditResult,
+		Ranking:               t.ranking,
+		RankBoundary:          t.rankBoundary,
+		AgentLQueue:           t.agentLQueue,
+		MinCommonPoolLeftover: t.minCommonPoolLeftover,
+		OffenceMap:            t.offenceMap,
+		Rand:                  randState,
+	})
 }
-func (t *Team1AoA) Team4_HandlePunishmentVote(map[uuid.UUID]map[int]int) int {
-	return 0
+
+func (t *Team1AoA) RestoreState(data json.RawMessage) error {
+	state := team1AoAState{AuditRecord: t.auditResult}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	t.auditResult = state.AuditRecord
+	t.ranking = state.Ranking
+	t.rankBoundary = state.RankBoundary
+	t.agentLQueue = state.AgentLQueue
+	t.minCommonPoolLeftover = state.MinCommonPoolLeftover
+	t.offenceMap = state.OffenceMap
+	return SetRandState(t.rng, state.Rand)
 }
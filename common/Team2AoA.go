@@ -1,11 +1,13 @@
 package common
 
 import (
+	"encoding/json"
 	"log"
 	"math"
 	"math/rand"
 
 	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+	voting "github.com/ADimoska/SOMASExtended/voting"
 	"github.com/google/uuid"
 )
 
@@ -21,6 +23,17 @@ type Team2AoA struct {
 	RollsLeftMap map[uuid.UUID]int
 	Leader       uuid.UUID
 	Team         *Team
+	rng          *rand.Rand
+	// Leaders deposed by an audit this turn, who are not kicked for it
+	deposedLeaders map[uuid.UUID]bool
+	// What each agent's latest audit in each phase did, undone if the verdict is overturned
+	auditEffects map[AuditPhase]map[uuid.UUID]team2AuditEffect
+}
+
+type team2AuditEffect struct {
+	Offences  int  // offences the audit added
+	RollsLeft int  // rolls the agent had left before the audit
+	Leader    bool // whether the agent was leader, and so deposed by the audit
 }
 
 func (t *Team2AoA) GetExpectedContribution(agentId uuid.UUID, agentScore int) int {
@@ -30,17 +43,14 @@ func (t *Team2AoA) GetExpectedContribution(agentId uuid.UUID, agentScore int) in
 // Probably not very relevant, the punishment is levied based on offences committed and is enforced by the server
 func (t *Team2AoA) GetAuditResult(agentId uuid.UUID) bool {
 	// Only deduct from the common pool for a successful audit
-	warnings := t.auditRecord.GetAllInfractions(agentId)
+	warnings := t.auditRecord.Audit(agentId)
 	offences := t.OffenceMap[agentId]
 	offences += warnings
 
-	if offences == 1 {
-		t.RollsLeftMap[agentId] = 3
-	} else if offences == 2 {
-		t.RollsLeftMap[agentId] = 2
-	} else if offences >= 3 {
+	if offences >= 3 {
 		offences = 3
 	}
+	t.setRollsLeft(agentId, offences)
 
 	t.OffenceMap[agentId] = offences
 
@@ -50,8 +60,33 @@ func (t *Team2AoA) GetAuditResult(agentId uuid.UUID) bool {
 	return offences > 0
 }
 
+// Agents on their first offence have their next 3 rolls made by the leader,
+// and on their second their next 2
+func (t *Team2AoA) setRollsLeft(agentId uuid.UUID, offences int) {
+	if offences == 1 {
+		t.RollsLeftMap[agentId] = 3
+	} else if offences == 2 {
+		t.RollsLeftMap[agentId] = 2
+	}
+}
+
+// Run the audit, remembering what it did in case the verdict is overturned
+func (t *Team2AoA) auditIn(phase AuditPhase, agentId uuid.UUID) bool {
+	effect := team2AuditEffect{Offences: t.OffenceMap[agentId], RollsLeft: t.RollsLeftMap[agentId], Leader: agentId == t.Leader}
+	guilty := t.GetAuditResult(agentId)
+	effect.Offences = t.OffenceMap[agentId] - effect.Offences
+	if t.auditEffects == nil {
+		t.auditEffects = make(map[AuditPhase]map[uuid.UUID]team2AuditEffect)
+	}
+	if t.auditEffects[phase] == nil {
+		t.auditEffects[phase] = make(map[uuid.UUID]team2AuditEffect)
+	}
+	t.auditEffects[phase][agentId] = effect
+	return guilty
+}
+
 func (t *Team2AoA) GetContributionAuditResult(agentId uuid.UUID) bool {
-	return t.GetAuditResult(agentId)
+	return t.auditIn(ContributionPhase, agentId)
 }
 
 func (t *Team2AoA) SetContributionAuditResult(agentId uuid.UUID, agentScore int, agentActualContribution int, agentStatedContribution int) {
@@ -66,7 +101,7 @@ func (t *Team2AoA) SetContributionAuditResult(agentId uuid.UUID, agentScore int,
 }
 
 func (t *Team2AoA) GetWithdrawalAuditResult(agentId uuid.UUID) bool {
-	return t.GetAuditResult(agentId)
+	return t.auditIn(WithdrawalPhase, agentId)
 }
 
 func (t *Team2AoA) GetExpectedWithdrawal(agentId uuid.UUID, agentScore int, commonPool int) int {
@@ -124,44 +159,41 @@ func (t *Team2AoA) GetAuditCost(commonPool int) int {
 	return t.auditRecord.GetAuditCost()
 }
 
+func (t *Team2AoA) GetAuditQuality() AuditQuality {
+	return t.auditRecord.GetAuditQuality()
+}
+
+func (t *Team2AoA) SetFalsePositive(rate float64) {
+	t.auditRecord.SetFalsePositive(rate)
+}
+
 func (t *Team2AoA) GetVoteResult(votes []Vote) uuid.UUID {
 	if len(votes) == 0 {
 		return uuid.Nil
 	}
 
-	voteMap := make(map[uuid.UUID]int)
-	duration := 0
 	count := len(t.Team.Agents)
+	t.auditRecord.VoteOnQuality(votes)
 
-	for _, vote := range votes {
-		durationVote, agentVotedFor := vote.AuditDuration, vote.VotedForID
-		votes := 1
+	// Votes against the leader count twice
+	cast, candidates := auditBallots(votes)
+	weights := make([]float64, len(cast))
+	for i, vote := range cast {
+		weights[i] = 1
 		if vote.VotedForID == t.Leader {
-			durationVote = durationVote * 2
-			votes = 2
-		}
-		if vote.IsVote == 1 {
-			voteMap[agentVotedFor] += votes
+			weights[i] = 2
 		}
-		duration += durationVote
-	}
-
-	duration /= len(votes)
-	if duration > 0 {
-		t.auditRecord.SetAuditDuration(duration)
 	}
+	result := voting.WeightedPlurality(candidates, votedFor(cast), weights)
 
 	// Make it easier to audit a leader, this ensures the leader can't outvote the rest and stay in power
-	leaderVotes := voteMap[t.Leader]
-	if leaderVotes >= max(1, (count/2)-1) {
+	if t.Leader != uuid.Nil && result.Scores[t.Leader] >= float64(max(1, (count/2)-1)) {
 		return t.Leader
 	}
 
 	// If the leader is not the one votes up, then check if someone else has been voted up
-	for votedFor, votes := range voteMap {
-		if votes >= ((count / 2) + 1) {
-			return votedFor
-		}
+	if agentID, elected := result.Winner(voting.FirstListed()); elected && result.Scores[agentID] >= float64((count/2)+1) {
+		return agentID
 	}
 
 	return uuid.Nil
@@ -173,7 +205,7 @@ func (t *Team2AoA) GetWithdrawalOrder(agentIDs []uuid.UUID) []uuid.UUID {
 	copy(shuffledAgents, agentIDs)
 
 	// Shuffle the agent list
-	rand.Shuffle(len(shuffledAgents), func(i, j int) {
+	t.rng.Shuffle(len(shuffledAgents), func(i, j int) {
 		shuffledAgents[i], shuffledAgents[j] = shuffledAgents[j], shuffledAgents[i]
 	})
 
@@ -193,6 +225,7 @@ func (t *Team2AoA) GetWithdrawalOrder(agentIDs []uuid.UUID) []uuid.UUID {
 }
 
 func (t *Team2AoA) RunPreIterationAoaLogic(team *Team, agentMap map[uuid.UUID]IExtendedAgent, dataRecorder *gameRecorder.ServerDataRecorder) {
+	t.deposedLeaders = make(map[uuid.UUID]bool)
 }
 func (t *Team2AoA) RunPostContributionAoaLogic(team *Team, agentMap map[uuid.UUID]IExtendedAgent) {}
 
@@ -200,6 +233,11 @@ func (f *Team2AoA) ResourceAllocation(agentScores map[uuid.UUID]int, remainingRe
 	return make(map[uuid.UUID]int)
 }
 
+// Leaders are elected by plurality and serve until they are audited
+func (t *Team2AoA) GetLeadershipRules() LeadershipRules {
+	return LeadershipRules{Method: ElectPlurality}
+}
+
 func (t *Team2AoA) SetLeader(leader uuid.UUID) {
 	t.Leader = leader
 }
@@ -220,6 +258,61 @@ func (t *Team2AoA) RollOnce(agentId uuid.UUID) {
 	t.RollsLeftMap[agentId] = max(0, t.RollsLeftMap[agentId]-1)
 }
 
+// Agents that have lost rolling privileges have their rolls made by the leader
+func (t *Team2AoA) GetRollController(agentId uuid.UUID) (uuid.UUID, bool) {
+	if t.GetRollsLeft(agentId) <= 0 {
+		return uuid.Nil, false
+	}
+	t.RollOnce(agentId)
+	return t.GetLeader(), true
+}
+
+// An audited leader is deposed and a new one elected, whatever the result
+func (t *Team2AoA) OnAudit(server IAoAHookServer, team *Team, agentMap map[uuid.UUID]IExtendedAgent, agentId uuid.UUID, guilty bool) {
+	if agentId != t.GetLeader() {
+		return
+	}
+	server.ElectNewLeader(team.TeamID)
+	if t.deposedLeaders == nil {
+		t.deposedLeaders = make(map[uuid.UUID]bool)
+	}
+	t.deposedLeaders[agentId] = true
+}
+
+// An overturned verdict takes back the offences the audit added, leaving the
+// agent's rolls as an innocent verdict would have, and gives a deposed leader
+// their place back
+func (t *Team2AoA) OnVerdictOverturned(server IAoAHookServer, team *Team, agentMap map[uuid.UUID]IExtendedAgent, agentId uuid.UUID, phase AuditPhase) {
+	effect, audited := t.auditEffects[phase][agentId]
+	if !audited {
+		return
+	}
+	delete(t.auditEffects[phase], agentId)
+	t.OffenceMap[agentId] -= effect.Offences
+	if t.OffenceMap[agentId] == 0 {
+		t.RollsLeftMap[agentId] = effect.RollsLeft
+	}
+	t.setRollsLeft(agentId, t.OffenceMap[agentId])
+	if effect.Leader {
+		delete(t.deposedLeaders, agentId)
+		server.ReinstateLeader(team.TeamID, agentId)
+	}
+}
+
+// Citizens are kicked on their third offence. A deposed leader is not kicked,
+// but cannot be leader again for the rest of the iteration.
+func (t *Team2AoA) GetSanction(agentId uuid.UUID, agentScore int) Sanction {
+	sanction := Sanction{Fine: t.GetPunishment(agentScore, agentId)}
+	if t.deposedLeaders[agentId] {
+		sanction.Restrictions = []RoleRestriction{{Role: RoleLeader}}
+		sanction.Reason = "deposed leader"
+	} else if t.GetOffences(agentId) == 3 {
+		sanction.Kick = true
+		sanction.Reason = "third offence"
+	}
+	return sanction
+}
+
 func (t *Team2AoA) GetPunishment(agentScore int, agentId uuid.UUID) int {
 	multiplier := 50
 	if t.OffenceMap[agentId] == 2 {
@@ -228,34 +321,84 @@ func (t *Team2AoA) GetPunishment(agentScore int, agentId uuid.UUID) int {
 	return (agentScore * multiplier) / 100
 }
 
+// The ID agents rank the Team2 AoA by
+const Team2AoAID = 2
+
+func init() {
+	RegisterAoA(AoAEntry{
+		ID:            Team2AoAID,
+		Name:          "Team2 (leader)",
+		DefaultParams: AoAParams{"auditDuration": 5},
+		Create: func(team *Team, params AoAParams) IArticlesOfAssociation {
+			return CreateTeam2AoA(team, uuid.Nil, params["auditDuration"])
+		},
+		// The team votes for its first leader once the AoA is in place
+		PostCreate: func(server IAoAHookServer, team *Team) {
+			server.ElectNewLeader(team.TeamID)
+		},
+	})
+}
+
 func CreateTeam2AoA(team *Team, leader uuid.UUID, auditDuration int) IArticlesOfAssociation {
 	log.Println("Creating Team2AoA")
 	offenceMap := make(map[uuid.UUID]int)
 	rollsLeftMap := make(map[uuid.UUID]int)
+	rng := NewRandStream("aoa/team2")
 
-	if leader == uuid.Nil {
+	// a team whose members have all died, as checkpoints can hold, has no one to lead it
+	if leader == uuid.Nil && len(team.Agents) > 0 {
 		shuffledAgents := make([]uuid.UUID, len(team.Agents))
 		copy(shuffledAgents, team.Agents)
-		rand.Shuffle(len(shuffledAgents), func(i, j int) {
+		rng.Shuffle(len(shuffledAgents), func(i, j int) {
 			shuffledAgents[i], shuffledAgents[j] = shuffledAgents[j], shuffledAgents[i]
 		})
 		leader = shuffledAgents[0]
 	}
 
 	return &Team2AoA{
-		auditRecord:  NewAuditRecord(auditDuration),
-		OffenceMap:   offenceMap,
-		RollsLeftMap: rollsLeftMap,
-		Leader:       leader,
-		Team:         team,
+		auditRecord:    NewAuditRecord(auditDuration),
+		OffenceMap:     offenceMap,
+		RollsLeftMap:   rollsLeftMap,
+		Leader:         leader,
+		Team:           team,
+		rng:            rng,
+		deposedLeaders: make(map[uuid.UUID]bool),
 	}
 }
 
-// Do nothing
-func (t *Team2AoA) Team4_SetRankUp(rankUpVoteMap map[uuid.UUID]map[uuid.UUID]int) {
+type team2AoAState struct {
+	AuditRecord    *AuditRecord
+	OffenceMap     map[uuid.UUID]int
+	RollsLeftMap   map[uuid.UUID]int
+	Leader         uuid.UUID
+	DeposedLeaders map[uuid.UUID]bool
+	Rand           RandState
 }
-func (t *Team2AoA) Team4_RunProposedWithdrawalVote(map[uuid.UUID]int, map[uuid.UUID]map[uuid.UUID]int) {
+
+func (t *Team2AoA) SnapshotState() (json.RawMessage, error) {
+	randState, err := GetRandState(t.rng)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(team2AoAState{
+		AuditRecord:    t.auditRecord,
+		OffenceMap:     t.OffenceMap,
+		RollsLeftMap:   t.RollsLeftMap,
+		Leader:         t.Leader,
+		DeposedLeaders: t.deposedLeaders,
+		Rand:           randState,
+	})
 }
-func (t *Team2AoA) Team4_HandlePunishmentVote(map[uuid.UUID]map[int]int) int {
-	return 0
+
+func (t *Team2AoA) RestoreState(data json.RawMessage) error {
+	state := team2AoAState{AuditRecord: t.auditRecord}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	t.auditRecord = state.AuditRecord
+	t.OffenceMap = state.OffenceMap
+	t.RollsLeftMap = state.RollsLeftMap
+	t.Leader = state.Leader
+	t.deposedLeaders = state.DeposedLeaders
+	return SetRandState(t.rng, state.Rand)
 }
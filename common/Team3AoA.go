@@ -2,12 +2,14 @@ package common
 
 import (
 	"container/list"
+	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
-	"time"
+	"sort"
 
 	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+	voting "github.com/ADimoska/SOMASExtended/voting"
 	"github.com/google/uuid"
 )
 
@@ -42,7 +44,11 @@ func NewTeam3AuditQueue(length int) *Team3AuditQueue {
 }
 
 // AddToQueue adds a new audit result to the queue. If the queue is full, the oldest result is removed.
+// A queue of length 0, as the Lenient strategy makes, remembers nothing.
 func (aq *Team3AuditQueue) AddToQueue(auditResult bool) {
+	if aq.length <= 0 {
+		return
+	}
 	if aq.length == aq.rounds.Len() {
 		aq.rounds.Remove(aq.rounds.Front()) // Remove the oldest result if the queue is full
 	}
@@ -67,6 +73,18 @@ type Team3AoA struct {
 	OffenceMap       map[uuid.UUID]int              // Tracks cumulative score reductions for agents
 	LyingHistory     map[uuid.UUID]*Team3AuditQueue // Tracks the history of lying for agents
 	PunishmentPeriod int                            // Number of rounds to remember lies (varies by strategy)
+	rng              *rand.Rand
+	auditResults     map[AuditPhase]map[uuid.UUID]*list.Element // the result each audit this turn found, in case its verdict is overturned
+}
+
+func init() {
+	RegisterAoA(AoAEntry{
+		ID:   3,
+		Name: "Team3 (strategy vote)",
+		Create: func(team *Team, params AoAParams) IArticlesOfAssociation {
+			return CreateTeam3AoA()
+		},
+	})
 }
 
 // CreateTeam3AoA initializes a new instance of Team3AoA with default settings.
@@ -76,6 +94,7 @@ func CreateTeam3AoA() *Team3AoA {
 		OffenceMap:       make(map[uuid.UUID]int),
 		LyingHistory:     make(map[uuid.UUID]*Team3AuditQueue),
 		PunishmentPeriod: 3, // Default to Moderates (remembers lies for 3 rounds)
+		rng:              NewRandStream("aoa/team3"),
 	}
 }
 
@@ -118,66 +137,70 @@ func (t *Team3AoA) GetAuditCost(commonPool int) int {
 	return 1
 }
 
-// DetermineStrategy calculates the majority vote using Instant Runoff Voting (IRV).
-func (t *Team3AoA) DetermineStrategy(votes []Vote) Strategy {
-	voteCounts := make(map[Strategy]int)  // Maps strategy to its vote count
-	eliminated := make(map[Strategy]bool) // Tracks eliminated strategies
-
-	for {
-		// Reset vote counts
-		for strategy := range voteCounts {
-			voteCounts[strategy] = 0
-		}
+// The ID a vote for a strategy is cast for
+func strategyID(strategy Strategy) uuid.UUID {
+	return uuid.NewSHA1(uuid.Nil, []byte{byte(strategy)})
+}
 
-		// Count first-choice votes
-		for _, vote := range votes {
-			if vote.IsVote == 1 {
-				strategy := Strategy(vote.VotedForID.ID() % 3) // Convert UUID to one of the three strategies
-				if !eliminated[strategy] {
-					voteCounts[strategy]++
-				}
-			}
+// The strategy a vote was cast for. Votes for anything other than a strategy
+// are converted to one of the three.
+func votedStrategy(vote Vote) Strategy {
+	for _, strategy := range []Strategy{Lenient, Moderates, Resolutes} {
+		if vote.VotedForID == strategyID(strategy) {
+			return strategy
 		}
+	}
+	return Strategy(vote.VotedForID.ID() % 3)
+}
 
-		// Check for majority
-		totalVotes := 0
-		for _, count := range voteCounts {
-			totalVotes += count
+// Each voter's ballot, ranking the strategies it voted for by the weight of
+// its votes, heaviest first
+func strategyBallots(votes []Vote) []voting.Ranking[Strategy] {
+	voters := []uuid.UUID{}
+	byVoter := make(map[uuid.UUID][]Vote)
+	for _, vote := range votes {
+		if vote.IsVote < 1 {
+			continue
 		}
-
-		for strategy, count := range voteCounts {
-			if count > totalVotes/2 {
-				// Return the strategy with the majority
-				if strategy == Resolutes {
-					t.PunishmentPeriod = 7 // Resolutes: Remember lies for 7 rounds
-				} else if strategy == Moderates {
-					t.PunishmentPeriod = 3 // Moderates: Remember lies for 3 rounds
-				} else {
-					t.PunishmentPeriod = 0 // Lenient: Remember nothing
-				}
-				return strategy
-			}
+		if _, seen := byVoter[vote.VoterID]; !seen {
+			voters = append(voters, vote.VoterID)
 		}
+		byVoter[vote.VoterID] = append(byVoter[vote.VoterID], vote)
+	}
 
-		// Find the strategy with the fewest votes
-		minVotes := totalVotes
-		toEliminate := Strategy(-1)
-		for strategy, count := range voteCounts {
-			if !eliminated[strategy] && count < minVotes {
-				minVotes = count
-				toEliminate = strategy
-			}
+	ballots := make([]voting.Ranking[Strategy], 0, len(voters))
+	for _, voterID := range voters {
+		cast := byVoter[voterID]
+		sort.SliceStable(cast, func(i, j int) bool { return cast[i].IsVote > cast[j].IsVote })
+		ballot := voting.Ranking[Strategy]{}
+		for _, vote := range cast {
+			ballot = append(ballot, votedStrategy(vote))
 		}
+		ballots = append(ballots, ballot)
+	}
+	return ballots
+}
 
-		// Eliminate the strategy with the fewest votes
-		eliminated[toEliminate] = true
+// DetermineStrategy calculates the majority vote using Instant Runoff Voting (IRV)
+// over every voter's ranked ballot. Ties for the fewest votes knock out the most
+// lenient strategy, and a tie between every strategy left (or no votes at all)
+// defaults to Lenient.
+func (t *Team3AoA) DetermineStrategy(votes []Vote) Strategy {
+	strategies := []Strategy{Lenient, Moderates, Resolutes}
+	ballots := strategyBallots(votes)
 
-		// If all strategies are eliminated (tie), default to Lenient
-		if len(eliminated) == len(voteCounts) {
-			t.PunishmentPeriod = 0
-			return Lenient
-		}
+	strategy, elected := voting.IRV(strategies, ballots, voting.FirstListed()).Winner(voting.FirstListed())
+	if !elected {
+		strategy = Lenient
+	}
+	if strategy == Resolutes {
+		t.PunishmentPeriod = 7 // Resolutes: Remember lies for 7 rounds
+	} else if strategy == Moderates {
+		t.PunishmentPeriod = 3 // Moderates: Remember lies for 3 rounds
+	} else {
+		t.PunishmentPeriod = 0 // Lenient: Remember nothing
 	}
+	return strategy
 }
 
 // ApplyPunishment applies the calculated punishment to the agent, reducing their score.
@@ -204,13 +227,12 @@ func (t *Team3AoA) Audit(agentId uuid.UUID, actual int, stated int, votes []Vote
 }
 
 // GetVoteResult calculates the majority vote and returns the winning agent's UUID.
+// The agent with the most votes is audited if more than four members voted for it.
 func (t *Team3AoA) GetVoteResult(votes []Vote) uuid.UUID {
-	voteMap := make(map[uuid.UUID]int)
-	for _, vote := range votes {
-		voteMap[vote.VotedForID]++
-		if voteMap[vote.VotedForID] > 4 {
-			return vote.VotedForID
-		}
+	cast, candidates := auditBallots(votes)
+	result := voting.Plurality(candidates, votedFor(cast))
+	if agentID, elected := result.Winner(voting.FirstListed()); elected && result.Scores[agentID] > 4 {
+		return agentID
 	}
 	return uuid.Nil
 }
@@ -237,8 +259,7 @@ func (t *Team3AoA) GetWithdrawalOrder(agentIDs []uuid.UUID) []uuid.UUID {
 	}
 
 	// Shuffle non-liars randomly
-	rand.Seed(time.Now().UnixNano())
-	rand.Shuffle(len(nonLiars), func(i, j int) {
+	t.rng.Shuffle(len(nonLiars), func(i, j int) {
 		nonLiars[i], nonLiars[j] = nonLiars[j], nonLiars[i]
 	})
 
@@ -294,18 +315,39 @@ func (t *Team3AoA) SetWithdrawalAuditResult(agentId uuid.UUID, agentScore int, a
 	t.AuditMap[agentId].AddToQueue(auditResult)
 }
 
-func (t *Team3AoA) GetContributionAuditResult(agentId uuid.UUID) bool {
-	if queue, exists := t.AuditMap[agentId]; exists && queue.rounds.Len() > 0 {
-		return queue.rounds.Back().Value.(bool)
+// Run the audit on the agent's last result, remembering it in case the verdict is overturned
+func (t *Team3AoA) auditIn(phase AuditPhase, agentId uuid.UUID) bool {
+	queue, exists := t.AuditMap[agentId]
+	if !exists || queue.rounds.Len() == 0 {
+		return false
+	}
+	if t.auditResults == nil {
+		t.auditResults = make(map[AuditPhase]map[uuid.UUID]*list.Element)
+	}
+	if t.auditResults[phase] == nil {
+		t.auditResults[phase] = make(map[uuid.UUID]*list.Element)
 	}
-	return false
+	lastResult := queue.rounds.Back()
+	t.auditResults[phase][agentId] = lastResult
+	return lastResult.Value.(bool)
+}
+
+func (t *Team3AoA) GetContributionAuditResult(agentId uuid.UUID) bool {
+	return t.auditIn(ContributionPhase, agentId)
 }
 
 func (t *Team3AoA) GetWithdrawalAuditResult(agentId uuid.UUID) bool {
-	if queue, exists := t.AuditMap[agentId]; exists && queue.rounds.Len() > 0 {
-		return queue.rounds.Back().Value.(bool)
+	return t.auditIn(WithdrawalPhase, agentId)
+}
+
+// An overturned verdict clears the lie the audit found from the agent's audit
+// results. Lies only enter its lying history when it is sanctioned, which an
+// overturned verdict is not.
+func (t *Team3AoA) OnVerdictOverturned(server IAoAHookServer, team *Team, agentMap map[uuid.UUID]IExtendedAgent, agentId uuid.UUID, phase AuditPhase) {
+	if result, audited := t.auditResults[phase][agentId]; audited {
+		delete(t.auditResults[phase], agentId)
+		result.Value = false
 	}
-	return false
 }
 
 func (t *Team3AoA) SetContributionAuditResult(agentId uuid.UUID, agentScore int, agentActualContribution int, agentStatedContribution int) {
@@ -338,6 +380,10 @@ func (t *Team3AoA) GetPunishment(agentScore int, agentId uuid.UUID) int {
 	return punishment.ScoreReduction
 }
 
+func (t *Team3AoA) GetSanction(agentId uuid.UUID, agentScore int) Sanction {
+	return Sanction{Fine: t.GetPunishment(agentScore, agentId)}
+}
+
 // RunPreIterationAoaLogic collects votes from agents and determines the strategy for the iteration
 func (t *Team3AoA) RunPreIterationAoaLogic(team *Team, agentMap map[uuid.UUID]IExtendedAgent, dataRecorder *gameRecorder.ServerDataRecorder) {
 	votes := make([]Vote, 0)
@@ -355,11 +401,10 @@ func (t *Team3AoA) RunPreIterationAoaLogic(team *Team, agentMap map[uuid.UUID]IE
 
 			// Add both votes with different weights
 			for rank, strategy := range rankedVotes {
-				strategyUUID := uuid.NewSHA1(uuid.Nil, []byte{byte(strategy)})
 				vote := Vote{
 					IsVote:     2 - rank, // First choice (rank 0) gets weight 2, second choice (rank 1) gets weight 1
 					VoterID:    agentID,
-					VotedForID: strategyUUID,
+					VotedForID: strategyID(strategy),
 				}
 				votes = append(votes, vote)
 			}
@@ -406,15 +451,62 @@ func (t *Team3AoA) RunPostContributionAoaLogic(team *Team, agentMap map[uuid.UUI
 	// Empty implementation as Team3 doesn't need post-contribution logic
 }
 
-func (t *Team3AoA) Team4_HandlePunishmentVote(map[uuid.UUID]map[int]int) int {
-	// Empty implementation as this is Team4-specific
-	return 0
+type team3AuditQueueState struct {
+	Length int
+	Rounds []bool
 }
 
-func (t *Team3AoA) Team4_RunProposedWithdrawalVote(map[uuid.UUID]int, map[uuid.UUID]map[uuid.UUID]int) {
-	// Empty implementation as this is Team4-specific
+func (aq *Team3AuditQueue) MarshalJSON() ([]byte, error) {
+	state := team3AuditQueueState{Length: aq.length, Rounds: []bool{}}
+	for e := aq.rounds.Front(); e != nil; e = e.Next() {
+		state.Rounds = append(state.Rounds, e.Value.(bool))
+	}
+	return json.Marshal(state)
+}
+
+func (aq *Team3AuditQueue) UnmarshalJSON(data []byte) error {
+	var state team3AuditQueueState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	aq.length = state.Length
+	aq.rounds.Init()
+	for _, round := range state.Rounds {
+		aq.rounds.PushBack(round)
+	}
+	return nil
+}
+
+type team3AoAState struct {
+	AuditMap         map[uuid.UUID]*Team3AuditQueue
+	OffenceMap       map[uuid.UUID]int
+	LyingHistory     map[uuid.UUID]*Team3AuditQueue
+	PunishmentPeriod int
+	Rand             RandState
+}
+
+func (t *Team3AoA) SnapshotState() (json.RawMessage, error) {
+	randState, err := GetRandState(t.rng)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(team3AoAState{
+		AuditMap:         t.AuditMap,
+		OffenceMap:       t.OffenceMap,
+		LyingHistory:     t.LyingHistory,
+		PunishmentPeriod: t.PunishmentPeriod,
+		Rand:             randState,
+	})
 }
 
-func (t *Team3AoA) Team4_SetRankUp(rankUpVoteMap map[uuid.UUID]map[uuid.UUID]int) {
-	// Empty implementation as this is Team4-specific
+func (t *Team3AoA) RestoreState(data json.RawMessage) error {
+	var state team3AoAState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	t.AuditMap = state.AuditMap
+	t.OffenceMap = state.OffenceMap
+	t.LyingHistory = state.LyingHistory
+	t.PunishmentPeriod = state.PunishmentPeriod
+	return SetRandState(t.rng, state.Rand)
 }
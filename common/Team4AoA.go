@@ -1,14 +1,27 @@
 package common
 
 import (
+	"encoding/json"
 	"log"
 	"math/rand"
+	"slices"
 	"sort"
 
 	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+	voting "github.com/ADimoska/SOMASExtended/voting"
 	"github.com/google/uuid"
 )
 
+func init() {
+	RegisterAoA(AoAEntry{
+		ID:   4,
+		Name: "Team4 (adventurers' guild)",
+		Create: func(team *Team, params AoAParams) IArticlesOfAssociation {
+			return CreateTeam4AoA(team)
+		},
+	})
+}
+
 func CreateTeam4AoA(team *Team) *Team4AoA {
 
 	adventurers := make(map[uuid.UUID]struct {
@@ -30,8 +43,10 @@ func CreateTeam4AoA(team *Team) *Team4AoA {
 	}
 
 	return &Team4AoA{
-		Adventurers: adventurers,
-		AuditMap:    auditMap,
+		Adventurers:     adventurers,
+		AuditMap:        auditMap,
+		PunishmentVotes: make(map[uuid.UUID]int),
+		rng:             NewRandStream("aoa/team4"),
 	}
 }
 
@@ -41,6 +56,9 @@ type Team4AoA struct {
 		ExpectedWithdrawal int
 	}
 	AuditMap map[uuid.UUID][]int
+	// Percentage of score each audited agent is fined, as voted by the team
+	PunishmentVotes map[uuid.UUID]int
+	rng             *rand.Rand
 }
 
 func (t *Team4AoA) GetExpectedContribution(agentId uuid.UUID, agentScore int) int {
@@ -63,29 +81,21 @@ func (t *Team4AoA) GetAuditCost(commonPool int) int {
 
 // Punishment Voting System
 func (t *Team4AoA) Team4_HandlePunishmentVote(punishmentVoteMap map[uuid.UUID]map[int]int) int {
-	punishmentGrades := make(map[int][]int)
-
-	for _, votes := range punishmentVoteMap {
-		for punishment, grade := range votes {
-			punishmentGrades[punishment] = append(punishmentGrades[punishment], grade)
+	// Every punishment graded by a voter is a candidate
+	punishments := []int{}
+	ballots := []voting.Grades[int]{}
+	for _, voterID := range SortedIDs(punishmentVoteMap) {
+		votes := punishmentVoteMap[voterID]
+		for punishment := range votes {
+			punishments = append(punishments, punishment)
 		}
+		ballots = append(ballots, votes)
 	}
+	slices.Sort(punishments)
+	punishments = slices.Compact(punishments)
 
-	// Calculate median for each punishment
-	medianGrades := make(map[int]int)
-	for punishment, grades := range punishmentGrades {
-		medianGrades[punishment] = getMedian(grades)
-	}
-
-	// Determine punishment with the highest median grade
-	var selectedPunishment int
-	highestMedian := -1
-	for punishment, median := range medianGrades {
-		if median > highestMedian {
-			highestMedian = median
-			selectedPunishment = punishment
-		}
-	}
+	// Determine punishment with the highest median grade (lowest punishment wins ties)
+	selectedPunishment, _ := voting.MajorityJudgement(punishments, ballots).Winner(voting.FirstListed())
 
 	// Return punishment score (you can define values for each punishment)
 	return getPunishmentScore(selectedPunishment)
@@ -108,26 +118,10 @@ func getPunishmentScore(punishment int) int {
 	}
 }
 
-func getMedian(grades []int) int {
-	if len(grades) == 0 {
-		return 0
-	}
-
-	sort.Ints(grades)
-
-	mid := len(grades) / 2
-	if len(grades)%2 == 0 {
-		// Average of middle grades if even
-		return (grades[mid-1] + grades[mid]) / 2
-	}
-	// Middle grade if odd
-	return grades[mid]
-}
-
 func (t *Team4AoA) GetDefaultRankUpChance() map[uuid.UUID]int {
 	rankUpVotes := make(map[uuid.UUID]int)
-	agentsInTeam := t.Adventurers
-	for agentID, adventurer := range agentsInTeam {
+	for _, agentID := range SortedIDs(t.Adventurers) {
+		adventurer := t.Adventurers[agentID]
 
 		rankUpChances := map[string]int{
 			"F":   80, // 80% chance
@@ -141,7 +135,7 @@ func (t *Team4AoA) GetDefaultRankUpChance() map[uuid.UUID]int {
 			"SSS": 0,  // No chance
 		}
 		chance := rankUpChances[adventurer.Rank]
-		if chance > 0 && rand.Intn(100) < chance {
+		if chance > 0 && t.rng.Intn(100) < chance {
 			rankUpVotes[agentID] = 1 // Rank-up vote
 		} else {
 			rankUpVotes[agentID] = 0 // No rank-up vote
@@ -330,35 +324,27 @@ func (t *Team4AoA) GetRankUpThreshold() int {
 	return threshold
 }
 
+// The agent with the most votes, each scaled by the voter's rank, is audited if
+// it reaches the threshold. Ties go to the agent voted for first.
 func (t *Team4AoA) GetVoteResult(votes []Vote) uuid.UUID {
-	voteMap := make(map[uuid.UUID]int)
-	for _, vote := range votes {
-		if vote.IsVote >= 1 {
-
-			// Get the rank of the voter
-			voter, exists := t.Adventurers[vote.VoterID]
-			if !exists {
-				continue
-			}
-
-			// Get the vote weight based on the voter's rank
-			voteWeight := t.GetVoteWeight(voter.Rank)
-
-			// Accumulate the vote scaled by the voter's rank
-			voteMap[vote.VotedForID] += voteWeight
+	cast, candidates := auditBallots(votes)
+	choices := []uuid.UUID{}
+	weights := []float64{}
+	for _, vote := range cast {
+		// Get the rank of the voter
+		voter, exists := t.Adventurers[vote.VoterID]
+		if !exists {
+			continue
 		}
+		choices = append(choices, vote.VotedForID)
+		weights = append(weights, float64(t.GetVoteWeight(voter.Rank)))
 	}
+	result := voting.WeightedPlurality(candidates, choices, weights)
 
-	// Calculate the vote threshold
-	threshold := t.GetVoteThreshold()
-
-	// Check if any candidate's exceed the threshold
-	for votedForID, totalVotes := range voteMap {
-		if totalVotes >= threshold {
-			return votedForID
-		}
+	// Check if the winner reaches the vote threshold
+	if agentID, elected := result.Winner(voting.FirstListed()); elected && result.Scores[agentID] >= float64(t.GetVoteThreshold()) {
+		return agentID
 	}
-
 	return uuid.Nil
 }
 
@@ -417,12 +403,68 @@ func (t *Team4AoA) GetVoteWeight(rank string) int {
 	}
 }
 
-// Unused Functions
+func (t *Team4AoA) GetMemberRank(agentId uuid.UUID) int {
+	return t.GetVoteWeight(t.Adventurers[agentId].Rank)
+}
 
+// Members vote on who deserves to rank up after contributing
 func (t *Team4AoA) RunPostContributionAoaLogic(team *Team, agentMap map[uuid.UUID]IExtendedAgent) {
+	rankUpVoteMap := make(map[uuid.UUID]map[uuid.UUID]int)
+	for _, agentID := range team.Agents {
+		if agent, exists := agentMap[agentID]; exists {
+			rankUpVoteMap[agentID] = agent.Team4_GetRankUpVote()
+		}
+	}
+	t.Team4_SetRankUp(rankUpVoteMap)
+}
+
+// Members propose how much they should be allowed to withdraw, and the team
+// votes on each proposal
+func (t *Team4AoA) RunPreWithdrawalAoaLogic(team *Team, agentMap map[uuid.UUID]IExtendedAgent) {
+	proposedWithdrawalMap := make(map[uuid.UUID]int)
+	for _, agentID := range team.Agents {
+		agent, exists := agentMap[agentID]
+		if !exists {
+			continue
+		}
+		proposedWithdrawalMap[agentID] = agent.Team4_GetProposedWithdrawal(agent)
+		agent.Team4_StateProposalToTeam()
+	}
 
+	withdrawalVoteMap := make(map[uuid.UUID]map[uuid.UUID]int)
+	for _, agentID := range team.Agents {
+		if agent, exists := agentMap[agentID]; exists {
+			// Map of AgentId to 1 or 0 for each proposed withdrawal
+			withdrawalVoteMap[agentID] = agent.Team4_GetProposedWithdrawalVote()
+		}
+	}
+	t.Team4_RunProposedWithdrawalVote(proposedWithdrawalMap, withdrawalVoteMap)
 }
 
+// The audited agent confesses to the team, which then votes on the punishment
+// to use should the agent be found guilty
+func (t *Team4AoA) OnAudit(server IAoAHookServer, team *Team, agentMap map[uuid.UUID]IExtendedAgent, agentId uuid.UUID, guilty bool) {
+	if audited, exists := agentMap[agentId]; exists {
+		audited.Team4_StateConfessionToTeam()
+	}
+
+	punishmentVoteMap := make(map[uuid.UUID]map[int]int)
+	for _, agentID := range team.Agents {
+		if agent, exists := agentMap[agentID]; exists {
+			punishmentVoteMap[agentID] = agent.Team4_GetPunishmentVoteMap()
+		}
+	}
+	t.PunishmentVotes[agentId] = t.Team4_HandlePunishmentVote(punishmentVoteMap)
+	log.Printf("Punishment vote for Agent %v: %d%%\n", agentId, t.PunishmentVotes[agentId])
+}
+
+// The team voted on a punishment for an offence it no longer holds against the agent
+func (t *Team4AoA) OnVerdictOverturned(server IAoAHookServer, team *Team, agentMap map[uuid.UUID]IExtendedAgent, agentId uuid.UUID, phase AuditPhase) {
+	delete(t.PunishmentVotes, agentId)
+}
+
+// Unused Functions
+
 func (f *Team4AoA) ResourceAllocation(agentScores map[uuid.UUID]int, remainingResources int) map[uuid.UUID]int {
 	return make(map[uuid.UUID]int)
 }
@@ -430,6 +472,51 @@ func (f *Team4AoA) ResourceAllocation(agentScores map[uuid.UUID]int, remainingRe
 func (t *Team4AoA) RunPreIterationAoaLogic(team *Team, agentMap map[uuid.UUID]IExtendedAgent, dataRecorder *gameRecorder.ServerDataRecorder) {
 }
 
+// Uses the punishment the team voted for when the agent was audited, falling
+// back to 25% if there was no vote
 func (t *Team4AoA) GetPunishment(agentScore int, agentId uuid.UUID) int {
-	return (agentScore * 25) / 100
+	percentage, voted := t.PunishmentVotes[agentId]
+	if !voted {
+		return (agentScore * 25) / 100
+	}
+	delete(t.PunishmentVotes, agentId)
+	return (agentScore * percentage) / 100
+}
+
+func (t *Team4AoA) GetSanction(agentId uuid.UUID, agentScore int) Sanction {
+	return Sanction{Fine: t.GetPunishment(agentScore, agentId)}
+}
+
+type team4AoAState struct {
+	Adventurers map[uuid.UUID]struct {
+		Rank               string
+		ExpectedWithdrawal int
+	}
+	AuditMap        map[uuid.UUID][]int
+	PunishmentVotes map[uuid.UUID]int
+	Rand            RandState
+}
+
+func (t *Team4AoA) SnapshotState() (json.RawMessage, error) {
+	randState, err := GetRandState(t.rng)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(team4AoAState{
+		Adventurers:     t.Adventurers,
+		AuditMap:        t.AuditMap,
+		PunishmentVotes: t.PunishmentVotes,
+		Rand:            randState,
+	})
+}
+
+func (t *Team4AoA) RestoreState(data json.RawMessage) error {
+	var state team4AoAState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	t.Adventurers = state.Adventurers
+	t.AuditMap = state.AuditMap
+	t.PunishmentVotes = state.PunishmentVotes
+	return SetRandState(t.rng, state.Rand)
 }
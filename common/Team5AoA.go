@@ -1,12 +1,13 @@
 package common
 
 import (
+	"encoding/json"
 	// environmentServer "SOMAS_Extended/server"
 	"container/list"
 	"math/rand"
-	"time"
 
 	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+	voting "github.com/ADimoska/SOMASExtended/voting"
 	"github.com/google/uuid"
 )
 
@@ -15,6 +16,9 @@ type Team5AOA struct {
 	WithdrawalAuditMap   map[uuid.UUID]bool
 	ContributionRoundMap map[uuid.UUID]int // Tracks the number of successful contribution rounds for each agent
 	Allocation           map[uuid.UUID]int // Stores the resource allocation for each agent
+	OverContributionMap  map[uuid.UUID]int // How much each agent contributed over what was expected this turn
+	rng                  *rand.Rand
+	contributionAudits   map[uuid.UUID]*list.Element // the contribution each audit this turn judged, in case its verdict is overturned
 }
 
 // ResetAuditMap resets the audit maps for both contribution and withdrawal
@@ -23,6 +27,7 @@ func (f *Team5AOA) ResetAuditMap() {
 	f.WithdrawalAuditMap = make(map[uuid.UUID]bool)
 	f.ContributionRoundMap = make(map[uuid.UUID]int)
 	f.Allocation = make(map[uuid.UUID]int)
+	f.OverContributionMap = make(map[uuid.UUID]int)
 }
 
 // GetExpectedContribution returns the expected contribution from an agent based on its score
@@ -31,20 +36,23 @@ func (f *Team5AOA) GetExpectedContribution(agentId uuid.UUID, agentScore int) in
 	return int(float64(agentScore) * 0.75)
 }
 
-// SetContributionAuditResult sets the audit result for an agent's contribution
+// SetContributionAuditResult sets the audit result for an agent's contribution.
+// Agents are held to the expected contribution rather than what they stated.
 func (f *Team5AOA) SetContributionAuditResult(agentId uuid.UUID, agentScore int, agentActualContribution int, agentStatedContribution int) {
 	if f.ContributionAuditMap[agentId] == nil {
 		f.ContributionAuditMap[agentId] = list.New()
 	}
-	// If the agent's actual contribution does not match the stated contribution, mark it as failed and add to the list
-	f.ContributionAuditMap[agentId].PushBack(agentStatedContribution > agentActualContribution)
+	expectedContribution := f.GetExpectedContribution(agentId, agentScore)
+	// If the agent contributed less than expected, mark it as failed and add to the list
+	f.ContributionAuditMap[agentId].PushBack(expectedContribution > agentActualContribution)
 
 	// Track successful contributions for bonus
-	if agentStatedContribution == agentActualContribution {
+	if expectedContribution == agentActualContribution {
 		f.ContributionRoundMap[agentId]++
 	} else {
 		f.ContributionRoundMap[agentId] = 0 // Reset the count if the contribution is incorrect
 	}
+	f.OverContributionMap[agentId] = max(agentActualContribution-expectedContribution, 0)
 }
 
 // GetContributionAuditResult returns the audit result for an agent's contribution
@@ -53,6 +61,10 @@ func (f *Team5AOA) GetContributionAuditResult(agentId uuid.UUID) bool {
 	if f.ContributionAuditMap[agentId] == nil {
 		return false
 	}
+	if f.contributionAudits == nil {
+		f.contributionAudits = make(map[uuid.UUID]*list.Element)
+	}
+	f.contributionAudits[agentId] = f.ContributionAuditMap[agentId].Back()
 	for e := f.ContributionAuditMap[agentId].Front(); e != nil; e = e.Next() {
 		if e.Value.(bool) {
 			return true
@@ -61,6 +73,20 @@ func (f *Team5AOA) GetContributionAuditResult(agentId uuid.UUID) bool {
 	return false
 }
 
+// An overturned verdict clears the failure the audit found this turn, so that it
+// does not count towards the agent being kicked out
+func (f *Team5AOA) OnVerdictOverturned(server IAoAHookServer, team *Team, agentMap map[uuid.UUID]IExtendedAgent, agentId uuid.UUID, phase AuditPhase) {
+	switch phase {
+	case ContributionPhase:
+		if contribution, audited := f.contributionAudits[agentId]; audited && contribution != nil {
+			contribution.Value = false
+		}
+		delete(f.contributionAudits, agentId)
+	case WithdrawalPhase:
+		f.WithdrawalAuditMap[agentId] = false
+	}
+}
+
 // GetExpectedWithdrawal returns the expected withdrawal for an agent based on ResourceAllocation
 func (f *Team5AOA) GetExpectedWithdrawal(agentId uuid.UUID, agentScore int, commonPool int) int {
 	if val, ok := f.Allocation[agentId]; ok {
@@ -69,14 +95,18 @@ func (f *Team5AOA) GetExpectedWithdrawal(agentId uuid.UUID, agentScore int, comm
 	return 0
 }
 
+// The allocation is binding: agents may not withdraw more than they were allocated
+func (f *Team5AOA) GetWithdrawalEntitlement(agentId uuid.UUID) (int, bool) {
+	entitlement, ok := f.Allocation[agentId]
+	return entitlement, ok
+}
+
 // SetWithdrawalAuditResult sets the audit result for an agent's withdrawal
 func (f *Team5AOA) SetWithdrawalAuditResult(agentId uuid.UUID, agentScore int, agentActualWithdrawal int, agentStatedWithdrawal int, commonPool int) {
-	// If the agent's actual withdrawal does not match the stated withdrawal, mark it as failed
-	if agentActualWithdrawal != agentStatedWithdrawal {
-		f.WithdrawalAuditMap[agentId] = true
-	} else {
-		f.WithdrawalAuditMap[agentId] = false
-	}
+	// If the agent's actual withdrawal does not match the stated withdrawal, or
+	// goes over its allocation, mark it as failed
+	entitlement, _ := f.GetWithdrawalEntitlement(agentId)
+	f.WithdrawalAuditMap[agentId] = agentActualWithdrawal != agentStatedWithdrawal || agentActualWithdrawal > entitlement
 }
 
 // GetWithdrawalAuditResult returns the audit result for an agent's withdrawal
@@ -98,43 +128,24 @@ func (f *Team5AOA) GetAuditCost(commonPool int) int {
 // GetVoteResult determines the agent to be audited based on votes
 // MUST return UUID nil if audit should not be executed
 func (f *Team5AOA) GetVoteResult(votes []Vote) uuid.UUID {
-	voteCount := make(map[uuid.UUID]int)
-	// Count the votes for each agent
-	for _, vote := range votes {
-		if vote.IsVote != 0 {
-			voteCount[vote.VotedForID]++
-		}
-	}
-
-	// Find the agent with the majority of votes
-	var maxVotes int
-	var selectedAgent uuid.UUID
-	for agentID, count := range voteCount {
-		if count > maxVotes {
-			maxVotes = count
-			selectedAgent = agentID
-		}
-	}
+	cast, candidates := auditBallots(votes)
+	result := voting.Plurality(candidates, votedFor(cast))
 
 	// If no agent has more than 50% of the votes, return nil
-	totalVotes := len(votes)
-	if maxVotes > totalVotes/2 {
-		return selectedAgent
+	if agentID, elected := result.Winner(voting.NoTies()); elected && result.Scores[agentID]*2 > float64(len(votes)) {
+		return agentID
 	}
 	return uuid.Nil
 }
 
 // GetWithdrawalOrder returns a shuffled order of agents for withdrawal
 func (t *Team5AOA) GetWithdrawalOrder(agentIDs []uuid.UUID) []uuid.UUID {
-	// Seed the random number generator to ensure different shuffles each time
-	rand.Seed(time.Now().UnixNano())
-
 	// Create a copy of the agentIDs to avoid modifying the original list
 	shuffledAgents := make([]uuid.UUID, len(agentIDs))
 	copy(shuffledAgents, agentIDs)
 
 	// Shuffle the agent list
-	rand.Shuffle(len(shuffledAgents), func(i, j int) {
+	t.rng.Shuffle(len(shuffledAgents), func(i, j int) {
 		shuffledAgents[i], shuffledAgents[j] = shuffledAgents[j], shuffledAgents[i]
 	})
 
@@ -150,6 +161,30 @@ func (f *Team5AOA) GetBonusContribution(agentId uuid.UUID, commonPool int) int {
 	return 0
 }
 
+// GetOverContributionBonus returns the bonus for contributing more than expected this turn
+func (f *Team5AOA) GetOverContributionBonus(agentId uuid.UUID) int {
+	// A quarter of what the agent gave over its expected contribution is paid back
+	return f.OverContributionMap[agentId] / 4
+}
+
+// Members on an honesty streak get the contribution bonus, which starts their
+// streak again, and members who contributed more than expected this turn get
+// some of the excess back
+func (f *Team5AOA) GetRewards(team *Team, agentMap map[uuid.UUID]IExtendedAgent) []Reward {
+	rewards := []Reward{}
+	for _, agentId := range team.Agents {
+		if bonus := f.GetBonusContribution(agentId, team.GetCommonPool()); bonus > 0 {
+			rewards = append(rewards, Reward{AgentID: agentId, Amount: bonus, Reason: "honesty streak"})
+			f.ContributionRoundMap[agentId] = 0
+		}
+		if bonus := f.GetOverContributionBonus(agentId); bonus > 0 {
+			rewards = append(rewards, Reward{AgentID: agentId, Amount: bonus, Reason: "over-contribution"})
+		}
+		delete(f.OverContributionMap, agentId)
+	}
+	return rewards
+}
+
 // ApplyPunishment applies punishment to an agent if they failed an audit
 func (f *Team5AOA) ApplyPunishment(agentId uuid.UUID) bool {
 	if f.GetContributionAuditResult(agentId) || f.GetWithdrawalAuditResult(agentId) {
@@ -179,6 +214,31 @@ func (t *Team5AOA) RunPreIterationAoaLogic(team *Team, agentMap map[uuid.UUID]IE
 }
 func (t *Team5AOA) RunPostContributionAoaLogic(team *Team, agentMap map[uuid.UUID]IExtendedAgent) {}
 
+// Allocate the common pool between the team's members before anyone withdraws
+// from it
+func (t *Team5AOA) RunPreWithdrawalAoaLogic(team *Team, agentMap map[uuid.UUID]IExtendedAgent) {
+	agentScores := make(map[uuid.UUID]int)
+	for _, agentID := range team.Agents {
+		if agent, exists := agentMap[agentID]; exists {
+			agentScores[agentID] = agent.GetTrueScore()
+		}
+	}
+	t.ResourceAllocation(agentScores, team.GetCommonPool())
+}
+
+// Agents are kicked after failing three contribution audits
+func (t *Team5AOA) GetSanction(agentId uuid.UUID, agentScore int) Sanction {
+	sanction := Sanction{Fine: t.GetPunishment(agentScore, agentId)}
+	if t.KickOutAgent(agentId) {
+		sanction.Kick = true
+		sanction.Reason = "three failed audits"
+		delete(t.ContributionAuditMap, agentId)
+		delete(t.ContributionRoundMap, agentId)
+		delete(t.OverContributionMap, agentId)
+	}
+	return sanction
+}
+
 func (f *Team5AOA) ResourceAllocation(agentScores map[uuid.UUID]int, remainingResources int) map[uuid.UUID]int {
 	// Step 1: Calculate the need threshold (T)
 	var scores []int
@@ -191,10 +251,7 @@ func (f *Team5AOA) ResourceAllocation(agentScores map[uuid.UUID]int, remainingRe
 	threshold := max(medianScore, int(float64(meanScore)*alpha))
 
 	// Step 2: Allocate resources based on need level until needs are met or resources are depleted
-	agentIDs := make([]uuid.UUID, 0, len(agentScores))
-	for agentID := range agentScores {
-		agentIDs = append(agentIDs, agentID)
-	}
+	agentIDs := SortedIDs(agentScores)
 
 	// Sort agent IDs based on scores in ascending order (lower scores get higher priority)
 	sortedAgents := make([]uuid.UUID, len(agentIDs))
@@ -207,7 +264,12 @@ func (f *Team5AOA) ResourceAllocation(agentScores map[uuid.UUID]int, remainingRe
 		}
 	}
 
+	// Every agent is allocated something, if only nothing, so that what they may
+	// withdraw is always set
 	allocation := make(map[uuid.UUID]int)
+	for _, agentID := range agentIDs {
+		allocation[agentID] = 0
+	}
 	nPriority := 0
 	for _, agentID := range sortedAgents {
 		if remainingResources <= 0 {
@@ -222,7 +284,7 @@ func (f *Team5AOA) ResourceAllocation(agentScores map[uuid.UUID]int, remainingRe
 	}
 
 	// Step 3: Residual allocation - distribute remaining resources equally among all agents
-	if remainingResources > 0 {
+	if remainingResources > 0 && len(agentScores) > 0 {
 		residual := remainingResources / len(agentScores)
 		for agentID := range agentScores {
 			allocation[agentID] += residual
@@ -281,26 +343,81 @@ func min(a, b int) int {
 	return b
 }
 
-// CreateFixedAoA creates a new instance of Team5AOA
+func init() {
+	RegisterAoA(AoAEntry{
+		ID:   5,
+		Name: "Team5 (resource allocation)",
+		Create: func(team *Team, params AoAParams) IArticlesOfAssociation {
+			return CreateTeam5AoA()
+		},
+	})
+}
+
+// CreateTeam5AoA creates a new instance of Team5AOA
 func CreateTeam5AoA() IArticlesOfAssociation {
 	return &Team5AOA{
 		ContributionAuditMap: make(map[uuid.UUID]*list.List),
 		WithdrawalAuditMap:   make(map[uuid.UUID]bool),
 		ContributionRoundMap: make(map[uuid.UUID]int),
 		Allocation:           make(map[uuid.UUID]int),
+		OverContributionMap:  make(map[uuid.UUID]int),
+		rng:                  NewRandStream("aoa/team5"),
 	}
 }
 
-// Do nothing
-func (t *Team5AOA) Team4_SetRankUp(map[uuid.UUID]map[uuid.UUID]int) {
+func (t *Team5AOA) GetPunishment(agentScore int, agentId uuid.UUID) int {
+	return (agentScore * 25) / 100
 }
 
-func (t *Team5AOA) Team4_RunProposedWithdrawalVote(map[uuid.UUID]int, map[uuid.UUID]map[uuid.UUID]int) {
+type team5AoAState struct {
+	ContributionAuditMap map[uuid.UUID][]bool
+	WithdrawalAuditMap   map[uuid.UUID]bool
+	ContributionRoundMap map[uuid.UUID]int
+	Allocation           map[uuid.UUID]int
+	OverContributionMap  map[uuid.UUID]int
+	Rand                 RandState
 }
-func (t *Team5AOA) Team4_HandlePunishmentVote(map[uuid.UUID]map[int]int) int {
-	return 0
+
+func (f *Team5AOA) SnapshotState() (json.RawMessage, error) {
+	randState, err := GetRandState(f.rng)
+	if err != nil {
+		return nil, err
+	}
+	contributionAudits := make(map[uuid.UUID][]bool, len(f.ContributionAuditMap))
+	for agentID, results := range f.ContributionAuditMap {
+		contributionAudits[agentID] = []bool{}
+		for e := results.Front(); e != nil; e = e.Next() {
+			contributionAudits[agentID] = append(contributionAudits[agentID], e.Value.(bool))
+		}
+	}
+	return json.Marshal(team5AoAState{
+		ContributionAuditMap: contributionAudits,
+		WithdrawalAuditMap:   f.WithdrawalAuditMap,
+		ContributionRoundMap: f.ContributionRoundMap,
+		Allocation:           f.Allocation,
+		OverContributionMap:  f.OverContributionMap,
+		Rand:                 randState,
+	})
 }
 
-func (t *Team5AOA) GetPunishment(agentScore int, agentId uuid.UUID) int {
-	return (agentScore * 25) / 100
+func (f *Team5AOA) RestoreState(data json.RawMessage) error {
+	var state team5AoAState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	f.ContributionAuditMap = make(map[uuid.UUID]*list.List, len(state.ContributionAuditMap))
+	for agentID, results := range state.ContributionAuditMap {
+		f.ContributionAuditMap[agentID] = list.New()
+		for _, result := range results {
+			f.ContributionAuditMap[agentID].PushBack(result)
+		}
+	}
+	f.WithdrawalAuditMap = state.WithdrawalAuditMap
+	f.ContributionRoundMap = state.ContributionRoundMap
+	f.Allocation = state.Allocation
+	f.OverContributionMap = state.OverContributionMap
+	if f.OverContributionMap == nil {
+		f.OverContributionMap = make(map[uuid.UUID]int)
+	}
+	return SetRandState(f.rng, state.Rand)
 }
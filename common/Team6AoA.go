@@ -1,12 +1,12 @@
 package common
 
 import (
-	"time"
-
+	"encoding"
+	"encoding/json"
 	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+	voting "github.com/ADimoska/SOMASExtended/voting"
 	"github.com/google/uuid"
 	"golang.org/x/exp/rand"
-	"gonum.org/v1/gonum/stat/distuv"
 )
 
 type CheatingRecord struct {
@@ -24,6 +24,26 @@ type Team6AoA struct {
 	auditHistory            map[uuid.UUID][]*CheatingRecord // Audit history per agent
 	agentsToMonitor         map[uuid.UUID]int64             // Monitoring tracking
 
+	src rand.Source // Random source for monitoring checks and withdrawal order
+
+	auditFindings map[AuditPhase]map[uuid.UUID]team6AuditFinding // What each audit this turn found, in case its verdict is overturned
+}
+
+// The record an audit found the agent cheating in, and whether that put the
+// agent under monitoring
+type team6AuditFinding struct {
+	record  int
+	monitor bool
+}
+
+func init() {
+	RegisterAoA(AoAEntry{
+		ID:   6,
+		Name: "Team6 (monitoring)",
+		Create: func(team *Team, params AoAParams) IArticlesOfAssociation {
+			return CreateTeam6AoA()
+		},
+	})
 }
 
 func CreateTeam6AoA() IArticlesOfAssociation {
@@ -35,6 +55,8 @@ func CreateTeam6AoA() IArticlesOfAssociation {
 		currentContributions:    make(map[uuid.UUID]float64),           // Current turn contributions
 		auditHistory:            make(map[uuid.UUID][]*CheatingRecord), // Audit history per agent
 		agentsToMonitor:         make(map[uuid.UUID]int64),
+
+		src: rand.NewSource(uint64(NewRandStream("aoa/team6").Int63())),
 	}
 }
 
@@ -151,28 +173,19 @@ func (t *Team6AoA) GetVoteResult(votes []Vote) uuid.UUID {
 
 	votingPower := t.CalculateVotingPower()
 
-	voteTotals := make(map[uuid.UUID]float64)
-
-	for _, vote := range votes {
-		if vote.IsVote == 1 {
-			voteTotals[vote.VotedForID] += votingPower[vote.VoterID]
-		}
+	cast, candidates := auditBallots(votes)
+	weights := make([]float64, len(cast))
+	for i, vote := range cast {
+		weights[i] = votingPower[vote.VoterID]
 	}
+	result := voting.WeightedPlurality(candidates, votedFor(cast), weights)
 
 	// Find the agent with the highest vote count above threshold (50%)
 	const auditThreshold = 0.5
-	var maxVotedAgent uuid.UUID
-	maxVotes := 0.0
-
-	for agentID, voteTotal := range voteTotals {
-		if voteTotal > maxVotes && voteTotal > auditThreshold {
-			maxVotedAgent = agentID
-			maxVotes = voteTotal
-		}
+	if agentID, elected := result.Winner(voting.FirstListed()); elected && result.Scores[agentID] > auditThreshold {
+		return agentID
 	}
-
-	return maxVotedAgent // Returns uuid.Nil if no agent meets the threshold
-
+	return uuid.Nil // No agent meets the threshold
 }
 
 // Mointoring: 3 stages
@@ -180,14 +193,35 @@ func (t *Team6AoA) GetVoteResult(votes []Vote) uuid.UUID {
 // 1 -> monitoring stage 1
 // 2 -> stage 2
 // 3 -> stage 3
+//
+// Each stage watches more closely, finding each unit cheated with a higher
+// probability. The amount of evidence found is Poisson distributed, see
+// DetectPoisson.
+func monitoringQuality(monitStage int64) AuditQuality {
+	detection := 1.0
+	if monitStage == 1 {
+		detection = 0.5
+	} else if monitStage == 2 {
+		detection = 0.75
+	}
+	return AuditQuality{Lookback: 1, Detection: detection, Model: DetectPoisson}
+}
+
 func (t *Team6AoA) RunContributionMonitoring() {
+	t.runMonitoring()
+}
+
+func (t *Team6AoA) RunWithdrawalMonitoring() {
+	t.runMonitoring()
+}
+
+func (t *Team6AoA) runMonitoring() {
 	// for all agent in monitoring system
 
 	// for now, we're saying monitoring is included in cost of audit
 
-	for monitAgent, monitStage := range t.agentsToMonitor {
-
-		source := rand.NewSource(uint64(time.Now().UnixNano()))
+	for _, monitAgent := range SortedIDs(t.agentsToMonitor) {
+		monitStage := t.agentsToMonitor[monitAgent]
 
 		// Check if agent has any audit history
 		if monitHistory, monitExists := t.auditHistory[monitAgent]; monitExists && len(monitHistory) > 0 {
@@ -197,69 +231,20 @@ func (t *Team6AoA) RunContributionMonitoring() {
 			if lastMonitRecord == nil {
 				// if agent being monitored was good last turn, move them down a stage
 				t.agentsToMonitor[monitAgent] -= 1
+			} else if monitStage > 3 || monitoringQuality(monitStage).Detect(lastMonitRecord.CheatedAmount, rand.New(t.src)) > 0 {
+				// agent gets caught
+				t.agentsToMonitor[monitAgent] += 1
 			} else {
-				if monitStage == 1 {
-					// stage 1, half of actual
-					lambda := float64((lastMonitRecord.Actual + lastMonitRecord.Expected) / 2)
-					poisson := distuv.Poisson{
-						Lambda: lambda,           // The rate parameter
-						Src:    rand.New(source), // Random source
-					}
-					monitCheck := poisson.Rand()
-
-					if monitCheck <= float64(lastMonitRecord.Expected) {
-						// agent gets away with it
-						t.agentsToMonitor[monitAgent] -= 1
-					} else {
-						// agent gets caught
-						t.agentsToMonitor[monitAgent] += 1
-					}
-
-				} else if monitStage == 2 {
-					// stage 2, 3/4 of actual
-
-					halfwayActualExp := (lastMonitRecord.Actual + lastMonitRecord.Expected) / 2
-
-					lambda := float64((halfwayActualExp + lastMonitRecord.Actual) / 2)
-					poisson := distuv.Poisson{
-						Lambda: lambda,           // The rate parameter
-						Src:    rand.New(source), // Random source
-					}
-					monitCheck := poisson.Rand()
-
-					if monitCheck <= float64(lastMonitRecord.Expected) {
-						// agent gets away with it
-						t.agentsToMonitor[monitAgent] -= 1
-					} else {
-						// agent gets caught
-						t.agentsToMonitor[monitAgent] += 1
-					}
-
-				} else if monitStage >= 3 {
-					// stage 3, full actual
-					lambda := float64(lastMonitRecord.Actual)
-					poisson := distuv.Poisson{
-						Lambda: lambda,           // The rate parameter
-						Src:    rand.New(source), // Random source
-					}
-					monitCheck := poisson.Rand()
-
-					if monitCheck <= float64(lastMonitRecord.Expected) && monitStage == 3 {
-						// agent gets away with it
-						t.agentsToMonitor[monitAgent] -= 1
-					} else {
-						// agent gets caught
-						t.agentsToMonitor[monitAgent] += 1
-					}
-				}
+				// agent gets away with it
+				t.agentsToMonitor[monitAgent] -= 1
 			}
 
 			if monitStage < 0 {
 				// monit stage can't be negative
 				t.agentsToMonitor[monitAgent] = 0
 			} else if monitStage > 3 {
-				// agent has passed stage 3 of monitoring, must get kicked out
-				// KILL/KICKOUT AGENT
+				// agent has passed stage 3 of monitoring, it is kicked out by
+				// GetSanction the next time it is found guilty
 			}
 		}
 		if monitStage == 0 {
@@ -278,120 +263,18 @@ func (t *Team6AoA) GetContributionAuditResult(agentId uuid.UUID) bool {
 	// first, run monitoring for all agents being monitored!
 	t.RunContributionMonitoring()
 
-	// Check if agent has any audit history
-	if history, exists := t.auditHistory[agentId]; exists && len(history) > 0 {
-		// Get the most recent audit record
-		lastRecord := history[len(history)-1]
-		// If lastRecord is not nil, it means the agent cheated in their last contribution
-
-		cheatCheck := lastRecord != nil
-		_, inMonitMap := t.agentsToMonitor[agentId]
-
-		if inMonitMap {
-			// if this agent is already in monitoring map, do nothing
-			// if it cheated, that'll already have been sorted by the RunMonitoring Function
-		} else if cheatCheck {
-			// this agent cheated, but isn't in monitoring map
-			// we must add it in at stage 1!
-			t.agentsToMonitor[agentId] = 1
-		}
-
-		return cheatCheck
-	}
-	return false // No history or empty history means no detected cheating
-}
-
-func (t *Team6AoA) RunWithdrawalMonitoring() {
-	// for all agent in monitoring system
-
-	// for now, we're saying monitoring is
-	// included as part of audit cost
-
-	for monitAgent, monitStage := range t.agentsToMonitor {
-
-		source := rand.NewSource(uint64(time.Now().UnixNano()))
-
-		// Check if agent has any audit history
-		if monitHistory, monitExists := t.auditHistory[monitAgent]; monitExists && len(monitHistory) > 0 {
-
-			lastMonitRecord := monitHistory[len(monitHistory)-1]
-
-			if lastMonitRecord == nil {
-				// if agent being monitored was good last turn, move them down a stage
-				t.agentsToMonitor[monitAgent] -= 1
-			} else {
-				if monitStage == 1 {
-					// stage 1, half of actual
-					lambda := float64(lastMonitRecord.Actual / 2)
-					poisson := distuv.Poisson{
-						Lambda: lambda,           // The rate parameter
-						Src:    rand.New(source), // Random source
-					}
-					monitCheck := poisson.Rand()
-
-					if monitCheck >= float64(lastMonitRecord.Expected) {
-						// agent gets away with it
-						t.agentsToMonitor[monitAgent] -= 1
-					} else {
-						// agent gets caught
-						t.agentsToMonitor[monitAgent] += 1
-					}
-
-				} else if monitStage == 2 {
-					// stage 2, 3/4 of actual
-					lambda := float64(3 * lastMonitRecord.Actual / 4)
-					poisson := distuv.Poisson{
-						Lambda: lambda,           // The rate parameter
-						Src:    rand.New(source), // Random source
-					}
-					monitCheck := poisson.Rand()
-
-					if monitCheck >= float64(lastMonitRecord.Expected) {
-						// agent gets away with it
-						t.agentsToMonitor[monitAgent] -= 1
-					} else {
-						// agent gets caught
-						t.agentsToMonitor[monitAgent] += 1
-					}
-
-				} else if monitStage >= 3 {
-					// stage 3, full actual
-					lambda := float64(lastMonitRecord.Actual)
-					poisson := distuv.Poisson{
-						Lambda: lambda,           // The rate parameter
-						Src:    rand.New(source), // Random source
-					}
-					monitCheck := poisson.Rand()
-
-					if monitCheck >= float64(lastMonitRecord.Expected) && monitStage == 3 {
-						// agent gets away with it
-						t.agentsToMonitor[monitAgent] -= 1
-					} else {
-						// agent gets caught
-						t.agentsToMonitor[monitAgent] += 1
-					}
-				}
-			}
-			if monitStage < 0 {
-				// monit stage can't be negative
-				t.agentsToMonitor[monitAgent] = 0
-			} else if monitStage > 3 {
-				// agent has passed stage 3 of monitoring, must get kicked out
-				// KILL/KICKOUT AGENT
-			}
-		}
-		if monitStage == 0 {
-			// if the monit stage of this agent in system drops from 1 -> 0, free it from monitoring
-			delete(t.agentsToMonitor, monitAgent)
-		}
-
-	}
+	return t.auditIn(ContributionPhase, agentId)
 }
 
 func (t *Team6AoA) GetWithdrawalAuditResult(agentId uuid.UUID) bool {
 	// first, run monitoring for all agents being monitored!
 	t.RunWithdrawalMonitoring()
 
+	return t.auditIn(WithdrawalPhase, agentId)
+}
+
+// Check the agent's last record, remembering what was found in case the verdict is overturned
+func (t *Team6AoA) auditIn(phase AuditPhase, agentId uuid.UUID) bool {
 	// Check if agent has any audit history
 	if history, exists := t.auditHistory[agentId]; exists && len(history) > 0 {
 		// Get the most recent audit record
@@ -410,11 +293,36 @@ func (t *Team6AoA) GetWithdrawalAuditResult(agentId uuid.UUID) bool {
 			t.agentsToMonitor[agentId] = 1
 		}
 
+		if cheatCheck {
+			if t.auditFindings == nil {
+				t.auditFindings = make(map[AuditPhase]map[uuid.UUID]team6AuditFinding)
+			}
+			if t.auditFindings[phase] == nil {
+				t.auditFindings[phase] = make(map[uuid.UUID]team6AuditFinding)
+			}
+			t.auditFindings[phase][agentId] = team6AuditFinding{record: len(history) - 1, monitor: !inMonitMap}
+		}
 		return cheatCheck
 	}
 	return false // No history or empty history means no detected cheating
 }
 
+// An overturned verdict clears the cheating the audit found from the agent's
+// history, and frees it from monitoring if that is what put it there
+func (t *Team6AoA) OnVerdictOverturned(server IAoAHookServer, team *Team, agentMap map[uuid.UUID]IExtendedAgent, agentId uuid.UUID, phase AuditPhase) {
+	finding, audited := t.auditFindings[phase][agentId]
+	if !audited {
+		return
+	}
+	delete(t.auditFindings[phase], agentId)
+	if history := t.auditHistory[agentId]; finding.record < len(history) {
+		history[finding.record] = nil
+	}
+	if finding.monitor {
+		delete(t.agentsToMonitor, agentId)
+	}
+}
+
 func (t *Team6AoA) CalculateVotingPower() map[uuid.UUID]float64 {
 	weightedContributions := make(map[uuid.UUID]float64)
 	totalWeighted := 0.0
@@ -478,14 +386,25 @@ func (t *Team6AoA) GetPunishment(agentScore int, agentID uuid.UUID) int { // Pun
 	return int(deduction)
 }
 
+// Agents that have got past stage 3 of monitoring lose their whole score and
+// are kicked out
+func (t *Team6AoA) GetSanction(agentID uuid.UUID, agentScore int) Sanction {
+	sanction := Sanction{Fine: t.GetPunishment(agentScore, agentID)}
+	if t.agentsToMonitor[agentID] > 3 {
+		sanction.Kick = true
+		sanction.Reason = "failed stage 3 monitoring"
+		delete(t.agentsToMonitor, agentID)
+	}
+	return sanction
+}
+
 func (t *Team6AoA) GetWithdrawalOrder(agentIDs []uuid.UUID) []uuid.UUID {
-	// math.rand.Seed(time.Now().UnixNano())
 	// Create a copy of the agentIDs to avoid modifying the original list
 	shuffledAgents := make([]uuid.UUID, len(agentIDs))
 	copy(shuffledAgents, agentIDs)
 
 	// Shuffle the agent list
-	rand.Shuffle(len(shuffledAgents), func(i, j int) {
+	rand.New(t.src).Shuffle(len(shuffledAgents), func(i, j int) {
 		shuffledAgents[i], shuffledAgents[j] = shuffledAgents[j], shuffledAgents[i]
 	})
 
@@ -504,18 +423,45 @@ func (t *Team6AoA) RunPreIterationAoaLogic(team *Team, agentMap map[uuid.UUID]IE
 }
 
 // not needed, dw abt it, here to fix error complaints
-func (t *Team6AoA) Team4_HandlePunishmentVote(map[uuid.UUID]map[int]int) int {
-	return 0
+func (t *Team6AoA) RunPostContributionAoaLogic(team *Team, agentMap map[uuid.UUID]IExtendedAgent) {
 }
 
-// not needed, dw abt it, here to fix error complaints
-func (t *Team6AoA) Team4_RunProposedWithdrawalVote(map[uuid.UUID]int, map[uuid.UUID]map[uuid.UUID]int) {
+type team6AoAState struct {
+	Weight                  float64
+	Decay                   float64
+	CumulativeContributions map[uuid.UUID]float64
+	CurrentContributions    map[uuid.UUID]float64
+	AuditHistory            map[uuid.UUID][]*CheatingRecord
+	AgentsToMonitor         map[uuid.UUID]int64
+	Source                  []byte
 }
 
-// not needed, dw abt it, here to fix error complaints
-func (t *Team6AoA) Team4_SetRankUp(rankUpVoteMap map[uuid.UUID]map[uuid.UUID]int) {
+func (t *Team6AoA) SnapshotState() (json.RawMessage, error) {
+	source, err := t.src.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(team6AoAState{
+		Weight:                  t.weight,
+		Decay:                   t.decay,
+		CumulativeContributions: t.cumulativeContributions,
+		CurrentContributions:    t.currentContributions,
+		AuditHistory:            t.auditHistory,
+		AgentsToMonitor:         t.agentsToMonitor,
+		Source:                  source,
+	})
 }
 
-// not needed, dw abt it, here to fix error complaints
-func (t *Team6AoA) RunPostContributionAoaLogic(team *Team, agentMap map[uuid.UUID]IExtendedAgent) {
+func (t *Team6AoA) RestoreState(data json.RawMessage) error {
+	var state team6AoAState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	t.weight = state.Weight
+	t.decay = state.Decay
+	t.cumulativeContributions = state.CumulativeContributions
+	t.currentContributions = state.CurrentContributions
+	t.auditHistory = state.AuditHistory
+	t.agentsToMonitor = state.AgentsToMonitor
+	return t.src.(encoding.BinaryUnmarshaler).UnmarshalBinary(state.Source)
 }
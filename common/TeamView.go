@@ -0,0 +1,126 @@
+package common
+
+import (
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+/*
+* A read-only snapshot of a team, which is what agents are given in place of
+* the live *Team (see IServer.GetTeam). It is copied when asked for, so changing
+* it has no effect on the game and it goes stale once the team changes: ask the
+* server again rather than holding on to it. Only the server changes teams.
+*
+* The zero TeamView, with a nil TeamID, is returned for agents without a team.
+ */
+type TeamView struct {
+	TeamID     uuid.UUID
+	Agents     []uuid.UUID
+	AoAID      int
+	CommonPool int // also available through IServer.GetTeamCommonPool
+
+	knownThreshold int
+	validThreshold bool
+	aoa            IArticlesOfAssociation
+}
+
+// Take a snapshot of the team to hand to agents
+func (team *Team) View() TeamView {
+	if team == nil {
+		return TeamView{}
+	}
+	return TeamView{
+		TeamID:         team.TeamID,
+		Agents:         slices.Clone(team.Agents),
+		AoAID:          team.TeamAoAID,
+		CommonPool:     team.commonPool,
+		knownThreshold: team.knownThreshold,
+		validThreshold: team.validThreshold,
+		aoa:            team.TeamAoA,
+	}
+}
+
+// Whether the view is of an actual team, rather than the zero view handed to
+// agents without one
+func (view TeamView) Exists() bool {
+	return view.TeamID != uuid.Nil
+}
+
+// The threshold, and whether it is known. It is only known on the turns the
+// server exposes it.
+func (view TeamView) GetKnownThreshold() (int, bool) {
+	return view.knownThreshold, view.validThreshold
+}
+
+// What the team's AoA expects the agent to contribute this turn
+func (view TeamView) GetExpectedContribution(agentID uuid.UUID, agentScore int) int {
+	if view.aoa == nil {
+		return 0
+	}
+	return view.aoa.GetExpectedContribution(agentID, agentScore)
+}
+
+// What the team's AoA expects the agent to withdraw this turn
+func (view TeamView) GetExpectedWithdrawal(agentID uuid.UUID, agentScore int, commonPool int) int {
+	if view.aoa == nil {
+		return 0
+	}
+	return view.aoa.GetExpectedWithdrawal(agentID, agentScore, commonPool)
+}
+
+// The most the agent is entitled to withdraw this turn, if the team's AoA sets
+// entitlements (see IWithdrawalEntitlementAoA). They are set just before
+// members withdraw.
+func (view TeamView) GetWithdrawalEntitlement(agentID uuid.UUID) (int, bool) {
+	aoa, ok := view.aoa.(IWithdrawalEntitlementAoA)
+	if !ok {
+		return 0, false
+	}
+	return aoa.GetWithdrawalEntitlement(agentID)
+}
+
+// The team's leader, if its AoA has one (see ILeaderAoA). uuid.Nil while the
+// post is empty.
+func (view TeamView) GetLeader() (uuid.UUID, bool) {
+	aoa, ok := view.aoa.(ILeaderAoA)
+	if !ok {
+		return uuid.Nil, false
+	}
+	return aoa.GetLeader(), true
+}
+
+// --------- AoA specific queries ---------
+
+// Read-only queries on the ranks of a Team1 AoA
+type Team1RankView interface {
+	GetAgentRank(agentId uuid.UUID) int
+	GetAgentNewRank(agentId uuid.UUID) int
+	GetRankThresholds() [5]int
+}
+
+// Wraps the AoA so that agents cannot type assert their way back to it
+type team1RankView struct {
+	aoa *Team1AoA
+}
+
+func (v team1RankView) GetAgentRank(agentId uuid.UUID) int {
+	return v.aoa.GetAgentRank(agentId)
+}
+
+func (v team1RankView) GetAgentNewRank(agentId uuid.UUID) int {
+	return v.aoa.GetAgentNewRank(agentId)
+}
+
+func (v team1RankView) GetRankThresholds() [5]int {
+	return v.aoa.GetRankThresholds()
+}
+
+// The team's ranks, if it is run by the Team1 AoA
+func (view TeamView) Team1Ranks() (Team1RankView, bool) {
+	aoa, ok := view.aoa.(*Team1AoA)
+	if !ok {
+		return nil, false
+	}
+	return team1RankView{aoa}, true
+}
@@ -0,0 +1,264 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+/*
+* Agents share what they think of each other as Opinions, which every team's
+* agents can read the same way: how far the holder trusts the subject, how sure
+* it is, and what it has to go on. Each agent keeps the opinions it holds and
+* those it has been told in a TrustNetwork, and a trust model combines them
+* into how far it trusts any one agent. The server picks the model, and
+* records every agent's opinions as the trust graph at the end of each turn.
+ */
+
+type EvidenceType string
+
+const (
+	EvidenceAudit     EvidenceType = "audit"     // the subject's audit results
+	EvidenceStatement EvidenceType = "statement" // what the subject stated, against what was expected of it
+	EvidenceHearsay   EvidenceType = "hearsay"   // what other agents said of the subject
+	EvidenceRecord    EvidenceType = "record"    // the subject's offences on the server's registry
+)
+
+// One agent's opinion of another
+type Opinion struct {
+	Subject    uuid.UUID
+	Trust      float64 // from 0 for no trust at all to 1 for complete trust
+	Confidence float64 // how much the opinion should count, from 0 to 1
+	Evidence   EvidenceType
+}
+
+// An opinion, with trust and confidence kept between 0 and 1
+func NewOpinion(subject uuid.UUID, trust, confidence float64, evidence EvidenceType) Opinion {
+	return Opinion{
+		Subject:    subject,
+		Trust:      math.Max(0, math.Min(trust, 1)),
+		Confidence: math.Max(0, math.Min(confidence, 1)),
+		Evidence:   evidence,
+	}
+}
+
+// An opinion held by one agent of another, an edge of the trust graph
+type TrustEdge struct {
+	Truster uuid.UUID
+	Opinion
+}
+
+// Combines the opinions an agent knows of into how far it trusts each agent
+type ITrustModel interface {
+	// How far the truster trusts the subject, from 0 to 1, given every
+	// opinion it knows of (its own included)
+	Trust(truster, subject uuid.UUID, edges []TrustEdge) float64
+}
+
+type TrustModelName string
+
+const (
+	TrustBeta       TrustModelName = "beta"
+	TrustEigenTrust TrustModelName = "eigentrust"
+)
+
+var TrustModelNames = []TrustModelName{TrustBeta, TrustEigenTrust}
+
+// The trust model with the given name, with its default parameters
+func NewTrustModel(name TrustModelName) (ITrustModel, error) {
+	switch name {
+	case TrustBeta:
+		return BetaReputation{}, nil
+	case TrustEigenTrust:
+		return EigenTrust{Damping: 0.15, Iterations: 50}, nil
+	}
+	return nil, fmt.Errorf("unknown trust model %q, must be one of %v", name, TrustModelNames)
+}
+
+/*
+* Beta reputation: every opinion of the subject counts as Confidence
+* observations, Trust of them good and the rest bad, and the trust is the
+* expected value of the beta distribution they give. The truster's own opinion
+* counts in full, and anyone else's is discounted by how far the truster trusts
+* them itself, so that unknown agents count for half.
+ */
+type BetaReputation struct{}
+
+func (BetaReputation) Trust(truster, subject uuid.UUID, edges []TrustEdge) float64 {
+	direct := map[uuid.UUID]float64{}
+	for _, edge := range edges {
+		if edge.Truster == truster {
+			direct[edge.Subject] = betaExpectation(edge.Trust*edge.Confidence, (1-edge.Trust)*edge.Confidence)
+		}
+	}
+
+	good, bad := 0.0, 0.0
+	for _, edge := range edges {
+		if edge.Subject != subject || edge.Truster == subject {
+			continue
+		}
+		weight := 1.0
+		if edge.Truster != truster {
+			weight = 0.5
+			if trust, known := direct[edge.Truster]; known {
+				weight = trust
+			}
+		}
+		good += weight * edge.Trust * edge.Confidence
+		bad += weight * (1 - edge.Trust) * edge.Confidence
+	}
+	return betaExpectation(good, bad)
+}
+
+func betaExpectation(good, bad float64) float64 {
+	return (good + 1) / (good + bad + 2)
+}
+
+/*
+* EigenTrust: each agent's opinions, weighted by their confidence, are
+* normalised into how it shares out its trust, and trust flows along them
+* until it settles. With probability Damping it instead restarts from every
+* agent equally, as EigenTrust does from its pre-trusted peers. The result is
+* the same for every truster, and is given relative to the most trusted agent.
+ */
+type EigenTrust struct {
+	Damping    float64 // from 0 to 1
+	Iterations int     // the most rounds of flow before giving up on it settling
+}
+
+func (model EigenTrust) Trust(truster, subject uuid.UUID, edges []TrustEdge) float64 {
+	trust := model.GlobalTrust(edges)
+	best := 0.0
+	for _, value := range trust {
+		best = math.Max(best, value)
+	}
+	if best == 0 {
+		return 0.5
+	}
+	if value, known := trust[subject]; known {
+		return value / best
+	}
+	return 0.5
+}
+
+// The trust of every agent in the graph, adding up to 1
+func (model EigenTrust) GlobalTrust(edges []TrustEdge) map[uuid.UUID]float64 {
+	peers := map[uuid.UUID]struct{}{}
+	local := map[uuid.UUID]map[uuid.UUID]float64{}
+	for _, edge := range edges {
+		peers[edge.Truster] = struct{}{}
+		peers[edge.Subject] = struct{}{}
+		if edge.Truster == edge.Subject {
+			continue
+		}
+		if local[edge.Truster] == nil {
+			local[edge.Truster] = map[uuid.UUID]float64{}
+		}
+		local[edge.Truster][edge.Subject] += edge.Trust * edge.Confidence
+	}
+	order := SortedIDs(peers)
+	if len(order) == 0 {
+		return map[uuid.UUID]float64{}
+	}
+
+	start := 1 / float64(len(order))
+	trust := map[uuid.UUID]float64{}
+	for _, peer := range order {
+		trust[peer] = start
+	}
+	for i := 0; i < model.Iterations; i++ {
+		next := map[uuid.UUID]float64{}
+		for _, peer := range order {
+			next[peer] = model.Damping * start
+		}
+		for _, from := range order {
+			total := 0.0
+			for _, value := range local[from] {
+				total += value
+			}
+			if total == 0 {
+				// trusts nobody, so shares its trust out equally
+				for _, to := range order {
+					next[to] += (1 - model.Damping) * trust[from] * start
+				}
+				continue
+			}
+			for _, to := range SortedIDs(local[from]) {
+				next[to] += (1 - model.Damping) * trust[from] * local[from][to] / total
+			}
+		}
+		change := 0.0
+		for _, peer := range order {
+			change += math.Abs(next[peer] - trust[peer])
+		}
+		trust = next
+		if change < 1e-9 {
+			break
+		}
+	}
+	return trust
+}
+
+// The opinions an agent holds and has been told, the latest from each truster
+// of each subject. Opinions arrive in message handlers while the server reads
+// the network, so it is safe for concurrent use.
+type TrustNetwork struct {
+	mutex sync.RWMutex
+	edges map[uuid.UUID]map[uuid.UUID]Opinion
+}
+
+func NewTrustNetwork() *TrustNetwork {
+	return &TrustNetwork{edges: map[uuid.UUID]map[uuid.UUID]Opinion{}}
+}
+
+// Note the truster's opinion, in place of any it held before of the subject
+func (n *TrustNetwork) Observe(truster uuid.UUID, opinion Opinion) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.edges[truster] == nil {
+		n.edges[truster] = map[uuid.UUID]Opinion{}
+	}
+	n.edges[truster][opinion.Subject] = opinion
+}
+
+// The truster's opinions, by subject
+func (n *TrustNetwork) OpinionsOf(truster uuid.UUID) []Opinion {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return n.opinionsOf(truster)
+}
+
+func (n *TrustNetwork) opinionsOf(truster uuid.UUID) []Opinion {
+	opinions := []Opinion{}
+	for _, subject := range SortedIDs(n.edges[truster]) {
+		opinions = append(opinions, n.edges[truster][subject])
+	}
+	return opinions
+}
+
+// Every opinion in the network, by truster and then subject
+func (n *TrustNetwork) Edges() []TrustEdge {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	edges := []TrustEdge{}
+	for _, truster := range SortedIDs(n.edges) {
+		for _, opinion := range n.opinionsOf(truster) {
+			edges = append(edges, TrustEdge{Truster: truster, Opinion: opinion})
+		}
+	}
+	return edges
+}
+
+// The network's opinions, with the truster's own replaced by the given ones
+func (n *TrustNetwork) EdgesWith(truster uuid.UUID, own []Opinion) []TrustEdge {
+	edges := slices.DeleteFunc(n.Edges(), func(edge TrustEdge) bool {
+		return edge.Truster == truster
+	})
+	for _, opinion := range own {
+		edges = append(edges, TrustEdge{Truster: truster, Opinion: opinion})
+	}
+	return edges
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"github.com/MattSScott/basePlatformSOMAS/v2/pkg/message"
+	"github.com/google/uuid"
+)
+
+/*
+* Besides voting for an audit, a member that has noticed a teammate cheating
+* can blow the whistle on them by handing the server a WhistleblowerMessage
+* (IServer.ReportCheating). The server passes every report to the team's AoA
+* in the whistleblowing phase, after both audits. An AoA that takes reports
+* (IWhistleblowerAoA) decides which to act on, and those it does are audited,
+* unless the accused has already been audited for that phase this turn, in
+* which case that verdict stands. The whistleblower is rewarded if the accused
+* is found guilty and may be sanctioned if not. AoAs that do not take reports
+* ignore them. Either way every report is recorded in the event log.
+ */
+
+// The part of the turn an accusation is about, which is the audit it asks for
+type AuditPhase string
+
+const (
+	ContributionPhase AuditPhase = "contribution"
+	WithdrawalPhase   AuditPhase = "withdrawal"
+)
+
+// What made the whistleblower suspicious, e.g. what the accused stated it
+// contributed against what the AoA expected of it
+type WhistleblowerEvidence struct {
+	StatedAmount   int
+	ExpectedAmount int
+	Note           string
+}
+
+type WhistleblowerMessage struct {
+	message.BaseMessage
+	Accused  uuid.UUID
+	Phase    AuditPhase
+	Turn     int // set by the server to the turn the report was made in
+	Evidence WhistleblowerEvidence
+}
+
+// Whistleblowing phase: the AoA acts on members' reports of cheating. AoAs
+// that do not implement this ignore reports.
+type IWhistleblowerAoA interface {
+	// Whether to audit the accused on the strength of the report
+	ReviewReport(report *WhistleblowerMessage) bool
+	// Paid to the whistleblower from the common pool when the accused is found guilty
+	GetReportReward(report *WhistleblowerMessage) int
+	// Carried out on the whistleblower when the accused is found innocent
+	GetFalseReportSanction(report *WhistleblowerMessage, agentScore int) Sanction
+}
@@ -8,6 +8,8 @@ import (
 	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
 )
 
+// A team as the server holds it. Agents are only given read-only snapshots of
+// it, see TeamView.
 type Team struct {
 	TeamID         uuid.UUID
 	Agents         []uuid.UUID
@@ -48,16 +50,15 @@ func NewTeam(teamID uuid.UUID) *Team {
 
 /**
 * Set the known threshold so that agents can adapt their behaviour based on
-* this. AGENTS PLEASE DON'T CALL THIS - in an ideal world we would use
-* pre-signed certificates to know that only the server updated this but our
-* code is not prioritising security.
+* this. Only the server has the live team, agents see the threshold through
+* TeamView.GetKnownThreshold.
  */
 func (team *Team) SetKnownThreshold(threshold int) {
 	team.knownThreshold = threshold
 	team.validThreshold = true
 }
 
-// Same as above, @agents please don't call this
+// Mark the known threshold as out of date, server only as above
 func (team *Team) InvalidateThreshold() {
 	team.validThreshold = false
 }
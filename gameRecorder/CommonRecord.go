@@ -10,6 +10,13 @@ type CommonRecord struct {
 
 	Threshold              int  // current threshold set by server
 	ThresholdAppliedInTurn bool // whether the threshold was applied in the current turn
+
+	// how the threshold is set and enforced, so runs with different rules can be told apart
+	ThresholdPolicy   string // policy name and parameters, e.g. "uniform(low=0, high=10, slope=1)"
+	ThresholdAction   string // what happens to agents below the threshold: kill, fine or orphan
+	ThresholdDeducted bool   // whether agents that meet the threshold pay it
+
+	AuditPayer string // who pays for audits: pool, voters or guilty
 }
 
 func NewCommonRecord(turnNumber int, iterationNumber int, threshold int, thresholdAppliedInTurn bool) CommonRecord {
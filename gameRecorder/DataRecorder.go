@@ -34,6 +34,10 @@ type ServerDataRecorder struct {
 	currentIteration int
 	currentTurn      int
 	Turnteam1Rank    []Team1RankRecord
+	SanctionRecords  []SanctionRecord
+
+	events             *EventLog // optional, see EventLog.go
+	eventsAtCheckpoint int       // events logged when the checkpoint this recorder was restored from was saved
 }
 
 func (sdr *ServerDataRecorder) GetCurrentTurnRecord() *TurnRecord {
@@ -57,7 +61,17 @@ func (sdr *ServerDataRecorder) RecordNewIteration() {
 }
 
 func (sdr *ServerDataRecorder) RecordTeam1RankBoundaries(TeamID uuid.UUID, boundaries [5]int) {
-	sdr.Turnteam1Rank = append(sdr.Turnteam1Rank, NewTeam1RankRecord(sdr.currentTurn, sdr.currentIteration, TeamID, boundaries))
+	record := NewTeam1RankRecord(sdr.currentTurn, sdr.currentIteration, TeamID, boundaries)
+	sdr.Turnteam1Rank = append(sdr.Turnteam1Rank, record)
+	sdr.RecordEvent(record.IterationNumber, record.TurnNumber, EventTeam1Rank, record)
+}
+
+func (sdr *ServerDataRecorder) RecordSanctionChange(record SanctionRecord) {
+	if sdr == nil {
+		return
+	}
+	sdr.SanctionRecords = append(sdr.SanctionRecords, record)
+	sdr.RecordEvent(record.IterationNumber, record.TurnNumber, EventSanctionChanged, record)
 }
 
 func (sdr *ServerDataRecorder) RecordNewTurn(agentRecords []AgentRecord, teamRecords []TeamRecord, commonRecord CommonRecord) {
@@ -67,6 +81,70 @@ func (sdr *ServerDataRecorder) RecordNewTurn(agentRecords []AgentRecord, teamRec
 	sdr.TurnRecords[len(sdr.TurnRecords)-1].AgentRecords = agentRecords
 	sdr.TurnRecords[len(sdr.TurnRecords)-1].TeamRecords = teamRecords
 	sdr.TurnRecords[len(sdr.TurnRecords)-1].CommonRecord = commonRecord
+
+	// the rest of the record is rebuilt from the events, see Replay
+	ended := TurnEnded{Agents: []AgentReport{}}
+	for _, record := range agentRecords {
+		ended.Agents = append(ended.Agents, AgentReport{
+			AgentID:            record.AgentID,
+			Contribution:       record.Contribution,
+			StatedContribution: record.StatedContribution,
+			Withdrawal:         record.Withdrawal,
+			StatedWithdrawal:   record.StatedWithdrawal,
+			SpecialNote:        record.SpecialNote,
+		})
+	}
+	sdr.RecordEvent(commonRecord.IterationNumber, commonRecord.TurnNumber, EventTurnEnded, ended)
+	// so that the log is complete up to here if the game crashes
+	sdr.FlushEvents()
+}
+
+// --------- Event Log Functions ---------
+
+func (sdr *ServerDataRecorder) SetEventLog(events *EventLog) {
+	sdr.events = events
+}
+
+// Add an event to the event log, if there is one
+func (sdr *ServerDataRecorder) RecordEvent(iteration, turn int, eventType EventType, data any) {
+	if sdr == nil || sdr.events == nil {
+		return
+	}
+	if err := sdr.events.Write(iteration, turn, eventType, data); err != nil {
+		log.Printf("[WARNING] Failed to write %s event: %v\n", eventType, err)
+	}
+}
+
+func (sdr *ServerDataRecorder) FlushEvents() {
+	if sdr == nil || sdr.events == nil {
+		return
+	}
+	if err := sdr.events.Flush(); err != nil {
+		log.Printf("[WARNING] Failed to write the event log: %v\n", err)
+	}
+}
+
+// The number of events logged so far
+func (sdr *ServerDataRecorder) EventsWritten() int {
+	if sdr == nil || sdr.events == nil {
+		return 0
+	}
+	return sdr.events.Written()
+}
+
+// The number of events logged when the checkpoint this recorder was restored
+// from was saved
+func (sdr *ServerDataRecorder) EventsAtCheckpoint() int {
+	return sdr.eventsAtCheckpoint
+}
+
+func (sdr *ServerDataRecorder) CloseEventLog() error {
+	if sdr.events == nil {
+		return nil
+	}
+	err := sdr.events.Close()
+	sdr.events = nil
+	return err
 }
 
 func (sdr *ServerDataRecorder) GamePlaybackSummary() {
@@ -86,3 +164,39 @@ func (sdr *ServerDataRecorder) GamePlaybackSummary() {
 	// Create the HTML visualization
 	CreatePlaybackHTML(sdr)
 }
+
+// --------- Checkpointing ---------
+
+// Everything recorded so far, in a form that can be written to a checkpoint
+type RecorderSnapshot struct {
+	TurnRecords      []TurnRecord
+	Turnteam1Rank    []Team1RankRecord
+	SanctionRecords  []SanctionRecord `json:",omitempty"`
+	CurrentIteration int
+	CurrentTurn      int
+	Events           int // events logged so far
+}
+
+func (sdr *ServerDataRecorder) Snapshot() RecorderSnapshot {
+	return RecorderSnapshot{
+		TurnRecords:      sdr.TurnRecords,
+		Turnteam1Rank:    sdr.Turnteam1Rank,
+		SanctionRecords:  sdr.SanctionRecords,
+		CurrentIteration: sdr.currentIteration,
+		CurrentTurn:      sdr.currentTurn,
+		Events:           sdr.EventsWritten(),
+	}
+}
+
+// Recreate a recorder from a checkpoint, so that recording carries on from
+// where it was saved
+func RestoreRecorder(snapshot RecorderSnapshot) *ServerDataRecorder {
+	return &ServerDataRecorder{
+		TurnRecords:        snapshot.TurnRecords,
+		Turnteam1Rank:      snapshot.Turnteam1Rank,
+		SanctionRecords:    snapshot.SanctionRecords,
+		currentIteration:   snapshot.CurrentIteration,
+		currentTurn:        snapshot.CurrentTurn,
+		eventsAtCheckpoint: snapshot.Events,
+	}
+}
@@ -779,6 +779,11 @@ func ExportToCSV(recorder *ServerDataRecorder, outputDir string) error {
 		return fmt.Errorf("failed to export common records: %v", err)
 	}
 
+	// Export changes to agents' standing on their team's sanction ladder
+	if err := exportStructSliceToCSV(recorder.SanctionRecords, filepath.Join(outputDir, "sanction_records.csv")); err != nil {
+		return fmt.Errorf("failed to export sanction records: %v", err)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,433 @@
+package gameRecorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+/*
+* The event log is an append-only record of every change to the game state,
+* written as JSON Lines (one event per line) as the game is played. Turn
+* records only show the state at the end of each turn, the events show how it
+* got there: every roll, contribution, withdrawal, vote, audit, report of
+* cheating, appeal, punishment, kick, death, orphan allocation and AoA selection.
+*
+* Replay rebuilds the turn records, and from them the HTML and CSV output,
+* from the log alone without running any agents, by playing the changes back
+* from the population the game started with (EventGameStarted). The only thing
+* taken on trust is what each agent says about itself at the end of a turn
+* (EventTurnEnded), which the server never sees change.
+ */
+
+type EventType string
+
+const (
+	EventGameStarted      EventType = "game_started"
+	EventIterationStarted EventType = "iteration_started"
+	EventThresholdSet     EventType = "threshold_set"
+	EventThresholdApplied EventType = "threshold_applied"
+	EventThresholdMissed  EventType = "threshold_missed"
+	EventThresholdPaid    EventType = "threshold_paid"
+	EventTeamCreated      EventType = "team_created"
+	EventTeamJoined       EventType = "team_joined"
+	EventAoASelected      EventType = "aoa_selected"
+	EventDiceRolled       EventType = "dice_rolled"
+	EventContribution     EventType = "contribution"
+	EventWithdrawal       EventType = "withdrawal"
+	EventVoteCast         EventType = "vote_cast"
+	EventAudit            EventType = "audit"
+	EventAuditCharge      EventType = "audit_charge"
+	EventWhistleblown     EventType = "whistleblown"
+	EventAppeal           EventType = "appeal"
+	EventOffence          EventType = "offence"
+	EventPunishment       EventType = "punishment"
+	EventRestricted       EventType = "restricted"
+	EventSanctionChanged  EventType = "sanction_changed"
+	EventReward           EventType = "reward"
+	EventKicked           EventType = "kicked"
+	EventLeaderElected    EventType = "leader_elected"
+	EventDeath            EventType = "death"
+	EventRevived          EventType = "revived"
+	EventOrphanAllocated  EventType = "orphan_allocated"
+	EventTrustGraph       EventType = "trust_graph"
+	EventTurnEnded        EventType = "turn_ended"
+	EventTeam1Rank        EventType = "team1_rank"
+	EventResumed          EventType = "resumed"
+)
+
+type Event struct {
+	Seq       int // position in the log, starting from 1
+	Iteration int
+	Turn      int
+	Type      EventType
+	Data      json.RawMessage // one of the event structs below, depending on Type
+}
+
+// --------- Event data ---------
+
+// The agents and rules the game started with
+type GameStarted struct {
+	Agents            []StartingAgent
+	ThresholdAction   string
+	ThresholdDeducted bool
+	AuditPayer        string
+}
+
+type StartingAgent struct {
+	AgentID         uuid.UUID
+	TrueSomasTeamID int
+	Score           int
+	TeamID          uuid.UUID
+}
+
+type IterationStarted struct {
+	Iteration int
+}
+
+type ThresholdSet struct {
+	Threshold int
+	Policy    string // the policy that chose it
+}
+
+type ThresholdApplied struct {
+	Threshold int
+}
+
+type ThresholdMissed struct {
+	AgentID uuid.UUID
+	TeamID  uuid.UUID
+	Score   int    // the score that fell below the threshold
+	Action  string // what was done about it: kill, fine or orphan
+}
+
+// An agent that met the threshold paying it, when the rules say it should
+type ThresholdPaid struct {
+	AgentID uuid.UUID
+	TeamID  uuid.UUID
+	Amount  int
+	Score   int // score after paying
+}
+
+type TeamCreated struct {
+	TeamID uuid.UUID
+}
+
+type TeamJoined struct {
+	TeamID  uuid.UUID
+	AgentID uuid.UUID
+}
+
+type AoASelected struct {
+	TeamID uuid.UUID
+	AoAID  int
+	Name   string
+}
+
+type DiceRolled struct {
+	AgentID      uuid.UUID
+	TeamID       uuid.UUID
+	ControlledBy uuid.UUID // the agent that decided when to stick, if not the agent itself
+	Rolls        []int     // every throw, in order, the last one is the bust if the agent went bust
+	TurnScore    int       // 0 if the agent went bust
+	Score        int       // score after the roll
+}
+
+type Contribution struct {
+	AgentID uuid.UUID
+	TeamID  uuid.UUID
+	Actual  int
+	Stated  int
+	Score   int // score after contributing
+}
+
+type Withdrawal struct {
+	AgentID    uuid.UUID
+	TeamID     uuid.UUID
+	Actual     int
+	Stated     int
+	Score      int // score after withdrawing
+	CommonPool int // pool after withdrawing
+}
+
+type VoteCast struct {
+	TeamID     uuid.UUID
+	Ballot     string // what the vote was about, e.g. "Contribution audit"
+	VoterID    uuid.UUID
+	VotedForID uuid.UUID
+	IsVote     int
+}
+
+type Audit struct {
+	TeamID     uuid.UUID
+	Kind       string
+	AgentID    uuid.UUID
+	Cost       int
+	Skipped    bool // the team could not afford the audit
+	Guilty     bool
+	CommonPool int // pool after paying for the audit
+	// How good the audit was, for AoAs that say
+	Quality *AuditQuality `json:",omitempty"`
+}
+
+// The quality of an audit, see common.AuditQuality
+type AuditQuality struct {
+	Lookback      int
+	Detection     float64
+	FalsePositive float64
+	Model         string
+}
+
+// One payment towards the cost of an audit, there is one for each payer
+type AuditCharge struct {
+	TeamID    uuid.UUID
+	Kind      string
+	AuditedID uuid.UUID
+	PayerID   uuid.UUID // the agent that paid, nil if the common pool paid
+	Payer     string    // pool, voter or guilty
+	Amount    int
+	Balance   int // the payer's score, or the pool, after paying
+}
+
+// A member's report of a teammate cheating, and what came of it
+type Whistleblown struct {
+	TeamID          uuid.UUID
+	WhistleblowerID uuid.UUID
+	AccusedID       uuid.UUID
+	Phase           string
+	Turn            int
+	StatedAmount    int
+	ExpectedAmount  int
+	Note            string
+	Outcome         string // invalid, ignored, declined, unaffordable, upheld or rejected
+}
+
+// An appeal against a guilty verdict, heard by a jury of teammates
+type Appeal struct {
+	TeamID      uuid.UUID
+	AgentID     uuid.UUID
+	Kind        string
+	Jurors      []uuid.UUID
+	ForOverturn int
+	ForUphold   int
+	Overturned  bool
+	Refunded    int       // paid back to the appellant from the common pool
+	Reclaimed   int       // taken back into the pool from whistleblowers rewarded for the audit
+	Reclaims    []Reclaim `json:",omitempty"`
+	Score       int       // the appellant's score after the refund
+	CommonPool  int
+}
+
+// What was taken back from one whistleblower when an appeal was upheld
+type Reclaim struct {
+	AgentID uuid.UUID
+	Amount  int
+	Score   int // score after paying it back
+}
+
+// A guilty verdict entered in the server's offence registry
+type OffenceRegistered struct {
+	TeamID  uuid.UUID
+	AgentID uuid.UUID
+	AoAID   int
+	Phase   string
+	Amount  int // how far the agent misstated what it contributed or withdrew
+	Total   int // offences on the agent's record, this one included
+}
+
+type Punishment struct {
+	TeamID     uuid.UUID
+	AgentID    uuid.UUID
+	Amount     int
+	Score      int // score after the punishment
+	CommonPool int // pool after the punishment was paid into it
+}
+
+type Reward struct {
+	TeamID     uuid.UUID
+	AgentID    uuid.UUID
+	Amount     int
+	Reason     string
+	Score      int // score after the reward
+	CommonPool int // pool after the reward was paid from it
+}
+
+type Restricted struct {
+	TeamID  uuid.UUID
+	AgentID uuid.UUID
+	Role    string
+	Turns   int // turns after this one the restriction lasts, 0 for the rest of the iteration
+	Reason  string
+}
+
+type Kicked struct {
+	TeamID  uuid.UUID
+	AgentID uuid.UUID
+	Reason  string
+}
+
+type LeaderElected struct {
+	TeamID     uuid.UUID
+	LeaderID   uuid.UUID
+	PreviousID uuid.UUID // uuid.Nil if the team had no leader
+	Method     string
+	Reason     string // why the election was held, e.g. "succession" or "recalled"
+}
+
+type Death struct {
+	AgentID uuid.UUID
+	TeamID  uuid.UUID
+	Score   int // the score that fell below the threshold
+}
+
+type Revived struct {
+	AgentID uuid.UUID
+}
+
+type OrphanAllocated struct {
+	AgentID uuid.UUID
+	TeamID  uuid.UUID
+}
+
+// Every living agent's opinions of the others at the end of a turn, and how
+// far the game's trust model has each of them trusted overall
+type TrustGraph struct {
+	Opinions   []TrustOpinion
+	Reputation []Reputation
+}
+
+type TrustOpinion struct {
+	TrusterID  uuid.UUID
+	SubjectID  uuid.UUID
+	Trust      float64
+	Confidence float64
+	Evidence   string
+}
+
+type Reputation struct {
+	AgentID uuid.UUID
+	Trust   float64
+}
+
+// The end of a turn, with what each agent, living or dead, said about itself
+type TurnEnded struct {
+	Agents []AgentReport
+}
+
+type AgentReport struct {
+	AgentID            uuid.UUID
+	Contribution       int
+	StatedContribution int
+	Withdrawal         int
+	StatedWithdrawal   int
+	SpecialNote        string
+}
+
+type Resumed struct {
+	Checkpoint string
+	Events     int // number of events logged when the checkpoint was saved
+}
+
+// --------- Writing ---------
+
+type EventLog struct {
+	mutex  sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	seq    int
+}
+
+// Create a new event log at path, replacing any log already there
+func CreateEventLog(path string) (*EventLog, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &EventLog{file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+// Open an existing event log to carry on writing to it, e.g. after resuming
+// from a checkpoint. Sequence numbers carry on from the last event in the log.
+func AppendEventLog(path string) (*EventLog, error) {
+	seq := 0
+	if events, err := ReadEventLog(path); err == nil && len(events) > 0 {
+		seq = events[len(events)-1].Seq
+	} else if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &EventLog{file: file, writer: bufio.NewWriter(file), seq: seq}, nil
+}
+
+func (l *EventLog) Write(iteration, turn int, eventType EventType, data any) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.seq++
+	line, err := json.Marshal(Event{Seq: l.seq, Iteration: iteration, Turn: turn, Type: eventType, Data: encoded})
+	if err != nil {
+		return err
+	}
+	if _, err := l.writer.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
+
+// The number of events written so far
+func (l *EventLog) Written() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.seq
+}
+
+// Write out any buffered events
+func (l *EventLog) Flush() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.writer.Flush()
+}
+
+func (l *EventLog) Close() error {
+	if err := l.Flush(); err != nil {
+		return err
+	}
+	return l.file.Close()
+}
+
+// --------- Reading ---------
+
+// Read every event in a log, in order
+func ReadEventLog(path string) ([]Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	events := []Event{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024) // trust graphs can make for long lines
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", path, line, err)
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
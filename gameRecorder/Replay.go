@@ -0,0 +1,374 @@
+package gameRecorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+/*
+* Rebuild the recorder from an event log, by playing every change back from
+* the population the game started with. Events logged after a checkpoint that
+* the game was later resumed from are dropped first, as the resumed game
+* played those turns again.
+*
+* Two changes happen to everyone at once and are not logged one by one: when
+* an iteration starts the teams are disbanded, and every agent, revived or
+* not, goes back to a score of 0 without a team before new teams are formed.
+ */
+func Replay(events []Event) (*ServerDataRecorder, error) {
+	played, err := playedEvents(events)
+	if err != nil {
+		return nil, err
+	}
+
+	sdr := CreateRecorder()
+	state := &replayState{
+		agents: make(map[uuid.UUID]*replayAgent),
+		pools:  make(map[uuid.UUID]int),
+	}
+	for _, event := range played {
+		if err := state.apply(sdr, event); err != nil {
+			return nil, fmt.Errorf("event %d (%s): %v", event.Seq, event.Type, err)
+		}
+	}
+	return sdr, nil
+}
+
+// The events of the game as it was finally played, leaving out those undone
+// by resuming from an earlier checkpoint
+func playedEvents(events []Event) ([]Event, error) {
+	played := []Event{}
+	for _, event := range events {
+		if event.Type != EventResumed {
+			played = append(played, event)
+			continue
+		}
+		var resumed Resumed
+		if err := json.Unmarshal(event.Data, &resumed); err != nil {
+			return nil, fmt.Errorf("event %d: %v", event.Seq, err)
+		}
+		kept := len(played)
+		for kept > 0 && played[kept-1].Seq > resumed.Events {
+			kept--
+		}
+		played = played[:kept]
+	}
+	return played, nil
+}
+
+type replayAgent struct {
+	trueSomasTeamID int
+	score           int
+	teamID          uuid.UUID
+	alive           bool
+}
+
+// The game state as far as the turn records show it
+type replayState struct {
+	rules   GameStarted
+	agents  map[uuid.UUID]*replayAgent
+	dead    []uuid.UUID       // in the order the agents died
+	pools   map[uuid.UUID]int // every team's common pool
+	applied Event             // the last ThresholdApplied event
+	set     ThresholdSet
+}
+
+func (s *replayState) agent(agentID uuid.UUID) (*replayAgent, error) {
+	agent, exists := s.agents[agentID]
+	if !exists {
+		return nil, fmt.Errorf("agent %v was never added", agentID)
+	}
+	return agent, nil
+}
+
+// Set an agent's score, from an event that says what it came to
+func (s *replayState) setScore(agentID uuid.UUID, score int) error {
+	agent, err := s.agent(agentID)
+	if err != nil {
+		return err
+	}
+	agent.score = score
+	return nil
+}
+
+func (s *replayState) apply(sdr *ServerDataRecorder, event Event) error {
+	switch event.Type {
+	case EventGameStarted:
+		var started GameStarted
+		if err := json.Unmarshal(event.Data, &started); err != nil {
+			return err
+		}
+		s.rules = started
+		for _, agent := range started.Agents {
+			s.agents[agent.AgentID] = &replayAgent{trueSomasTeamID: agent.TrueSomasTeamID, score: agent.Score, teamID: agent.TeamID, alive: true}
+		}
+
+	case EventIterationStarted:
+		for _, agent := range s.agents {
+			if agent.alive {
+				agent.score = 0
+				agent.teamID = uuid.Nil
+			}
+		}
+		s.pools = make(map[uuid.UUID]int)
+
+	case EventThresholdSet:
+		return json.Unmarshal(event.Data, &s.set)
+
+	case EventThresholdApplied:
+		s.applied = event
+
+	case EventThresholdMissed:
+		var missed ThresholdMissed
+		if err := json.Unmarshal(event.Data, &missed); err != nil {
+			return err
+		}
+		if missed.Action == "fine" {
+			return s.setScore(missed.AgentID, missed.Score-s.set.Threshold)
+		}
+
+	case EventThresholdPaid:
+		var paid ThresholdPaid
+		if err := json.Unmarshal(event.Data, &paid); err != nil {
+			return err
+		}
+		return s.setScore(paid.AgentID, paid.Score)
+
+	case EventTeamCreated:
+		var created TeamCreated
+		if err := json.Unmarshal(event.Data, &created); err != nil {
+			return err
+		}
+		s.pools[created.TeamID] = 0
+
+	case EventTeamJoined:
+		var joined TeamJoined
+		if err := json.Unmarshal(event.Data, &joined); err != nil {
+			return err
+		}
+		agent, err := s.agent(joined.AgentID)
+		if err != nil {
+			return err
+		}
+		agent.teamID = joined.TeamID
+
+	case EventOrphanAllocated:
+		var allocated OrphanAllocated
+		if err := json.Unmarshal(event.Data, &allocated); err != nil {
+			return err
+		}
+		agent, err := s.agent(allocated.AgentID)
+		if err != nil {
+			return err
+		}
+		agent.teamID = allocated.TeamID
+
+	case EventDiceRolled:
+		var rolled DiceRolled
+		if err := json.Unmarshal(event.Data, &rolled); err != nil {
+			return err
+		}
+		return s.setScore(rolled.AgentID, rolled.Score)
+
+	case EventContribution:
+		var contribution Contribution
+		if err := json.Unmarshal(event.Data, &contribution); err != nil {
+			return err
+		}
+		s.pools[contribution.TeamID] += contribution.Actual
+		return s.setScore(contribution.AgentID, contribution.Score)
+
+	case EventWithdrawal:
+		var withdrawal Withdrawal
+		if err := json.Unmarshal(event.Data, &withdrawal); err != nil {
+			return err
+		}
+		s.pools[withdrawal.TeamID] = withdrawal.CommonPool
+		return s.setScore(withdrawal.AgentID, withdrawal.Score)
+
+	case EventAuditCharge:
+		var charge AuditCharge
+		if err := json.Unmarshal(event.Data, &charge); err != nil {
+			return err
+		}
+		if charge.Payer == "pool" {
+			s.pools[charge.TeamID] = charge.Balance
+			return nil
+		}
+		return s.setScore(charge.PayerID, charge.Balance)
+
+	case EventAppeal:
+		var appeal Appeal
+		if err := json.Unmarshal(event.Data, &appeal); err != nil {
+			return err
+		}
+		for _, reclaim := range appeal.Reclaims {
+			if err := s.setScore(reclaim.AgentID, reclaim.Score); err != nil {
+				return err
+			}
+		}
+		s.pools[appeal.TeamID] = appeal.CommonPool
+		return s.setScore(appeal.AgentID, appeal.Score)
+
+	case EventPunishment:
+		var punishment Punishment
+		if err := json.Unmarshal(event.Data, &punishment); err != nil {
+			return err
+		}
+		s.pools[punishment.TeamID] = punishment.CommonPool
+		return s.setScore(punishment.AgentID, punishment.Score)
+
+	case EventReward:
+		var reward Reward
+		if err := json.Unmarshal(event.Data, &reward); err != nil {
+			return err
+		}
+		s.pools[reward.TeamID] = reward.CommonPool
+		return s.setScore(reward.AgentID, reward.Score)
+
+	case EventKicked:
+		var kicked Kicked
+		if err := json.Unmarshal(event.Data, &kicked); err != nil {
+			return err
+		}
+		agent, err := s.agent(kicked.AgentID)
+		if err != nil {
+			return err
+		}
+		if agent.alive { // the dead cannot be kicked
+			agent.teamID = uuid.Nil
+		}
+
+	case EventDeath:
+		var death Death
+		if err := json.Unmarshal(event.Data, &death); err != nil {
+			return err
+		}
+		agent, err := s.agent(death.AgentID)
+		if err != nil {
+			return err
+		}
+		agent.score = 0
+		agent.teamID = uuid.Nil
+		agent.alive = false
+		s.dead = append(s.dead, death.AgentID)
+
+	case EventRevived:
+		var revived Revived
+		if err := json.Unmarshal(event.Data, &revived); err != nil {
+			return err
+		}
+		agent, err := s.agent(revived.AgentID)
+		if err != nil {
+			return err
+		}
+		agent.score = 0
+		agent.teamID = uuid.Nil
+		agent.alive = true
+		for i, agentID := range s.dead {
+			if agentID == revived.AgentID {
+				s.dead = append(s.dead[:i], s.dead[i+1:]...)
+				break
+			}
+		}
+
+	case EventTurnEnded:
+		var ended TurnEnded
+		if err := json.Unmarshal(event.Data, &ended); err != nil {
+			return err
+		}
+		agentRecords, err := s.agentRecords(event, ended)
+		if err != nil {
+			return err
+		}
+		sdr.RecordNewTurn(agentRecords, s.teamRecords(event), s.commonRecord(event))
+
+	case EventTeam1Rank:
+		var record Team1RankRecord
+		if err := json.Unmarshal(event.Data, &record); err != nil {
+			return err
+		}
+		sdr.Turnteam1Rank = append(sdr.Turnteam1Rank, record)
+
+	case EventSanctionChanged:
+		var record SanctionRecord
+		if err := json.Unmarshal(event.Data, &record); err != nil {
+			return err
+		}
+		sdr.SanctionRecords = append(sdr.SanctionRecords, record)
+	}
+	return nil
+}
+
+// Living agents come first, ordered by ID, and then the dead in the order they died
+func (s *replayState) agentRecords(event Event, ended TurnEnded) ([]AgentRecord, error) {
+	living := []uuid.UUID{}
+	for agentID, agent := range s.agents {
+		if agent.alive {
+			living = append(living, agentID)
+		}
+	}
+	sortIDs(living)
+
+	reports := make(map[uuid.UUID]AgentReport)
+	for _, report := range ended.Agents {
+		reports[report.AgentID] = report
+	}
+	if len(reports) != len(living)+len(s.dead) {
+		return nil, fmt.Errorf("%d agents reported, expected %d", len(reports), len(living)+len(s.dead))
+	}
+
+	records := []AgentRecord{}
+	for _, agentID := range append(living, s.dead...) {
+		report, reported := reports[agentID]
+		if !reported {
+			return nil, fmt.Errorf("agent %v did not report", agentID)
+		}
+		agent := s.agents[agentID]
+		record := NewAgentRecord(agentID, agent.trueSomasTeamID, agent.score, report.Contribution, report.StatedContribution, report.Withdrawal, report.StatedWithdrawal, agent.teamID, report.SpecialNote)
+		record.IsAlive = agent.alive
+		record.TurnNumber = event.Turn
+		record.IterationNumber = event.Iteration
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *replayState) teamRecords(event Event) []TeamRecord {
+	teamIDs := []uuid.UUID{}
+	for teamID := range s.pools {
+		teamIDs = append(teamIDs, teamID)
+	}
+	sortIDs(teamIDs)
+
+	records := []TeamRecord{}
+	for _, teamID := range teamIDs {
+		record := NewTeamRecord(teamID)
+		record.TurnNumber = event.Turn
+		record.IterationNumber = event.Iteration
+		record.TeamCommonPool = s.pools[teamID]
+		records = append(records, record)
+	}
+	return records
+}
+
+func (s *replayState) commonRecord(event Event) CommonRecord {
+	appliedInTurn := s.applied.Type == EventThresholdApplied && s.applied.Iteration == event.Iteration && s.applied.Turn == event.Turn
+	record := NewCommonRecord(event.Turn, event.Iteration, s.set.Threshold, appliedInTurn)
+	record.ThresholdPolicy = s.set.Policy
+	record.ThresholdAction = s.rules.ThresholdAction
+	record.ThresholdDeducted = s.rules.ThresholdDeducted
+	record.AuditPayer = s.rules.AuditPayer
+	return record
+}
+
+// Sort IDs the way the server does, see common.SortedIDs
+func sortIDs(ids []uuid.UUID) {
+	sort.Slice(ids, func(i, j int) bool {
+		return bytes.Compare(ids[i][:], ids[j][:]) < 0
+	})
+}
@@ -0,0 +1,26 @@
+package gameRecorder
+
+import "github.com/google/uuid"
+
+// A change to an agent's standing on its team's sanction ladder
+type SanctionRecord struct {
+	TurnNumber      int
+	IterationNumber int
+	TeamID          uuid.UUID
+	AgentID         uuid.UUID
+	Change          string // escalated, decayed or cleared
+	Offences        int    // after the change
+	Level           string // the ladder step the agent is now on
+}
+
+func NewSanctionRecord(turnNumber int, iterationNumber int, teamID uuid.UUID, agentID uuid.UUID, change string, offences int, level string) SanctionRecord {
+	return SanctionRecord{
+		TurnNumber:      turnNumber,
+		IterationNumber: iterationNumber,
+		TeamID:          teamID,
+		AgentID:         agentID,
+		Change:          change,
+		Offences:        offences,
+		Level:           level,
+	}
+}
@@ -7,13 +7,9 @@ import (
 	"os"
 	"time"
 
-	"github.com/google/uuid"
-
-	baseServer "github.com/MattSScott/basePlatformSOMAS/v2/pkg/server"
-
-	agents "github.com/ADimoska/SOMASExtended/agents"
 	common "github.com/ADimoska/SOMASExtended/common"
 	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+	scenario "github.com/ADimoska/SOMASExtended/scenario"
 	envServer "github.com/ADimoska/SOMASExtended/server"
 )
 
@@ -42,63 +38,64 @@ func main() {
 
 	log.Println("main function started.")
 
-	// agent configuration
-	agentConfig := agents.AgentConfig{
-		InitScore:    0,
-		VerboseLevel: 10,
-	}
-
+	argScenario := flag.String("scenario", "", "Path to a YAML/JSON scenario file (defaults to the built-in scenario)")
 	argExposeThresholds := flag.Bool("exposeThresholds", false, "Expose the thresholds to the agents")
+	argSeed := flag.Int64("seed", 0, "Master seed for a reproducible run (0 picks a random seed, overrides the scenario)")
+	argResume := flag.String("resume", "", "Path to a checkpoint to resume a game from (the other flags are ignored)")
 	flag.Parse()
 
-	serv := &envServer.EnvironmentServer{
-		// note: the zero turn is used for team forming
-		BaseServer: baseServer.CreateBaseServer[common.IExtendedAgent](
-			3,                   //  iterations
-			120,                 //  turns per iteration
-			50*time.Millisecond, //  max duration
-			10),                 //  message bandwidth
-		Teams: make(map[uuid.UUID]*common.Team),
-	}
-	serv.Init(
-		3,                    // turns to apply threshold once
-		*argExposeThresholds, // expose thresholds
-	)
-	serv.SetGameRunner(serv)
-
-	const numAgents int = 10
-
-	agentPopulation := []common.IExtendedAgent{}
-	for i := 0; i < numAgents; i++ {
-		agentPopulation = append(agentPopulation, agents.Team4_CreateAgent(serv, agentConfig))
-		agentPopulation = append(agentPopulation, agents.Team2_CreateAgent(serv, agentConfig))
-		// agentPopulation = append(agentPopulation, agents.GetBaseAgents(serv, agentConfig))
-		// Add other teams' agents here
-	}
-
-	for i := 0; i < numAgents-4; i++ {
-		// Add mostly honest agents
-		agentPopulation = append(agentPopulation, agents.Create_Team1Agent(serv, agentConfig, agents.Rational))
-	}
-
-	// Add a short term and long term cheater agent from team 1
-	for i := 0; i < 2; i++ {
-		team1ShortTermCheater := agents.Create_Team1Agent(serv, agentConfig, agents.CheatShortTerm)
-		log.Printf("Team1 %v is of type CheatShortTerm", team1ShortTermCheater.GetID())
-		agentPopulation = append(agentPopulation, team1ShortTermCheater)
-
-		team1LongTermCheater := agents.Create_Team1Agent(serv, agentConfig, agents.CheatLongTerm)
-		log.Printf("Team1 %v is of type CheatLongTerm", team1LongTermCheater.GetID())
-		agentPopulation = append(agentPopulation, team1LongTermCheater)
-	}
-
-	for i, agent := range agentPopulation {
-		agent.SetName(i)
-		serv.AddAgent(agent)
+	var serv *envServer.EnvironmentServer
+	if *argResume != "" {
+		var gameScenario *scenario.Scenario
+		serv, gameScenario, err = scenario.Resume(*argResume)
+		if err != nil {
+			log.Fatalf("Failed to resume from %s: %v", *argResume, err)
+		}
+		log.Printf("Resuming from checkpoint %s, master seed: %v\n", *argResume, common.GetMasterSeed())
+		if err := gameScenario.ResumeEventLog(serv, *argResume); err != nil {
+			log.Fatalf("Failed to open event log %s: %v", gameScenario.EventLog, err)
+		}
+	} else {
+		gameScenario := scenario.Default()
+		if *argScenario != "" {
+			gameScenario, err = scenario.Load(*argScenario)
+			if err != nil {
+				log.Fatalf("Failed to load scenario %s: %v", *argScenario, err)
+			}
+			log.Printf("Loaded scenario from %s\n", *argScenario)
+		}
+		if *argExposeThresholds {
+			gameScenario.Threshold.Expose = true
+		}
+		if *argSeed != 0 {
+			gameScenario.Seed = *argSeed
+		}
+
+		// The seed must be set before the server and agents are created, as they
+		// each take their own random stream from it
+		if gameScenario.Seed != 0 {
+			common.SetMasterSeed(gameScenario.Seed)
+		}
+		log.Printf("Master seed: %v\n", common.GetMasterSeed())
+
+		serv = gameScenario.CreateServer()
+		agentPopulation := gameScenario.CreatePopulation(serv)
+
+		for i, agent := range agentPopulation {
+			agent.SetName(i)
+			serv.AddAgent(agent)
+		}
+
+		if err := gameScenario.StartEventLog(serv); err != nil {
+			log.Fatalf("Failed to create event log %s: %v", gameScenario.EventLog, err)
+		}
 	}
 
 	//serv.ReportMessagingDiagnostics()
 	serv.Start()
+	if err := serv.DataRecorder.CloseEventLog(); err != nil {
+		log.Printf("[WARNING] Failed to write the event log: %v\n", err)
+	}
 
 	// custom function to see agent result
 	serv.LogAgentStatus()
@@ -0,0 +1,44 @@
+package scenario
+
+import (
+	"os"
+	"path/filepath"
+
+	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+	envServer "github.com/ADimoska/SOMASExtended/server"
+)
+
+// Start writing the scenario's event log, replacing any log left by a
+// previous game. Does nothing if the scenario has no event log.
+func (s *Scenario) StartEventLog(serv *envServer.EnvironmentServer) error {
+	if s.EventLog == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.EventLog), 0755); err != nil {
+		return err
+	}
+	events, err := gameRecorder.CreateEventLog(s.EventLog)
+	if err != nil {
+		return err
+	}
+	serv.DataRecorder.SetEventLog(events)
+	serv.RecordGameStarted()
+	return nil
+}
+
+// Carry on writing the event log of a game resumed from a checkpoint
+func (s *Scenario) ResumeEventLog(serv *envServer.EnvironmentServer, checkpointPath string) error {
+	if s.EventLog == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.EventLog), 0755); err != nil {
+		return err
+	}
+	events, err := gameRecorder.AppendEventLog(s.EventLog)
+	if err != nil {
+		return err
+	}
+	serv.DataRecorder.SetEventLog(events)
+	serv.RecordResumed(checkpointPath)
+	return nil
+}
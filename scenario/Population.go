@@ -0,0 +1,200 @@
+package scenario
+
+import (
+	"errors"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"github.com/MattSScott/basePlatformSOMAS/v2/pkg/agent"
+	baseServer "github.com/MattSScott/basePlatformSOMAS/v2/pkg/server"
+
+	agents "github.com/ADimoska/SOMASExtended/agents"
+	common "github.com/ADimoska/SOMASExtended/common"
+	envServer "github.com/ADimoska/SOMASExtended/server"
+)
+
+type serverFuncs = agent.IExposedServerFunctions[common.IExtendedAgent]
+
+// Overrides that every factory accepts, with the smallest value each may take.
+// initScore and verboseLevel map onto AgentConfig, diceBonus and extraDice onto
+// the agent's common.DiceModifier, which must also leave the agent dice rules
+// that pass DiceRules.Validate. All other overrides are passed to the factory
+// as parameters.
+var agentConfigOverrides = map[string]int{
+	"initScore":    0,
+	"verboseLevel": 0,
+	"diceBonus":    math.MinInt,
+	"extraDice":    0,
+}
+
+// Servers that give agents a restricted view of themselves to be created with
+type agentFacingServer interface {
+	AgentFacing() common.IServer
+}
+
+// Servers that let the dice rules be changed for a single agent
+type diceModifierSetter interface {
+	SetDiceModifier(agentID uuid.UUID, modifier common.DiceModifier)
+}
+
+func validateEntryConfig(field string, entry PopulationEntry, dice common.DiceRules) []error {
+	factory, exists := agents.GetAgentFactory(entry.Factory)
+	if !exists {
+		return []error{fieldError(field+".factory", "unknown factory %q, expected one of [%s]", entry.Factory, strings.Join(agents.AgentFactoryNames(), ", "))}
+	}
+
+	var errs []error
+	for _, key := range sortedKeys(entry.Config) {
+		minimum, isOverride := agentConfigOverrides[key]
+		if !isOverride {
+			continue
+		}
+		if n, ok := entry.Config[key].(int); !ok || n < minimum {
+			if minimum == 0 {
+				errs = append(errs, fieldError(field+".config."+key, "must be a non-negative integer, got %v", entry.Config[key]))
+			} else {
+				errs = append(errs, fieldError(field+".config."+key, "must be an integer, got %v", entry.Config[key]))
+			}
+		}
+	}
+	modifier := entryDiceModifier(entry)
+	var diceErr *common.DiceRulesError
+	if len(errs) == 0 && modifier != (common.DiceModifier{}) && dice.Validate() == nil && errors.As(dice.With(modifier).Validate(), &diceErr) {
+		key := "diceBonus"
+		if diceErr.Field == "count" {
+			key = "extraDice"
+		}
+		errs = append(errs, fieldError(field+".config."+key, "gives dice rules whose %s %s", diceErr.Field, diceErr.Problem))
+	}
+	for _, paramErr := range factory.ValidateParams(entryParams(entry)) {
+		errs = append(errs, fieldError(field+".config."+paramErr.Param, "%s", paramErr.Problem))
+	}
+	return errs
+}
+
+// How the entry changes the dice rules for its agents
+func entryDiceModifier(entry PopulationEntry) common.DiceModifier {
+	modifier := common.DiceModifier{}
+	if value, ok := entry.Config["diceBonus"].(int); ok {
+		modifier.Bonus = value
+	}
+	if value, ok := entry.Config["extraDice"].(int); ok {
+		modifier.ExtraDice = value
+	}
+	return modifier
+}
+
+// The overrides of an entry that are parameters of its factory
+func entryParams(entry PopulationEntry) agents.AgentParams {
+	params := agents.AgentParams{}
+	for key, value := range entry.Config {
+		if _, isOverride := agentConfigOverrides[key]; !isOverride {
+			params[key] = value
+		}
+	}
+	return params
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Create the server described by the scenario, ready for agents to be added
+func (s *Scenario) CreateServer() *envServer.EnvironmentServer {
+	serv := &envServer.EnvironmentServer{
+		// note: the zero turn is used for team forming
+		BaseServer: baseServer.CreateBaseServer[common.IExtendedAgent](
+			s.Server.Iterations,
+			s.Server.Turns,
+			time.Duration(s.Server.MaxDuration),
+			s.Server.Bandwidth),
+		Teams: make(map[uuid.UUID]*common.Team),
+	}
+	serv.Init(
+		s.Threshold.Turns,
+		s.Threshold.Expose,
+	)
+	rules, err := s.ThresholdRules()
+	if err != nil {
+		// cannot happen for a validated scenario
+		log.Fatalf("scenario: %v", err)
+	}
+	serv.SetThresholdRules(rules)
+	serv.SetDiceRules(s.DiceRules())
+	serv.SetAuditPayer(s.Audit.Payer)
+	serv.SetAuditFalsePositive(s.Audit.FalsePositive)
+	serv.SetSanctionLadder(s.Sanctions)
+	serv.SetAppealRules(envServer.AppealRules{Jury: s.Appeals.Jury, Size: s.Appeals.JurySize})
+	serv.SetOffenceRules(envServer.OffenceRules{Visibility: s.Offences.Visibility, ExpireTurns: s.Offences.ExpireTurns})
+	trustModel, err := common.NewTrustModel(s.Trust.Model)
+	if err != nil {
+		// cannot happen for a validated scenario
+		log.Fatalf("scenario: %v", err)
+	}
+	serv.SetTrustModel(trustModel)
+	serv.SetTeamFormingTimeout(time.Duration(s.Server.TeamFormingTimeout))
+	if s.Checkpoint.Every > 0 {
+		// checkpoints store the scenario, so that they can be resumed on their own
+		scenarioYAML, err := yaml.Marshal(s)
+		if err != nil {
+			log.Fatalf("scenario: %v", err)
+		}
+		serv.EnableCheckpoints(s.Checkpoint.Every, s.Checkpoint.Dir, scenarioYAML)
+	}
+	serv.SetGameRunner(serv)
+	return serv
+}
+
+// Create every agent in the population through the agent factory registry, in
+// the order the entries are listed. The scenario must have been validated first.
+// Each agent is created with its own AgentFacing view where the server has one.
+func (s *Scenario) CreatePopulation(serv serverFuncs) []common.IExtendedAgent {
+	facing, isFacing := serv.(agentFacingServer)
+
+	population := []common.IExtendedAgent{}
+	for _, entry := range s.Population {
+		config := agents.AgentConfig{
+			InitScore:    s.AgentConfig.InitScore,
+			VerboseLevel: s.AgentConfig.VerboseLevel,
+		}
+		if value, ok := entry.Config["initScore"]; ok {
+			config.InitScore = value.(int)
+		}
+		if value, ok := entry.Config["verboseLevel"]; ok {
+			config.VerboseLevel = value.(int)
+		}
+
+		modifier := entryDiceModifier(entry)
+		modifiers, canModify := serv.(diceModifierSetter)
+
+		factory, _ := agents.GetAgentFactory(entry.Factory)
+		params := entryParams(entry)
+		for i := 0; i < entry.Count; i++ {
+			funcs := serv
+			if isFacing {
+				funcs = facing.AgentFacing()
+			}
+			newAgent, err := factory.New(funcs, config, params)
+			if err != nil {
+				// cannot happen for a validated scenario
+				log.Fatalf("scenario: population entry %q: %v", entry.Factory, err)
+			}
+			if canModify && modifier != (common.DiceModifier{}) {
+				modifiers.SetDiceModifier(newAgent.GetID(), modifier)
+			}
+			population = append(population, newAgent)
+		}
+	}
+	return population
+}
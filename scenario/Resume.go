@@ -0,0 +1,37 @@
+package scenario
+
+import (
+	"fmt"
+
+	envServer "github.com/ADimoska/SOMASExtended/server"
+
+	common "github.com/ADimoska/SOMASExtended/common"
+)
+
+/*
+* Re-create the game saved in a checkpoint, ready to be started. The server
+* and population are built from the scenario stored in the checkpoint, in the
+* same order as main does, so that every agent gets back the ID it had before.
+ */
+func Resume(path string) (*envServer.EnvironmentServer, *Scenario, error) {
+	checkpoint, err := envServer.LoadCheckpoint(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	s, err := Parse([]byte(checkpoint.Scenario))
+	if err != nil {
+		return nil, nil, fmt.Errorf("checkpoint %s: %v", path, err)
+	}
+
+	common.RestoreMasterSeed(checkpoint.Random)
+	serv := s.CreateServer()
+	for i, agent := range s.CreatePopulation(serv) {
+		agent.SetName(i)
+		serv.AddAgent(agent)
+	}
+
+	if err := serv.RestoreCheckpoint(checkpoint); err != nil {
+		return nil, nil, fmt.Errorf("checkpoint %s: %v", path, err)
+	}
+	return serv, s, nil
+}
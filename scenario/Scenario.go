@@ -0,0 +1,383 @@
+package scenario
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	common "github.com/ADimoska/SOMASExtended/common"
+	envServer "github.com/ADimoska/SOMASExtended/server"
+)
+
+/*
+* A scenario describes everything needed to set up a game: the server
+* parameters, how the threshold is applied and the agent population. Scenarios
+* are written in YAML (or JSON, which is a subset of YAML), e.g.
+*
+*	seed: 42
+*	server:
+*	  iterations: 3
+*	  turns: 120
+*	  maxDuration: 50ms
+*	  bandwidth: 10
+*	  teamFormingTimeout: 2s
+*	threshold:
+*	  turns: 3
+*	  expose: false
+*	  policy: uniform
+*	  params: {low: 0, high: 10, slope: 1}
+*	  action: kill
+*	  deduct: true
+*	dice:
+*	  count: 3
+*	  faces: 6
+*	  bust: not-higher
+*	  maxRerolls: -1
+*	sanctions:
+*	  steps:
+*	    - {level: warning}
+*	    - {level: fine, percent: 25}
+*	    - {level: no-vote, turns: 3}
+*	    - {level: expel}
+*	  escalation: 1
+*	  decayTurns: 10
+*	appeals:
+*	  jury: not-accusers
+*	  jurySize: 3
+*	offences:
+*	  visibility: expiring
+*	  expireTurns: 20
+*	trust:
+*	  model: eigentrust
+*	checkpoint:
+*	  every: 40
+*	  dir: checkpoints
+*	eventLog: visualization_output/events.jsonl
+*	population:
+*	  - factory: team4
+*	    count: 10
+*	    config: {chaoticness: 3, evilness: 1}
+*	  - factory: team1:cheat_long_term
+*	    count: 2
+*	    config: {diceBonus: -2}
+*
+* The factory is the name of an agent factory registered with
+* agents.RegisterAgentFactory. Its config may set initScore, verboseLevel,
+* diceBonus and extraDice, anything else is passed to the factory as a
+* parameter.
+ */
+type Scenario struct {
+	Seed        int64                 `yaml:"seed"` // 0 means pick a random seed
+	Server      ServerParams          `yaml:"server"`
+	Threshold   ThresholdParams       `yaml:"threshold"`
+	Dice        DiceParams            `yaml:"dice"`
+	Audit       AuditParams           `yaml:"audit"`
+	Appeals     AppealParams          `yaml:"appeals"`
+	Offences    OffenceParams         `yaml:"offences"`
+	Trust       TrustParams           `yaml:"trust"`
+	Sanctions   common.SanctionLadder `yaml:"sanctions"`   // the ladder AoAs with graduated sanctions climb
+	AgentConfig AgentParams           `yaml:"agentConfig"` // defaults for every agent, can be overridden per entry
+	Population  []PopulationEntry     `yaml:"population"`
+	Checkpoint  CheckpointParams      `yaml:"checkpoint"`
+	EventLog    string                `yaml:"eventLog"` // where to write the event log, empty to not write one
+}
+
+type ServerParams struct {
+	Iterations  int      `yaml:"iterations"`
+	Turns       int      `yaml:"turns"` // turns per iteration, turn 0 is used for team forming
+	MaxDuration Duration `yaml:"maxDuration"`
+	Bandwidth   int      `yaml:"bandwidth"`
+	// the longest team forming may take before the server carries on without
+	// the agents that have not finished
+	TeamFormingTimeout Duration `yaml:"teamFormingTimeout"`
+}
+
+type ThresholdParams struct {
+	Turns  int                             `yaml:"turns"`  // apply the threshold once every this many turns
+	Expose bool                            `yaml:"expose"` // expose the current threshold to agents
+	Policy string                          `yaml:"policy"` // name of a policy registered with envServer.RegisterThresholdPolicy
+	Params envServer.ThresholdPolicyParams `yaml:"params"` // parameters of the policy, missing ones take its defaults
+	Action envServer.ThresholdAction       `yaml:"action"` // what happens to agents below the threshold: kill, fine or orphan
+	Deduct bool                            `yaml:"deduct"` // whether agents that meet the threshold pay it
+}
+
+type DiceParams struct {
+	Count      int             `yaml:"count"`      // dice thrown at once
+	Faces      int             `yaml:"faces"`      // faces per die
+	Bust       common.BustRule `yaml:"bust"`       // not-higher, face or cap
+	BustFace   int             `yaml:"bustFace"`   // the face that busts when bust is face
+	Cap        int             `yaml:"cap"`        // the most a turn can score when bust is cap
+	MaxRerolls int             `yaml:"maxRerolls"` // rerolls allowed after the first throw, -1 for no limit
+}
+
+type AuditParams struct {
+	Payer         envServer.AuditPayer `yaml:"payer"`         // who pays for audits: pool, voters or guilty
+	FalsePositive float64              `yaml:"falsePositive"` // chance an audit that found nothing accuses the agent anyway
+}
+
+type AppealParams struct {
+	Jury     envServer.JurySelection `yaml:"jury"`     // how jurors are chosen: random, rank or not-accusers
+	JurySize int                     `yaml:"jurySize"` // jurors for each appeal
+}
+
+type OffenceParams struct {
+	Visibility  envServer.OffenceVisibility `yaml:"visibility"`  // what agents see of the offence registry: public, team or expiring
+	ExpireTurns int                         `yaml:"expireTurns"` // turns an offence stays visible for when visibility is expiring
+}
+
+type TrustParams struct {
+	Model common.TrustModelName `yaml:"model"` // how agents combine opinions into trust: beta or eigentrust
+}
+
+type CheckpointParams struct {
+	Every int    `yaml:"every"` // save a checkpoint every this many turns, 0 to never save
+	Dir   string `yaml:"dir"`   // directory the checkpoints are written to
+}
+
+type AgentParams struct {
+	InitScore    int `yaml:"initScore"`
+	VerboseLevel int `yaml:"verboseLevel"`
+}
+
+// A group of agents built by the same factory with the same config overrides
+type PopulationEntry struct {
+	Factory string         `yaml:"factory"`
+	Count   int            `yaml:"count"`
+	Config  map[string]any `yaml:"config"`
+}
+
+// time.Duration that is written as a string such as "50ms" in scenario files
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("line %d: %v", value.Line, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalYAML() (any, error) {
+	return time.Duration(d).String(), nil
+}
+
+// The reason a scenario was rejected, along with the field that caused it
+type FieldError struct {
+	Field   string
+	Problem string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("scenario field %s: %s", e.Field, e.Problem)
+}
+
+func fieldError(field string, format string, args ...any) error {
+	return &FieldError{Field: field, Problem: fmt.Sprintf(format, args...)}
+}
+
+// The scenario used when no scenario file is given
+func Default() *Scenario {
+	return &Scenario{
+		Server: ServerParams{
+			Iterations:         3,
+			Turns:              120,
+			MaxDuration:        Duration(50 * time.Millisecond),
+			Bandwidth:          10,
+			TeamFormingTimeout: Duration(envServer.DefaultTeamFormingTimeout),
+		},
+		Threshold: ThresholdParams{
+			Turns:  3,
+			Expose: false,
+			Policy: "uniform",
+			Action: envServer.ThresholdKill,
+			Deduct: true,
+		},
+		Dice: DiceParams{
+			Count:      3,
+			Faces:      6,
+			Bust:       common.BustNotHigher,
+			BustFace:   1,
+			MaxRerolls: -1,
+		},
+		Audit: AuditParams{
+			Payer: envServer.AuditPaidByPool,
+		},
+		Appeals: AppealParams{
+			Jury:     envServer.DefaultAppealRules().Jury,
+			JurySize: envServer.DefaultAppealRules().Size,
+		},
+		Offences: OffenceParams{
+			Visibility:  envServer.DefaultOffenceRules().Visibility,
+			ExpireTurns: envServer.DefaultOffenceRules().ExpireTurns,
+		},
+		Trust: TrustParams{
+			Model: common.TrustBeta,
+		},
+		Sanctions: common.DefaultSanctionLadder(),
+		AgentConfig: AgentParams{
+			InitScore:    0,
+			VerboseLevel: 10,
+		},
+		Population: []PopulationEntry{
+			{Factory: "team4", Count: 10},
+			{Factory: "team2", Count: 10},
+			{Factory: "team1:rational", Count: 6},
+			{Factory: "team1:cheat_short_term", Count: 2},
+			{Factory: "team1:cheat_long_term", Count: 2},
+		},
+		Checkpoint: CheckpointParams{
+			Every: 0,
+			Dir:   "checkpoints",
+		},
+		EventLog: "visualization_output/events.jsonl",
+	}
+}
+
+// Read and validate a scenario file
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse and validate a scenario. Fields that are left out keep the value from
+// Default(), except for the population which must always be given.
+func Parse(data []byte) (*Scenario, error) {
+	s := Default()
+	s.Population = nil
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true) // reject misspelt fields rather than silently ignoring them
+	if err := decoder.Decode(s); err != nil {
+		return nil, fmt.Errorf("scenario: %v", err)
+	}
+
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Check the scenario for mistakes. Every problem found is reported, each naming
+// the field responsible.
+func (s *Scenario) Validate() error {
+	var errs []error
+
+	if s.Server.Iterations <= 0 {
+		errs = append(errs, fieldError("server.iterations", "must be positive, got %d", s.Server.Iterations))
+	}
+	if s.Server.Turns <= 0 {
+		errs = append(errs, fieldError("server.turns", "must be positive, got %d", s.Server.Turns))
+	}
+	if s.Server.MaxDuration <= 0 {
+		errs = append(errs, fieldError("server.maxDuration", "must be positive, got %v", time.Duration(s.Server.MaxDuration)))
+	}
+	if s.Server.Bandwidth <= 0 {
+		errs = append(errs, fieldError("server.bandwidth", "must be positive, got %d", s.Server.Bandwidth))
+	}
+	if s.Server.TeamFormingTimeout <= 0 {
+		errs = append(errs, fieldError("server.teamFormingTimeout", "must be positive, got %v", time.Duration(s.Server.TeamFormingTimeout)))
+	}
+	if s.Threshold.Turns <= 0 {
+		errs = append(errs, fieldError("threshold.turns", "must be positive, got %d", s.Threshold.Turns))
+	}
+	errs = append(errs, s.validateThresholdRules()...)
+	var diceErr *common.DiceRulesError
+	if errors.As(s.DiceRules().Validate(), &diceErr) {
+		errs = append(errs, fieldError("dice."+diceErr.Field, "%s", diceErr.Problem))
+	}
+	if !slices.Contains(envServer.AuditPayers, s.Audit.Payer) {
+		errs = append(errs, fieldError("audit.payer", "must be one of %v, got %q", envServer.AuditPayers, s.Audit.Payer))
+	}
+	if s.Audit.FalsePositive < 0 || s.Audit.FalsePositive > 1 {
+		errs = append(errs, fieldError("audit.falsePositive", "must be between 0 and 1, got %v", s.Audit.FalsePositive))
+	}
+	if !slices.Contains(envServer.JurySelections, s.Appeals.Jury) {
+		errs = append(errs, fieldError("appeals.jury", "must be one of %v, got %q", envServer.JurySelections, s.Appeals.Jury))
+	}
+	if s.Appeals.JurySize <= 0 {
+		errs = append(errs, fieldError("appeals.jurySize", "must be positive, got %d", s.Appeals.JurySize))
+	}
+	if !slices.Contains(envServer.OffenceVisibilities, s.Offences.Visibility) {
+		errs = append(errs, fieldError("offences.visibility", "must be one of %v, got %q", envServer.OffenceVisibilities, s.Offences.Visibility))
+	}
+	if s.Offences.Visibility == envServer.OffencesExpiring && s.Offences.ExpireTurns <= 0 {
+		errs = append(errs, fieldError("offences.expireTurns", "must be positive when offences.visibility is %s, got %d", envServer.OffencesExpiring, s.Offences.ExpireTurns))
+	}
+	if !slices.Contains(common.TrustModelNames, s.Trust.Model) {
+		errs = append(errs, fieldError("trust.model", "must be one of %v, got %q", common.TrustModelNames, s.Trust.Model))
+	}
+	var ladderErr *common.SanctionLadderError
+	if errors.As(s.Sanctions.Validate(), &ladderErr) {
+		errs = append(errs, fieldError("sanctions."+ladderErr.Field, "%s", ladderErr.Problem))
+	}
+
+	if s.Checkpoint.Every < 0 {
+		errs = append(errs, fieldError("checkpoint.every", "must not be negative, got %d", s.Checkpoint.Every))
+	}
+	if s.Checkpoint.Every > 0 && s.Checkpoint.Dir == "" {
+		errs = append(errs, fieldError("checkpoint.dir", "must be set when checkpoint.every is"))
+	}
+
+	if len(s.Population) == 0 {
+		errs = append(errs, fieldError("population", "must contain at least one entry"))
+	}
+	for i, entry := range s.Population {
+		field := fmt.Sprintf("population[%d]", i)
+		if entry.Count <= 0 {
+			errs = append(errs, fieldError(field+".count", "must be positive, got %d", entry.Count))
+		}
+		errs = append(errs, validateEntryConfig(field, entry, s.DiceRules())...)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Scenario) validateThresholdRules() []error {
+	var errs []error
+	if _, err := s.ThresholdRules(); err != nil {
+		var paramErr *envServer.ThresholdParamError
+		if errors.As(err, &paramErr) {
+			errs = append(errs, fieldError("threshold.params."+paramErr.Param, "%s", paramErr.Problem))
+		} else {
+			errs = append(errs, fieldError("threshold.policy", "%v", err))
+		}
+	}
+	if !slices.Contains(envServer.ThresholdActions, s.Threshold.Action) {
+		errs = append(errs, fieldError("threshold.action", "must be one of %v, got %q", envServer.ThresholdActions, s.Threshold.Action))
+	}
+	return errs
+}
+
+// The threshold rules the scenario describes
+func (s *Scenario) ThresholdRules() (envServer.ThresholdRules, error) {
+	policy, err := envServer.NewThresholdPolicy(s.Threshold.Policy, s.Threshold.Params)
+	if err != nil {
+		return envServer.ThresholdRules{}, err
+	}
+	return envServer.ThresholdRules{Policy: policy, Action: s.Threshold.Action, Deduct: s.Threshold.Deduct}, nil
+}
+
+// The dice rules the scenario describes
+func (s *Scenario) DiceRules() common.DiceRules {
+	return common.DiceRules{
+		Count:      s.Dice.Count,
+		Faces:      s.Dice.Faces,
+		Bust:       s.Dice.Bust,
+		BustFace:   s.Dice.BustFace,
+		Cap:        s.Dice.Cap,
+		MaxRerolls: s.Dice.MaxRerolls,
+	}
+}
@@ -0,0 +1,128 @@
+package environmentServer
+
+import (
+	"log"
+
+	"github.com/MattSScott/basePlatformSOMAS/v2/pkg/agent"
+	"github.com/MattSScott/basePlatformSOMAS/v2/pkg/message"
+	"github.com/google/uuid"
+
+	common "github.com/ADimoska/SOMASExtended/common"
+)
+
+/*
+* The server as agents see it. Agents are created with this rather than the
+* server itself, so that they only have the server functions in IServer and
+* cannot type assert their way to the rest. Other agents are only handed out as
+* a common.AgentHandle, so an agent cannot read another's score or make its
+* decisions for it. Message handlers run on the real recipient, so only the
+* message types declared in common are delivered. Each agent is created with
+* its own view, which it binds to its ID, so that the server knows who is
+* asking where the answer depends on it.
+ */
+type agentServer struct {
+	// messaging, which only needs agent IDs
+	agent.IExposedServerFunctions[common.IExtendedAgent]
+	cs     *EnvironmentServer
+	viewer uuid.UUID // the agent the view belongs to, nil until it is bound
+}
+
+// The server to create an agent with, one for each agent
+func (cs *EnvironmentServer) AgentFacing() common.IServer {
+	return &agentServer{IExposedServerFunctions: cs, cs: cs}
+}
+
+// Bind the view to the agent created with it. Only the first binding counts.
+func (s *agentServer) BindAgent(agentID uuid.UUID) {
+	if s.viewer == uuid.Nil {
+		s.viewer = agentID
+	}
+}
+
+func (s *agentServer) AccessAgentByID(agentID uuid.UUID) common.IExtendedAgent {
+	return common.NewAgentHandle(s.cs.AccessAgentByID(agentID), s)
+}
+
+func (s *agentServer) DeliverMessage(msg message.IMessage[common.IExtendedAgent], recipient uuid.UUID) {
+	if !common.IsGameMessage(msg) {
+		log.Printf("[WARNING] Agent %v sent Agent %v a message of undeclared type %T, dropping it\n", msg.GetSender(), recipient, msg)
+		return
+	}
+	s.cs.DeliverMessage(msg, recipient)
+}
+
+func (s *agentServer) CreateTeam() {
+	s.cs.CreateTeam()
+}
+
+func (s *agentServer) AddAgentToTeam(agentID uuid.UUID, teamID uuid.UUID) {
+	s.cs.AddAgentToTeam(agentID, teamID)
+}
+
+func (s *agentServer) GetAgentsInTeam(teamID uuid.UUID) []uuid.UUID {
+	return s.cs.GetAgentsInTeam(teamID)
+}
+
+func (s *agentServer) CheckAgentAlreadyInTeam(agentID uuid.UUID) bool {
+	return s.cs.CheckAgentAlreadyInTeam(agentID)
+}
+
+func (s *agentServer) CreateAndInitTeamWithAgents(agentIDs []uuid.UUID) uuid.UUID {
+	return s.cs.CreateAndInitTeamWithAgents(agentIDs)
+}
+
+func (s *agentServer) UpdateAndGetAgentExposedInfo() []common.ExposedAgentInfo {
+	return s.cs.UpdateAndGetAgentExposedInfo()
+}
+
+func (s *agentServer) IsAgentDead(agentID uuid.UUID) bool {
+	return s.cs.IsAgentDead(agentID)
+}
+
+func (s *agentServer) GetAgentKilledScore(agentID uuid.UUID) int {
+	return s.cs.GetAgentKilledScore(agentID)
+}
+
+func (s *agentServer) StartAgentTeamForming() {
+	s.cs.StartAgentTeamForming()
+}
+
+func (s *agentServer) GetTeam(agentID uuid.UUID) common.TeamView {
+	return s.cs.GetTeam(agentID)
+}
+
+func (s *agentServer) GetTeamFromTeamID(teamID uuid.UUID) common.TeamView {
+	return s.cs.GetTeamFromTeamID(teamID)
+}
+
+func (s *agentServer) GetTeamIDs() []uuid.UUID {
+	return s.cs.GetTeamIDs()
+}
+
+func (s *agentServer) GetTeamCommonPool(teamID uuid.UUID) int {
+	return s.cs.GetTeamCommonPool(teamID)
+}
+
+func (s *agentServer) GetDiceRules(agentID uuid.UUID) common.DiceRules {
+	return s.cs.GetDiceRules(agentID)
+}
+
+func (s *agentServer) ReportCheating(report *common.WhistleblowerMessage) {
+	s.cs.ReportCheating(report)
+}
+
+func (s *agentServer) GetOffences(agentID uuid.UUID) []common.Offence {
+	return s.cs.OffencesSeenBy(s.viewer, agentID)
+}
+
+func (s *agentServer) GetTrustModel() common.ITrustModel {
+	return s.cs.GetTrustModel()
+}
+
+func (s *agentServer) LogAgentStatus() {
+	s.cs.LogAgentStatus()
+}
+
+func (s *agentServer) PrintOrphanPool() {
+	s.cs.PrintOrphanPool()
+}
@@ -0,0 +1,206 @@
+package environmentServer
+
+import (
+	"log"
+	"slices"
+
+	"github.com/google/uuid"
+
+	common "github.com/ADimoska/SOMASExtended/common"
+	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+	voting "github.com/ADimoska/SOMASExtended/voting"
+)
+
+/*
+* An agent found guilty in an audit may appeal in the same turn. Appeals are
+* heard after the whistleblowing phase and before the sanctions phase, so the
+* sanction is held until the jury has decided. The jury is drawn from the
+* accused's teammates, as the appeal rules say, and a majority of it must vote
+* to overturn the verdict. An overturned verdict is not sanctioned: whatever
+* the accused paid for the audit is refunded from the common pool, and any
+* reward paid to a whistleblower for calling the audit is taken back. The AoA
+* undoes what it did on finding the agent guilty (see common.IAppealAoA), and
+* the team is told the agent was found innocent after all.
+ */
+
+type JurySelection string
+
+const (
+	JuryAtRandom    JurySelection = "random"       // teammates drawn at random
+	JuryByRank      JurySelection = "rank"         // the highest ranked teammates, see common.IRankedAoA
+	JuryNotAccusers JurySelection = "not-accusers" // teammates drawn at random from those that did not call for the audit
+)
+
+var JurySelections = []JurySelection{JuryAtRandom, JuryByRank, JuryNotAccusers}
+
+type AppealRules struct {
+	Jury JurySelection
+	Size int // jurors for each appeal, fewer if there are not enough teammates
+}
+
+func DefaultAppealRules() AppealRules {
+	return AppealRules{Jury: JuryAtRandom, Size: 3}
+}
+
+// Set how appeals are heard
+func (cs *EnvironmentServer) SetAppealRules(rules AppealRules) {
+	cs.appealRules = &rules
+}
+
+// How appeals are heard, the default rules for servers that were never told
+func (cs *EnvironmentServer) GetAppealRules() AppealRules {
+	if cs.appealRules == nil {
+		rules := DefaultAppealRules()
+		cs.appealRules = &rules
+	}
+	return *cs.appealRules
+}
+
+// Hear the appeal of every agent found guilty this turn that wants to appeal
+func (cs *EnvironmentServer) appealsPhase(turn *teamTurn) {
+	for i := range turn.verdicts {
+		verdict := &turn.verdicts[i]
+		if !verdict.guilty || !cs.isActiveMember(turn, verdict.agentID) {
+			continue
+		}
+		if !turn.agentMap[verdict.agentID].DecideAppeal(verdict.phase) {
+			continue
+		}
+		cs.hearAppeal(turn, verdict)
+	}
+}
+
+func (cs *EnvironmentServer) hearAppeal(turn *teamTurn, verdict *auditVerdict) {
+	team := turn.team
+	jurors := cs.drawJury(turn, verdict)
+
+	votes := []bool{}
+	for _, juror := range jurors {
+		overturn := turn.agentMap[juror].GetAppealVote(verdict.agentID, verdict.phase)
+		votes = append(votes, overturn)
+		isVote := -1
+		if overturn {
+			isVote = 1
+		}
+		cs.recordEvent(gameRecorder.EventVoteCast, gameRecorder.VoteCast{
+			TeamID:     team.TeamID,
+			Ballot:     "appeal",
+			VoterID:    juror,
+			VotedForID: verdict.agentID,
+			IsVote:     isVote,
+		})
+	}
+	result := voting.Plurality([]bool{true, false}, votes)
+	overturned, _ := result.Winner(voting.NoTies())
+
+	appeal := gameRecorder.Appeal{
+		TeamID:      team.TeamID,
+		AgentID:     verdict.agentID,
+		Kind:        verdict.audit,
+		Jurors:      jurors,
+		ForOverturn: int(result.Scores[true]),
+		ForUphold:   int(result.Scores[false]),
+		Overturned:  overturned,
+	}
+	if overturned {
+		verdict.guilty = false
+		appeal.Refunded = cs.transferFromPool(team, verdict.agentID, verdict.paid)
+		for _, reward := range verdict.rewards {
+			reclaimed := cs.transferToPool(team, reward.AgentID, reward.Amount)
+			if reclaimed > 0 {
+				appeal.Reclaimed += reclaimed
+				appeal.Reclaims = append(appeal.Reclaims, gameRecorder.Reclaim{AgentID: reward.AgentID, Amount: reclaimed, Score: cs.GetAgentMap()[reward.AgentID].GetTrueScore()})
+			}
+		}
+		cs.overturnVerdict(turn, verdict)
+		log.Printf("[server] Agent %v's appeal against its %s audit was upheld %v to %v, refunded %v\n", verdict.agentID, verdict.audit, appeal.ForOverturn, appeal.ForUphold, appeal.Refunded)
+	} else {
+		log.Printf("[server] Agent %v's appeal against its %s audit was dismissed %v to %v\n", verdict.agentID, verdict.audit, appeal.ForUphold, appeal.ForOverturn)
+	}
+	appeal.Score = turn.agentMap[verdict.agentID].GetTrueScore()
+	appeal.CommonPool = team.GetCommonPool()
+	cs.recordEvent(gameRecorder.EventAppeal, appeal)
+}
+
+// Have the AoA undo the guilty verdict and tell the team of the new one
+func (cs *EnvironmentServer) overturnVerdict(turn *teamTurn, verdict *auditVerdict) {
+	team := turn.team
+	if aoa, ok := team.TeamAoA.(common.IAppealAoA); ok {
+		aoa.OnVerdictOverturned(cs, team, turn.agentMap, verdict.agentID, verdict.phase)
+	}
+	kind, ok := auditKindOf(verdict.phase)
+	if !ok {
+		return
+	}
+	for _, agent := range cs.activeMembers(turn, team.Agents) {
+		kind.broadcast(agent, verdict.agentID, false)
+	}
+}
+
+// The teammates that hear an appeal, as the appeal rules say
+func (cs *EnvironmentServer) drawJury(turn *teamTurn, verdict *auditVerdict) []uuid.UUID {
+	rules := cs.GetAppealRules()
+	eligible := []uuid.UUID{}
+	for _, agent := range cs.activeMembers(turn, turn.team.Agents) {
+		agentID := agent.GetID()
+		if agentID == verdict.agentID {
+			continue
+		}
+		if rules.Jury == JuryNotAccusers && slices.Contains(verdict.accusers, agentID) {
+			continue
+		}
+		eligible = append(eligible, agentID)
+	}
+	if len(eligible) <= rules.Size {
+		return eligible
+	}
+
+	if rules.Jury == JuryByRank {
+		rank := cs.memberRank(turn)
+		slices.SortStableFunc(eligible, func(a, b uuid.UUID) int {
+			return rank(b) - rank(a)
+		})
+	} else {
+		cs.getRand().Shuffle(len(eligible), func(i, j int) {
+			eligible[i], eligible[j] = eligible[j], eligible[i]
+		})
+	}
+	return eligible[:rules.Size]
+}
+
+// How the team's AoA ranks its members, or their scores if it does not
+func (cs *EnvironmentServer) memberRank(turn *teamTurn) func(agentID uuid.UUID) int {
+	if ranked, ok := turn.team.TeamAoA.(common.IRankedAoA); ok {
+		return ranked.GetMemberRank
+	}
+	return func(agentID uuid.UUID) int {
+		return turn.agentMap[agentID].GetTrueScore()
+	}
+}
+
+// Pay an agent up to amount from the common pool, returning what was paid
+func (cs *EnvironmentServer) transferFromPool(team *common.Team, agentID uuid.UUID, amount int) int {
+	amount = min(amount, team.GetCommonPool())
+	if amount <= 0 {
+		return 0
+	}
+	agent := cs.GetAgentMap()[agentID]
+	team.SetCommonPool(team.GetCommonPool() - amount)
+	agent.SetTrueScore(agent.GetTrueScore() + amount)
+	return amount
+}
+
+// Take up to amount from an agent into the common pool, returning what was taken
+func (cs *EnvironmentServer) transferToPool(team *common.Team, agentID uuid.UUID, amount int) int {
+	agent := cs.GetAgentMap()[agentID]
+	if agent == nil {
+		return 0
+	}
+	amount = min(amount, agent.GetTrueScore())
+	if amount <= 0 {
+		return 0
+	}
+	agent.SetTrueScore(agent.GetTrueScore() - amount)
+	team.SetCommonPool(team.GetCommonPool() + amount)
+	return amount
+}
@@ -0,0 +1,159 @@
+package environmentServer
+
+import (
+	"log"
+
+	"github.com/google/uuid"
+
+	common "github.com/ADimoska/SOMASExtended/common"
+	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+)
+
+/*
+* Every audit costs what the team's AoA says it does (GetAuditCost), and the
+* turn pipeline charges it the same way whatever the AoA. Who pays is set for
+* the whole game by the audit payer rule. The common pool always pays when the
+* rule finds nobody else to, and an audit is skipped when those who would pay
+* for it cannot afford to.
+ */
+
+type AuditPayer string
+
+const (
+	AuditPaidByPool   AuditPayer = "pool"   // the team's common pool, as it always has
+	AuditPaidByVoters AuditPayer = "voters" // the agents that voted to audit the agent, in equal shares
+	AuditPaidByGuilty AuditPayer = "guilty" // the audited agent, if it is found guilty
+)
+
+var AuditPayers = []AuditPayer{AuditPaidByPool, AuditPaidByVoters, AuditPaidByGuilty}
+
+// Set who pays for audits
+func (cs *EnvironmentServer) SetAuditPayer(payer AuditPayer) {
+	cs.auditPayer = payer
+}
+
+// Get who pays for audits, the common pool for servers that were never told
+func (cs *EnvironmentServer) getAuditPayer() AuditPayer {
+	if cs.auditPayer == "" {
+		return AuditPaidByPool
+	}
+	return cs.auditPayer
+}
+
+// Set how often audits accuse an agent they found nothing on, for every team
+// whose AoA lets it be set (see common.IAuditNoiseAoA)
+func (cs *EnvironmentServer) SetAuditFalsePositive(rate float64) {
+	cs.auditFalsePositive = rate
+}
+
+// Give a team's newly taken up AoA the server's false positive rate
+func (cs *EnvironmentServer) applyAuditNoise(team *common.Team) {
+	if noisy, ok := team.TeamAoA.(common.IAuditNoiseAoA); ok {
+		noisy.SetFalsePositive(cs.auditFalsePositive)
+	}
+}
+
+// What each agent owes for an audit. Agents not in the bill, and any part of
+// the cost the bill does not cover, are paid for by the common pool.
+type auditBill struct {
+	cost   int
+	shares map[uuid.UUID]int
+	order  []uuid.UUID // the agents in shares, in the order they are charged
+}
+
+// Share the cost between the agents that voted to audit the agent, those
+// earlier in the vote paying any remainder
+func (cs *EnvironmentServer) votersBill(turn *teamTurn, votes []common.Vote, agentToAudit uuid.UUID, cost int) auditBill {
+	bill := auditBill{cost: cost, shares: make(map[uuid.UUID]int)}
+	for _, vote := range votes {
+		if vote.IsVote != 1 || vote.VotedForID != agentToAudit {
+			continue
+		}
+		if _, counted := bill.shares[vote.VoterID]; counted || turn.agentMap[vote.VoterID] == nil {
+			continue
+		}
+		bill.shares[vote.VoterID] = 0
+		bill.order = append(bill.order, vote.VoterID)
+	}
+	for i, voterID := range bill.order {
+		bill.shares[voterID] = cost / len(bill.order)
+		if i < cost%len(bill.order) {
+			bill.shares[voterID]++
+		}
+	}
+	return bill
+}
+
+// Work out who will pay for an audit before it is carried out, and whether they
+// can afford to. Under the guilty rule the verdict is not known yet, so the
+// common pool must be able to pay in case the agent is innocent.
+func (cs *EnvironmentServer) billAudit(turn *teamTurn, votes []common.Vote, agentToAudit uuid.UUID, cost int) (auditBill, bool) {
+	bill := auditBill{cost: cost}
+	if cs.getAuditPayer() == AuditPaidByVoters {
+		bill = cs.votersBill(turn, votes, agentToAudit, cost)
+	}
+	if len(bill.order) == 0 {
+		return bill, cost <= turn.team.GetCommonPool()
+	}
+	for _, voterID := range bill.order {
+		if bill.shares[voterID] > turn.agentMap[voterID].GetTrueScore() {
+			return bill, false
+		}
+	}
+	return bill, true
+}
+
+// Charge for an audit once its verdict is known, recording each payment.
+// Returns what the audited agent paid.
+func (cs *EnvironmentServer) chargeAudit(turn *teamTurn, kind auditKind, bill auditBill, agentToAudit uuid.UUID, guilty bool) int {
+	if bill.cost <= 0 {
+		return 0
+	}
+	if cs.getAuditPayer() == AuditPaidByGuilty && guilty {
+		// the guilty agent pays what it can, and the pool the rest
+		agent := turn.agentMap[agentToAudit]
+		bill.shares = map[uuid.UUID]int{agentToAudit: min(bill.cost, max(agent.GetTrueScore(), 0))}
+		bill.order = []uuid.UUID{agentToAudit}
+	}
+
+	remaining, paid := bill.cost, 0
+	for _, payerID := range bill.order {
+		agent := turn.agentMap[payerID]
+		share := bill.shares[payerID]
+		if share <= 0 {
+			continue
+		}
+		agent.SetTrueScore(agent.GetTrueScore() - share)
+		remaining -= share
+		payer := "voter"
+		if payerID == agentToAudit {
+			payer = "guilty"
+			paid += share
+		}
+		log.Printf("[server] Agent %v paid %v towards the %s audit of %v. Remaining score: %v\n", payerID, share, kind.name, agentToAudit, agent.GetTrueScore())
+		cs.recordEvent(gameRecorder.EventAuditCharge, gameRecorder.AuditCharge{
+			TeamID:    turn.team.TeamID,
+			Kind:      kind.name,
+			AuditedID: agentToAudit,
+			PayerID:   payerID,
+			Payer:     payer,
+			Amount:    share,
+			Balance:   agent.GetTrueScore(),
+		})
+	}
+
+	if remaining > 0 {
+		team := turn.team
+		team.SetCommonPool(team.GetCommonPool() - remaining)
+		log.Printf("[server] %s audit cost of %v deducted from the common pool. Remaining pool: %v\n", kind.name, remaining, team.GetCommonPool())
+		cs.recordEvent(gameRecorder.EventAuditCharge, gameRecorder.AuditCharge{
+			TeamID:    team.TeamID,
+			Kind:      kind.name,
+			AuditedID: agentToAudit,
+			Payer:     "pool",
+			Amount:    remaining,
+			Balance:   team.GetCommonPool(),
+		})
+	}
+	return paid
+}
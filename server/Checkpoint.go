@@ -0,0 +1,325 @@
+package environmentServer
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+
+	common "github.com/ADimoska/SOMASExtended/common"
+	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+)
+
+/*
+* A checkpoint holds the complete state of a game at a turn boundary, so that a
+* long run can be resumed after a crash. To resume, the scenario stored in the
+* checkpoint is used to create the server and the same agent population again
+* (with the master seed restored first so every agent gets back its ID), and
+* RestoreCheckpoint then overwrites their state. The server skips every turn up
+* to and including the one the checkpoint was saved after.
+*
+* AoAs and agents take part by implementing common.ISnapshotter. Every AoA must,
+* as the game cannot carry on without it. Agents that do not are restored with
+* their score and team only.
+ */
+
+// Bump this whenever the format changes, old checkpoints are then rejected
+const CheckpointVersion = 2
+
+type Checkpoint struct {
+	Version  int
+	Scenario string // the scenario the game was created from, as YAML
+	Random   common.RandomSnapshot
+	Server   serverSnapshot
+}
+
+type serverSnapshot struct {
+	Iteration              int
+	Turn                   int
+	RoundScoreThreshold    int
+	ThresholdAppliedInTurn bool
+	AllAgentsDead          bool
+	Rand                   common.RandState
+	Teams                  []teamSnapshot
+	OrphanPool             []uuid.UUID
+	DeadAgents             []uuid.UUID                       // in the order they died
+	Restrictions           map[uuid.UUID]map[common.Role]int `json:",omitempty"`
+	LeaderTerms            map[uuid.UUID]int                 `json:",omitempty"`
+	Offences               []common.Offence                  `json:",omitempty"`
+	Agents                 []agentSnapshot
+	Recorder               gameRecorder.RecorderSnapshot
+}
+
+type teamSnapshot struct {
+	TeamID         uuid.UUID
+	Agents         []uuid.UUID
+	AoAID          int
+	AoA            json.RawMessage
+	CommonPool     int
+	KnownThreshold int
+	ValidThreshold bool
+}
+
+type agentSnapshot struct {
+	ID     uuid.UUID
+	Score  int
+	TeamID uuid.UUID
+	State  json.RawMessage `json:",omitempty"`
+}
+
+// Save a checkpoint every this many turns, counted across iterations
+func (cs *EnvironmentServer) EnableCheckpoints(every int, dir string, scenario []byte) {
+	cs.checkpointEvery = every
+	cs.checkpointDir = dir
+	cs.scenario = scenario
+}
+
+// Called at the end of every turn
+func (cs *EnvironmentServer) maybeSaveCheckpoint() {
+	if cs.checkpointEvery <= 0 {
+		return
+	}
+	turnsPlayed := cs.iteration*cs.GetTurns() + cs.turn + 1
+	if turnsPlayed%cs.checkpointEvery != 0 {
+		return
+	}
+
+	path := filepath.Join(cs.checkpointDir, fmt.Sprintf("checkpoint_%d_%d.json", cs.iteration, cs.turn))
+	if err := cs.SaveCheckpoint(path); err != nil {
+		log.Printf("[WARNING] Failed to save checkpoint: %v\n", err)
+		return
+	}
+	log.Printf("[server] Saved checkpoint to %s\n", path)
+}
+
+// Write the state of the game, as it is at the end of the current turn, to path
+func (cs *EnvironmentServer) SaveCheckpoint(path string) error {
+	// the events up to the checkpoint must be in the log for it to be replayed
+	cs.DataRecorder.FlushEvents()
+	snapshot, err := cs.snapshot()
+	if err != nil {
+		return err
+	}
+	checkpoint := Checkpoint{
+		Version:  CheckpointVersion,
+		Scenario: string(cs.scenario),
+		Random:   common.SnapshotRandom(),
+		Server:   snapshot,
+	}
+
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	// write to a temporary file first so a crash mid-write leaves the previous checkpoint intact
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("checkpoint %s: %v", path, err)
+	}
+	if checkpoint.Version != CheckpointVersion {
+		return nil, fmt.Errorf("checkpoint %s has version %d, expected %d", path, checkpoint.Version, CheckpointVersion)
+	}
+	return &checkpoint, nil
+}
+
+func (cs *EnvironmentServer) snapshot() (serverSnapshot, error) {
+	randState, err := common.GetRandState(cs.getRand())
+	if err != nil {
+		return serverSnapshot{}, err
+	}
+	snapshot := serverSnapshot{
+		Iteration:              cs.iteration,
+		Turn:                   cs.turn,
+		RoundScoreThreshold:    cs.roundScoreThreshold,
+		ThresholdAppliedInTurn: cs.thresholdAppliedInTurn,
+		AllAgentsDead:          cs.allAgentsDead,
+		Rand:                   randState,
+		OrphanPool:             common.SortedIDs(cs.orphanPool),
+		Restrictions:           cs.restrictions,
+		LeaderTerms:            cs.leaderTerms,
+		Offences:               cs.offences,
+		Recorder:               cs.DataRecorder.Snapshot(),
+	}
+
+	for _, teamID := range common.SortedIDs(cs.Teams) {
+		team := cs.Teams[teamID]
+		snapshotter, ok := team.TeamAoA.(common.ISnapshotter)
+		if !ok {
+			return serverSnapshot{}, fmt.Errorf("team %v: AoA %d (%T) does not support checkpoints", teamID, team.TeamAoAID, team.TeamAoA)
+		}
+		aoaState, err := snapshotter.SnapshotState()
+		if err != nil {
+			return serverSnapshot{}, fmt.Errorf("team %v: AoA %d: %v", teamID, team.TeamAoAID, err)
+		}
+		knownThreshold, validThreshold := team.GetKnownThreshold()
+		snapshot.Teams = append(snapshot.Teams, teamSnapshot{
+			TeamID:         teamID,
+			Agents:         team.Agents,
+			AoAID:          team.TeamAoAID,
+			AoA:            aoaState,
+			CommonPool:     team.GetCommonPool(),
+			KnownThreshold: knownThreshold,
+			ValidThreshold: validThreshold,
+		})
+	}
+
+	allAgents := []common.IExtendedAgent{}
+	agentMap := cs.GetAgentMap()
+	for _, agentID := range common.SortedIDs(agentMap) {
+		allAgents = append(allAgents, agentMap[agentID])
+	}
+	for _, agent := range cs.deadAgents {
+		snapshot.DeadAgents = append(snapshot.DeadAgents, agent.GetID())
+		allAgents = append(allAgents, agent)
+	}
+	for _, agent := range allAgents {
+		agentState := agentSnapshot{ID: agent.GetID(), Score: agent.GetTrueScore(), TeamID: agent.GetTeamID()}
+		if snapshotter, ok := agent.(common.ISnapshotter); ok {
+			if agentState.State, err = snapshotter.SnapshotState(); err != nil {
+				return serverSnapshot{}, fmt.Errorf("agent %v: %v", agent.GetID(), err)
+			}
+		}
+		snapshot.Agents = append(snapshot.Agents, agentState)
+	}
+	return snapshot, nil
+}
+
+/*
+* Put the server and its agents back into the state saved in the checkpoint.
+* The agents must already have been added to the server, created from the
+* checkpoint's scenario after common.RestoreMasterSeed.
+ */
+func (cs *EnvironmentServer) RestoreCheckpoint(checkpoint *Checkpoint) error {
+	snapshot := checkpoint.Server
+	agentMap := cs.GetAgentMap()
+
+	for _, agentState := range snapshot.Agents {
+		agent, exists := agentMap[agentState.ID]
+		if !exists {
+			return fmt.Errorf("agent %v in the checkpoint was not created, was the population changed?", agentState.ID)
+		}
+		agent.SetTrueScore(agentState.Score)
+		agent.SetTeamID(agentState.TeamID)
+		if agentState.State == nil {
+			continue
+		}
+		snapshotter, ok := agent.(common.ISnapshotter)
+		if !ok {
+			return fmt.Errorf("agent %v (%T) has saved state but does not support checkpoints", agentState.ID, agent)
+		}
+		if err := snapshotter.RestoreState(agentState.State); err != nil {
+			return fmt.Errorf("agent %v: %v", agentState.ID, err)
+		}
+	}
+	if len(agentMap) != len(snapshot.Agents) {
+		return fmt.Errorf("checkpoint has %d agents, but %d were created", len(snapshot.Agents), len(agentMap))
+	}
+
+	cs.deadAgents = nil
+	for _, agentID := range snapshot.DeadAgents {
+		agent := agentMap[agentID]
+		cs.deadAgents = append(cs.deadAgents, agent)
+		cs.RemoveAgent(agent)
+	}
+
+	cs.Teams = make(map[uuid.UUID]*common.Team)
+	for _, teamState := range snapshot.Teams {
+		entry, exists := common.GetAoAEntry(teamState.AoAID)
+		if !exists {
+			return fmt.Errorf("team %v: AoA %d is not registered", teamState.TeamID, teamState.AoAID)
+		}
+		team := common.NewTeam(teamState.TeamID)
+		team.Agents = teamState.Agents
+		team.TeamAoAID = teamState.AoAID
+		team.TeamAoA = entry.New(team)
+		team.SetCommonPool(teamState.CommonPool)
+		team.SetKnownThreshold(teamState.KnownThreshold)
+		if !teamState.ValidThreshold {
+			team.InvalidateThreshold()
+		}
+		snapshotter, ok := team.TeamAoA.(common.ISnapshotter)
+		if !ok {
+			return fmt.Errorf("team %v: AoA %d (%s) does not support checkpoints", teamState.TeamID, entry.ID, entry.Name)
+		}
+		if err := snapshotter.RestoreState(teamState.AoA); err != nil {
+			return fmt.Errorf("team %v: AoA %d (%s): %v", teamState.TeamID, entry.ID, entry.Name, err)
+		}
+		cs.Teams[teamState.TeamID] = team
+	}
+
+	cs.orphanPool = make(OrphanPoolType)
+	for _, agentID := range snapshot.OrphanPool {
+		cs.orphanPool[agentID] = struct{}{}
+	}
+
+	cs.iteration = snapshot.Iteration
+	cs.turn = snapshot.Turn
+	cs.roundScoreThreshold = snapshot.RoundScoreThreshold
+	cs.thresholdAppliedInTurn = snapshot.ThresholdAppliedInTurn
+	cs.allAgentsDead = snapshot.AllAgentsDead
+	cs.restrictions = snapshot.Restrictions
+	cs.leaderTerms = snapshot.LeaderTerms
+	cs.offences = snapshot.Offences
+	cs.DataRecorder = gameRecorder.RestoreRecorder(snapshot.Recorder)
+	cs.resumeAfter = &turnPosition{iteration: snapshot.Iteration, turn: snapshot.Turn}
+
+	// last, as creating the teams and AoAs above takes from the random streams
+	if err := common.SetRandState(cs.getRand(), snapshot.Rand); err != nil {
+		return err
+	}
+	common.RestoreRandomStreams(checkpoint.Random)
+	return nil
+}
+
+// Mark in the event log that the game carries on from a checkpoint, so that
+// replays drop the events logged after it by the original run
+func (cs *EnvironmentServer) RecordResumed(checkpointPath string) {
+	cs.recordEvent(gameRecorder.EventResumed, gameRecorder.Resumed{
+		Checkpoint: checkpointPath,
+		Events:     cs.DataRecorder.EventsAtCheckpoint(),
+	})
+}
+
+type turnPosition struct {
+	iteration int
+	turn      int
+}
+
+// Whether the given turn was already played before the checkpoint being
+// resumed from was saved. Pass a turn of -1 for the start of the iteration.
+func (cs *EnvironmentServer) alreadyPlayed(iteration, turn int) bool {
+	if cs.resumeAfter == nil {
+		return false
+	}
+	if iteration != cs.resumeAfter.iteration {
+		return iteration < cs.resumeAfter.iteration
+	}
+	return turn <= cs.resumeAfter.turn
+}
+
+// End a turn that is skipped on resume straight away, rather than waiting for
+// the agents' messaging to time out
+func (cs *EnvironmentServer) skipTurn() {
+	for _, agentID := range common.SortedIDs(cs.GetAgentMap()) {
+		go cs.BaseServer.AgentStoppedTalking(agentID)
+	}
+}
@@ -0,0 +1,89 @@
+package environmentServer
+
+import (
+	"log"
+
+	"github.com/google/uuid"
+
+	"github.com/ADimoska/SOMASExtended/common"
+)
+
+/*
+* The server owns the dice, and rolls them by the rules set in the scenario
+* (see common.DiceRules). Agents are only asked whether to stick or roll
+* again (StickOrAgain, or StickOrAgainFor when someone else controls their
+* rolls), and are told the outcome of each throw through HandleDiceRoll and
+* HandleRollingComplete. Their score is only ever credited here, so an agent
+* cannot make up its own rolls.
+ */
+
+// Set the rules of the dice game
+func (cs *EnvironmentServer) SetDiceRules(rules common.DiceRules) {
+	cs.diceRules = &rules
+}
+
+// Change the dice rules for a single agent
+func (cs *EnvironmentServer) SetDiceModifier(agentID uuid.UUID, modifier common.DiceModifier) {
+	if cs.diceModifiers == nil {
+		cs.diceModifiers = make(map[uuid.UUID]common.DiceModifier)
+	}
+	cs.diceModifiers[agentID] = modifier
+}
+
+// The rules the server rolls by for an agent, including its modifier. Servers
+// that were never given any rules (e.g. in tests) use the defaults.
+func (cs *EnvironmentServer) GetDiceRules(agentID uuid.UUID) common.DiceRules {
+	if cs.diceRules == nil {
+		rules := common.DefaultDiceRules()
+		cs.diceRules = &rules
+	}
+	return cs.diceRules.With(cs.diceModifiers[agentID])
+}
+
+// Throw the dice for an agent. Returns the roll and whether it went bust.
+func (cs *EnvironmentServer) throwDice(rules common.DiceRules, accumulatedScore int, prevRoll int) (int, bool) {
+	roll, faces := rules.Throw(cs.getRand())
+	return roll, rules.IsBust(roll, faces, accumulatedScore, prevRoll)
+}
+
+// Roll the dice for an agent that decides for itself when to stick. The first
+// throw is always made. Returns every roll made, in order.
+func (cs *EnvironmentServer) RollDice(agent common.IExtendedAgent) []int {
+	rules := cs.GetDiceRules(agent.GetID())
+	rolls := []int{}
+	turnScore, prevRoll := 0, -1
+
+	for {
+		roll, bust := cs.throwDice(rules, turnScore, prevRoll)
+		rolls = append(rolls, roll)
+		if bust {
+			// Gone bust, so lose everything rolled this turn
+			turnScore = 0
+			agent.HandleDiceRoll(common.DiceRoll{Throw: len(rolls), Roll: roll, Bust: true})
+			break
+		}
+
+		turnScore += roll
+		prevRoll = roll
+		agent.HandleDiceRoll(common.DiceRoll{Throw: len(rolls), Roll: roll, TurnScore: turnScore})
+		if !rules.MayRollAgain(len(rolls)) {
+			log.Printf("%s has used all of its rerolls\n", agent.GetID())
+			break
+		}
+		if agent.StickOrAgain(turnScore, roll) {
+			agent.DecideStick()
+			break
+		}
+		agent.DecideRollAgain()
+	}
+
+	cs.creditTurnScore(agent, turnScore)
+	return rolls
+}
+
+// Add the score rolled this turn and let the agent know
+func (cs *EnvironmentServer) creditTurnScore(agent common.IExtendedAgent, turnScore int) {
+	agent.SetTrueScore(agent.GetTrueScore() + turnScore)
+	log.Printf("%s turn score: %v, total score: %v\n", agent.GetID(), turnScore, agent.GetTrueScore())
+	agent.HandleRollingComplete(turnScore, agent.GetTrueScore())
+}
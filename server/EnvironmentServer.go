@@ -1,10 +1,9 @@
 package environmentServer
 
 import (
-	"fmt"
 	"log"
 	"math/rand"
-	"reflect"
+	"slices"
 	"sync"
 	"time"
 
@@ -14,6 +13,7 @@ import (
 	"github.com/MattSScott/basePlatformSOMAS/v2/pkg/server"
 
 	common "github.com/ADimoska/SOMASExtended/common"
+	voting "github.com/ADimoska/SOMASExtended/voting"
 )
 
 type EnvironmentServer struct {
@@ -36,354 +36,54 @@ type EnvironmentServer struct {
 	thresholdTurns         int
 	thresholdAppliedInTurn bool
 	allAgentsDead          bool
+	rng                    *rand.Rand // server's own random stream, see common.NewRandStream
 
-	// game config parameters :D
-	exposeThresholds bool // expose current threshold to agents
-}
+	// protocol round currently being waited on, see ProtocolRound.go
+	roundMutex  sync.Mutex
+	activeRound *protocolRound
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
+	// reports of cheating waiting for the whistleblowing phase, see Whistleblowing.go
+	reportsMutex sync.Mutex
+	reports      []filedReport
 
-func (cs *EnvironmentServer) RunTurnDefault(team *common.Team) {
-	log.Println("\nRunning turn for team ", team.TeamID)
-	team.TeamAoA.RunPreIterationAoaLogic(team, cs.GetAgentMap(), cs.DataRecorder)
-	// Sum of contributions from all agents in the team for this turn
-	agentContributionsTotal := 0
-	for _, agentID := range team.Agents {
-		agent := cs.GetAgentMap()[agentID]
-		if agent == nil || agent.GetTeamID() == uuid.Nil || cs.IsAgentDead(agentID) {
-			continue
-		}
-
-		if team.TeamAoAID == 2 && team.TeamAoA.(*common.Team2AoA).GetRollsLeft(agentID) > 0 {
-			team.TeamAoA.(*common.Team2AoA).RollOnce(agentID)
-			cs.OverrideAgentRolls(agentID, team.TeamAoA.(*common.Team2AoA).GetLeader())
-		} else {
-			agent.StartRollingDice(agent)
-		}
-
-		agentActualContribution := agent.GetActualContribution(agent)
-		agentContributionsTotal += agentActualContribution
-		agentStatedContribution := agent.GetStatedContribution(agent)
-
-		agent.StateContributionToTeam(agent)
-		agentScore := agent.GetTrueScore()
-		// Update audit result for this agent
-		team.TeamAoA.SetContributionAuditResult(agentID, agentScore, agentActualContribution, agentStatedContribution)
-		agent.SetTrueScore(agentScore - agentActualContribution)
-	}
-
-	// Update common pool with total contribution from this team
-	// 	Agents do not get to see the common pool before deciding their contribution
-	//  Different to the withdrawal phase!
-	team.SetCommonPool(team.GetCommonPool() + agentContributionsTotal)
-
-	team.TeamAoA.RunPostContributionAoaLogic(team, cs.GetAgentMap())
-
-	// Initiate Contribution Audit vote
-	contributionAuditVotes := []common.Vote{}
-	for _, agentID := range team.Agents {
-		agent := cs.GetAgentMap()[agentID]
-		vote := agent.GetContributionAuditVote()
-		contributionAuditVotes = append(contributionAuditVotes, vote)
-	}
-
-	// Execute Contribution Audit if necessary
-	if agentToAudit := team.TeamAoA.GetVoteResult(contributionAuditVotes); agentToAudit != uuid.Nil {
-		auditResult := team.TeamAoA.GetContributionAuditResult(agentToAudit)
-		log.Printf("Agent %v has been audited for Contribution\n", agentToAudit)
-
-		leaderAudited := false
-
-		if team.TeamAoAID == 2 {
-			if agentToAudit == team.TeamAoA.(*common.Team2AoA).GetLeader() {
-				cs.ElectNewLeader(team.TeamID)
-				leaderAudited = true
-			}
-		}
-
-		if auditResult {
-			cs.ApplyPunishment(team, agentToAudit)
-			if team.TeamAoAID == 2 && !leaderAudited {
-				if team.TeamAoA.(*common.Team2AoA).GetOffences(agentToAudit) == 3 {
-					cs.RemoveAgentFromTeam(agentToAudit)
-					log.Printf("Team2AoA Contribution: Agent %v has been removed from the team due to multiple offences\n", agentToAudit)
-				}
-			} else if team.TeamAoAID == 1 {
-				if team.TeamAoA.(*common.Team1AoA).GetNumberOfOffences(agentToAudit) >= 2 {
-					team.TeamAoA.(*common.Team1AoA).RemoveAgentFromTeam(agentToAudit)
-					cs.RemoveAgentFromTeam(agentToAudit)
-					// reset the number of offences for the agent
-					team.TeamAoA.(*common.Team1AoA).ResetNumberOfOffences(agentToAudit)
-					log.Printf("Team1AoA Contribution: Agent %v has been removed from the team due to multiple offences\n", agentToAudit)
-				}
-			}
-
-		}
-
-		for _, agentID := range team.Agents {
-			agent := cs.GetAgentMap()[agentID]
-			agent.SetAgentContributionAuditResult(agentToAudit, auditResult)
-		}
-	}
-
-	orderedAgents := team.TeamAoA.GetWithdrawalOrder(team.Agents)
-	for _, agentID := range orderedAgents {
-		agent := cs.GetAgentMap()[agentID]
-		if agent == nil || agent.GetTeamID() == uuid.Nil || cs.IsAgentDead(agentID) {
-			continue
-		}
-
-		// Pass the current pool value to agent's methods
-		currentPool := team.GetCommonPool()
-		agentActualWithdrawal := agent.GetActualWithdrawal(agent)
-		if agentActualWithdrawal > currentPool {
-			agentActualWithdrawal = currentPool // Ensure withdrawal does not exceed available pool
-		}
-		agentStatedWithdrawal := agent.GetStatedWithdrawal(agent)
-
-		agentScore := agent.GetTrueScore()
-		// Update audit result for this agent
-		team.TeamAoA.SetWithdrawalAuditResult(agentID, agentScore, agentActualWithdrawal, agentStatedWithdrawal, team.GetCommonPool())
-		agent.SetTrueScore(agentScore + agentActualWithdrawal)
-
-		// Update the common pool after each withdrawal so agents can see the updated pool before deciding their withdrawal.
-		//  Different to the contribution phase!
-		team.SetCommonPool(currentPool - agentActualWithdrawal)
-		log.Printf("[server] Agent %v withdrew %v. Remaining pool: %v\n", agentID, agentActualWithdrawal, team.GetCommonPool())
-	}
-
-	stateWithdrawOrder := make([]uuid.UUID, len(team.Agents))
-	copy(stateWithdrawOrder, team.Agents)
-	// Shuffle the order of agents to broadcast withdrawal amounts
-	rand.Shuffle(len(stateWithdrawOrder), func(i, j int) {
-		stateWithdrawOrder[i], stateWithdrawOrder[j] = stateWithdrawOrder[j], stateWithdrawOrder[i]
-	})
-
-	for _, agentId := range stateWithdrawOrder {
-		agent := cs.GetAgentMap()[agentId]
-		if agent.GetTeamID() == uuid.Nil || cs.IsAgentDead(agentId) {
-			continue
-		}
-		agent.StateWithdrawalToTeam(agent)
-	}
-
-	// Initiate Withdrawal Audit vote
-	withdrawalAuditVotes := []common.Vote{}
-	for _, agentID := range team.Agents {
-		agent := cs.GetAgentMap()[agentID]
-		vote := agent.GetWithdrawalAuditVote()
-		withdrawalAuditVotes = append(withdrawalAuditVotes, vote)
-	}
-
-	// Execute Withdrawal Audit if necessary
-	if agentToAudit := team.TeamAoA.GetVoteResult(withdrawalAuditVotes); agentToAudit != uuid.Nil {
-		auditResult := team.TeamAoA.GetWithdrawalAuditResult(agentToAudit)
-
-		leaderAudited := false
-
-		if team.TeamAoAID == 2 {
-			if agentToAudit == team.TeamAoA.(*common.Team2AoA).GetLeader() {
-				cs.ElectNewLeader(team.TeamID)
-				leaderAudited = true
-			}
-		}
-
-		if auditResult {
-			cs.ApplyPunishment(team, agentToAudit)
-			if team.TeamAoAID == 2 && !leaderAudited {
-				if team.TeamAoA.(*common.Team2AoA).GetOffences(agentToAudit) == 3 {
-					cs.RemoveAgentFromTeam(agentToAudit)
-					log.Printf("Team2AoA Withdraw: Agent %v has been removed from the team due to multiple offences\n", agentToAudit)
-				}
-			} else if team.TeamAoAID == 1 {
-				if team.TeamAoA.(*common.Team1AoA).GetNumberOfOffences(agentToAudit) >= 2 {
-					team.TeamAoA.(*common.Team1AoA).RemoveAgentFromTeam(agentToAudit)
-					cs.RemoveAgentFromTeam(agentToAudit)
-					// reset the number of offences for the agent
-					team.TeamAoA.(*common.Team1AoA).ResetNumberOfOffences(agentToAudit)
-					log.Printf("Team1AoA Withdraw: Agent %v has been removed from the team due to multiple offences\n", agentToAudit)
-				}
-			}
-
-		}
-
-		for _, agentID := range team.Agents {
-			agent := cs.GetAgentMap()[agentID]
-			agent.SetAgentWithdrawalAuditResult(agentToAudit, auditResult)
-		}
-	}
+	// game config parameters :D
+	exposeThresholds   bool            // expose current threshold to agents
+	teamFormingTimeout time.Duration   // how long to wait for team forming before carrying on
+	thresholdRules     *ThresholdRules // how the threshold is set and enforced, see ThresholdPolicy.go
+	diceRules          *common.DiceRules
+	diceModifiers      map[uuid.UUID]common.DiceModifier // per-agent changes to diceRules
+	auditPayer         AuditPayer                        // who pays for audits, see AuditCost.go
+	auditFalsePositive float64                           // how often audits accuse the innocent, see AuditCost.go
+	restrictions       map[uuid.UUID]map[common.Role]int // the last turn each agent is barred from each role, see Sanctions.go
+	sanctionLadder     *common.SanctionLadder            // climbed by AoAs with graduated sanctions
+	appealRules        *AppealRules                      // how appeals against audits are heard, see Appeals.go
+	offenceRules       *OffenceRules                     // how much of the offence registry agents see, see Offences.go
+	offences           []common.Offence                  // every offence proven in an audit, oldest first
+	trustModel         common.ITrustModel                // how agents combine opinions into trust, see Trust.go
+	leaderTerms        map[uuid.UUID]int                 // turns each team's leader has served, see Leadership.go
+
+	// checkpointing, see Checkpoint.go
+	checkpointEvery int           // save a checkpoint every this many turns, 0 to never save
+	checkpointDir   string        // where checkpoints are saved
+	scenario        []byte        // the scenario the server was created from, stored in checkpoints
+	resumeAfter     *turnPosition // the turn the game was resumed after, if it was resumed
+}
+
+// Get the server's random stream, creating it on first use so that servers
+// constructed without Init (e.g. in tests) still work.
+func (cs *EnvironmentServer) getRand() *rand.Rand {
+	if cs.rng == nil {
+		cs.rng = common.NewRandStream("server")
+	}
+	return cs.rng
 }
 
-func (cs *EnvironmentServer) RunTurnTeam4(team *common.Team) {
-	log.Println("\nRunning AoA 4 Variant turn for team ", team.TeamID)
-	// Sum of contributions from all agents in the team for this turn
-	agentContributionsTotal := 0
-	for _, agentID := range team.Agents {
-		agent := cs.GetAgentMap()[agentID]
-		if agent.GetTeamID() == uuid.Nil || cs.IsAgentDead(agentID) {
-			continue
-		}
-		// Override agent rolls for testing purposes
-		// agentList := []uuid.UUID{agentID}
-		// cs.OverrideAgentRolls(agentID, agentList, 1)
-		agent.StartRollingDice(agent)
-		agentActualContribution := agent.GetActualContribution(agent)
-		agentContributionsTotal += agentActualContribution
-		agentStatedContribution := agent.GetStatedContribution(agent)
-
-		agent.StateContributionToTeam(agent)
-		agentScore := agent.GetTrueScore()
-		// Update audit result for this agent
-		team.TeamAoA.SetContributionAuditResult(agentID, agentScore, agentActualContribution, agentStatedContribution)
-		agent.SetTrueScore(agentScore - agentActualContribution)
-	}
-
-	// ***************
-	rankUpVoteMap := make(map[uuid.UUID]map[uuid.UUID]int)
-	for _, agentID := range team.Agents {
-		agent := cs.GetAgentMap()[agentID]
-		agentRankMap := agent.Team4_GetRankUpVote()
-		rankUpVoteMap[agentID] = agentRankMap
-	}
-	team.TeamAoA.Team4_SetRankUp(rankUpVoteMap)
-
-	// ***************
-
-	// Update common pool with total contribution from this team
-	// 	Agents do not get to see the common pool before deciding their contribution
-	//  Different to the withdrawal phase!
-	team.SetCommonPool(team.GetCommonPool() + agentContributionsTotal)
-
-	// Initiate Contribution Audit vote
-	contributionAuditVotes := []common.Vote{}
-	for _, agentID := range team.Agents {
-		agent := cs.GetAgentMap()[agentID]
-		vote := agent.GetContributionAuditVote()
-		contributionAuditVotes = append(contributionAuditVotes, vote)
-	}
-
-	// Execute Contribution Audit if necessary
-	if agentToAudit := team.TeamAoA.GetVoteResult(contributionAuditVotes); agentToAudit != uuid.Nil {
-		auditResult := team.TeamAoA.GetContributionAuditResult(agentToAudit)
-		for _, agentID := range team.Agents {
-			agent := cs.GetAgentMap()[agentID]
-			agent.SetAgentContributionAuditResult(agentToAudit, auditResult)
-		}
-	}
-
-	// ***************
-	proposedWithdrawalMap := make(map[uuid.UUID]int)
-	for _, agentID := range team.Agents {
-		agent := cs.GetAgentMap()[agentID]
-		agentStatedWithdrawal := agent.Team4_GetProposedWithdrawal(agent)
-		proposedWithdrawalMap[agentID] = agentStatedWithdrawal
-		agent.Team4_StateProposalToTeam()
-
-	}
-	withdrawalVoteMap := make(map[uuid.UUID]map[uuid.UUID]int)
-
-	for _, agentID := range team.Agents {
-		agent := cs.GetAgentMap()[agentID]
-		// Get Map of AgentId and 1 or 0 to proposed withdrawal (for each agent)
-		agentVote := agent.Team4_GetProposedWithdrawalVote()
-		withdrawalVoteMap[agentID] = agentVote
-	}
-	team.TeamAoA.Team4_RunProposedWithdrawalVote(proposedWithdrawalMap, withdrawalVoteMap)
-	// ***************
-
-	orderedAgents := team.TeamAoA.GetWithdrawalOrder(team.Agents)
-	for _, agentID := range orderedAgents {
-		agent := cs.GetAgentMap()[agentID]
-		if agent.GetTeamID() == uuid.Nil || cs.IsAgentDead(agentID) {
-			continue
-		}
-
-		// Pass the current pool value to agent's methods
-		currentPool := team.GetCommonPool()
-		agentActualWithdrawal := agent.GetActualWithdrawal(agent)
-		if agentActualWithdrawal > currentPool {
-			agentActualWithdrawal = currentPool // Ensure withdrawal does not exceed available pool
-		}
-		agentStatedWithdrawal := agent.GetStatedWithdrawal(agent)
-
-		agentScore := agent.GetTrueScore()
-		// Update audit result for this agent
-		team.TeamAoA.SetWithdrawalAuditResult(agentID, agentScore, agentActualWithdrawal, agentStatedWithdrawal, team.GetCommonPool())
-		agent.SetTrueScore(agentScore + agentActualWithdrawal)
-
-		// Update the common pool after each withdrawal so agents can see the updated pool before deciding their withdrawal.
-		//  Different to the contribution phase!
-		team.SetCommonPool(currentPool - agentActualWithdrawal)
-		log.Printf("[server] Agent %v withdrew %v. Remaining pool: %v\n", agentID, agentActualWithdrawal, team.GetCommonPool())
-	}
-
-	stateWithdrawOrder := make([]uuid.UUID, len(team.Agents))
-	copy(stateWithdrawOrder, team.Agents)
-	// Shuffle the order of agents to broadcast withdrawal amounts
-	rand.Shuffle(len(stateWithdrawOrder), func(i, j int) {
-		stateWithdrawOrder[i], stateWithdrawOrder[j] = stateWithdrawOrder[j], stateWithdrawOrder[i]
-	})
-
-	for _, agentId := range stateWithdrawOrder {
-		agent := cs.GetAgentMap()[agentId]
-		if agent.GetTeamID() == uuid.Nil || cs.IsAgentDead(agentId) {
-			continue
-		}
-		agent.StateWithdrawalToTeam(agent)
-	}
-
-	// Initiate Withdrawal Audit vote
-	withdrawalAuditVotes := []common.Vote{}
-	for _, agentID := range team.Agents {
-		agent := cs.GetAgentMap()[agentID]
-		vote := agent.GetWithdrawalAuditVote()
-		withdrawalAuditVotes = append(withdrawalAuditVotes, vote)
-	}
-
-	// ***************
-	if agentToAudit := team.TeamAoA.GetVoteResult(withdrawalAuditVotes); agentToAudit != uuid.Nil {
-		agent := cs.GetAgentMap()[agentToAudit]
-		// agentConfession := agent.GetConfession()
-		agent.Team4_StateConfessionToTeam()
-		agentScore := agent.GetTrueScore()
-		punishmentVoteMap := make(map[uuid.UUID]map[int]int)
-		for _, agentID := range team.Agents {
-			agent := cs.GetAgentMap()[agentID]
-			punishmentVote := agent.Team4_GetPunishmentVoteMap()
-			punishmentVoteMap[agentID] = punishmentVote
-		}
-
-		punishmentResult := team.TeamAoA.Team4_HandlePunishmentVote(punishmentVoteMap) * agentScore / 100
-
-		log.Printf("Punishment Result for Agent %v: %d (Agent Score: %d)\n", agent.GetID(), punishmentResult, agentScore)
-
-		newScore := agentScore - punishmentResult
-		agent.SetTrueScore(newScore)
-
-		log.Printf("Updated Score for Agent %v: %d\n", agent.GetID(), agent.GetTrueScore())
-
-		currentPool := team.GetCommonPool()
-		log.Printf("Current Common Pool: %d\n", currentPool)
-
-		team.SetCommonPool(currentPool + punishmentResult)
-		updatedPool := team.GetCommonPool()
-		log.Printf("Updated Common Pool: %d\n", updatedPool)
-
-	}
-	// ***************
-	// Execute Withdrawal Audit if necessary
-	if agentToAudit := team.TeamAoA.GetVoteResult(withdrawalAuditVotes); agentToAudit != uuid.Nil {
-		auditResult := team.TeamAoA.GetWithdrawalAuditResult(agentToAudit)
-		for _, agentID := range team.Agents {
-			agent := cs.GetAgentMap()[agentID]
-			agent.SetAgentWithdrawalAuditResult(agentToAudit, auditResult)
-		}
+func (cs *EnvironmentServer) RunTurn(i, j int) {
+	if cs.alreadyPlayed(i, j) {
+		cs.skipTurn()
+		return
 	}
-}
 
-func (cs *EnvironmentServer) RunTurn(i, j int) {
 	log.Printf("\n\nIteration %v, Turn %v, current agent count: %v\n", i, j, len(cs.GetAgentMap()))
 
 	// Go over the list of all agents and add orphans to the orphan pool if
@@ -409,20 +109,13 @@ func (cs *EnvironmentServer) RunTurn(i, j int) {
 
 	cs.teamsMutex.Lock()
 
-	for _, team := range cs.Teams {
+	for _, teamID := range common.SortedIDs(cs.Teams) {
+		team := cs.Teams[teamID]
 		if len(team.Agents) == 0 {
 			log.Printf("No agents in team: %s\n", team.TeamID)
 			continue
 		}
-		teamAoA := reflect.TypeOf(team.TeamAoA)
-		switch teamAoA {
-		case reflect.TypeOf(&common.Team4AoA{}):
-			cs.RunTurnTeam4(team)
-		case reflect.TypeOf(&common.Team5AOA{}):
-			cs.RunTurnTeam5(team)
-		default:
-			cs.RunTurnDefault(team)
-		}
+		cs.runTeamTurn(team)
 	}
 
 	// check if threshold turn
@@ -441,20 +134,29 @@ func (cs *EnvironmentServer) RunTurn(i, j int) {
 	// do not record if the turn number is 0
 	if cs.turn > 0 && !cs.allAgentsDead {
 		cs.RecordTurnInfo()
+		cs.recordTrustGraph()
 	}
 
 	if cs.IsAllAgentsDead() {
 		cs.allAgentsDead = true
 	}
+
+	cs.maybeSaveCheckpoint()
 }
 
 func (cs *EnvironmentServer) RunStartOfIteration(iteration int) {
+	if cs.alreadyPlayed(iteration, -1) {
+		return
+	}
+
 	log.Printf("--------Start of iteration %v---------\n", iteration)
 
 	cs.iteration = iteration
 	cs.allAgentsDead = false
 
 	cs.turn = 0
+	cs.liftRestrictions()
+	cs.recordEvent(gameRecorder.EventIterationStarted, gameRecorder.IterationStarted{Iteration: iteration})
 
 	// record data
 	// cs.DataRecorder.RecordNewIteration()
@@ -468,206 +170,89 @@ func (cs *EnvironmentServer) RunStartOfIteration(iteration int) {
 	// reset all agents (make sure their score starts at 0)
 	cs.ResetAgents()
 
-	// start team forming
-	cs.StartAgentTeamForming()
+	// start team forming, and wait for all the invitations to be handled
+	cs.runProtocolRound("Team forming", cs.getTeamFormingTimeout(), cs.StartAgentTeamForming)
 
-	time.Sleep(2 * time.Second)
 	// take votes at team level and allocate Strategy.
 	cs.allocateAoAs()
 
 	// Perform any functionality needed by AoA at start of iteration.
 }
 
-func runCopelandVote(team *common.Team, cs *EnvironmentServer) []int {
-
-	pairwiseWins := make(map[string]int)
-	copelandScores := make(map[byte]float64)
-
-	log.Printf("Starting Copeland Vote for Team %s with %d members.\n", team.TeamID, len(team.Agents))
-	// Loop through each agent in the team
-
-	for _, agent := range team.Agents {
-
-		agentAoARanking := cs.GetAgentMap()[agent].GetAoARanking()
-
-		log.Printf("Agent %s has the following AoA rankings:\n", agent)
-		log.Println(agentAoARanking)
-
-		// Loop through each pair of ranked candidates and perform pairwise comparison
-		for i := 0; i < len(agentAoARanking); i++ {
-			for j := i + 1; j < len(agentAoARanking); j++ {
-				if agentAoARanking[i] < agentAoARanking[j] {
-
-					pair := []int{agentAoARanking[i], agentAoARanking[j]}
-
-					pairKey := fmt.Sprintf("%d-%d", pair[0], pair[1])
-
-					log.Printf("Agent %s: Comparing candidates %d and %d. Winner: %d\n", agent, pair[0], pair[1], pair[0])
-
-					pairwiseWins[pairKey]++
-				} else {
-
-					pair := []int{agentAoARanking[j], agentAoARanking[i]}
-
-					pairKey := fmt.Sprintf("%d-%d", pair[0], pair[1])
-
-					log.Printf("Agent %s: Comparing candidates %d and %d. Winner: %d\n", agent, pair[1], pair[0], pair[1])
-
-					pairwiseWins[pairKey] -= 1
-				}
-
-			}
-		}
-	}
-
-	log.Println(pairwiseWins)
-	for pair, score := range pairwiseWins {
-		// Subtract ASCII value of 0
-		candidate1 := pair[0] - 48
-		candidate2 := pair[2] - 48
-
-		log.Printf("Processing pair %s (candidate 1: %d, candidate 2: %d), score: %d\n", pair, candidate1, candidate2, score)
-
-		if score > 0 {
-			copelandScores[candidate1] += 1
-			log.Printf("Candidate %d wins, Copeland score updated: %v\n", candidate1, copelandScores[candidate1])
-
-		} else if score < 0 {
-			copelandScores[candidate2] += 1
-			log.Printf("Candidate %d wins, Copeland score updated: %v\n", candidate2, copelandScores[candidate2])
-		} else {
-			copelandScores[candidate1] += 0.5
-			copelandScores[candidate2] += 0.5
-			log.Printf("It's a tie! Copeland scores updated: %v, %v\n", copelandScores[candidate1], copelandScores[candidate2])
-
-		}
-	}
-	log.Println(copelandScores)
-
-	var maxScore float64
-	var maxCandidates []int
-	for key, score := range copelandScores {
-		candidate := int(key)
-		if score > maxScore {
-			maxScore = score
-			maxCandidates = []int{candidate}
-		} else if score == maxScore {
-			maxCandidates = append(maxCandidates, candidate)
-		}
+/*
+* Get an agent's AoA ranking, checked against the AoA registry. A ballot that
+* ranks an AoA which does not exist is spoilt, and is treated as empty.
+ */
+func (cs *EnvironmentServer) getAoABallot(agentID uuid.UUID) []int {
+	ranking := cs.GetAgentMap()[agentID].GetAoARanking()
+	if err := common.ValidateAoARanking(ranking); err != nil {
+		log.Printf("[WARNING] Ignoring AoA ballot of agent %v: %v\n", agentID, err)
+		return nil
 	}
-
-	log.Printf("\nWinning candidates for Team %s: %v\n", team.TeamID, maxCandidates)
-
-	return maxCandidates
+	return ranking
 }
 
-// Aggregates scores for candidates returns all candidates who have the highest score
-func runBordaVote(team *common.Team, aoaCandidates []int, cs *EnvironmentServer) []int {
-
-	aoaCandidatesSet := make(map[int]struct{})
-	for _, candidate := range aoaCandidates {
-		aoaCandidatesSet[candidate] = struct{}{}
-	}
-
-	voteSum := make(map[int]int) // key = AoA candidate, value = total votes
-	n := len(aoaCandidates)
-	for _, agent := range team.Agents {
-
-		agentRanking := cs.GetAgentMap()[agent].GetAoARanking()
-		log.Printf("Agent %s has the following AoA rankings:\n", agent)
-		log.Println((agentRanking))
-
-		// Check if the current AoA is a candidate
-		for vote, aoa := range agentRanking {
-			if _, exists := aoaCandidatesSet[aoa]; exists {
-				points := n - vote - 1
-				voteSum[aoa] += points
-				log.Printf("Agent %s votes for AoA %d with %d point\n", agent, aoa, points)
-			}
-		}
-	}
-
-	log.Println("\nCandidates scores:")
-	log.Println(voteSum)
-	var filtered []int
-
-	if len(voteSum) == 1 {
-		return filtered
+/*
+* Every member ranks the AoAs, and the Copeland winner among those ranked is
+* chosen. Ties are settled by a Borda count between the tied AoAs, and any
+* that remain at random. Returns false if nobody ranked an AoA.
+ */
+func (cs *EnvironmentServer) runAoAVote(team *common.Team) (int, bool) {
+	log.Printf("Starting AoA vote for Team %s with %d members.\n", team.TeamID, len(team.Agents))
+	candidates := []int{}
+	ballots := []voting.Ranking[int]{}
+	for _, agentID := range team.Agents {
+		ranking := cs.getAoABallot(agentID)
+		log.Printf("Agent %s has the following AoA rankings: %v\n", agentID, ranking)
+		candidates = append(candidates, ranking...)
+		ballots = append(ballots, ranking)
 	}
+	slices.Sort(candidates)
+	candidates = slices.Compact(candidates)
 
-	// Initialize maxVotes to the first candidate's score
-	maxVotes := voteSum[aoaCandidates[0]]
-
-	// Find the max score and filter candidates with the max score
-	for candidate, score := range voteSum {
-		if score > maxVotes {
-			maxVotes = score
-			// Reset filtered list with the new max score
-			filtered = []int{candidate}
-		} else if score == maxVotes {
-			filtered = append(filtered, candidate)
-		}
-
-		log.Printf("Processing candidate %d with score %d\n", candidate, score)
+	copeland := voting.Copeland(candidates, ballots)
+	log.Printf("Copeland scores for Team %s: %v\n", team.TeamID, copeland.Scores)
+	winners := copeland.Winners
+	if len(winners) > 1 {
+		log.Println("Multiple winners detected. Running Borda Vote.")
+		borda := voting.Borda(winners, ballots)
+		log.Printf("Borda scores for Team %s: %v\n", team.TeamID, borda.Scores)
+		winners = borda.Winners
 	}
-
-	// Remove candidates below a threshold (check if there are ties)
-	log.Println("\nFiltered candidates after tie removal:")
-	log.Println(filtered)
-
-	return filtered
+	return voting.Choose(voting.AtRandom(cs.getRand()), winners)
 }
 
 func (cs *EnvironmentServer) allocateAoAs() {
-	for _, team := range cs.Teams {
-		winners := runCopelandVote(team, cs)
-		if len(winners) > 1 {
-			log.Println("Multiple winners detected. Running Borda Vote.")
-			winners = runBordaVote(team, winners, cs)
-		}
-		// Select random AoA if still tied, else select 'winner'
-		if len(winners) > 0 {
-
-			// Create a random number generator with a seed based on current time
-			r := rand.New(rand.NewSource(time.Now().UnixNano()))
-			// Generate random index
-			randomI := r.Intn(len(winners))
-			preference := winners[randomI]
-
-			// Update the team's strategy
-			switch preference {
-			case 1:
-				team.TeamAoA = common.CreateTeam1AoA(team, 5)
-				team.TeamAoAID = 1
-			case 2:
-				team.TeamAoA = common.CreateTeam2AoA(team, uuid.Nil, 5)
-				team.TeamAoAID = 2
-				cs.ElectNewLeader(team.TeamID)
-			case 3:
-				team.TeamAoA = common.CreateTeam3AoA()
-				team.TeamAoAID = 3
-			case 4:
-				team.TeamAoA = common.CreateTeam4AoA(team)
-				team.TeamAoAID = 4
-			case 5:
-				team.TeamAoA = common.CreateTeam5AoA()
-				team.TeamAoAID = 5
-			case 6:
-				team.TeamAoA = common.CreateTeam6AoA()
-				team.TeamAoAID = 6
-			default:
-				team.TeamAoA = common.CreateFixedAoA(1)
-				team.TeamAoAID = 0
+	for _, teamID := range common.SortedIDs(cs.Teams) {
+		team := cs.Teams[teamID]
+		if preference, voted := cs.runAoAVote(team); voted {
+			// Update the team's strategy. Ballots are validated against the
+			// registry, so the winner always exists.
+			entry, exists := common.GetAoAEntry(preference)
+			if !exists {
+				log.Printf("[WARNING] AoA %v is not registered, using the fixed AoA\n", preference)
+				entry, _ = common.GetAoAEntry(common.FixedAoAID)
 			}
+			team.TeamAoA = entry.New(team)
+			team.TeamAoAID = entry.ID
+			cs.applyAuditNoise(team)
 
 			cs.Teams[team.TeamID] = team
-			log.Printf("Team %v has AoA: %v\n", team.TeamID, winners[randomI])
+			if entry.PostCreate != nil {
+				entry.PostCreate(cs, team)
+			}
+			log.Printf("Team %v has AoA: %v (%s)\n", team.TeamID, entry.ID, entry.Name)
+			cs.recordEvent(gameRecorder.EventAoASelected, gameRecorder.AoASelected{TeamID: team.TeamID, AoAID: entry.ID, Name: entry.Name})
 
 		}
 	}
 }
 
-func (cs *EnvironmentServer) RunEndOfIteration(int) {
+func (cs *EnvironmentServer) RunEndOfIteration(iteration int) {
+	if cs.alreadyPlayed(iteration, cs.GetTurns()) {
+		return
+	}
+
 	for _, team := range cs.Teams {
 		team.SetCommonPool(0)
 	}
@@ -684,6 +269,7 @@ func (cs *EnvironmentServer) Init(turnsForThreshold int, exposeThresholds bool)
 	cs.DataRecorder = gameRecorder.CreateRecorder()
 	cs.thresholdTurns = turnsForThreshold
 	cs.exposeThresholds = exposeThresholds
+	cs.rng = common.NewRandStream("server")
 }
 
 func (cs *EnvironmentServer) reviveDeadAgents() {
@@ -691,6 +277,7 @@ func (cs *EnvironmentServer) reviveDeadAgents() {
 		log.Printf("[server] Agent %v is being revived\n", agent.GetID())
 		agent.SetTrueScore(0) // new agents start with a score of 0
 		cs.AddAgent(agent)    // re-add the agent to the server map
+		cs.recordEvent(gameRecorder.EventRevived, gameRecorder.Revived{AgentID: agent.GetID()})
 	}
 
 	// Clear the slice
@@ -743,17 +330,61 @@ func (cs *EnvironmentServer) LogTeamStatus() {
 func (cs *EnvironmentServer) UpdateAndGetAgentExposedInfo() []common.ExposedAgentInfo {
 	// clear the list
 	cs.agentInfoList = nil
-	for _, agent := range cs.GetAgentMap() {
-		cs.agentInfoList = append(cs.agentInfoList, agent.GetExposedInfo())
+	agentMap := cs.GetAgentMap()
+	for _, agentID := range common.SortedIDs(agentMap) {
+		cs.agentInfoList = append(cs.agentInfoList, agentMap[agentID].GetExposedInfo())
 	}
 	return cs.agentInfoList
 }
 
+// Set how the threshold is chosen and what happens to agents that miss it
+func (cs *EnvironmentServer) SetThresholdRules(rules ThresholdRules) {
+	cs.thresholdRules = &rules
+}
+
+// Get the threshold rules, falling back to the defaults for servers that were
+// never given any (e.g. in tests)
+func (cs *EnvironmentServer) getThresholdRules() ThresholdRules {
+	if cs.thresholdRules == nil {
+		rules := DefaultThresholdRules()
+		cs.thresholdRules = &rules
+	}
+	return *cs.thresholdRules
+}
+
 // create a new round score threshold
 func (cs *EnvironmentServer) createNewRoundScoreThreshold() {
-	// NEW: increase threshold dynamically through the game
-	cs.roundScoreThreshold = rand.Intn(10) + cs.turn
-	log.Printf("[server] New round score threshold: %v\n", cs.roundScoreThreshold)
+	policy := cs.getThresholdRules().Policy
+	cs.roundScoreThreshold = policy.NextThreshold(cs.thresholdContext())
+	log.Printf("[server] New round score threshold: %v (%v)\n", cs.roundScoreThreshold, policy)
+	cs.recordEvent(gameRecorder.EventThresholdSet, gameRecorder.ThresholdSet{Threshold: cs.roundScoreThreshold, Policy: policy.String()})
+}
+
+func (cs *EnvironmentServer) thresholdContext() ThresholdContext {
+	ctx := ThresholdContext{
+		Iteration: cs.iteration,
+		Turn:      cs.turn,
+		Previous:  cs.roundScoreThreshold,
+		Rand:      cs.getRand(),
+	}
+	totalScore := 0
+	for agentID, agent := range cs.GetAgentMap() {
+		if !cs.IsAgentDead(agentID) {
+			ctx.AliveAgents++
+			totalScore += agent.GetTrueScore()
+		}
+	}
+	if ctx.AliveAgents > 0 {
+		ctx.MeanScore = float64(totalScore) / float64(ctx.AliveAgents)
+	}
+	totalPool := 0
+	for _, team := range cs.Teams {
+		totalPool += team.GetCommonPool()
+	}
+	if len(cs.Teams) > 0 {
+		ctx.MeanCommonPool = float64(totalPool) / float64(len(cs.Teams))
+	}
+	return ctx
 }
 
 // check agent score
@@ -761,6 +392,7 @@ func (cs *EnvironmentServer) killAgentBelowThreshold(agentID uuid.UUID) int {
 	agent := cs.GetAgentMap()[agentID]
 	score := agent.GetTrueScore()
 	if score < cs.roundScoreThreshold {
+		cs.recordEvent(gameRecorder.EventDeath, gameRecorder.Death{AgentID: agentID, TeamID: agent.GetTeamID(), Score: score})
 		agent.SetTrueScore(0)
 		cs.killAgent(agentID)
 	}
@@ -842,7 +474,9 @@ func (cs *EnvironmentServer) StartAgentTeamForming() {
 	log.Printf("------------- [server] Starting team formation -------------\n\n")
 
 	// Launch team formation for each agent
-	for _, agent := range cs.GetAgentMap() {
+	agentMap := cs.GetAgentMap()
+	for _, agentID := range common.SortedIDs(agentMap) {
+		agent := agentMap[agentID]
 		agent.StartTeamForming(agent, agentInfo)
 	}
 
@@ -872,6 +506,7 @@ func (cs *EnvironmentServer) AddAgentToTeam(agentID uuid.UUID, teamID uuid.UUID)
 	}
 
 	team.Agents = append(team.Agents, agentID)
+	cs.recordEvent(gameRecorder.EventTeamJoined, gameRecorder.TeamJoined{TeamID: teamID, AgentID: agentID})
 }
 
 func (cs *EnvironmentServer) GetAgentsInTeam(teamID uuid.UUID) []uuid.UUID {
@@ -914,7 +549,9 @@ func (cs *EnvironmentServer) CreateAndInitTeamWithAgents(agentIDs []uuid.UUID) u
 	// Protect map write with mutex
 	cs.teamsMutex.Lock()
 	cs.Teams[teamID] = common.NewTeam(teamID)
+	cs.applyAuditNoise(cs.Teams[teamID])
 	cs.teamsMutex.Unlock()
+	cs.recordEvent(gameRecorder.EventTeamCreated, gameRecorder.TeamCreated{TeamID: teamID})
 
 	// Update each agent's team ID
 	for _, agentID := range agentIDs {
@@ -928,25 +565,26 @@ func (cs *EnvironmentServer) CreateAndInitTeamWithAgents(agentIDs []uuid.UUID) u
 	return teamID
 }
 
-// agent get team
-func (cs *EnvironmentServer) GetTeam(agentID uuid.UUID) *common.Team {
+// agent get team, as a read-only snapshot (the zero view if the agent has no team)
+func (cs *EnvironmentServer) GetTeam(agentID uuid.UUID) common.TeamView {
 	// cs.teamsMutex.RLock()
 	// defer cs.teamsMutex.RUnlock()
-	return cs.Teams[cs.GetAgentMap()[agentID].GetTeamID()]
+	agent := cs.GetAgentMap()[agentID]
+	if agent == nil {
+		return common.TeamView{}
+	}
+	return cs.Teams[agent.GetTeamID()].View()
 }
 
-// Get team from team ID, mostly for testing.
-func (cs *EnvironmentServer) GetTeamFromTeamID(teamID uuid.UUID) *common.Team {
-	return cs.Teams[teamID]
+// Get a read-only snapshot of a team from its ID. The server itself changes
+// teams through cs.Teams.
+func (cs *EnvironmentServer) GetTeamFromTeamID(teamID uuid.UUID) common.TeamView {
+	return cs.Teams[teamID].View()
 }
 
 // To be used by agents to find out what teams they want to join in the next round (if they are orphaned).
 func (cs *EnvironmentServer) GetTeamIDs() []uuid.UUID {
-	teamIDs := make([]uuid.UUID, 0, len(cs.Teams))
-	for teamID := range cs.Teams {
-		teamIDs = append(teamIDs, teamID)
-	}
-	return teamIDs
+	return common.SortedIDs(cs.Teams)
 }
 
 // Can be used to find the amount in the common pool for a team. If this is used,
@@ -967,15 +605,44 @@ func (cs *EnvironmentServer) ResetAgents() {
 
 func (cs *EnvironmentServer) ApplyThreshold() {
 	cs.thresholdAppliedInTurn = true
+	cs.recordEvent(gameRecorder.EventThresholdApplied, gameRecorder.ThresholdApplied{Threshold: cs.roundScoreThreshold})
 
-	for _, agent := range cs.GetAgentMap() {
-		cs.killAgentBelowThreshold(agent.GetID())
+	rules := cs.getThresholdRules()
+
+	missed := make(map[uuid.UUID]bool)
+	agentMap := cs.GetAgentMap()
+	for _, agentID := range common.SortedIDs(agentMap) {
+		agent := agentMap[agentID]
+		score := agent.GetTrueScore()
+		if score >= cs.roundScoreThreshold {
+			continue
+		}
+		missed[agentID] = true
+		cs.recordEvent(gameRecorder.EventThresholdMissed, gameRecorder.ThresholdMissed{AgentID: agentID, TeamID: agent.GetTeamID(), Score: score, Action: string(rules.Action)})
+
+		switch rules.Action {
+		case ThresholdFine:
+			agent.SetTrueScore(score - cs.roundScoreThreshold)
+		case ThresholdOrphan:
+			if agent.HasTeam() {
+				cs.recordEvent(gameRecorder.EventKicked, gameRecorder.Kicked{TeamID: agent.GetTeamID(), AgentID: agentID, Reason: "missed threshold"})
+				cs.RemoveAgentFromTeam(agentID)
+			}
+		default:
+			cs.killAgentBelowThreshold(agentID)
+		}
 	}
 
-	// after checking threshold, minus threshold score from each agent
-	for _, agent := range cs.GetAgentMap() {
-		// minus threshold score from each agent
-		agent.SetTrueScore(agent.GetTrueScore() - cs.roundScoreThreshold)
+	// after checking threshold, minus threshold score from every agent that met it
+	if rules.Deduct {
+		agentMap = cs.GetAgentMap()
+		for _, agentID := range common.SortedIDs(agentMap) {
+			if !missed[agentID] {
+				agent := agentMap[agentID]
+				agent.SetTrueScore(agent.GetTrueScore() - cs.roundScoreThreshold)
+				cs.recordEvent(gameRecorder.EventThresholdPaid, gameRecorder.ThresholdPaid{AgentID: agentID, TeamID: agent.GetTeamID(), Amount: cs.roundScoreThreshold, Score: agent.GetTrueScore()})
+			}
+		}
 	}
 
 	cs.createNewRoundScoreThreshold() // create new threshold for the next round
@@ -984,7 +651,9 @@ func (cs *EnvironmentServer) ApplyThreshold() {
 func (cs *EnvironmentServer) RecordTurnInfo() {
 	// agent information
 	agentRecords := []gameRecorder.AgentRecord{}
-	for _, agent := range cs.GetAgentMap() {
+	agentMap := cs.GetAgentMap()
+	for _, agentID := range common.SortedIDs(agentMap) {
+		agent := agentMap[agentID]
 		// if agent.GetTeamID() == uuid.Nil {
 		// 	// Skip agents that are not in a team
 		// 	continue
@@ -1010,7 +679,8 @@ func (cs *EnvironmentServer) RecordTurnInfo() {
 
 	// team information
 	teamRecords := []gameRecorder.TeamRecord{}
-	for _, team := range cs.Teams {
+	for _, teamID := range common.SortedIDs(cs.Teams) {
+		team := cs.Teams[teamID]
 		newTeamRecord := gameRecorder.NewTeamRecord(team.TeamID)
 		newTeamRecord.TurnNumber = cs.turn
 		newTeamRecord.IterationNumber = cs.iteration
@@ -1019,118 +689,41 @@ func (cs *EnvironmentServer) RecordTurnInfo() {
 	}
 
 	// common information
+	rules := cs.getThresholdRules()
 	newCommonRecord := gameRecorder.NewCommonRecord(cs.turn, cs.iteration, cs.roundScoreThreshold, cs.thresholdAppliedInTurn)
+	newCommonRecord.ThresholdPolicy = rules.Policy.String()
+	newCommonRecord.ThresholdAction = string(rules.Action)
+	newCommonRecord.ThresholdDeducted = rules.Deduct
+	newCommonRecord.AuditPayer = string(cs.getAuditPayer())
 
 	cs.DataRecorder.RecordNewTurn(agentRecords, teamRecords, newCommonRecord)
 }
 
-func (cs *EnvironmentServer) RunTurnTeam5(team *common.Team) {
-	log.Println("\nRunning turn for team ", team.TeamID)
-
-	// Sum of contributions from all agents in the team for this turn
-	agentContributionsTotal := 0
-	for _, agentID := range team.Agents {
-		agent := cs.GetAgentMap()[agentID]
-		if agent.GetTeamID() == uuid.Nil || cs.IsAgentDead(agentID) {
-			continue
-		}
-		agentScore := agent.GetTrueScore()
-		expectedContribution := team.TeamAoA.GetExpectedContribution(agentID, agentScore)
-
-		// Agents make actual contribution
-		agentActualContribution := agent.GetActualContribution(agent)
-
-		// Update audit result
-		team.TeamAoA.SetContributionAuditResult(agentID, agentScore, agentActualContribution, expectedContribution)
-		agent.SetTrueScore(agentScore - agentActualContribution)
-		agentContributionsTotal += agentActualContribution
+// Start the event log with the agents in the game and the rules it is played
+// by, which replays of the log begin from
+func (cs *EnvironmentServer) RecordGameStarted() {
+	started := gameRecorder.GameStarted{
+		Agents:            []gameRecorder.StartingAgent{},
+		ThresholdAction:   string(cs.getThresholdRules().Action),
+		ThresholdDeducted: cs.getThresholdRules().Deduct,
+		AuditPayer:        string(cs.getAuditPayer()),
 	}
-
-	// Update common pool with total contribution from this team
-	team.SetCommonPool(team.GetCommonPool() + agentContributionsTotal)
-
-	// Initiate Contribution Audit vote
-	contributionAuditVotes := []common.Vote{}
-	for _, agentID := range team.Agents {
-		agent := cs.GetAgentMap()[agentID]
-		vote := agent.GetContributionAuditVote()
-		contributionAuditVotes = append(contributionAuditVotes, vote)
-	}
-
-	// Execute Contribution Audit if necessary
-	if agentToAudit := team.TeamAoA.GetVoteResult(contributionAuditVotes); agentToAudit != uuid.Nil {
-		auditCost := team.TeamAoA.GetAuditCost(team.GetCommonPool())
-		if auditCost <= team.GetCommonPool() {
-			// Deduct the audit cost from the common pool
-			team.SetCommonPool(team.GetCommonPool() - auditCost)
-			log.Printf("[server] Audit cost of %v deducted from the common pool. Remaining pool: %v\n", auditCost, team.GetCommonPool())
-
-			// Proceed with the audit
-			auditResult := team.TeamAoA.GetContributionAuditResult(agentToAudit)
-			for _, agentID := range team.Agents {
-				agent := cs.GetAgentMap()[agentID]
-				agent.SetAgentContributionAuditResult(agentToAudit, auditResult)
-			}
-		} else {
-			log.Printf("[server] Not enough resources in the common pool to cover the audit cost. Skipping audit.\n")
-		}
-	}
-
-	// Calculate withdrawal order and allow agents to withdraw
-	remainingResources := team.GetCommonPool()
-	orderedAgents := team.TeamAoA.GetWithdrawalOrder(team.Agents)
-	team.TeamAoA.ResourceAllocation(cs.GetAgentScores(), remainingResources)
-	for _, agentID := range orderedAgents {
-		agent := cs.GetAgentMap()[agentID]
-		if agent.GetTeamID() == uuid.Nil || cs.IsAgentDead(agentID) {
-			continue
-		}
-
-		// Agents make actual withdrawal
-		agentActualWithdrawal := agent.GetActualWithdrawal(agent)
-		currentPool := team.GetCommonPool()
-		if agentActualWithdrawal > currentPool {
-			agentActualWithdrawal = currentPool // Ensure withdrawal does not exceed available pool
-		}
-
-		agentStatedWithdrawal := agent.GetStatedWithdrawal(agent)
-		agentScore := agent.GetTrueScore()
-
-		// Update audit result for this agent
-		team.TeamAoA.SetWithdrawalAuditResult(agentID, agentScore, agentActualWithdrawal, agentStatedWithdrawal, currentPool)
-
-		// Update agent score and common pool
-		agent.SetTrueScore(agentScore + agentActualWithdrawal)
-		team.SetCommonPool(currentPool - agentActualWithdrawal)
-		log.Printf("[server] Agent %v withdrew %v. Remaining pool: %v\n", agentID, agentActualWithdrawal, team.GetCommonPool())
-	}
-
-	// Initiate Withdrawal Audit vote
-	withdrawalAuditVotes := []common.Vote{}
-	for _, agentID := range team.Agents {
-		agent := cs.GetAgentMap()[agentID]
-		vote := agent.GetWithdrawalAuditVote()
-		withdrawalAuditVotes = append(withdrawalAuditVotes, vote)
+	agentMap := cs.GetAgentMap()
+	for _, agentID := range common.SortedIDs(agentMap) {
+		agent := agentMap[agentID]
+		started.Agents = append(started.Agents, gameRecorder.StartingAgent{
+			AgentID:         agentID,
+			TrueSomasTeamID: agent.GetTrueSomasTeamID(),
+			Score:           agent.GetTrueScore(),
+			TeamID:          agent.GetTeamID(),
+		})
 	}
+	cs.recordEvent(gameRecorder.EventGameStarted, started)
+}
 
-	// Execute Withdrawal Audit if necessary
-	if agentToAudit := team.TeamAoA.GetVoteResult(withdrawalAuditVotes); agentToAudit != uuid.Nil {
-		auditCost := team.TeamAoA.GetAuditCost(team.GetCommonPool())
-		if auditCost <= team.GetCommonPool() {
-			// Deduct the audit cost from the common pool
-			team.SetCommonPool(team.GetCommonPool() - auditCost)
-			log.Printf("[server] Withdrawal audit cost of %v deducted from the common pool. Remaining pool: %v\n", auditCost, team.GetCommonPool())
-
-			// Proceed with the audit
-			auditResult := team.TeamAoA.GetWithdrawalAuditResult(agentToAudit)
-			for _, agentID := range team.Agents {
-				agent := cs.GetAgentMap()[agentID]
-				agent.SetAgentWithdrawalAuditResult(agentToAudit, auditResult)
-			}
-		} else {
-			log.Printf("[server] Not enough resources in the common pool to cover the audit cost. Skipping withdrawal audit.\n")
-		}
-	}
+// Add an event to the game's event log, see gameRecorder/EventLog.go
+func (cs *EnvironmentServer) recordEvent(eventType gameRecorder.EventType, data any) {
+	cs.DataRecorder.RecordEvent(cs.iteration, cs.turn, eventType, data)
 }
 
 // GetAgentScores returns the current scores of all agents in the server
@@ -1151,8 +744,8 @@ func (cs *EnvironmentServer) RemoveAgentFromTeam(agentID uuid.UUID) {
 		return
 	}
 
-	// GetTeam() is a misleading name, but this gets the team the agent is in, as well as the agent itself
-	team, agent := cs.GetTeam(agentID), cs.GetAgentMap()[agentID]
+	agent := cs.GetAgentMap()[agentID]
+	team := cs.Teams[agent.GetTeamID()]
 
 	// Set the current agent's team ID back to the default after it has been used to get the team structure
 	agent.SetTeamID(uuid.UUID{})
@@ -1168,41 +761,19 @@ func (cs *EnvironmentServer) RemoveAgentFromTeam(agentID uuid.UUID) {
 
 // Ask all the agents if they want to leave the team they are in or not. Ignore dead agents
 func (cs *EnvironmentServer) ProcessAgentsLeaving() {
-	for agentID, agent := range cs.GetAgentMap() {
-		if !cs.IsAgentDead(agentID) && agent.GetLeaveOpinion(agentID) {
+	agentMap := cs.GetAgentMap()
+	for _, agentID := range common.SortedIDs(agentMap) {
+		if !cs.IsAgentDead(agentID) && agentMap[agentID].GetLeaveOpinion(agentID) {
+			cs.recordEvent(gameRecorder.EventKicked, gameRecorder.Kicked{TeamID: agentMap[agentID].GetTeamID(), AgentID: agentID, Reason: "left"})
 			cs.RemoveAgentFromTeam(agentID)
 		}
 	}
 }
 
-func (cs *EnvironmentServer) ApplyPunishment(team *common.Team, agentToAudit uuid.UUID) {
-	agent := cs.GetAgentMap()[agentToAudit]
-
-	if agent == nil {
-		return
-	}
-
-	if agent.HasTeam() {
-		agentScore := agent.GetTrueScore()
-		punishmentResult := team.TeamAoA.GetPunishment(agentScore, agentToAudit)
-		log.Printf("Punishment Result for Agent %v: %d (Agent Score: %d)\n", agent.GetID(), punishmentResult, agentScore)
-
-		newScore := agentScore - punishmentResult
-		agent.SetTrueScore(newScore)
-		log.Printf("Updated Score for Agent %v: %d\n", agent.GetID(), agent.GetTrueScore())
-
-		currentPool := team.GetCommonPool()
-		log.Printf("Current Common Pool: %d\n", currentPool)
-
-		team.SetCommonPool(currentPool + punishmentResult)
-		updatedPool := team.GetCommonPool()
-		log.Printf("Updated Common Pool: %d\n", updatedPool)
-	}
-}
-
 func (cs *EnvironmentServer) GetTeamsByAoA(aoa int) []common.Team {
 	teams := make([]common.Team, 0)
-	for _, team := range cs.Teams {
+	for _, teamID := range common.SortedIDs(cs.Teams) {
+		team := cs.Teams[teamID]
 		if team.TeamAoAID == aoa {
 			teams = append(teams, *team)
 		}
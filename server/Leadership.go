@@ -0,0 +1,191 @@
+package environmentServer
+
+import (
+	"log"
+	"slices"
+
+	"github.com/google/uuid"
+
+	common "github.com/ADimoska/SOMASExtended/common"
+	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+)
+
+/*
+* Elections for teams whose AoA has a leader (common.ILeaderAoA), run the same
+* way whatever the AoA. The leadership phase at the start of each turn replaces
+* a leader who has died or left the team, whose term is up, or whom the team
+* votes to recall, and the sanctions phase replaces a leader impeached for
+* failing an audit. AoAs can also call an election themselves through
+* ElectNewLeader, and give the leadership back through ReinstateLeader if the
+* reason they called it is overturned on appeal.
+ */
+
+// Hold an election for the team's leader, in which anyone may stand
+func (cs *EnvironmentServer) ElectNewLeader(teamId uuid.UUID) {
+	team := cs.Teams[teamId]
+	if team == nil {
+		log.Printf("[WARNING] Cannot elect a leader for team %v, which does not exist\n", teamId)
+		return
+	}
+	cs.electLeader(team, "called by the AoA", uuid.Nil)
+}
+
+// Give the team's leadership back to a leader the AoA replaced, who starts a
+// new term
+func (cs *EnvironmentServer) ReinstateLeader(teamId uuid.UUID, leaderId uuid.UUID) {
+	team := cs.Teams[teamId]
+	if team == nil {
+		log.Printf("[WARNING] Cannot reinstate a leader for team %v, which does not exist\n", teamId)
+		return
+	}
+	aoa, ok := team.TeamAoA.(common.ILeaderAoA)
+	if !ok || aoa.GetLeader() == leaderId {
+		return
+	}
+	previous := aoa.GetLeader()
+	aoa.SetLeader(leaderId)
+	if cs.leaderTerms == nil {
+		cs.leaderTerms = make(map[uuid.UUID]int)
+	}
+	cs.leaderTerms[team.TeamID] = 0
+	log.Printf("[server] Team %v reinstated %v as leader\n", team.TeamID, leaderId)
+	cs.recordEvent(gameRecorder.EventLeaderElected, gameRecorder.LeaderElected{
+		TeamID:     team.TeamID,
+		LeaderID:   leaderId,
+		PreviousID: previous,
+		Method:     string(aoa.GetLeadershipRules().Method),
+		Reason:     "reinstated",
+	})
+}
+
+/*
+* Ask every living member for a ballot and make the winner leader. Members
+* barred from leading, and the excluded agent if there is one, cannot stand.
+* If nobody votes for an eligible candidate, the leader is picked at random
+* from them instead.
+ */
+func (cs *EnvironmentServer) electLeader(team *common.Team, reason string, excluded uuid.UUID) {
+	aoa, ok := team.TeamAoA.(common.ILeaderAoA)
+	if !ok {
+		log.Printf("[WARNING] Team %v cannot elect a leader, as its AoA (%T) does not have one\n", team.TeamID, team.TeamAoA)
+		return
+	}
+	agentsInTeam := cs.GetAgentsInTeam(team.TeamID)
+	if len(agentsInTeam) <= 0 {
+		log.Printf("Team %v has no agents to elect a leader from\n", team.TeamID)
+		return
+	}
+
+	candidates := []uuid.UUID{}
+	for _, agentId := range agentsInTeam {
+		if agentId != excluded && !cs.IsAgentDead(agentId) && !cs.IsRestricted(agentId, common.RoleLeader) {
+			candidates = append(candidates, agentId)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = agentsInTeam
+	}
+
+	ballots := [][]uuid.UUID{}
+	for _, agentId := range agentsInTeam {
+		agent := cs.GetAgentMap()[agentId]
+		if agent == nil || cs.IsAgentDead(agentId) {
+			continue
+		}
+		ballot := agent.GetLeaderBallot(slices.Clone(candidates))
+		ballots = append(ballots, ballot)
+		firstChoice, isVote := uuid.Nil, 0
+		if len(ballot) > 0 {
+			firstChoice, isVote = ballot[0], 1
+		}
+		cs.recordEvent(gameRecorder.EventVoteCast, gameRecorder.VoteCast{TeamID: team.TeamID, Ballot: "leader", VoterID: agentId, VotedForID: firstChoice, IsVote: isVote})
+	}
+
+	rules := aoa.GetLeadershipRules()
+	leader := common.CountLeaderBallots(rules.Method, candidates, ballots, cs.getRand())
+	if leader == uuid.Nil {
+		log.Println("No candidate selected!")
+		leader = candidates[cs.getRand().Intn(len(candidates))]
+	}
+
+	previous := aoa.GetLeader()
+	aoa.SetLeader(leader)
+	if cs.leaderTerms == nil {
+		cs.leaderTerms = make(map[uuid.UUID]int)
+	}
+	cs.leaderTerms[team.TeamID] = 0
+	log.Printf("[server] Team %v elected %v as leader (%s, %s)\n", team.TeamID, leader, reason, rules.Method)
+	cs.recordEvent(gameRecorder.EventLeaderElected, gameRecorder.LeaderElected{
+		TeamID:     team.TeamID,
+		LeaderID:   leader,
+		PreviousID: previous,
+		Method:     string(rules.Method),
+		Reason:     reason,
+	})
+}
+
+// Replace the leader if they are gone, their term is up or the team recalls them
+func (cs *EnvironmentServer) leadershipPhase(turn *teamTurn) {
+	team := turn.team
+	aoa, ok := team.TeamAoA.(common.ILeaderAoA)
+	if !ok {
+		return
+	}
+	rules := aoa.GetLeadershipRules()
+	leader := aoa.GetLeader()
+
+	switch {
+	case !cs.isActiveMember(turn, leader):
+		cs.electLeader(team, "succession", uuid.Nil)
+	case rules.TermTurns > 0 && cs.leaderTerms[team.TeamID] >= rules.TermTurns:
+		cs.electLeader(team, "term ended", uuid.Nil)
+	case rules.Recall > 0 && cs.recallVote(turn, leader, rules.Recall):
+		cs.electLeader(team, "recalled", leader)
+	}
+
+	if cs.leaderTerms == nil {
+		cs.leaderTerms = make(map[uuid.UUID]int)
+	}
+	cs.leaderTerms[team.TeamID]++
+}
+
+// Whether the leader is still a living member of the team
+func (cs *EnvironmentServer) isActiveMember(turn *teamTurn, agentID uuid.UUID) bool {
+	return agentID != uuid.Nil && slices.ContainsFunc(cs.activeMembers(turn, turn.team.Agents), func(agent common.IExtendedAgent) bool {
+		return agent.GetID() == agentID
+	})
+}
+
+// Ask the other members whether to recall the leader. Returns true if more
+// than the given share of them want to.
+func (cs *EnvironmentServer) recallVote(turn *teamTurn, leader uuid.UUID, share float64) bool {
+	voters, inFavour := 0, 0
+	for _, agent := range cs.activeMembers(turn, turn.team.Agents) {
+		if agent.GetID() == leader {
+			continue
+		}
+		voters++
+		isVote := 0
+		if agent.GetRecallVote(leader) {
+			inFavour++
+			isVote = 1
+		}
+		cs.recordEvent(gameRecorder.EventVoteCast, gameRecorder.VoteCast{TeamID: turn.team.TeamID, Ballot: "recall", VoterID: agent.GetID(), VotedForID: leader, IsVote: isVote})
+	}
+	return voters > 0 && float64(inFavour) > share*float64(voters)
+}
+
+// Replace a leader found guilty in an audit, if the AoA impeaches for it
+func (cs *EnvironmentServer) impeachLeader(turn *teamTurn) {
+	aoa, ok := turn.team.TeamAoA.(common.ILeaderAoA)
+	if !ok || !aoa.GetLeadershipRules().ImpeachOnAudit {
+		return
+	}
+	leader := aoa.GetLeader()
+	for _, verdict := range turn.verdicts {
+		if verdict.guilty && verdict.agentID == leader {
+			cs.electLeader(turn.team, "impeached after "+verdict.audit+" audit", leader)
+			return
+		}
+	}
+}
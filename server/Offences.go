@@ -0,0 +1,116 @@
+package environmentServer
+
+import (
+	"log"
+
+	"github.com/google/uuid"
+
+	common "github.com/ADimoska/SOMASExtended/common"
+	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+)
+
+/*
+* Every guilty verdict that stands after the appeals phase is entered in the
+* server's offence registry (see common/Offences.go) in the sanctions phase.
+* Unlike the offences AoAs keep for themselves, the registry outlives teams, so
+* it can be looked up when voting on orphans or forming teams. The offence
+* rules decide how much of it each agent gets to see.
+ */
+
+type OffenceVisibility string
+
+const (
+	OffencesPublic   OffenceVisibility = "public"   // every agent sees every offence
+	OffencesTeamOnly OffenceVisibility = "team"     // agents only see offences committed in the team they are in
+	OffencesExpiring OffenceVisibility = "expiring" // every agent sees offences committed in the last ExpireTurns turns
+)
+
+var OffenceVisibilities = []OffenceVisibility{OffencesPublic, OffencesTeamOnly, OffencesExpiring}
+
+type OffenceRules struct {
+	Visibility  OffenceVisibility
+	ExpireTurns int // turns an offence stays visible for under OffencesExpiring
+}
+
+func DefaultOffenceRules() OffenceRules {
+	return OffenceRules{Visibility: OffencesPublic, ExpireTurns: 10}
+}
+
+// Set how much of the offence registry agents see
+func (cs *EnvironmentServer) SetOffenceRules(rules OffenceRules) {
+	cs.offenceRules = &rules
+}
+
+// How much of the offence registry agents see, the default rules for servers
+// that were never told
+func (cs *EnvironmentServer) GetOffenceRules() OffenceRules {
+	if cs.offenceRules == nil {
+		rules := DefaultOffenceRules()
+		cs.offenceRules = &rules
+	}
+	return *cs.offenceRules
+}
+
+// Enter the offence proven by a verdict in the registry
+func (cs *EnvironmentServer) registerOffence(turn *teamTurn, verdict auditVerdict) {
+	offence := common.Offence{
+		AgentID:   verdict.agentID,
+		Iteration: cs.iteration,
+		Turn:      cs.turn,
+		TeamID:    turn.team.TeamID,
+		AoAID:     turn.team.TeamAoAID,
+		Phase:     verdict.phase,
+		Amount:    turn.misstated[verdict.phase][verdict.agentID],
+	}
+	cs.offences = append(cs.offences, offence)
+	total := len(cs.offencesOf(offence.AgentID))
+	log.Printf("[server] Registered a %s offence by Agent %v, %d in total\n", offence.Phase, offence.AgentID, total)
+	cs.recordEvent(gameRecorder.EventOffence, gameRecorder.OffenceRegistered{
+		TeamID:  offence.TeamID,
+		AgentID: offence.AgentID,
+		AoAID:   offence.AoAID,
+		Phase:   string(offence.Phase),
+		Amount:  offence.Amount,
+		Total:   total,
+	})
+}
+
+// Every offence on the agent's record, oldest first
+func (cs *EnvironmentServer) offencesOf(agentID uuid.UUID) []common.Offence {
+	record := []common.Offence{}
+	for _, offence := range cs.offences {
+		if offence.AgentID == agentID {
+			record = append(record, offence)
+		}
+	}
+	return record
+}
+
+// The whole of the agent's record, oldest first. Agents are created with the
+// server's AgentFacing view, which only shows them what the offence rules let
+// them see.
+func (cs *EnvironmentServer) GetOffences(agentID uuid.UUID) []common.Offence {
+	return cs.offencesOf(agentID)
+}
+
+// The offences on the agent's record that the viewer may see, oldest first
+func (cs *EnvironmentServer) OffencesSeenBy(viewerID, agentID uuid.UUID) []common.Offence {
+	rules := cs.GetOffenceRules()
+	visible := []common.Offence{}
+	for _, offence := range cs.offencesOf(agentID) {
+		switch rules.Visibility {
+		case OffencesTeamOnly:
+			viewer := cs.GetAgentMap()[viewerID]
+			if viewer == nil || !viewer.HasTeam() || viewer.GetTeamID() != offence.TeamID {
+				continue
+			}
+		case OffencesExpiring:
+			age := (cs.iteration-offence.Iteration)*cs.GetTurns() + cs.turn - offence.Turn
+			if age >= rules.ExpireTurns {
+				continue
+			}
+		}
+		visible = append(visible, offence)
+	}
+	return visible
+}
@@ -3,6 +3,8 @@ package environmentServer
 import (
 	"log"
 
+	"github.com/ADimoska/SOMASExtended/common"
+	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
 	"github.com/google/uuid"
 )
 
@@ -28,7 +30,7 @@ const MajorityVoteThreshold float32 = 0.7
  */
 func (cs *EnvironmentServer) RequestOrphanEntry(orphanID, teamID uuid.UUID, entryThreshold float32) bool {
 	// Get the team and the current number of team members
-	team := cs.GetTeamFromTeamID(teamID)
+	team := cs.Teams[teamID]
 	agent_map := cs.GetAgentMap()
 
 	num_members := len(team.Agents)
@@ -64,14 +66,13 @@ func (cs *EnvironmentServer) AllocateOrphans() {
 	unallocated := make(OrphanPoolType)
 
 	// for each orphan currently in the pool / shelter
-	for orphanID := range cs.orphanPool {
+	for _, orphanID := range common.SortedIDs(cs.orphanPool) {
 		log.Printf("allocating %v\n", orphanID)
 		var accepted = false
 		var acceptedTeamID = uuid.Nil
 
 		// Check each aoa preference and try to allocate to allocate to team with that AoA
-		agent := agent_map[orphanID]
-		aoaRanking := agent.GetAoARanking()
+		aoaRanking := cs.getAoABallot(orphanID)
 		if len(aoaRanking) != 0 {
 			log.Printf("orphan %v has no team preferences checking AoA ranking\n", orphanID)
 			// In preference order of AoA iterate through the teams and try to allocate
@@ -96,6 +97,7 @@ func (cs *EnvironmentServer) AllocateOrphans() {
 			agent_map[orphanID].SetTeamID(acceptedTeamID) // Update agent's knowledge of its team
 			cs.AddAgentToTeam(orphanID, acceptedTeamID)   // Update team's knowledge of its agents
 			log.Printf("%v accepted by team %v !!\n", orphanID, acceptedTeamID)
+			cs.recordEvent(gameRecorder.EventOrphanAllocated, gameRecorder.OrphanAllocated{AgentID: orphanID, TeamID: acceptedTeamID})
 		} else {
 			unallocated[orphanID] = struct{}{}
 			log.Printf("%v remains in the orphan pool after allocation...\n", orphanID)
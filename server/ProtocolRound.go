@@ -0,0 +1,128 @@
+package environmentServer
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	common "github.com/ADimoska/SOMASExtended/common"
+)
+
+/*
+* A protocol round is a stretch of messaging outside of the normal turn (e.g.
+* team forming) that the server has to wait on before carrying on. The round
+* is over once every expected agent has called SignalMessagingComplete. Agents
+* only do so once their synchronous messages have been handled, so at that
+* point everything they sent has been acted on. If an agent never signals, the
+* server gives up after a timeout and logs a warning rather than hanging.
+ */
+
+// How long to wait for team forming to finish if no timeout has been set
+const DefaultTeamFormingTimeout = 2 * time.Second
+
+type protocolRound struct {
+	name     string
+	mutex    sync.Mutex
+	waiting  map[uuid.UUID]bool // agents that have not signalled yet
+	complete chan struct{}      // closed once waiting is empty
+}
+
+func newProtocolRound(name string, agentIDs []uuid.UUID) *protocolRound {
+	round := &protocolRound{
+		name:     name,
+		waiting:  make(map[uuid.UUID]bool, len(agentIDs)),
+		complete: make(chan struct{}),
+	}
+	for _, agentID := range agentIDs {
+		round.waiting[agentID] = true
+	}
+	if len(round.waiting) == 0 {
+		close(round.complete)
+	}
+	return round
+}
+
+// Record that an agent has finished messaging. Returns false if the agent was
+// not taking part in the round.
+func (round *protocolRound) signal(agentID uuid.UUID) bool {
+	round.mutex.Lock()
+	defer round.mutex.Unlock()
+	if !round.waiting[agentID] {
+		return false
+	}
+	delete(round.waiting, agentID)
+	if len(round.waiting) == 0 {
+		close(round.complete)
+	}
+	return true
+}
+
+func (round *protocolRound) stragglers() []uuid.UUID {
+	round.mutex.Lock()
+	defer round.mutex.Unlock()
+	return common.SortedIDs(round.waiting)
+}
+
+// Run start (which should get every living agent messaging) and block until
+// they have all signalled that they are done, or the timeout has passed.
+// Returns false on timeout.
+func (cs *EnvironmentServer) runProtocolRound(name string, timeout time.Duration, start func()) bool {
+	agentIDs := []uuid.UUID{}
+	for _, agentID := range common.SortedIDs(cs.GetAgentMap()) {
+		if !cs.IsAgentDead(agentID) {
+			agentIDs = append(agentIDs, agentID)
+		}
+	}
+	round := newProtocolRound(name, agentIDs)
+
+	cs.roundMutex.Lock()
+	cs.activeRound = round
+	cs.roundMutex.Unlock()
+	defer func() {
+		cs.roundMutex.Lock()
+		cs.activeRound = nil
+		cs.roundMutex.Unlock()
+	}()
+
+	startTime := time.Now()
+	start()
+
+	select {
+	case <-round.complete:
+		log.Printf("[server] %s complete after %v\n", name, time.Since(startTime))
+		return true
+	case <-time.After(timeout - time.Since(startTime)):
+		stragglers := round.stragglers()
+		log.Printf("[WARNING] %s timed out after %v, %d agent(s) did not signal messaging complete: %v\n", name, timeout, len(stragglers), stragglers)
+		return false
+	}
+}
+
+// Agents call this (through SignalMessagingComplete) when they have finished
+// messaging. During a protocol round it counts towards the round, otherwise
+// it is passed on to the base server's end of turn handling.
+func (cs *EnvironmentServer) AgentStoppedTalking(agentID uuid.UUID) {
+	cs.roundMutex.Lock()
+	round := cs.activeRound
+	cs.roundMutex.Unlock()
+
+	if round != nil && round.signal(agentID) {
+		return
+	}
+	cs.BaseServer.AgentStoppedTalking(agentID)
+}
+
+// Set how long team forming may take before the server carries on without
+// the agents that have not finished
+func (cs *EnvironmentServer) SetTeamFormingTimeout(timeout time.Duration) {
+	cs.teamFormingTimeout = timeout
+}
+
+func (cs *EnvironmentServer) getTeamFormingTimeout() time.Duration {
+	if cs.teamFormingTimeout <= 0 {
+		return DefaultTeamFormingTimeout
+	}
+	return cs.teamFormingTimeout
+}
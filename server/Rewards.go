@@ -0,0 +1,52 @@
+package environmentServer
+
+import (
+	"log"
+
+	common "github.com/ADimoska/SOMASExtended/common"
+	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+)
+
+/*
+* Rewards are declared by the team's AoA (IRewardsAoA) and paid here from the
+* common pool, the same way for every AoA. They are recorded next to the
+* punishments in the event log.
+ */
+
+// Pay the bonuses the AoA declares this turn
+func (cs *EnvironmentServer) rewardsPhase(turn *teamTurn) {
+	rewarder, ok := turn.team.TeamAoA.(common.IRewardsAoA)
+	if !ok {
+		return
+	}
+	for _, reward := range rewarder.GetRewards(turn.team, turn.agentMap) {
+		cs.PayReward(turn.team, reward)
+	}
+}
+
+// Pay a reward to a member of the team from its common pool, or as much of it
+// as the pool can afford. Returns the amount paid.
+func (cs *EnvironmentServer) PayReward(team *common.Team, reward common.Reward) int {
+	agent := cs.GetAgentMap()[reward.AgentID]
+	if agent == nil || agent.GetTeamID() != team.TeamID || cs.IsAgentDead(reward.AgentID) || reward.Amount <= 0 {
+		return 0
+	}
+	amount := min(reward.Amount, team.GetCommonPool())
+	if amount <= 0 {
+		log.Printf("[server] Common pool is empty, Agent %v goes without its %s reward\n", reward.AgentID, reward.Reason)
+		return 0
+	}
+
+	team.SetCommonPool(team.GetCommonPool() - amount)
+	agent.SetTrueScore(agent.GetTrueScore() + amount)
+	log.Printf("[server] Agent %v rewarded %v for %s. Remaining pool: %v\n", reward.AgentID, amount, reward.Reason, team.GetCommonPool())
+	cs.recordEvent(gameRecorder.EventReward, gameRecorder.Reward{
+		TeamID:     team.TeamID,
+		AgentID:    reward.AgentID,
+		Amount:     amount,
+		Reason:     reward.Reason,
+		Score:      agent.GetTrueScore(),
+		CommonPool: team.GetCommonPool(),
+	})
+	return amount
+}
@@ -0,0 +1,118 @@
+package environmentServer
+
+import (
+	"log"
+	"math"
+
+	"github.com/google/uuid"
+
+	common "github.com/ADimoska/SOMASExtended/common"
+	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+)
+
+/*
+* Sanctions are decided by the team's AoA (GetSanction) and carried out here,
+* the same way for every AoA. Role restrictions are kept by the server, which
+* checks them wherever the role comes up: when the dice are rolled, when audit
+* votes are collected, when members withdraw and when a leader is elected.
+* They are all lifted at the start of each iteration.
+ */
+
+// Set the ladder AoAs that use graduated sanctions climb
+func (cs *EnvironmentServer) SetSanctionLadder(ladder common.SanctionLadder) {
+	cs.sanctionLadder = &ladder
+}
+
+// The sanction ladder, the default one for servers that were never given one
+func (cs *EnvironmentServer) GetSanctionLadder() common.SanctionLadder {
+	if cs.sanctionLadder == nil {
+		ladder := common.DefaultSanctionLadder()
+		cs.sanctionLadder = &ladder
+	}
+	return *cs.sanctionLadder
+}
+
+// Carry out a sanction on a member of the team
+func (cs *EnvironmentServer) ApplySanction(team *common.Team, agentID uuid.UUID, sanction common.Sanction, cause string) {
+	agent := cs.GetAgentMap()[agentID]
+	if agent == nil || !agent.HasTeam() {
+		return
+	}
+	log.Printf("[server] Sanction for Agent %v after %s: %v\n", agentID, cause, sanction)
+
+	if sanction.Fine != 0 {
+		agentScore := agent.GetTrueScore()
+		agent.SetTrueScore(agentScore - sanction.Fine)
+		log.Printf("Updated Score for Agent %v: %d\n", agentID, agent.GetTrueScore())
+
+		team.SetCommonPool(team.GetCommonPool() + sanction.Fine)
+		log.Printf("Updated Common Pool: %d\n", team.GetCommonPool())
+		cs.recordEvent(gameRecorder.EventPunishment, gameRecorder.Punishment{TeamID: team.TeamID, AgentID: agentID, Amount: sanction.Fine, Score: agent.GetTrueScore(), CommonPool: team.GetCommonPool()})
+	}
+
+	for _, restriction := range sanction.Restrictions {
+		cs.restrict(agentID, restriction)
+		cs.recordEvent(gameRecorder.EventRestricted, gameRecorder.Restricted{TeamID: team.TeamID, AgentID: agentID, Role: string(restriction.Role), Turns: restriction.Turns, Reason: sanction.Reason})
+	}
+
+	if sanction.Kick {
+		reason := "expelled after " + cause
+		if sanction.Reason != "" {
+			reason += " (" + sanction.Reason + ")"
+		}
+		cs.recordEvent(gameRecorder.EventKicked, gameRecorder.Kicked{TeamID: team.TeamID, AgentID: agentID, Reason: reason})
+		cs.RemoveAgentFromTeam(agentID)
+		log.Printf("%s: Agent %v has been removed from the team\n", cause, agentID)
+	}
+}
+
+// Bar an agent from a role, from now until the restriction runs out
+func (cs *EnvironmentServer) restrict(agentID uuid.UUID, restriction common.RoleRestriction) {
+	if cs.restrictions == nil {
+		cs.restrictions = make(map[uuid.UUID]map[common.Role]int)
+	}
+	if cs.restrictions[agentID] == nil {
+		cs.restrictions[agentID] = make(map[common.Role]int)
+	}
+	lastTurn := math.MaxInt
+	if restriction.Turns > 0 {
+		lastTurn = cs.turn + restriction.Turns
+		log.Printf("[server] Agent %v may not be %s until after turn %v\n", agentID, restriction.Role, lastTurn)
+	} else {
+		log.Printf("[server] Agent %v may not be %s for the rest of the iteration\n", agentID, restriction.Role)
+	}
+	cs.restrictions[agentID][restriction.Role] = max(cs.restrictions[agentID][restriction.Role], lastTurn)
+}
+
+// Whether an agent is currently barred from a role
+func (cs *EnvironmentServer) IsRestricted(agentID uuid.UUID, role common.Role) bool {
+	lastTurn, restricted := cs.restrictions[agentID][role]
+	return restricted && cs.turn <= lastTurn
+}
+
+// The member that rolls for an agent barred from rolling: whoever in the team
+// has the highest score and may roll for themselves
+func (cs *EnvironmentServer) rollDelegate(turn *teamTurn, agentID uuid.UUID) (uuid.UUID, bool) {
+	delegate, bestScore := uuid.Nil, 0
+	for _, member := range cs.activeMembers(turn, turn.team.Agents) {
+		if member.GetID() == agentID || cs.IsRestricted(member.GetID(), common.RoleRoller) {
+			continue
+		}
+		if delegate == uuid.Nil || member.GetTrueScore() > bestScore {
+			delegate, bestScore = member.GetID(), member.GetTrueScore()
+		}
+	}
+	return delegate, delegate != uuid.Nil
+}
+
+// Record what a team's sanction engine changed this turn
+func (cs *EnvironmentServer) recordSanctionChanges(team *common.Team, engine *common.SanctionEngine) {
+	for _, change := range engine.TakeChanges() {
+		log.Printf("[server] Sanctions: Agent %v %s, %v offences (%s)\n", change.AgentID, change.Kind, change.Offences, change.Level)
+		cs.DataRecorder.RecordSanctionChange(gameRecorder.NewSanctionRecord(cs.turn, cs.iteration, team.TeamID, change.AgentID, string(change.Kind), change.Offences, string(change.Level)))
+	}
+}
+
+func (cs *EnvironmentServer) liftRestrictions() {
+	cs.restrictions = nil
+}
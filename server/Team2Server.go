@@ -2,7 +2,6 @@ package environmentServer
 
 import (
 	"log"
-	"math/rand"
 
 	"github.com/ADimoska/SOMASExtended/common"
 	"github.com/google/uuid"
@@ -19,67 +18,11 @@ func (cs *EnvironmentServer) GetAgentKilledScore(agentID uuid.UUID) int {
 }
 
 /*
- * In case the leader is caught cheating, they will be deposed,
- * this calls a vote for the leader among agents on the current team.
- * TODO: convert this to a borda vote maybe? ->
- * Would need ordered preference of votes
+ * For the leader to override what a punished agent is rolling at that point.
+ * The leader is asked before every throw, including the first. Returns every
+ * roll made, in order.
  */
-func (cs *EnvironmentServer) ElectNewLeader(teamId uuid.UUID) {
-	agentsInTeam := cs.GetAgentsInTeam(teamId)
-	if len(agentsInTeam) <= 0 {
-		log.Printf("Team %d has non-positive agent count", teamId)
-		return
-	}
-
-	votes := make(map[uuid.UUID]int)
-	var maxVotes int
-	var candidates []uuid.UUID
-
-	for _, agentId := range agentsInTeam {
-		agent := cs.GetAgentMap()[agentId]
-
-		if cs.IsAgentDead(agentId) {
-			continue
-		}
-
-		// Pending fix on the main branch, this needs to call the function for any general agent
-		leaderVote := agent.Team2_GetLeaderVote()
-		votedFor := leaderVote.VotedForID
-
-		votes[votedFor]++
-		voteCount := votes[votedFor]
-
-		// In case of new maximum, create a new tie-break array
-		if voteCount > maxVotes {
-			maxVotes = voteCount
-			candidates = []uuid.UUID{votedFor}
-		} else if voteCount == maxVotes {
-			candidates = append(candidates, votedFor)
-			// Update the old tie-break array
-		}
-	}
-
-	// Handle tie by selecting randomly
-	var selectedLeader uuid.UUID
-	if len(candidates) > 1 {
-		selectedLeader = candidates[rand.Intn(len(candidates))]
-	} else if len(candidates) == 1 {
-		selectedLeader = candidates[0]
-	}
-
-	if len(candidates) == 0 {
-		log.Println("No candidate selected!")
-		selectedLeader = agentsInTeam[rand.Intn(len(agentsInTeam))]
-	}
-
-	team := cs.Teams[teamId]
-	team.TeamAoA.(*common.Team2AoA).SetLeader(selectedLeader)
-}
-
-/*
- * For the leader to override what a punished agent is rolling at that point
- */
-func (cs *EnvironmentServer) OverrideAgentRolls(agentId uuid.UUID, leaderId uuid.UUID) {
+func (cs *EnvironmentServer) OverrideAgentRolls(agentId uuid.UUID, leaderId uuid.UUID) []int {
 	log.Printf("*****Override Agent Roll\n")
 
 	controlled := cs.GetAgentMap()[agentId]
@@ -87,22 +30,21 @@ func (cs *EnvironmentServer) OverrideAgentRolls(agentId uuid.UUID, leaderId uuid
 
 	if controlled == nil {
 		log.Printf("Controlled agent with ID %v not found", agentId)
-		return
+		return nil
 	}
 
 	if leader == nil {
 		log.Printf("Leader with ID %v not found", leaderId)
 		cs.ElectNewLeader(controlled.GetTeamID())
-		return
+		return nil
 	}
 
-	currentScore, accumulatedScore := controlled.GetTrueScore(), 0
+	rules := cs.GetDiceRules(agentId)
+	rolls := []int{}
+	accumulatedScore := 0
 	prevRoll := -1
-	rounds := 0
-
-	rollingComplete := false
 
-	for !rollingComplete {
+	for rules.MayRollAgain(len(rolls)) {
 		log.Printf("*****Prev Roll: %d\n", prevRoll)
 		log.Printf("*****Accumulated score: %d\n", accumulatedScore)
 		stickDecision := leader.StickOrAgainFor(agentId, accumulatedScore, prevRoll)
@@ -111,33 +53,26 @@ func (cs *EnvironmentServer) OverrideAgentRolls(agentId uuid.UUID, leaderId uuid
 			break
 		}
 
-		if rounds > 1 {
+		if len(rolls) > 1 {
 			log.Printf("%s decided to [CONTINUE ROLLING], previous roll: %v", agentId, prevRoll)
 		}
 
-		currentRoll := generateScore()
+		currentRoll, bust := cs.throwDice(rules, accumulatedScore, prevRoll)
+		rolls = append(rolls, currentRoll)
 		log.Printf("%s rolled: %v this turn\n", agentId, currentRoll)
-		if currentRoll <= prevRoll {
+		if bust {
 			// Gone bust, so reset the accumulated score and break out of the loop
 			accumulatedScore = 0
-			log.Printf("%s **[HAS GONE BUST!]** round: %v, current score: %v\n", agentId, rounds, currentScore)
+			log.Printf("%s **[HAS GONE BUST!]** round: %v, current score: %v\n", agentId, len(rolls), controlled.GetTrueScore())
+			controlled.HandleDiceRoll(common.DiceRoll{Throw: len(rolls), Roll: currentRoll, Bust: true})
 			break
 		}
 
 		accumulatedScore += currentRoll
 		prevRoll = currentRoll
-		rounds++
-	}
-	// In case the agent has gone bust, this does nothing
-	controlled.SetTrueScore(currentScore + accumulatedScore)
-	// Log the updated score
-	log.Printf("%s turn score: %v, total score: %v\n", agentId, accumulatedScore, controlled.GetTrueScore())
-}
-
-func generateScore() int {
-	score := 0
-	for i := 0; i < 3; i++ {
-		score += rand.Intn(6) + 1
+		controlled.HandleDiceRoll(common.DiceRoll{Throw: len(rolls), Roll: currentRoll, TurnScore: accumulatedScore})
 	}
-	return score
+	// In case the agent has gone bust, this adds nothing
+	cs.creditTurnScore(controlled, accumulatedScore)
+	return rolls
 }
@@ -0,0 +1,341 @@
+package environmentServer
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+* A threshold policy decides the score every agent must have when the
+* threshold is next applied, and the threshold rules decide what happens to
+* the agents that fall short. Both are chosen in the scenario and recorded with
+* every turn, so that threshold difficulty can be varied between experiments.
+*
+* Policies are registered by name like AoAs and agent factories, so new ones
+* can be added from an init function with RegisterThresholdPolicy.
+ */
+
+// What a policy may base the next threshold on
+type ThresholdContext struct {
+	Iteration      int
+	Turn           int
+	Previous       int     // the threshold that was last in force, 0 at the start of the game
+	AliveAgents    int     // agents that are not dead
+	MeanScore      float64 // mean score of the living agents
+	MeanCommonPool float64 // mean common pool of the teams
+	Rand           *rand.Rand
+}
+
+type ThresholdPolicy interface {
+	NextThreshold(ctx ThresholdContext) int
+	// Name and parameters of the policy, recorded with every turn
+	String() string
+}
+
+// What happens to an agent whose score is below the threshold
+type ThresholdAction string
+
+const (
+	ThresholdKill   ThresholdAction = "kill"   // the agent dies, as it always has
+	ThresholdFine   ThresholdAction = "fine"   // the agent pays the threshold anyway, taking its score below zero
+	ThresholdOrphan ThresholdAction = "orphan" // the agent is thrown out of its team and keeps its score
+)
+
+var ThresholdActions = []ThresholdAction{ThresholdKill, ThresholdFine, ThresholdOrphan}
+
+type ThresholdRules struct {
+	Policy ThresholdPolicy
+	Action ThresholdAction
+	Deduct bool // whether agents that meet the threshold pay it
+}
+
+// The rules the game has always been played with: a threshold drawn uniformly
+// from [turn, turn+10), agents below it die and everyone else pays it
+func DefaultThresholdRules() ThresholdRules {
+	policy, _ := NewThresholdPolicy("uniform", nil)
+	return ThresholdRules{Policy: policy, Action: ThresholdKill, Deduct: true}
+}
+
+// --------- Registry ---------
+
+// Parameters passed to a policy constructor, e.g. "slope"
+type ThresholdPolicyParams map[string]float64
+
+type ThresholdPolicyEntry struct {
+	Name          string
+	Description   string
+	DefaultParams ThresholdPolicyParams // every parameter the policy accepts
+	Create        func(params ThresholdPolicyParams) (ThresholdPolicy, error)
+}
+
+// The reason a policy's parameters were rejected
+type ThresholdParamError struct {
+	Policy  string
+	Param   string
+	Problem string
+}
+
+func (e *ThresholdParamError) Error() string {
+	return fmt.Sprintf("threshold policy %s: parameter %s: %s", e.Policy, e.Param, e.Problem)
+}
+
+var (
+	thresholdPoliciesMutex sync.RWMutex
+	thresholdPolicies      = make(map[string]ThresholdPolicyEntry)
+)
+
+// Make a policy available by name. Panics if the name is already taken, as
+// this is a programming error that should be caught at start up.
+func RegisterThresholdPolicy(entry ThresholdPolicyEntry) {
+	if entry.Name == "" || entry.Create == nil {
+		panic("server: RegisterThresholdPolicy needs a name and a constructor")
+	}
+
+	thresholdPoliciesMutex.Lock()
+	defer thresholdPoliciesMutex.Unlock()
+	if _, exists := thresholdPolicies[entry.Name]; exists {
+		panic("server: threshold policy registered twice: " + entry.Name)
+	}
+	thresholdPolicies[entry.Name] = entry
+}
+
+func GetThresholdPolicy(name string) (ThresholdPolicyEntry, bool) {
+	thresholdPoliciesMutex.RLock()
+	defer thresholdPoliciesMutex.RUnlock()
+	entry, exists := thresholdPolicies[name]
+	return entry, exists
+}
+
+// Names of all registered policies, in alphabetical order
+func ThresholdPolicyNames() []string {
+	thresholdPoliciesMutex.RLock()
+	defer thresholdPoliciesMutex.RUnlock()
+	names := make([]string, 0, len(thresholdPolicies))
+	for name := range thresholdPolicies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Look up a policy by name and create it, with any parameters that are not
+// given taking their defaults
+func NewThresholdPolicy(name string, params ThresholdPolicyParams) (ThresholdPolicy, error) {
+	entry, exists := GetThresholdPolicy(name)
+	if !exists {
+		return nil, fmt.Errorf("unknown threshold policy %q, expected one of [%s]", name, strings.Join(ThresholdPolicyNames(), ", "))
+	}
+
+	filled := make(ThresholdPolicyParams, len(entry.DefaultParams))
+	for param, value := range entry.DefaultParams {
+		filled[param] = value
+	}
+	for _, param := range sortedParamNames(params) {
+		if _, known := entry.DefaultParams[param]; !known {
+			return nil, &ThresholdParamError{name, param, "not a parameter of this policy"}
+		}
+		filled[param] = params[param]
+	}
+	return entry.Create(filled)
+}
+
+func sortedParamNames(params ThresholdPolicyParams) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// --------- Built-in policies ---------
+
+// The threshold never changes
+type constantThreshold struct {
+	value int
+}
+
+func (p constantThreshold) NextThreshold(ctx ThresholdContext) int {
+	return p.value
+}
+
+func (p constantThreshold) String() string {
+	return fmt.Sprintf("constant(value=%d)", p.value)
+}
+
+// The threshold grows by a fixed amount every turn of the iteration
+type linearThreshold struct {
+	base, slope float64
+}
+
+func (p linearThreshold) NextThreshold(ctx ThresholdContext) int {
+	return roundThreshold(p.base+p.slope*float64(ctx.Turn), 0)
+}
+
+func (p linearThreshold) String() string {
+	return fmt.Sprintf("linear(base=%v, slope=%v)", p.base, p.slope)
+}
+
+// The threshold starts each iteration at start and then moves by up to step in
+// either direction, never going below min
+type randomWalkThreshold struct {
+	start, step, min int
+}
+
+func (p randomWalkThreshold) NextThreshold(ctx ThresholdContext) int {
+	if ctx.Turn == 0 {
+		return max(p.start, p.min)
+	}
+	return max(ctx.Previous+ctx.Rand.Intn(2*p.step+1)-p.step, p.min)
+}
+
+func (p randomWalkThreshold) String() string {
+	return fmt.Sprintf("random-walk(start=%d, step=%d, min=%d)", p.start, p.step, p.min)
+}
+
+// The threshold is drawn uniformly from [low, high), plus slope for every turn
+// of the iteration. The defaults are the original threshold rule.
+type uniformThreshold struct {
+	low, high int
+	slope     float64
+}
+
+func (p uniformThreshold) NextThreshold(ctx ThresholdContext) int {
+	return p.low + ctx.Rand.Intn(p.high-p.low) + roundThreshold(p.slope*float64(ctx.Turn), math.MinInt)
+}
+
+func (p uniformThreshold) String() string {
+	return fmt.Sprintf("uniform(low=%d, high=%d, slope=%v)", p.low, p.high, p.slope)
+}
+
+// The threshold is drawn from a normal distribution, plus slope for every turn
+// of the iteration, never going below min
+type normalThreshold struct {
+	mean, stddev, slope float64
+	min                 int
+}
+
+func (p normalThreshold) NextThreshold(ctx ThresholdContext) int {
+	return roundThreshold(ctx.Rand.NormFloat64()*p.stddev+p.mean+p.slope*float64(ctx.Turn), p.min)
+}
+
+func (p normalThreshold) String() string {
+	return fmt.Sprintf("normal(mean=%v, stddev=%v, slope=%v, min=%d)", p.mean, p.stddev, p.slope, p.min)
+}
+
+// The threshold follows how well off the population is, so it stays a
+// challenge however rich or poor the agents get
+type wealthThreshold struct {
+	base, scoreFraction, poolFraction, perAgent float64
+}
+
+func (p wealthThreshold) NextThreshold(ctx ThresholdContext) int {
+	return roundThreshold(p.base+p.scoreFraction*ctx.MeanScore+p.poolFraction*ctx.MeanCommonPool+p.perAgent*float64(ctx.AliveAgents), 0)
+}
+
+func (p wealthThreshold) String() string {
+	return fmt.Sprintf("wealth(base=%v, scoreFraction=%v, poolFraction=%v, perAgent=%v)", p.base, p.scoreFraction, p.poolFraction, p.perAgent)
+}
+
+func roundThreshold(value float64, min int) int {
+	return max(int(math.Round(value)), min)
+}
+
+// Parameters that must be whole numbers
+func intParam(policy string, params ThresholdPolicyParams, name string) (int, error) {
+	value := params[name]
+	if value != math.Trunc(value) {
+		return 0, &ThresholdParamError{policy, name, fmt.Sprintf("must be a whole number, got %v", value)}
+	}
+	return int(value), nil
+}
+
+func init() {
+	RegisterThresholdPolicy(ThresholdPolicyEntry{
+		Name:          "constant",
+		Description:   "the same threshold every time",
+		DefaultParams: ThresholdPolicyParams{"value": 5},
+		Create: func(params ThresholdPolicyParams) (ThresholdPolicy, error) {
+			value, err := intParam("constant", params, "value")
+			return constantThreshold{value}, err
+		},
+	})
+
+	RegisterThresholdPolicy(ThresholdPolicyEntry{
+		Name:          "linear",
+		Description:   "base + slope * turn",
+		DefaultParams: ThresholdPolicyParams{"base": 0, "slope": 1},
+		Create: func(params ThresholdPolicyParams) (ThresholdPolicy, error) {
+			return linearThreshold{params["base"], params["slope"]}, nil
+		},
+	})
+
+	RegisterThresholdPolicy(ThresholdPolicyEntry{
+		Name:          "random-walk",
+		Description:   "starts at start each iteration, then moves up to step either way",
+		DefaultParams: ThresholdPolicyParams{"start": 5, "step": 3, "min": 0},
+		Create: func(params ThresholdPolicyParams) (ThresholdPolicy, error) {
+			p := randomWalkThreshold{}
+			var err error
+			if p.start, err = intParam("random-walk", params, "start"); err != nil {
+				return nil, err
+			}
+			if p.step, err = intParam("random-walk", params, "step"); err != nil {
+				return nil, err
+			}
+			if p.step < 0 {
+				return nil, &ThresholdParamError{"random-walk", "step", fmt.Sprintf("must not be negative, got %d", p.step)}
+			}
+			if p.min, err = intParam("random-walk", params, "min"); err != nil {
+				return nil, err
+			}
+			return p, nil
+		},
+	})
+
+	RegisterThresholdPolicy(ThresholdPolicyEntry{
+		Name:          "uniform",
+		Description:   "uniform in [low, high) + slope * turn",
+		DefaultParams: ThresholdPolicyParams{"low": 0, "high": 10, "slope": 1},
+		Create: func(params ThresholdPolicyParams) (ThresholdPolicy, error) {
+			p := uniformThreshold{slope: params["slope"]}
+			var err error
+			if p.low, err = intParam("uniform", params, "low"); err != nil {
+				return nil, err
+			}
+			if p.high, err = intParam("uniform", params, "high"); err != nil {
+				return nil, err
+			}
+			if p.high <= p.low {
+				return nil, &ThresholdParamError{"uniform", "high", fmt.Sprintf("must be greater than low (%d), got %d", p.low, p.high)}
+			}
+			return p, nil
+		},
+	})
+
+	RegisterThresholdPolicy(ThresholdPolicyEntry{
+		Name:          "normal",
+		Description:   "normal with the given mean and stddev + slope * turn, at least min",
+		DefaultParams: ThresholdPolicyParams{"mean": 5, "stddev": 2, "slope": 0, "min": 0},
+		Create: func(params ThresholdPolicyParams) (ThresholdPolicy, error) {
+			if params["stddev"] < 0 {
+				return nil, &ThresholdParamError{"normal", "stddev", fmt.Sprintf("must not be negative, got %v", params["stddev"])}
+			}
+			min, err := intParam("normal", params, "min")
+			return normalThreshold{params["mean"], params["stddev"], params["slope"], min}, err
+		},
+	})
+
+	RegisterThresholdPolicy(ThresholdPolicyEntry{
+		Name:          "wealth",
+		Description:   "base + scoreFraction * mean score + poolFraction * mean common pool + perAgent * living agents",
+		DefaultParams: ThresholdPolicyParams{"base": 0, "scoreFraction": 0.5, "poolFraction": 0, "perAgent": 0},
+		Create: func(params ThresholdPolicyParams) (ThresholdPolicy, error) {
+			return wealthThreshold{params["base"], params["scoreFraction"], params["poolFraction"], params["perAgent"]}, nil
+		},
+	})
+}
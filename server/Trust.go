@@ -0,0 +1,67 @@
+package environmentServer
+
+import (
+	"github.com/google/uuid"
+
+	common "github.com/ADimoska/SOMASExtended/common"
+	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+)
+
+/*
+* The server chooses the trust model agents combine opinions with (see
+* common/Trust.go), and at the end of every turn records the opinions every
+* living agent holds as the trust graph, along with how far the model trusts
+* each agent from the whole graph.
+ */
+
+// Set how agents combine opinions into trust
+func (cs *EnvironmentServer) SetTrustModel(model common.ITrustModel) {
+	cs.trustModel = model
+}
+
+// How agents combine opinions into trust, beta reputation for servers that
+// were never told
+func (cs *EnvironmentServer) GetTrustModel() common.ITrustModel {
+	if cs.trustModel == nil {
+		cs.trustModel = common.BetaReputation{}
+	}
+	return cs.trustModel
+}
+
+// Every opinion held by a living agent, by truster
+func (cs *EnvironmentServer) trustGraph() []common.TrustEdge {
+	agentMap := cs.GetAgentMap()
+	edges := []common.TrustEdge{}
+	for _, agentID := range common.SortedIDs(agentMap) {
+		for _, opinion := range agentMap[agentID].GetOpinions() {
+			edges = append(edges, common.TrustEdge{Truster: agentID, Opinion: opinion})
+		}
+	}
+	return edges
+}
+
+func (cs *EnvironmentServer) recordTrustGraph() {
+	edges := cs.trustGraph()
+	graph := gameRecorder.TrustGraph{
+		Opinions:   []gameRecorder.TrustOpinion{},
+		Reputation: []gameRecorder.Reputation{},
+	}
+	for _, edge := range edges {
+		graph.Opinions = append(graph.Opinions, gameRecorder.TrustOpinion{
+			TrusterID:  edge.Truster,
+			SubjectID:  edge.Subject,
+			Trust:      edge.Trust,
+			Confidence: edge.Confidence,
+			Evidence:   string(edge.Evidence),
+		})
+	}
+	model := cs.GetTrustModel()
+	for _, agentID := range common.SortedIDs(cs.GetAgentMap()) {
+		// the server trusts nobody in particular, so every opinion is weighed alike
+		graph.Reputation = append(graph.Reputation, gameRecorder.Reputation{
+			AgentID: agentID,
+			Trust:   model.Trust(uuid.Nil, agentID, edges),
+		})
+	}
+	cs.recordEvent(gameRecorder.EventTrustGraph, graph)
+}
@@ -0,0 +1,362 @@
+package environmentServer
+
+import (
+	"log"
+	"slices"
+
+	"github.com/google/uuid"
+
+	common "github.com/ADimoska/SOMASExtended/common"
+	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+)
+
+/*
+* Every team plays its turn through the same ordered list of phases, whatever
+* AoA it has chosen. The AoA decides what is expected of its members through
+* IArticlesOfAssociation, and can take part in individual phases by
+* implementing the optional interfaces in common/AoAPhases.go. Teams whose AoA
+* has a leader first replace them if need be (see Leadership.go). The server
+* treats every AoA the same way otherwise: audits are paid for as the audit
+* payer rule says (see AuditCost.go), members' reports of cheating are heard
+* after both audits (see Whistleblowing.go), then appeals against guilty
+* verdicts (see Appeals.go), guilty agents are then entered in the offence
+* registry (see Offences.go) and sanctioned as the AoA decides (see
+* Sanctions.go), and any bonuses it declares are paid last (see Rewards.go).
+ */
+
+// State shared between the phases of a single team's turn
+type teamTurn struct {
+	team     *common.Team
+	agentMap map[uuid.UUID]common.IExtendedAgent
+	verdicts []auditVerdict // audits carried out this turn, in order
+	// how far each agent misstated what it contributed or withdrew this turn
+	misstated map[common.AuditPhase]map[uuid.UUID]int
+}
+
+type auditVerdict struct {
+	agentID  uuid.UUID
+	guilty   bool
+	audit    string
+	phase    common.AuditPhase
+	accusers []uuid.UUID     // the agents that called for the audit
+	paid     int             // what the audited agent paid towards the audit
+	rewards  []common.Reward // paid to whistleblowers for calling the audit
+}
+
+// The verdict of the audit of the agent for the given kind this turn, if it
+// has been audited
+func (turn *teamTurn) findVerdict(agentID uuid.UUID, audit string) *auditVerdict {
+	for i := len(turn.verdicts) - 1; i >= 0; i-- {
+		if turn.verdicts[i].agentID == agentID && turn.verdicts[i].audit == audit {
+			return &turn.verdicts[i]
+		}
+	}
+	return nil
+}
+
+type turnPhase struct {
+	name string
+	run  func(cs *EnvironmentServer, turn *teamTurn)
+}
+
+var turnPhases = []turnPhase{
+	{"leadership", (*EnvironmentServer).leadershipPhase},
+	{"roll", (*EnvironmentServer).rollPhase},
+	{"contribute", (*EnvironmentServer).contributePhase},
+	{"post-contribution", (*EnvironmentServer).postContributionPhase},
+	{"contribution audit", (*EnvironmentServer).contributionAuditPhase},
+	{"withdraw", (*EnvironmentServer).withdrawPhase},
+	{"withdrawal audit", (*EnvironmentServer).withdrawalAuditPhase},
+	{"whistleblowing", (*EnvironmentServer).whistleblowingPhase},
+	{"appeals", (*EnvironmentServer).appealsPhase},
+	{"sanctions", (*EnvironmentServer).sanctionsPhase},
+	{"rewards", (*EnvironmentServer).rewardsPhase},
+}
+
+// How to carry out one kind of audit
+type auditKind struct {
+	name      string
+	phase     common.AuditPhase
+	getVote   func(agent common.IExtendedAgent) common.Vote
+	getResult func(aoa common.IArticlesOfAssociation, agentID uuid.UUID) bool
+	broadcast func(agent common.IExtendedAgent, agentID uuid.UUID, result bool)
+}
+
+var contributionAudit = auditKind{
+	name:      "Contribution",
+	phase:     common.ContributionPhase,
+	getVote:   common.IExtendedAgent.GetContributionAuditVote,
+	getResult: common.IArticlesOfAssociation.GetContributionAuditResult,
+	broadcast: common.IExtendedAgent.SetAgentContributionAuditResult,
+}
+
+var withdrawalAudit = auditKind{
+	name:      "Withdrawal",
+	phase:     common.WithdrawalPhase,
+	getVote:   common.IExtendedAgent.GetWithdrawalAuditVote,
+	getResult: common.IArticlesOfAssociation.GetWithdrawalAuditResult,
+	broadcast: common.IExtendedAgent.SetAgentWithdrawalAuditResult,
+}
+
+// The kind of audit run in the given phase
+func auditKindOf(phase common.AuditPhase) (auditKind, bool) {
+	switch phase {
+	case common.ContributionPhase:
+		return contributionAudit, true
+	case common.WithdrawalPhase:
+		return withdrawalAudit, true
+	}
+	return auditKind{}, false
+}
+
+func (cs *EnvironmentServer) runTeamTurn(team *common.Team) {
+	log.Println("\nRunning turn for team ", team.TeamID)
+	turn := &teamTurn{
+		team:      team,
+		agentMap:  cs.GetAgentMap(),
+		misstated: map[common.AuditPhase]map[uuid.UUID]int{common.ContributionPhase: {}, common.WithdrawalPhase: {}},
+	}
+	team.TeamAoA.RunPreIterationAoaLogic(team, turn.agentMap, cs.DataRecorder)
+
+	for _, phase := range turnPhases {
+		phase.run(cs, turn)
+	}
+}
+
+// The agents of the team that are alive and still in it, in team order
+func (cs *EnvironmentServer) activeMembers(turn *teamTurn, order []uuid.UUID) []common.IExtendedAgent {
+	members := []common.IExtendedAgent{}
+	for _, agentID := range order {
+		agent := turn.agentMap[agentID]
+		if agent == nil || agent.GetTeamID() == uuid.Nil || cs.IsAgentDead(agentID) {
+			continue
+		}
+		members = append(members, agent)
+	}
+	return members
+}
+
+func (cs *EnvironmentServer) rollPhase(turn *teamTurn) {
+	rollControl, hasRollControl := turn.team.TeamAoA.(common.IRollControlAoA)
+	for _, agent := range cs.activeMembers(turn, turn.team.Agents) {
+		scoreBefore := agent.GetTrueScore()
+		controller, controlled := uuid.Nil, false
+		if hasRollControl {
+			controller, controlled = rollControl.GetRollController(agent.GetID())
+		}
+		if !controlled && cs.IsRestricted(agent.GetID(), common.RoleRoller) {
+			controller, controlled = cs.rollDelegate(turn, agent.GetID())
+		}
+		var rolls []int
+		if controlled {
+			rolls = cs.OverrideAgentRolls(agent.GetID(), controller)
+		} else {
+			rolls = cs.RollDice(agent)
+		}
+		cs.recordEvent(gameRecorder.EventDiceRolled, gameRecorder.DiceRolled{
+			AgentID:      agent.GetID(),
+			TeamID:       turn.team.TeamID,
+			ControlledBy: controller,
+			Rolls:        rolls,
+			TurnScore:    agent.GetTrueScore() - scoreBefore,
+			Score:        agent.GetTrueScore(),
+		})
+	}
+}
+
+func (cs *EnvironmentServer) contributePhase(turn *teamTurn) {
+	team := turn.team
+	// Sum of contributions from all agents in the team for this turn
+	agentContributionsTotal := 0
+	for _, agent := range cs.activeMembers(turn, team.Agents) {
+		agentActualContribution := agent.GetActualContribution(agent)
+		agentContributionsTotal += agentActualContribution
+		agentStatedContribution := agent.GetStatedContribution(agent)
+
+		agent.StateContributionToTeam(agent)
+		agentScore := agent.GetTrueScore()
+		// Update audit result for this agent
+		team.TeamAoA.SetContributionAuditResult(agent.GetID(), agentScore, agentActualContribution, agentStatedContribution)
+		agent.SetTrueScore(agentScore - agentActualContribution)
+		turn.misstated[common.ContributionPhase][agent.GetID()] = max(agentStatedContribution-agentActualContribution, 0)
+		cs.recordEvent(gameRecorder.EventContribution, gameRecorder.Contribution{
+			AgentID: agent.GetID(),
+			TeamID:  team.TeamID,
+			Actual:  agentActualContribution,
+			Stated:  agentStatedContribution,
+			Score:   agent.GetTrueScore(),
+		})
+	}
+
+	// Update common pool with total contribution from this team
+	// 	Agents do not get to see the common pool before deciding their contribution
+	//  Different to the withdrawal phase!
+	team.SetCommonPool(team.GetCommonPool() + agentContributionsTotal)
+}
+
+func (cs *EnvironmentServer) postContributionPhase(turn *teamTurn) {
+	turn.team.TeamAoA.RunPostContributionAoaLogic(turn.team, turn.agentMap)
+}
+
+func (cs *EnvironmentServer) contributionAuditPhase(turn *teamTurn) {
+	cs.runAudit(turn, contributionAudit)
+}
+
+func (cs *EnvironmentServer) withdrawPhase(turn *teamTurn) {
+	team := turn.team
+	if preWithdrawal, ok := team.TeamAoA.(common.IPreWithdrawalAoA); ok {
+		preWithdrawal.RunPreWithdrawalAoaLogic(team, turn.agentMap)
+	}
+
+	orderedAgents := team.TeamAoA.GetWithdrawalOrder(team.Agents)
+	for _, agent := range cs.activeMembers(turn, orderedAgents) {
+		if cs.IsRestricted(agent.GetID(), common.RoleWithdrawer) {
+			continue
+		}
+		// Pass the current pool value to agent's methods
+		currentPool := team.GetCommonPool()
+		agentActualWithdrawal := agent.GetActualWithdrawal(agent)
+		if agentActualWithdrawal > currentPool {
+			agentActualWithdrawal = currentPool // Ensure withdrawal does not exceed available pool
+		}
+		agentStatedWithdrawal := agent.GetStatedWithdrawal(agent)
+
+		agentScore := agent.GetTrueScore()
+		// Update audit result for this agent
+		team.TeamAoA.SetWithdrawalAuditResult(agent.GetID(), agentScore, agentActualWithdrawal, agentStatedWithdrawal, currentPool)
+		agent.SetTrueScore(agentScore + agentActualWithdrawal)
+		turn.misstated[common.WithdrawalPhase][agent.GetID()] = max(agentActualWithdrawal-agentStatedWithdrawal, 0)
+
+		// Update the common pool after each withdrawal so agents can see the updated pool before deciding their withdrawal.
+		//  Different to the contribution phase!
+		team.SetCommonPool(currentPool - agentActualWithdrawal)
+		log.Printf("[server] Agent %v withdrew %v. Remaining pool: %v\n", agent.GetID(), agentActualWithdrawal, team.GetCommonPool())
+		cs.recordEvent(gameRecorder.EventWithdrawal, gameRecorder.Withdrawal{
+			AgentID:    agent.GetID(),
+			TeamID:     team.TeamID,
+			Actual:     agentActualWithdrawal,
+			Stated:     agentStatedWithdrawal,
+			Score:      agent.GetTrueScore(),
+			CommonPool: team.GetCommonPool(),
+		})
+	}
+
+	stateWithdrawOrder := make([]uuid.UUID, len(team.Agents))
+	copy(stateWithdrawOrder, team.Agents)
+	// Shuffle the order of agents to broadcast withdrawal amounts
+	cs.getRand().Shuffle(len(stateWithdrawOrder), func(i, j int) {
+		stateWithdrawOrder[i], stateWithdrawOrder[j] = stateWithdrawOrder[j], stateWithdrawOrder[i]
+	})
+	for _, agent := range cs.activeMembers(turn, stateWithdrawOrder) {
+		if cs.IsRestricted(agent.GetID(), common.RoleWithdrawer) {
+			continue
+		}
+		agent.StateWithdrawalToTeam(agent)
+	}
+}
+
+func (cs *EnvironmentServer) withdrawalAuditPhase(turn *teamTurn) {
+	cs.runAudit(turn, withdrawalAudit)
+}
+
+// Hold a vote on who to audit and, if those paying for it can afford it, carry
+// out the audit. The verdict is acted on in the sanctions phase.
+func (cs *EnvironmentServer) runAudit(turn *teamTurn, kind auditKind) {
+	team := turn.team
+
+	votes := []common.Vote{}
+	for _, agent := range cs.activeMembers(turn, team.Agents) {
+		if cs.IsRestricted(agent.GetID(), common.RoleAuditVoter) {
+			continue
+		}
+		vote := kind.getVote(agent)
+		votes = append(votes, vote)
+		cs.recordEvent(gameRecorder.EventVoteCast, gameRecorder.VoteCast{
+			TeamID:     team.TeamID,
+			Ballot:     kind.name + " audit",
+			VoterID:    vote.VoterID,
+			VotedForID: vote.VotedForID,
+			IsVote:     vote.IsVote,
+		})
+	}
+
+	agentToAudit := team.TeamAoA.GetVoteResult(votes)
+	if agentToAudit == uuid.Nil {
+		return
+	}
+	cs.conductAudit(turn, kind, votes, agentToAudit)
+}
+
+// Audit the agent, if those paying for it can afford it, and tell the team the
+// verdict. Returns the verdict and whether the audit took place.
+func (cs *EnvironmentServer) conductAudit(turn *teamTurn, kind auditKind, votes []common.Vote, agentToAudit uuid.UUID) (guilty bool, audited bool) {
+	team := turn.team
+	auditCost := team.TeamAoA.GetAuditCost(team.GetCommonPool())
+	bill, affordable := cs.billAudit(turn, votes, agentToAudit, auditCost)
+	if !affordable {
+		log.Printf("[server] Not enough resources to cover the %s audit cost of %v (paid by %s). Skipping audit.\n", kind.name, auditCost, cs.getAuditPayer())
+		cs.recordEvent(gameRecorder.EventAudit, gameRecorder.Audit{TeamID: team.TeamID, Kind: kind.name, AgentID: agentToAudit, Cost: auditCost, Skipped: true, CommonPool: team.GetCommonPool()})
+		return false, false
+	}
+
+	auditResult := kind.getResult(team.TeamAoA, agentToAudit)
+	log.Printf("Agent %v has been audited for %s\n", agentToAudit, kind.name)
+	paid := cs.chargeAudit(turn, kind, bill, agentToAudit, auditResult)
+	cs.recordEvent(gameRecorder.EventAudit, gameRecorder.Audit{TeamID: team.TeamID, Kind: kind.name, AgentID: agentToAudit, Cost: auditCost, Guilty: auditResult, CommonPool: team.GetCommonPool(), Quality: auditQuality(team.TeamAoA)})
+
+	if observer, ok := team.TeamAoA.(common.IAuditObserverAoA); ok {
+		observer.OnAudit(cs, team, turn.agentMap, agentToAudit, auditResult)
+	}
+
+	for _, agent := range cs.activeMembers(turn, team.Agents) {
+		kind.broadcast(agent, agentToAudit, auditResult)
+	}
+	accusers := []uuid.UUID{}
+	for _, vote := range votes {
+		if vote.IsVote == 1 && vote.VotedForID == agentToAudit && !slices.Contains(accusers, vote.VoterID) {
+			accusers = append(accusers, vote.VoterID)
+		}
+	}
+	turn.verdicts = append(turn.verdicts, auditVerdict{agentID: agentToAudit, guilty: auditResult, audit: kind.name, phase: kind.phase, accusers: accusers, paid: paid})
+	return auditResult, true
+}
+
+// The quality of the AoA's audits, if it says
+func auditQuality(aoa common.IArticlesOfAssociation) *gameRecorder.AuditQuality {
+	qualityAoA, ok := aoa.(common.IAuditQualityAoA)
+	if !ok {
+		return nil
+	}
+	quality := qualityAoA.GetAuditQuality()
+	model := quality.Model
+	if model == "" {
+		model = common.DetectEach
+	}
+	return &gameRecorder.AuditQuality{
+		Lookback:      quality.Lookback,
+		Detection:     quality.Detection,
+		FalsePositive: quality.FalsePositive,
+		Model:         string(model),
+	}
+}
+
+// Sanction every agent found guilty this turn, as the AoA decides
+func (cs *EnvironmentServer) sanctionsPhase(turn *teamTurn) {
+	team := turn.team
+	if engineAoA, ok := team.TeamAoA.(common.ISanctionEngineAoA); ok {
+		engine := engineAoA.GetSanctionEngine()
+		engine.Tick()
+		defer cs.recordSanctionChanges(team, engine)
+	}
+
+	for _, verdict := range turn.verdicts {
+		agent := turn.agentMap[verdict.agentID]
+		if !verdict.guilty || agent == nil || agent.GetTeamID() != team.TeamID {
+			continue
+		}
+		cs.registerOffence(turn, verdict)
+		sanction := team.TeamAoA.GetSanction(verdict.agentID, agent.GetTrueScore())
+		cs.ApplySanction(team, verdict.agentID, sanction, verdict.audit+" audit")
+	}
+	cs.impeachLeader(turn)
+}
@@ -0,0 +1,120 @@
+package environmentServer
+
+import (
+	"log"
+	"slices"
+
+	"github.com/google/uuid"
+
+	common "github.com/ADimoska/SOMASExtended/common"
+	gameRecorder "github.com/ADimoska/SOMASExtended/gameRecorder"
+)
+
+/*
+* Members report teammates they think have cheated with ReportCheating, at any
+* point in the turn. Reports wait until the whistleblowing phase of the team
+* the whistleblower was in when it made the report, where they are heard in the
+* order they were made (see common/Whistleblowing.go).
+ */
+
+type filedReport struct {
+	teamID uuid.UUID
+	report *common.WhistleblowerMessage
+}
+
+// Report a teammate for cheating, to be heard in the team's whistleblowing phase
+func (cs *EnvironmentServer) ReportCheating(report *common.WhistleblowerMessage) {
+	whistleblower := cs.GetAgentMap()[report.GetSender()]
+	if whistleblower == nil || !whistleblower.HasTeam() {
+		log.Printf("[WARNING] Agent %v reported Agent %v for cheating but is not in a team, ignoring the report\n", report.GetSender(), report.Accused)
+		return
+	}
+	report.Turn = cs.turn
+
+	cs.reportsMutex.Lock()
+	defer cs.reportsMutex.Unlock()
+	cs.reports = append(cs.reports, filedReport{teamID: whistleblower.GetTeamID(), report: report})
+}
+
+// Take the team's reports off the queue, in the order they were made
+func (cs *EnvironmentServer) takeReports(teamID uuid.UUID) []*common.WhistleblowerMessage {
+	cs.reportsMutex.Lock()
+	defer cs.reportsMutex.Unlock()
+
+	taken := []*common.WhistleblowerMessage{}
+	kept := cs.reports[:0]
+	for _, filed := range cs.reports {
+		if filed.teamID == teamID {
+			taken = append(taken, filed.report)
+		} else {
+			kept = append(kept, filed)
+		}
+	}
+	cs.reports = kept
+	return taken
+}
+
+// Hear every report made by members of the team since its last turn
+func (cs *EnvironmentServer) whistleblowingPhase(turn *teamTurn) {
+	for _, report := range cs.takeReports(turn.team.TeamID) {
+		outcome := cs.hearReport(turn, report)
+		log.Printf("[server] Agent %v reported Agent %v for cheating on its %s: %s\n", report.GetSender(), report.Accused, report.Phase, outcome)
+		cs.recordEvent(gameRecorder.EventWhistleblown, gameRecorder.Whistleblown{
+			TeamID:          turn.team.TeamID,
+			WhistleblowerID: report.GetSender(),
+			AccusedID:       report.Accused,
+			Phase:           string(report.Phase),
+			Turn:            report.Turn,
+			StatedAmount:    report.Evidence.StatedAmount,
+			ExpectedAmount:  report.Evidence.ExpectedAmount,
+			Note:            report.Evidence.Note,
+			Outcome:         outcome,
+		})
+	}
+}
+
+// Act on a report as the AoA decides, returning what came of it
+func (cs *EnvironmentServer) hearReport(turn *teamTurn, report *common.WhistleblowerMessage) string {
+	team := turn.team
+	whistleblowerID := report.GetSender()
+
+	kind, ok := auditKindOf(report.Phase)
+	if !ok {
+		return "invalid"
+	}
+	if whistleblowerID == report.Accused || !cs.isActiveMember(turn, whistleblowerID) || !cs.isActiveMember(turn, report.Accused) {
+		return "invalid"
+	}
+
+	reviewer, ok := team.TeamAoA.(common.IWhistleblowerAoA)
+	if !ok {
+		return "ignored"
+	}
+	if !reviewer.ReviewReport(report) {
+		return "declined"
+	}
+
+	verdict := turn.findVerdict(report.Accused, kind.name)
+	if verdict == nil {
+		votes := []common.Vote{common.CreateVote(1, whistleblowerID, report.Accused)}
+		if _, audited := cs.conductAudit(turn, kind, votes, report.Accused); !audited {
+			return "unaffordable"
+		}
+		verdict = turn.findVerdict(report.Accused, kind.name)
+	} else if !slices.Contains(verdict.accusers, whistleblowerID) {
+		verdict.accusers = append(verdict.accusers, whistleblowerID)
+	}
+
+	if verdict.guilty {
+		reward := common.Reward{AgentID: whistleblowerID, Amount: reviewer.GetReportReward(report), Reason: "whistleblowing"}
+		// kept with the verdict, to be taken back if it is overturned on appeal
+		reward.Amount = cs.PayReward(team, reward)
+		verdict.rewards = append(verdict.rewards, reward)
+		return "upheld"
+	}
+	whistleblower := turn.agentMap[whistleblowerID]
+	if sanction := reviewer.GetFalseReportSanction(report, whistleblower.GetTrueScore()); !sanction.IsNone() {
+		cs.ApplySanction(team, whistleblowerID, sanction, "a false report")
+	}
+	return "rejected"
+}
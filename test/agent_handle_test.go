@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/MattSScott/basePlatformSOMAS/v2/pkg/message"
+	"github.com/google/uuid"
+
+	"github.com/ADimoska/SOMASExtended/agents"
+	"github.com/ADimoska/SOMASExtended/common"
+	envServer "github.com/ADimoska/SOMASExtended/server"
+)
+
+// Test that an agent can only see the public profile of another agent
+func TestAgentHandleIsRestricted(t *testing.T) {
+	serv, agentIDs := CreateTestServer(false)
+	teamID := serv.CreateAndInitTeamWithAgents(agentIDs[:2])
+	spy := agents.GetBaseAgents(serv.AgentFacing(), agents.AgentConfig{})
+	serv.AddAgent(spy)
+
+	if _, isServer := spy.Server.(*envServer.EnvironmentServer); isServer {
+		t.Fatalf("agent can type assert its way to the server")
+	}
+
+	other := spy.Server.AccessAgentByID(agentIDs[0])
+	if other.GetID() != agentIDs[0] || other.GetTeamID() != teamID {
+		t.Errorf("expected the public profile of agent %v in team %v, got %v in %v", agentIDs[0], teamID, other.GetID(), other.GetTeamID())
+	}
+	if spy.Server.AccessAgentByID(uuid.New()) != nil {
+		t.Errorf("expected looking up an unknown agent to give nil")
+	}
+
+	defer func() {
+		recovered := recover()
+		if message, ok := recovered.(string); !ok || !strings.Contains(message, "GetTrueScore is private") {
+			t.Errorf("expected reading another agent's score to panic, got %v", recovered)
+		}
+	}()
+	other.GetTrueScore()
+}
+
+// A message type of an agent's own, whose handler would empty the recipient's score
+type scoreThiefMessage struct {
+	message.BaseMessage
+}
+
+func (msg *scoreThiefMessage) InvokeMessageHandler(agent common.IExtendedAgent) {
+	agent.SetTrueScore(0)
+}
+
+// Test that messages of types not declared in common are not delivered
+func TestUndeclaredMessagesAreDropped(t *testing.T) {
+	serv, _ := CreateTestServer(false)
+	spy := agents.GetBaseAgents(serv.AgentFacing(), agents.AgentConfig{})
+	victim := agents.GetBaseAgents(serv.AgentFacing(), agents.AgentConfig{InitScore: 50})
+	serv.AddAgent(spy)
+	serv.AddAgent(victim)
+
+	spy.SendSynchronousMessage(&scoreThiefMessage{spy.CreateBaseMessage()}, victim.GetID())
+	if victim.GetTrueScore() != 50 {
+		t.Errorf("expected the message to be dropped, but the victim's score is %d", victim.GetTrueScore())
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MattSScott/basePlatformSOMAS/v2/pkg/agent"
+	baseServer "github.com/MattSScott/basePlatformSOMAS/v2/pkg/server"
+	"github.com/google/uuid"
+
+	agents "github.com/ADimoska/SOMASExtended/agents"
+	common "github.com/ADimoska/SOMASExtended/common"
+	envServer "github.com/ADimoska/SOMASExtended/server"
+)
+
+// A strategy defined outside of the agents package, registering itself
+type stubbornAgent struct {
+	*agents.ExtendedAgent
+	alwaysStick bool
+}
+
+func (s *stubbornAgent) StickOrAgain(accumulatedScore int, prevRoll int) bool {
+	return s.alwaysStick
+}
+
+func init() {
+	agents.RegisterAgentFactory(agents.AgentFactory{
+		Name: "test:stubborn",
+		Params: []agents.ParamSpec{
+			{Name: "alwaysStick", Kind: agents.ParamBool, Default: true},
+		},
+		Create: func(funcs agent.IExposedServerFunctions[common.IExtendedAgent], config agents.AgentConfig, params agents.AgentParams) common.IExtendedAgent {
+			return &stubbornAgent{agents.GetBaseAgents(funcs, config), params.Bool("alwaysStick")}
+		},
+	})
+}
+
+func createRegistryTestServer() *envServer.EnvironmentServer {
+	serv := &envServer.EnvironmentServer{
+		BaseServer: baseServer.CreateBaseServer[common.IExtendedAgent](1, 1, 100*time.Millisecond, 10),
+		Teams:      make(map[uuid.UUID]*common.Team),
+	}
+	serv.SetGameRunner(serv)
+	return serv
+}
+
+// Test that built in and third party agents can be created by name
+func TestCreateAgentByName(t *testing.T) {
+	serv := createRegistryTestServer()
+	config := agents.AgentConfig{InitScore: 5}
+
+	cheater, err := agents.CreateAgentByName("team1:cheat_long_term", serv, config, nil)
+	if err != nil {
+		t.Fatalf("expected team1:cheat_long_term to be registered, got %v", err)
+	}
+	if _, ok := cheater.(*agents.Team1Agent); !ok || cheater.GetTrueScore() != 5 {
+		t.Errorf("expected a Team1Agent with score 5, got %T with score %d", cheater, cheater.GetTrueScore())
+	}
+
+	stubborn, err := agents.CreateAgentByName("test:stubborn", serv, config, nil)
+	if err != nil {
+		t.Fatalf("expected test:stubborn to be registered, got %v", err)
+	}
+	if !stubborn.(*stubbornAgent).alwaysStick {
+		t.Errorf("expected default parameter alwaysStick to be applied")
+	}
+
+	mi, err := agents.CreateAgentByName("team4", serv, config, agents.AgentParams{"chaoticness": 3, "evilness": 1})
+	if err != nil {
+		t.Fatalf("expected team4 to accept chaoticness and evilness, got %v", err)
+	}
+	if chaoticness, evilness := mi.(*agents.MI_256_v1).GetCharacter(); chaoticness != 3 || evilness != 1 {
+		t.Errorf("expected character (3, 1), got (%d, %d)", chaoticness, evilness)
+	}
+}
+
+// Test that unknown factories and bad parameters are rejected
+func TestCreateAgentByNameRejectsBadInput(t *testing.T) {
+	serv := createRegistryTestServer()
+
+	if _, err := agents.CreateAgentByName("team99", serv, agents.AgentConfig{}, nil); err == nil {
+		t.Errorf("expected an unknown factory to be rejected")
+	}
+
+	factory, _ := agents.GetAgentFactory("team4")
+	errs := factory.ValidateParams(agents.AgentParams{"evilness": 4, "mood": 1})
+	if len(errs) != 2 || errs[0].Param != "evilness" || errs[1].Param != "mood" {
+		t.Errorf("expected errors for evilness and mood, got %v", errs)
+	}
+}
+
+// Test that registering the same name twice panics
+func TestRegisterAgentFactoryTwicePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a duplicate registration to panic")
+		}
+	}()
+	factory, _ := agents.GetAgentFactory("team2")
+	agents.RegisterAgentFactory(factory)
+}
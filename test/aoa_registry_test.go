@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ADimoska/SOMASExtended/common"
+	"github.com/google/uuid"
+)
+
+const testAoAID = 99
+
+func init() {
+	// An extra AoA, added without touching the server
+	common.RegisterAoA(common.AoAEntry{
+		ID:            testAoAID,
+		Name:          "Test (fixed, long memory)",
+		DefaultParams: common.AoAParams{"duration": 10},
+		Create: func(team *common.Team, params common.AoAParams) common.IArticlesOfAssociation {
+			return common.CreateFixedAoA(params["duration"])
+		},
+	})
+}
+
+// Test that the built in AoAs are all registered and can be created
+func TestBuiltInAoAsRegistered(t *testing.T) {
+	team := common.NewTeam(uuid.New())
+	team.Agents = []uuid.UUID{uuid.New(), uuid.New()}
+
+	for _, id := range []int{common.FixedAoAID, 1, 2, 3, 4, 5, 6, common.GraduatedAoAID} {
+		entry, exists := common.GetAoAEntry(id)
+		if !exists {
+			t.Errorf("expected AoA %d to be registered", id)
+			continue
+		}
+		if aoa := entry.New(team); aoa == nil {
+			t.Errorf("expected AoA %d (%s) to be created", id, entry.Name)
+		}
+	}
+
+	if entry, _ := common.GetAoAEntry(2); entry.PostCreate == nil {
+		t.Errorf("expected Team2 AoA to elect a leader after creation")
+	}
+}
+
+// Test that ballots may only rank registered AoAs
+func TestValidateAoARanking(t *testing.T) {
+	if err := common.ValidateAoARanking([]int{6, 5, 4, 3, 2, 1}); err != nil {
+		t.Errorf("expected the built in AoAs to be a valid ballot, got %v", err)
+	}
+	if err := common.ValidateAoARanking([]int{testAoAID, 1}); err != nil {
+		t.Errorf("expected a registered AoA to be a valid ballot, got %v", err)
+	}
+	if err := common.ValidateAoARanking([]int{1, 42}); err == nil {
+		t.Errorf("expected a ballot ranking AoA 42 to be rejected")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ADimoska/SOMASExtended/agents"
+	"github.com/ADimoska/SOMASExtended/common"
+	"github.com/ADimoska/SOMASExtended/gameRecorder"
+	envServer "github.com/ADimoska/SOMASExtended/server"
+)
+
+// An agent that always appeals
+type appellant struct {
+	*agents.ExtendedAgent
+}
+
+func (a *appellant) DecideAppeal(phase common.AuditPhase) bool {
+	return true
+}
+
+// An agent that votes to audit the target, and then to overturn the verdict
+type fickleAccuser struct {
+	*auditVoter
+}
+
+func (a *fickleAccuser) GetAppealVote(accused uuid.UUID, phase common.AuditPhase) bool {
+	return true
+}
+
+// Play a turn in which three agents have a fourth audited, paying for it
+// itself, and it appeals. Returns the appeal and the fines it was given.
+func playAppealedTurn(t *testing.T, jury envServer.JurySelection) (gameRecorder.Appeal, int) {
+	serv, _ := CreateTestServer(false)
+	serv.Init(3, false)
+	serv.SetAuditPayer(envServer.AuditPaidByGuilty)
+	serv.SetAppealRules(envServer.AppealRules{Jury: jury, Size: 3})
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	events, err := gameRecorder.CreateEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serv.DataRecorder.SetEventLog(events)
+
+	accused := &appellant{agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100})}
+	memberIDs := []uuid.UUID{accused.GetID()}
+	serv.AddAgent(accused)
+	for i := 0; i < 3; i++ {
+		accuser := &fickleAccuser{&auditVoter{agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100}), accused.GetID()}}
+		memberIDs = append(memberIDs, accuser.GetID())
+		serv.AddAgent(accuser)
+	}
+	teamID := serv.CreateAndInitTeamWithAgents(memberIDs)
+	serv.Teams[teamID].TeamAoA = fixedCostAoA{common.CreateFixedAoA(1), 6}
+	serv.Teams[teamID].SetCommonPool(50)
+
+	serv.RunTurn(0, 1)
+	if err := serv.DataRecorder.CloseEventLog(); err != nil {
+		t.Fatal(err)
+	}
+	logged, err := gameRecorder.ReadEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var appeal gameRecorder.Appeal
+	fined := 0
+	for _, event := range logged {
+		switch event.Type {
+		case gameRecorder.EventAppeal:
+			if err := json.Unmarshal(event.Data, &appeal); err != nil {
+				t.Fatal(err)
+			}
+		case gameRecorder.EventPunishment:
+			var punishment gameRecorder.Punishment
+			if err := json.Unmarshal(event.Data, &punishment); err != nil {
+				t.Fatal(err)
+			}
+			if punishment.AgentID == accused.GetID() {
+				fined += punishment.Amount
+			}
+		}
+	}
+	if appeal.AgentID != accused.GetID() {
+		t.Fatalf("expected %v to appeal, got %+v", accused.GetID(), appeal)
+	}
+	if appeal.Overturned && appeal.Score != accused.GetTrueScore() {
+		t.Errorf("expected the appeal to log the appellant's score after the refund, %d, got %d", accused.GetTrueScore(), appeal.Score)
+	}
+	return appeal, fined
+}
+
+// Test that an overturned verdict is refunded and not sanctioned, and that a
+// jury that leaves out the accusers can be left with nobody to overturn it
+func TestAppeals(t *testing.T) {
+	appeal, fined := playAppealedTurn(t, envServer.JuryAtRandom)
+	if !appeal.Overturned || len(appeal.Jurors) != 3 || appeal.Refunded != 6 {
+		t.Errorf("expected a jury of 3 to overturn the verdict and refund 6, got %+v", appeal)
+	}
+	if fined != 0 {
+		t.Errorf("expected no fine after the verdict was overturned, got %d", fined)
+	}
+
+	appeal, fined = playAppealedTurn(t, envServer.JuryNotAccusers)
+	if appeal.Overturned || len(appeal.Jurors) != 0 || appeal.Refunded != 0 {
+		t.Errorf("expected the verdict to stand without a jury, got %+v", appeal)
+	}
+	if fined == 0 {
+		t.Errorf("expected the held sanction to be carried out")
+	}
+}
+
+// An appellant that states it contributed more than it did
+type lyingAppellant struct {
+	*appellant
+}
+
+func (a *lyingAppellant) GetStatedContribution(instance common.IExtendedAgent) int {
+	return a.GetActualContribution(instance) + 5
+}
+
+// An appellant that also contributes less than expected of it, and withdraws
+// nothing so that only its contribution is in question
+type shirkingAppellant struct {
+	*lyingAppellant
+}
+
+func (a *shirkingAppellant) GetActualContribution(instance common.IExtendedAgent) int {
+	return a.ExtendedAgent.GetActualContribution(instance) - 5
+}
+
+func (a *shirkingAppellant) GetActualWithdrawal(instance common.IExtendedAgent) int {
+	return 0
+}
+
+func (a *shirkingAppellant) GetStatedWithdrawal(instance common.IExtendedAgent) int {
+	return 0
+}
+
+// Play a turn in which five agents have a sixth, which lied about and held back
+// its contribution, audited under the AoA newAoA creates, and then overturn the
+// verdict on its appeal. Returns the appellant.
+func playOverturnedTurn(t *testing.T, newAoA func(team *common.Team, accusedID uuid.UUID) common.IArticlesOfAssociation) uuid.UUID {
+	serv, _ := CreateTestServer(false)
+	serv.Init(3, false)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	events, err := gameRecorder.CreateEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serv.DataRecorder.SetEventLog(events)
+
+	accused := &shirkingAppellant{&lyingAppellant{&appellant{agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100})}}}
+	memberIDs := []uuid.UUID{accused.GetID()}
+	serv.AddAgent(accused)
+	for i := 0; i < 5; i++ {
+		accuser := &fickleAccuser{&auditVoter{agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100}), accused.GetID()}}
+		memberIDs = append(memberIDs, accuser.GetID())
+		serv.AddAgent(accuser)
+	}
+	teamID := serv.CreateAndInitTeamWithAgents(memberIDs)
+	serv.Teams[teamID].TeamAoA = newAoA(serv.Teams[teamID], accused.GetID())
+	serv.Teams[teamID].SetCommonPool(50)
+
+	serv.RunTurn(0, 1)
+	if err := serv.DataRecorder.CloseEventLog(); err != nil {
+		t.Fatal(err)
+	}
+	logged, err := gameRecorder.ReadEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var appeal gameRecorder.Appeal
+	for _, event := range logged {
+		if event.Type == gameRecorder.EventAppeal {
+			if err := json.Unmarshal(event.Data, &appeal); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if appeal.AgentID != accused.GetID() || !appeal.Overturned || appeal.Kind != "Contribution" {
+		t.Fatalf("expected %v's appeal against its contribution audit to be upheld, got %+v", accused.GetID(), appeal)
+	}
+	return accused.GetID()
+}
+
+// Test that overturning a verdict undoes what the AoA did on finding the agent
+// guilty: under Team2's AoA the offence is taken back and the deposed leader
+// reinstated
+func TestOverturnedVerdictIsUndone(t *testing.T) {
+	var aoa *common.Team2AoA
+	accusedID := playOverturnedTurn(t, func(team *common.Team, accusedID uuid.UUID) common.IArticlesOfAssociation {
+		aoa = common.CreateTeam2AoA(team, accusedID, 5).(*common.Team2AoA)
+		return aoa
+	})
+
+	if offences := aoa.GetOffences(accusedID); offences != 0 {
+		t.Errorf("expected the offence to be taken back, got %d", offences)
+	}
+	if rolls := aoa.GetRollsLeft(accusedID); rolls != 0 {
+		t.Errorf("expected the agent to keep its rolls, got %d made for it", rolls)
+	}
+	if leader := aoa.GetLeader(); leader != accusedID {
+		t.Errorf("expected the deposed leader to be reinstated, got %v", leader)
+	}
+}
+
+// Test that under Team3's AoA an overturned verdict leaves no lie on record
+func TestTeam3OverturnedVerdictIsUndone(t *testing.T) {
+	aoa := common.CreateTeam3AoA()
+	accusedID := playOverturnedTurn(t, func(team *common.Team, accusedID uuid.UUID) common.IArticlesOfAssociation {
+		return aoa
+	})
+
+	if warnings := aoa.AuditMap[accusedID].GetWarnings(); warnings != 0 {
+		t.Errorf("expected the lie to be cleared from the audit results, got %d warnings", warnings)
+	}
+	if history := aoa.LyingHistory[accusedID]; history != nil && history.GetWarnings() != 0 {
+		t.Errorf("expected no lies in the lying history, got %d", history.GetWarnings())
+	}
+}
+
+// Test that under Team5's AoA an overturned verdict does not count towards a kick
+func TestTeam5OverturnedVerdictIsUndone(t *testing.T) {
+	aoa := common.CreateTeam5AoA().(*common.Team5AOA)
+	accusedID := playOverturnedTurn(t, func(team *common.Team, accusedID uuid.UUID) common.IArticlesOfAssociation {
+		return aoa
+	})
+
+	if aoa.GetContributionAuditResult(accusedID) {
+		t.Errorf("expected the failed contribution to be cleared")
+	}
+}
+
+// Test that under Team6's AoA an overturned verdict does not put the agent under monitoring
+func TestTeam6OverturnedVerdictIsUndone(t *testing.T) {
+	aoa := common.CreateTeam6AoA()
+	accusedID := playOverturnedTurn(t, func(team *common.Team, accusedID uuid.UUID) common.IArticlesOfAssociation {
+		return aoa
+	})
+
+	if expected := aoa.GetExpectedContribution(accusedID, 100); expected != 30 {
+		t.Errorf("expected the agent to be held to the unmonitored contribution of 30, got %d", expected)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ADimoska/SOMASExtended/agents"
+	"github.com/ADimoska/SOMASExtended/common"
+	"github.com/ADimoska/SOMASExtended/gameRecorder"
+	envServer "github.com/ADimoska/SOMASExtended/server"
+)
+
+// An agent that always votes to audit the same agent's contribution
+type auditVoter struct {
+	*agents.ExtendedAgent
+	target uuid.UUID
+}
+
+func (a *auditVoter) GetContributionAuditVote() common.Vote {
+	return common.CreateVote(1, a.GetID(), a.target)
+}
+
+// An agent casting a vote that is not a valid call for an audit of the target
+type overeagerVoter struct {
+	*auditVoter
+}
+
+func (a *overeagerVoter) GetContributionAuditVote() common.Vote {
+	return common.CreateVote(2, a.GetID(), a.target)
+}
+
+// An AoA that expects nothing of its members and audits whoever is voted for,
+// at a fixed cost, always finding them guilty
+type fixedCostAoA struct {
+	common.IArticlesOfAssociation
+	cost int
+}
+
+func (aoa fixedCostAoA) GetExpectedContribution(agentID uuid.UUID, agentScore int) int {
+	return 0
+}
+
+func (aoa fixedCostAoA) GetAuditCost(commonPool int) int {
+	return aoa.cost
+}
+
+func (aoa fixedCostAoA) GetVoteResult(votes []common.Vote) uuid.UUID {
+	for _, vote := range votes {
+		if vote.IsVote == 1 {
+			return vote.VotedForID
+		}
+	}
+	return uuid.Nil
+}
+
+func (aoa fixedCostAoA) GetContributionAuditResult(agentID uuid.UUID) bool {
+	return true
+}
+
+// Play a turn in which three agents vote to audit a fourth, and a fifth casts
+// an invalid vote for it, and return what each paid towards the audit, by
+// payer ID
+func playAuditedTurn(t *testing.T, payer envServer.AuditPayer) (map[uuid.UUID]gameRecorder.AuditCharge, uuid.UUID) {
+	serv, _ := CreateTestServer(false)
+	serv.Init(3, false)
+	serv.SetAuditPayer(payer)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	events, err := gameRecorder.CreateEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serv.DataRecorder.SetEventLog(events)
+
+	target := agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100})
+	memberIDs := []uuid.UUID{target.GetID()}
+	serv.AddAgent(target)
+	for i := 0; i < 3; i++ {
+		voter := &auditVoter{agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100}), target.GetID()}
+		memberIDs = append(memberIDs, voter.GetID())
+		serv.AddAgent(voter)
+	}
+	overeager := &overeagerVoter{&auditVoter{agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100}), target.GetID()}}
+	memberIDs = append(memberIDs, overeager.GetID())
+	serv.AddAgent(overeager)
+	teamID := serv.CreateAndInitTeamWithAgents(memberIDs)
+	serv.Teams[teamID].TeamAoA = fixedCostAoA{common.CreateFixedAoA(1), 6}
+	serv.Teams[teamID].SetCommonPool(50)
+
+	serv.RunTurn(0, 1)
+	if err := serv.DataRecorder.CloseEventLog(); err != nil {
+		t.Fatal(err)
+	}
+
+	logged, err := gameRecorder.ReadEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	charges := map[uuid.UUID]gameRecorder.AuditCharge{}
+	for _, event := range logged {
+		if event.Type != gameRecorder.EventAuditCharge {
+			continue
+		}
+		var charge gameRecorder.AuditCharge
+		if err := json.Unmarshal(event.Data, &charge); err != nil {
+			t.Fatal(err)
+		}
+		if charge.AuditedID != target.GetID() || charge.TeamID != teamID {
+			t.Errorf("expected charges for the audit of %v, got %+v", target.GetID(), charge)
+		}
+		charges[charge.PayerID] = charge
+	}
+	return charges, target.GetID()
+}
+
+// Test that each payer rule charges the audit cost to the right agents
+func TestAuditPayers(t *testing.T) {
+	charges, _ := playAuditedTurn(t, envServer.AuditPaidByPool)
+	if len(charges) != 1 || charges[uuid.Nil].Payer != "pool" || charges[uuid.Nil].Amount != 6 {
+		t.Errorf("expected the pool to pay 6, got %+v", charges)
+	}
+
+	charges, targetID := playAuditedTurn(t, envServer.AuditPaidByVoters)
+	if len(charges) != 3 {
+		t.Errorf("expected each of the 3 voters to pay, got %+v", charges)
+	}
+	for payerID, charge := range charges {
+		if payerID == uuid.Nil || payerID == targetID || charge.Payer != "voter" || charge.Amount != 2 {
+			t.Errorf("expected each voter to pay 2, got %+v", charge)
+		}
+	}
+
+	charges, targetID = playAuditedTurn(t, envServer.AuditPaidByGuilty)
+	if len(charges) != 1 || charges[targetID].Payer != "guilty" || charges[targetID].Amount != 6 {
+		t.Errorf("expected the guilty agent to pay 6, got %+v", charges)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ADimoska/SOMASExtended/agents"
+	"github.com/ADimoska/SOMASExtended/common"
+	"github.com/google/uuid"
+)
+
+// Test that a perfect audit finds exactly the infractions in its lookback, at a cost of one per round
+func TestPerfectAudit(t *testing.T) {
+	ar := common.NewAuditRecord(2)
+	agentId := uuid.New()
+	ar.AddRecord(agentId, 1)
+	ar.AddRecord(agentId, 1)
+	ar.AddRecord(agentId, 0)
+
+	if found := ar.Audit(agentId); found != 1 {
+		t.Errorf("expected 1 infraction found, got %d", found)
+	}
+	if cost := ar.GetAuditCost(); cost != 2 {
+		t.Errorf("expected a cost of 2, got %d", cost)
+	}
+}
+
+// Test that votes set the quality of the next audit, and that abstaining leaves it alone
+func TestVoteOnQuality(t *testing.T) {
+	ar := common.NewAuditRecord(5)
+	voter := uuid.New()
+	votes := []common.Vote{
+		common.CreateVote(1, voter, uuid.Nil).WithAuditQuality(2, 0.5),
+		common.CreateVote(1, voter, uuid.Nil).WithAuditQuality(4, 0),
+		common.CreateVote(1, voter, uuid.Nil),
+	}
+	ar.VoteOnQuality(votes)
+
+	quality := ar.GetAuditQuality()
+	if quality.Lookback != 3 || quality.Detection != 0.5 {
+		t.Errorf("expected a lookback of 3 and detection of 0.5, got %+v", quality)
+	}
+	if cost := ar.GetAuditCost(); cost != 2 {
+		t.Errorf("expected a cheaper audit costing 2, got %d", cost)
+	}
+
+	ar.VoteOnQuality([]common.Vote{common.CreateVote(0, voter, uuid.Nil)})
+	if ar.GetAuditQuality() != quality {
+		t.Errorf("expected abstentions to leave the quality at %+v, got %+v", quality, ar.GetAuditQuality())
+	}
+}
+
+// Test that imperfect audits miss infractions and accuse the innocent at about the rates given
+func TestDetectionRates(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const trials = 10000
+	models := []common.DetectionModel{common.DetectEach, common.DetectPoisson}
+	for _, model := range models {
+		quality := common.AuditQuality{Lookback: 1, Detection: 0.5, FalsePositive: 0.2, Model: model}
+		caught, accused := 0, 0
+		for range trials {
+			if quality.Detect(1, rng) > 0 {
+				caught++
+			}
+			if quality.Detect(0, rng) > 0 {
+				accused++
+			}
+		}
+		if rate := float64(accused) / trials; rate < 0.18 || rate > 0.22 {
+			t.Errorf("%s: expected about 20%% of the innocent accused, got %v", model, rate)
+		}
+		// Each: 0.5 + 0.5 * 0.2. Poisson: 1 - e^-0.5, plus the false positives.
+		if rate := float64(caught) / trials; rate < 0.45 || rate > 0.65 {
+			t.Errorf("%s: expected about 55%% of cheaters caught, got %v", model, rate)
+		}
+	}
+
+	if err := (common.AuditQuality{Detection: 1.5}).Validate(); err == nil {
+		t.Errorf("expected a detection over 1 to be invalid")
+	}
+}
+
+// Test that teams' audits take the server's false positive rate, and that base
+// agents ask for less detection once they trust their teammates
+func TestAuditQualityInPlay(t *testing.T) {
+	serv, _ := CreateTestServer(false)
+	serv.Init(3, false)
+	serv.SetAuditFalsePositive(0.25)
+
+	voter := agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100})
+	teammate := agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100})
+	serv.AddAgent(voter)
+	serv.AddAgent(teammate)
+	teamID := serv.CreateAndInitTeamWithAgents([]uuid.UUID{voter.GetID(), teammate.GetID()})
+
+	quality := serv.Teams[teamID].TeamAoA.(common.IAuditQualityAoA).GetAuditQuality()
+	if quality.FalsePositive != 0.25 {
+		t.Errorf("expected the team's audits to accuse the innocent at 0.25, got %+v", quality)
+	}
+
+	if detection := voter.GetContributionAuditVote().AuditDetection; detection != 1 {
+		t.Errorf("expected an agent with no opinions to ask for full detection, got %v", detection)
+	}
+	voter.SetAgentContributionAuditResult(teammate.GetID(), false)
+	if detection := voter.GetContributionAuditVote().AuditDetection; detection >= 1 || detection < 0.5 {
+		t.Errorf("expected an agent that trusts its teammate to ask for less detection, got %v", detection)
+	}
+}
+
+// Test that Team1's audits cost more the more the team votes for them to detect
+func TestTeam1AuditCostFollowsQuality(t *testing.T) {
+	serv, agentIDs := CreateTestServer(false)
+	teamID := serv.CreateAndInitTeamWithAgents(agentIDs[:2])
+	team := serv.Teams[teamID]
+	aoa := common.CreateTeam1AoA(team, 4)
+	voter := agentIDs[0]
+
+	aoa.GetVoteResult([]common.Vote{common.CreateVote(1, voter, agentIDs[1]).WithAuditQuality(0, 0.5)})
+	cheap := aoa.GetAuditCost(team.GetCommonPool())
+	aoa.GetVoteResult([]common.Vote{common.CreateVote(1, voter, agentIDs[1]).WithAuditQuality(0, 1)})
+	if cost := aoa.GetAuditCost(team.GetCommonPool()); cost <= cheap {
+		t.Errorf("expected a full detection audit to cost more than %d, got %d", cheap, cost)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ADimoska/SOMASExtended/common"
+	"github.com/ADimoska/SOMASExtended/scenario"
+	envServer "github.com/ADimoska/SOMASExtended/server"
+)
+
+func runCheckpointedGame(t *testing.T, dir string) *envServer.EnvironmentServer {
+	s, err := scenario.Parse([]byte(`
+seed: 11
+server: {iterations: 2, turns: 6, maxDuration: 1ms}
+checkpoint: {every: 4, dir: ` + dir + `}
+population:
+  - {factory: team1:cheat_long_term, count: 2}
+  - {factory: team2, count: 3}
+  - {factory: team3, count: 2}
+  - {factory: team4, count: 3}
+`))
+	if err != nil {
+		t.Fatalf("expected scenario to parse, got %v", err)
+	}
+	common.SetMasterSeed(s.Seed)
+	serv := s.CreateServer()
+	for i, agent := range s.CreatePopulation(serv) {
+		agent.SetName(i)
+		serv.AddAgent(agent)
+	}
+	serv.Start()
+	return serv
+}
+
+// Test that a game resumed from a checkpoint records exactly the same turns as
+// the game that was never stopped
+func TestResumeFromCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	// team3 agents save their weights to the working directory
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	full := runCheckpointedGame(t, dir)
+
+	// saved after the 8th turn overall, the second of the second iteration
+	resumed, _, err := scenario.Resume(filepath.Join(dir, "checkpoint_1_1.json"))
+	if err != nil {
+		t.Fatalf("expected to resume, got %v", err)
+	}
+	resumed.Start()
+
+	if !reflect.DeepEqual(full.DataRecorder.TurnRecords, resumed.DataRecorder.TurnRecords) {
+		t.Errorf("resumed game recorded different turns from the full game")
+	}
+	if !reflect.DeepEqual(full.GetAgentScores(), resumed.GetAgentScores()) {
+		t.Errorf("expected final scores %v, got %v", full.GetAgentScores(), resumed.GetAgentScores())
+	}
+}
+
+// Test that checkpoints from a different version are rejected
+func TestLoadCheckpointRejectsOtherVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	data, _ := json.Marshal(envServer.Checkpoint{Version: envServer.CheckpointVersion + 1})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := envServer.LoadCheckpoint(path)
+	if err == nil || !strings.Contains(err.Error(), "version") {
+		t.Errorf("expected a version error, got %v", err)
+	}
+}
+
+// Test that a checkpoint holding a Team2 team whose members have all died can
+// be resumed
+func TestResumeWithEmptiedTeam2(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	runCheckpointedGame(t, dir)
+	checkpoint, err := envServer.LoadCheckpoint(filepath.Join(dir, "checkpoint_1_1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	emptied := false
+	for i, team := range checkpoint.Server.Teams {
+		if team.AoAID == 2 {
+			checkpoint.Server.Teams[i].Agents = []uuid.UUID{}
+			emptied = true
+		}
+	}
+	if !emptied {
+		t.Fatalf("expected the checkpoint to hold a Team2 team")
+	}
+	data, _ := json.Marshal(checkpoint)
+	path := filepath.Join(dir, "emptied.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, _, err := scenario.Resume(path)
+	if err != nil {
+		t.Fatalf("expected to resume, got %v", err)
+	}
+	resumed.Start()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ADimoska/SOMASExtended/agents"
+	"github.com/ADimoska/SOMASExtended/common"
+)
+
+// Test that the default rules give the 3d6 distribution the agents used to
+// hard-code
+func TestDefaultDiceRulesAre3d6(t *testing.T) {
+	rules := common.DefaultDiceRules()
+	if rules.MinRoll() != 3 || rules.MaxRoll() != 18 {
+		t.Errorf("expected rolls between 3 and 18, got %d to %d", rules.MinRoll(), rules.MaxRoll())
+	}
+	ways := map[int]float64{3: 1, 4: 3, 5: 6, 6: 10, 7: 15, 8: 21, 9: 25, 10: 27, 11: 27, 12: 25, 13: 21, 14: 15, 15: 10, 16: 6, 17: 3, 18: 1}
+	for _, outcome := range rules.Outcomes(0, 10) {
+		if expected := ways[outcome.Roll] / 216; outcome.Probability != expected {
+			t.Errorf("roll %d: expected probability %v, got %v", outcome.Roll, expected, outcome.Probability)
+		}
+		if outcome.Bust != (outcome.Roll <= 10) {
+			t.Errorf("roll %d after a 10: expected bust to be %t", outcome.Roll, outcome.Roll <= 10)
+		}
+	}
+	// 10 or less comes up half the time, so the expected gain after a 10 with
+	// 20 banked is what the rolls of 11 to 18 add (1395/216) less half of 20
+	if gain := rules.ExpectedGain(20, 10); math.Abs(gain-(1395.0/216-10)) > 1e-9 {
+		t.Errorf("expected gain of %v, got %v", 1395.0/216-10, gain)
+	}
+}
+
+// Test the odds under the other bust rules
+func TestDiceRulesBustOdds(t *testing.T) {
+	// a pair of d6 avoids a 1 on 25 of the 36 throws
+	face := common.DiceRules{Count: 2, Faces: 6, Bust: common.BustOnFace, BustFace: 1, MaxRerolls: -1}
+	if p := face.ImprovementProbability(0, -1); math.Abs(p-25.0/36) > 1e-9 {
+		t.Errorf("bust on face: expected %v, got %v", 25.0/36, p)
+	}
+
+	// with 9 of a cap of 12 banked, a d6 busts on 4, 5 and 6
+	capped := common.DiceRules{Count: 1, Faces: 6, Bust: common.BustOverCap, Cap: 12, MaxRerolls: -1}
+	if p := capped.ImprovementProbability(9, 2); math.Abs(p-0.5) > 1e-9 {
+		t.Errorf("bust over cap: expected 0.5, got %v", p)
+	}
+
+	// modifiers add dice and shift every roll
+	modified := common.DefaultDiceRules().With(common.DiceModifier{ExtraDice: 1, Bonus: -2})
+	if modified.MinRoll() != 2 || modified.MaxRoll() != 22 {
+		t.Errorf("expected modified rolls between 2 and 22, got %d to %d", modified.MinRoll(), modified.MaxRoll())
+	}
+}
+
+// Test that the server stops rolling for an agent once its rerolls are used up
+func TestServerLimitsRerolls(t *testing.T) {
+	serv, _ := CreateTestServer(false)
+	rules := common.DefaultDiceRules()
+	rules.MaxRerolls = 0
+	serv.SetDiceRules(rules)
+
+	agent := agents.GetBaseAgents(serv, agents.AgentConfig{})
+	serv.AddAgent(agent)
+	for i := 0; i < 20; i++ {
+		scoreBefore := agent.GetTrueScore()
+		rolls := serv.RollDice(agent)
+		if len(rolls) != 1 {
+			t.Fatalf("expected a single throw with no rerolls, got %v", rolls)
+		}
+		if agent.GetTrueScore() != scoreBefore+rolls[0] {
+			t.Fatalf("expected the throw of %d to be credited, score went from %d to %d", rolls[0], scoreBefore, agent.GetTrueScore())
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ADimoska/SOMASExtended/common"
+	"github.com/ADimoska/SOMASExtended/gameRecorder"
+	"github.com/ADimoska/SOMASExtended/scenario"
+	envServer "github.com/ADimoska/SOMASExtended/server"
+)
+
+// Play a scenario to the end with its event log written to path
+func playLoggedScenario(t *testing.T, path string, config string) *envServer.EnvironmentServer {
+	s, err := scenario.Parse([]byte(config + "\neventLog: " + path + "\n"))
+	if err != nil {
+		t.Fatalf("expected scenario to parse, got %v", err)
+	}
+	common.SetMasterSeed(s.Seed)
+	serv := s.CreateServer()
+	for i, agent := range s.CreatePopulation(serv) {
+		agent.SetName(i)
+		serv.AddAgent(agent)
+	}
+	if err := s.StartEventLog(serv); err != nil {
+		t.Fatal(err)
+	}
+	serv.Start()
+	if err := serv.DataRecorder.CloseEventLog(); err != nil {
+		t.Fatal(err)
+	}
+	return serv
+}
+
+// Test that replaying the event log of a game rebuilds the same turn records
+func TestReplayEventLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	serv := playLoggedScenario(t, path, `
+seed: 3
+server: {iterations: 1, turns: 8, maxDuration: 1ms}
+population:
+  - {factory: team1:cheat_long_term, count: 2}
+  - {factory: team2, count: 3}
+  - {factory: team4, count: 3}
+`)
+
+	events, err := gameRecorder.ReadEventLog(path)
+	if err != nil {
+		t.Fatalf("expected to read the event log, got %v", err)
+	}
+	counts := map[gameRecorder.EventType]int{}
+	for i, event := range events {
+		if event.Seq != i+1 {
+			t.Fatalf("expected event %d to have sequence number %d, got %d", i, i+1, event.Seq)
+		}
+		counts[event.Type]++
+	}
+	for _, eventType := range []gameRecorder.EventType{gameRecorder.EventGameStarted, gameRecorder.EventDiceRolled, gameRecorder.EventContribution, gameRecorder.EventWithdrawal, gameRecorder.EventVoteCast, gameRecorder.EventAoASelected} {
+		if counts[eventType] == 0 {
+			t.Errorf("expected the log to contain %s events", eventType)
+		}
+	}
+
+	replayed, err := gameRecorder.Replay(events)
+	if err != nil {
+		t.Fatalf("expected to replay the event log, got %v", err)
+	}
+	if !reflect.DeepEqual(serv.DataRecorder.TurnRecords, replayed.TurnRecords) {
+		t.Errorf("replayed turn records differ from the recorded ones")
+	}
+	if !reflect.DeepEqual(serv.DataRecorder.Turnteam1Rank, replayed.Turnteam1Rank) {
+		t.Errorf("replayed Team1 rank records differ from the recorded ones")
+	}
+}
+
+// Test that the turn records are rebuilt from the changes in the log, with
+// agents dying, being revived, fined, charged for audits and appealing, so
+// that a replay writes the same CSVs as the game did
+func TestReplayRebuildsCSVsFromChanges(t *testing.T) {
+	for name, config := range map[string]string{
+		"kill": `
+seed: 3
+server: {iterations: 3, turns: 9, maxDuration: 1ms}
+threshold: {turns: 2}
+population:
+  - {factory: team2, count: 3}
+  - {factory: team3, count: 3}
+  - {factory: team4, count: 3}
+  - {factory: base, count: 2}
+`,
+		"fine": `
+seed: 5
+server: {iterations: 2, turns: 9, maxDuration: 1ms}
+threshold: {turns: 2, action: fine, deduct: true}
+audit: {payer: guilty, falsePositive: 0.3}
+appeals: {jury: random, jurySize: 3}
+population:
+  - {factory: team2, count: 3}
+  - {factory: team3, count: 3}
+  - {factory: team4, count: 3}
+  - {factory: base, count: 3}
+`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "events.jsonl")
+			serv := playLoggedScenario(t, path, config)
+
+			events, err := gameRecorder.ReadEventLog(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			turnsEnded := 0
+			for _, event := range events {
+				if event.Type == gameRecorder.EventTurnEnded {
+					turnsEnded++
+				}
+			}
+			if turnsEnded != len(serv.DataRecorder.TurnRecords) || turnsEnded == 0 {
+				t.Fatalf("expected a turn_ended event for each of the %d turn records, got %d", len(serv.DataRecorder.TurnRecords), turnsEnded)
+			}
+
+			replayed, err := gameRecorder.Replay(events)
+			if err != nil {
+				t.Fatalf("expected to replay the event log, got %v", err)
+			}
+			played, replay := filepath.Join(dir, "played"), filepath.Join(dir, "replayed")
+			if err := gameRecorder.ExportToCSV(serv.DataRecorder, played); err != nil {
+				t.Fatal(err)
+			}
+			if err := gameRecorder.ExportToCSV(replayed, replay); err != nil {
+				t.Fatal(err)
+			}
+			files, err := os.ReadDir(played)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, file := range files {
+				want, err := os.ReadFile(filepath.Join(played, file.Name()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := os.ReadFile(filepath.Join(replay, file.Name()))
+				if err != nil {
+					t.Fatalf("expected the replay to write %s, got %v", file.Name(), err)
+				}
+				if !bytes.Equal(want, got) {
+					t.Errorf("replayed %s differs from the one the game wrote", file.Name())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ADimoska/SOMASExtended/common"
+)
+
+// Test that each counting method picks its own winner from the same ballots
+func TestCountLeaderBallots(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	candidates := []uuid.UUID{a, b, c}
+	ballots := [][]uuid.UUID{}
+	for i := 0; i < 4; i++ {
+		ballots = append(ballots, []uuid.UUID{a, c, b})
+	}
+	for i := 0; i < 3; i++ {
+		ballots = append(ballots, []uuid.UUID{b, c, a})
+	}
+	for i := 0; i < 2; i++ {
+		ballots = append(ballots, []uuid.UUID{c, b, a})
+	}
+
+	expected := map[common.ElectionMethod]uuid.UUID{
+		common.ElectPlurality: a, // most first choices
+		common.ElectIRV:       b, // c is knocked out and its voters prefer b
+		common.ElectBorda:     c, // everyone's first or second choice
+	}
+	for method, winner := range expected {
+		if got := common.CountLeaderBallots(method, candidates, ballots, rand.New(rand.NewSource(1))); got != winner {
+			t.Errorf("expected %s to elect %v, got %v", method, winner, got)
+		}
+	}
+
+	// Anyone who is not standing is ignored
+	outsider := uuid.New()
+	onlyB := [][]uuid.UUID{{outsider, b}, {outsider}}
+	if got := common.CountLeaderBallots(common.ElectRandomDictator, candidates, onlyB, rand.New(rand.NewSource(1))); got != b {
+		t.Errorf("expected the only candidate named to win, got %v", got)
+	}
+	if got := common.CountLeaderBallots(common.ElectPlurality, candidates, [][]uuid.UUID{{outsider}}, rand.New(rand.NewSource(1))); got != uuid.Nil {
+		t.Errorf("expected no winner when no candidate is named, got %v", got)
+	}
+}
+
+// A fixed AoA with a leader, counting the elections held
+type leaderAoA struct {
+	common.IArticlesOfAssociation
+	rules     common.LeadershipRules
+	leader    uuid.UUID
+	elections int
+}
+
+func (aoa *leaderAoA) GetLeadershipRules() common.LeadershipRules {
+	return aoa.rules
+}
+
+func (aoa *leaderAoA) GetLeader() uuid.UUID {
+	return aoa.leader
+}
+
+func (aoa *leaderAoA) SetLeader(leader uuid.UUID) {
+	aoa.leader = leader
+	aoa.elections++
+}
+
+// Test that the server elects a leader, again when the term is up, and a
+// successor when the leader leaves the team
+func TestLeaderTermsAndSuccession(t *testing.T) {
+	serv, teamID, _, _ := createSanctionedTeam(t, common.Sanction{})
+	team := serv.Teams[teamID]
+	aoa := &leaderAoA{IArticlesOfAssociation: common.CreateFixedAoA(1), rules: common.LeadershipRules{Method: common.ElectBorda, TermTurns: 2}}
+	team.TeamAoA = aoa
+
+	serv.RunTurn(0, 1)
+	if aoa.elections != 1 || !slices.Contains(team.Agents, aoa.leader) {
+		t.Fatalf("expected a member to be elected in the first turn, got %v after %d elections", aoa.leader, aoa.elections)
+	}
+	serv.RunTurn(0, 2)
+	if aoa.elections != 1 {
+		t.Errorf("expected the leader to serve out their term, got %d elections", aoa.elections)
+	}
+	serv.RunTurn(0, 3)
+	if aoa.elections != 2 {
+		t.Errorf("expected an election once the term was up, got %d elections", aoa.elections)
+	}
+
+	leader := aoa.leader
+	serv.RemoveAgentFromTeam(leader)
+	serv.RunTurn(0, 4)
+	if aoa.elections != 3 || aoa.leader == leader || !slices.Contains(team.Agents, aoa.leader) {
+		t.Errorf("expected a successor from the team once the leader left, got %v", aoa.leader)
+	}
+
+	view := serv.GetTeamFromTeamID(teamID)
+	if got, ok := view.GetLeader(); !ok || got != aoa.leader {
+		t.Errorf("expected the team view to show the leader, got %v", got)
+	}
+}
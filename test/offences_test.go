@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ADimoska/SOMASExtended/agents"
+	"github.com/ADimoska/SOMASExtended/common"
+	envServer "github.com/ADimoska/SOMASExtended/server"
+)
+
+// Test that guilty verdicts are registered, and that each viewer only sees
+// as much of the registry as the offence rules allow, whoever it claims to be
+func TestOffenceRegistry(t *testing.T) {
+	serv, _ := CreateTestServer(false)
+	serv.Init(3, false)
+
+	accused := agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100})
+	memberIDs := []uuid.UUID{accused.GetID()}
+	serv.AddAgent(accused)
+	accusers := []*auditVoter{}
+	for i := 0; i < 2; i++ {
+		accuser := &auditVoter{agents.GetBaseAgents(serv.AgentFacing(), agents.AgentConfig{InitScore: 100}), accused.GetID()}
+		accusers = append(accusers, accuser)
+		memberIDs = append(memberIDs, accuser.GetID())
+		serv.AddAgent(accuser)
+	}
+	outsider := agents.GetBaseAgents(serv.AgentFacing(), agents.AgentConfig{InitScore: 100})
+	serv.AddAgent(outsider)
+	teamID := serv.CreateAndInitTeamWithAgents(memberIDs)
+	serv.Teams[teamID].TeamAoA = fixedCostAoA{common.CreateFixedAoA(1), 1}
+	serv.Teams[teamID].SetCommonPool(50)
+
+	serv.RunTurn(0, 1)
+	serv.RunTurn(0, 2)
+
+	teammate := memberIDs[1]
+	record := serv.OffencesSeenBy(teammate, accused.GetID())
+	if len(record) != 2 {
+		t.Fatalf("expected 2 offences on the public record, got %+v", record)
+	}
+	if record[0].TeamID != teamID || record[0].Phase != common.ContributionPhase || record[0].Turn != 1 {
+		t.Errorf("expected a contribution offence in turn 1 in team %v, got %+v", teamID, record[0])
+	}
+	if len(serv.OffencesSeenBy(teammate, teammate)) != 0 {
+		t.Errorf("expected nothing on the record of an agent that was never audited")
+	}
+
+	serv.SetOffenceRules(envServer.OffenceRules{Visibility: envServer.OffencesTeamOnly})
+	if seen := serv.OffencesSeenBy(teammate, accused.GetID()); len(seen) != 2 {
+		t.Errorf("expected a teammate to see both offences, got %d", len(seen))
+	}
+	if seen := serv.OffencesSeenBy(outsider.GetID(), accused.GetID()); len(seen) != 0 {
+		t.Errorf("expected an agent from outside the team to see nothing, got %d", len(seen))
+	}
+	if seen := accusers[0].Server.GetOffences(accused.GetID()); len(seen) != 2 {
+		t.Errorf("expected a teammate asking the server itself to see both offences, got %d", len(seen))
+	}
+	outsider.Server.(common.IAgentBoundServer).BindAgent(teammate)
+	if seen := outsider.Server.GetOffences(accused.GetID()); len(seen) != 0 {
+		t.Errorf("expected an outsider claiming to be a teammate to still see nothing, got %d", len(seen))
+	}
+
+	serv.SetOffenceRules(envServer.OffenceRules{Visibility: envServer.OffencesExpiring, ExpireTurns: 1})
+	if seen := serv.OffencesSeenBy(outsider.GetID(), accused.GetID()); len(seen) != 1 || seen[0].Turn != 2 {
+		t.Errorf("expected only the offence from this turn to be visible, got %+v", seen)
+	}
+}
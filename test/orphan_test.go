@@ -180,7 +180,7 @@ func TestAllocateOrphans(t *testing.T) {
 	orphan2 := agentIDs[1]
 	teamID := serv.CreateAndInitTeamWithAgents(agentIDs[2:])
 
-	team := serv.GetTeamFromTeamID(teamID)
+	team := serv.Teams[teamID]
 
 	team.TeamAoAID = 1
 	// Set the preferences of these agents to the team ID
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ADimoska/SOMASExtended/common"
+	"github.com/google/uuid"
+)
+
+// Test that the same master seed hands out the same random streams and IDs
+func TestSeededStreamsAreReproducible(t *testing.T) {
+	draw := func() ([]int, uuid.UUID) {
+		common.SetMasterSeed(1234)
+		server, agent := common.NewRandStream("server"), common.NewRandStream("agent")
+		return []int{server.Intn(1000), server.Intn(1000), agent.Intn(1000)}, uuid.New()
+	}
+
+	firstDraws, firstID := draw()
+	secondDraws, secondID := draw()
+
+	for i := range firstDraws {
+		if firstDraws[i] != secondDraws[i] {
+			t.Errorf("draw %d differs between runs: %d vs %d", i, firstDraws[i], secondDraws[i])
+		}
+	}
+	if firstID != secondID {
+		t.Errorf("expected the same uuid for the same seed, got %v and %v", firstID, secondID)
+	}
+}
+
+// Test that two streams requested under the same name are independent
+func TestRepeatedStreamNamesDiffer(t *testing.T) {
+	common.SetMasterSeed(1234)
+	first, second := common.NewRandStream("aoa/fixed"), common.NewRandStream("aoa/fixed")
+	if first.Int63() == second.Int63() {
+		t.Errorf("expected streams with the same name to be different")
+	}
+}
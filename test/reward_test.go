@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ADimoska/SOMASExtended/common"
+)
+
+// Test that rewards are paid from the pool while it lasts, and only to members
+func TestPayReward(t *testing.T) {
+	serv, agentIDs := CreateTestServer(false)
+	teamID := serv.CreateAndInitTeamWithAgents(agentIDs[:2])
+	team := serv.Teams[teamID]
+	team.SetCommonPool(10)
+
+	if paid := serv.PayReward(team, common.Reward{AgentID: agentIDs[0], Amount: 6, Reason: "test"}); paid != 6 {
+		t.Errorf("expected the first reward to be paid in full, got %v", paid)
+	}
+	if paid := serv.PayReward(team, common.Reward{AgentID: agentIDs[1], Amount: 6, Reason: "test"}); paid != 4 {
+		t.Errorf("expected the second reward to get what was left of the pool, got %v", paid)
+	}
+	if paid := serv.PayReward(team, common.Reward{AgentID: agentIDs[5], Amount: 6, Reason: "test"}); paid != 0 {
+		t.Errorf("expected an agent outside the team not to be paid, got %v", paid)
+	}
+	if team.GetCommonPool() != 0 || serv.GetAgentMap()[agentIDs[0]].GetTrueScore() != 6 {
+		t.Errorf("expected the pool to have paid out 10, got pool %v", team.GetCommonPool())
+	}
+}
+
+// Test that Team5 rewards an honesty streak once
+func TestTeam5HonestyStreakReward(t *testing.T) {
+	serv, agentIDs := CreateTestServer(false)
+	teamID := serv.CreateAndInitTeamWithAgents(agentIDs[:2])
+	team := serv.Teams[teamID]
+	aoa := common.CreateTeam5AoA().(*common.Team5AOA)
+	team.TeamAoA = aoa
+	team.SetCommonPool(100)
+	aoa.ContributionRoundMap[agentIDs[0]] = 3
+	aoa.ContributionRoundMap[agentIDs[1]] = 2
+
+	rewards := aoa.GetRewards(team, serv.GetAgentMap())
+	if len(rewards) != 1 || rewards[0].AgentID != agentIDs[0] || rewards[0].Amount != 5 {
+		t.Errorf("expected a bonus of 5 for the agent on a streak, got %+v", rewards)
+	}
+	if rewards := aoa.GetRewards(team, serv.GetAgentMap()); len(rewards) != 0 {
+		t.Errorf("expected the streak to start again once rewarded, got %+v", rewards)
+	}
+}
+
+// Test that Team5 pays back some of what members contributed over what was
+// expected of them, for the turn they did it only
+func TestTeam5OverContributionReward(t *testing.T) {
+	serv, agentIDs := CreateTestServer(false)
+	teamID := serv.CreateAndInitTeamWithAgents(agentIDs[:2])
+	team := serv.Teams[teamID]
+	aoa := common.CreateTeam5AoA().(*common.Team5AOA)
+	team.TeamAoA = aoa
+	team.SetCommonPool(100)
+	aoa.SetContributionAuditResult(agentIDs[0], 40, 38, 38)
+	aoa.SetContributionAuditResult(agentIDs[1], 40, 30, 30)
+
+	rewards := aoa.GetRewards(team, serv.GetAgentMap())
+	if len(rewards) != 1 || rewards[0].AgentID != agentIDs[0] || rewards[0].Amount != 2 || rewards[0].Reason != "over-contribution" {
+		t.Errorf("expected a bonus of 2 for the agent that gave 8 over what was expected, got %+v", rewards)
+	}
+	if rewards := aoa.GetRewards(team, serv.GetAgentMap()); len(rewards) != 0 {
+		t.Errorf("expected the over-contribution to be rewarded once, got %+v", rewards)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ADimoska/SOMASExtended/common"
+	"github.com/ADimoska/SOMASExtended/scenario"
+)
+
+// Test that repeated offences climb the ladder and expulsion clears the record
+func TestSanctionEngineEscalates(t *testing.T) {
+	engine := common.NewSanctionEngine(common.DefaultSanctionLadder())
+	agentID := uuid.New()
+
+	if sanction := engine.Offend(agentID, 100); !sanction.IsNone() {
+		t.Errorf("expected a warning for the first offence, got %v", sanction)
+	}
+	if sanction := engine.Offend(agentID, 100); sanction.Fine != 25 {
+		t.Errorf("expected a fine of 25 for the second offence, got %v", sanction)
+	}
+	if sanction := engine.Offend(agentID, 100); len(sanction.Restrictions) != 1 || sanction.Restrictions[0].Role != common.RoleAuditVoter {
+		t.Errorf("expected the third offence to cost the agent its vote, got %v", sanction)
+	}
+	engine.Offend(agentID, 100)
+	if sanction := engine.Offend(agentID, 100); !sanction.Kick {
+		t.Errorf("expected the fifth offence to expel the agent, got %v", sanction)
+	}
+	if record := engine.GetRecord(agentID); record.Offences != 0 {
+		t.Errorf("expected the expelled agent's record to be cleared, got %+v", record)
+	}
+
+	changes := engine.TakeChanges()
+	if len(changes) != 6 || changes[5].Kind != common.SanctionCleared {
+		t.Errorf("expected five escalations and a clearing, got %+v", changes)
+	}
+	if len(engine.TakeChanges()) != 0 {
+		t.Errorf("expected the changes to be taken only once")
+	}
+}
+
+// Test that offences decay after a spell without any, and that escalation skips rungs
+func TestSanctionEngineDecays(t *testing.T) {
+	ladder := common.DefaultSanctionLadder()
+	ladder.Escalation = 2
+	ladder.DecayTurns = 2
+	engine := common.NewSanctionEngine(ladder)
+	agentID := uuid.New()
+
+	engine.Offend(agentID, 100)
+	if sanction := engine.Offend(agentID, 100); len(sanction.Restrictions) != 1 {
+		t.Errorf("expected the second offence to climb two rungs to losing the vote, got %v", sanction)
+	}
+	engine.TakeChanges()
+
+	engine.Tick()
+	if engine.GetRecord(agentID).Offences != 2 {
+		t.Errorf("expected no decay after one turn")
+	}
+	engine.Tick()
+	engine.Tick()
+	engine.Tick()
+	if record := engine.GetRecord(agentID); record.Offences != 0 || record.Total != 2 {
+		t.Errorf("expected both offences to decay, got %+v", record)
+	}
+	changes := engine.TakeChanges()
+	if len(changes) != 2 || changes[0].Kind != common.SanctionDecayed || changes[0].Level != common.SanctionWarning || changes[1].Level != "" {
+		t.Errorf("expected two decays, got %+v", changes)
+	}
+}
+
+// Test that a scenario's ladder replaces the default one
+func TestScenarioSanctionLadder(t *testing.T) {
+	s, err := scenario.Parse([]byte("sanctions: {steps: [{level: fine, percent: 10}]}\npopulation:\n  - {factory: team2, count: 1}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Sanctions.Steps) != 1 || s.Sanctions.Steps[0].Percent != 10 {
+		t.Errorf("expected a one step ladder, got %+v", s.Sanctions.Steps)
+	}
+	if s.Sanctions.DecayTurns != common.DefaultSanctionLadder().DecayTurns {
+		t.Errorf("expected decayTurns to keep its default, got %d", s.Sanctions.DecayTurns)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ADimoska/SOMASExtended/agents"
+	"github.com/ADimoska/SOMASExtended/common"
+	"github.com/ADimoska/SOMASExtended/gameRecorder"
+	envServer "github.com/ADimoska/SOMASExtended/server"
+)
+
+// An AoA that finds whoever is voted for guilty and gives them the same sanction
+type sanctioningAoA struct {
+	fixedCostAoA
+	sanction common.Sanction
+}
+
+func (aoa sanctioningAoA) GetSanction(agentID uuid.UUID, agentScore int) common.Sanction {
+	return aoa.sanction
+}
+
+// Set up a team in which three agents always vote to audit a fourth, which the
+// AoA sanctions, and return the server, the team and the audited agent
+func createSanctionedTeam(t *testing.T, sanction common.Sanction) (*envServer.EnvironmentServer, uuid.UUID, uuid.UUID, string) {
+	serv, _ := CreateTestServer(false)
+	serv.Init(3, false)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	events, err := gameRecorder.CreateEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serv.DataRecorder.SetEventLog(events)
+
+	target := agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100})
+	memberIDs := []uuid.UUID{target.GetID()}
+	serv.AddAgent(target)
+	for i := 0; i < 3; i++ {
+		voter := &auditVoter{agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100}), target.GetID()}
+		memberIDs = append(memberIDs, voter.GetID())
+		serv.AddAgent(voter)
+	}
+	teamID := serv.CreateAndInitTeamWithAgents(memberIDs)
+	serv.Teams[teamID].TeamAoA = sanctioningAoA{fixedCostAoA{common.CreateFixedAoA(1), 0}, sanction}
+	serv.Teams[teamID].SetCommonPool(50)
+	return serv, teamID, target.GetID(), path
+}
+
+// Test that a fine is paid into the pool and a kick removes the agent from the team
+func TestSanctionFineAndKick(t *testing.T) {
+	serv, teamID, targetID, path := createSanctionedTeam(t, common.Sanction{Fine: 10, Kick: true, Reason: "test"})
+	serv.RunTurn(0, 1)
+	if err := serv.DataRecorder.CloseEventLog(); err != nil {
+		t.Fatal(err)
+	}
+
+	target := serv.GetAgentMap()[targetID]
+	if target.HasTeam() {
+		t.Errorf("expected the sanctioned agent to have been kicked out of the team")
+	}
+	for _, agentID := range serv.Teams[teamID].Agents {
+		if agentID == targetID {
+			t.Errorf("expected the sanctioned agent to no longer be listed in the team")
+		}
+	}
+
+	logged, err := gameRecorder.ReadEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fined := false
+	for _, event := range logged {
+		if event.Type != gameRecorder.EventPunishment {
+			continue
+		}
+		var punishment gameRecorder.Punishment
+		if err := json.Unmarshal(event.Data, &punishment); err != nil {
+			t.Fatal(err)
+		}
+		fined = fined || (punishment.AgentID == targetID && punishment.Amount == 10)
+	}
+	if !fined {
+		t.Errorf("expected the sanctioned agent to have been fined 10")
+	}
+}
+
+// Test that a restriction bars the agent from its role for as long as it lasts
+func TestSanctionRestriction(t *testing.T) {
+	sanction := common.Sanction{Restrictions: []common.RoleRestriction{{Role: common.RoleWithdrawer, Turns: 1}}}
+	serv, _, targetID, path := createSanctionedTeam(t, sanction)
+
+	serv.RunTurn(0, 1)
+	if !serv.IsRestricted(targetID, common.RoleWithdrawer) {
+		t.Errorf("expected the sanctioned agent to be barred from withdrawing")
+	}
+	if serv.IsRestricted(targetID, common.RoleAuditVoter) {
+		t.Errorf("expected the sanctioned agent to still be able to vote")
+	}
+	// the agent is sanctioned again in turn 2, so its restriction lasts until turn 3
+	serv.RunTurn(0, 2)
+	serv.RunTurn(0, 3)
+	if err := serv.DataRecorder.CloseEventLog(); err != nil {
+		t.Fatal(err)
+	}
+
+	logged, err := gameRecorder.ReadEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restrictions := 0
+	for _, event := range logged {
+		switch event.Type {
+		case gameRecorder.EventRestricted:
+			restrictions++
+		case gameRecorder.EventWithdrawal:
+			var withdrawal gameRecorder.Withdrawal
+			if err := json.Unmarshal(event.Data, &withdrawal); err != nil {
+				t.Fatal(err)
+			}
+			if withdrawal.AgentID == targetID && event.Turn > 1 {
+				t.Errorf("expected the restricted agent not to withdraw in turn %v", event.Turn)
+			}
+		}
+	}
+	if restrictions != 3 {
+		t.Errorf("expected a restriction to be recorded each turn, got %v", restrictions)
+	}
+
+	serv.RunStartOfIteration(1)
+	if serv.IsRestricted(targetID, common.RoleWithdrawer) {
+		t.Errorf("expected restrictions to be lifted at the start of the iteration")
+	}
+}
+
+// A graduated sanctions AoA that finds whoever is voted for guilty
+type guiltyGraduatedAoA struct {
+	*common.GraduatedAoA
+}
+
+func (aoa guiltyGraduatedAoA) GetExpectedContribution(agentID uuid.UUID, agentScore int) int {
+	return 0
+}
+
+func (aoa guiltyGraduatedAoA) GetContributionAuditResult(agentID uuid.UUID) bool {
+	return true
+}
+
+// Test that the server moves a team's sanction engine on and records what it changes
+func TestSanctionEngineRecorded(t *testing.T) {
+	serv, teamID, targetID, _ := createSanctionedTeam(t, common.Sanction{})
+	ladder := common.SanctionLadder{Steps: []common.SanctionStep{{Level: common.SanctionWarning}, {Level: common.SanctionExpel}}, Escalation: 1}
+	serv.Teams[teamID].TeamAoA = guiltyGraduatedAoA{common.CreateGraduatedAoA(1, ladder)}
+
+	serv.RunTurn(0, 1)
+	serv.RunTurn(0, 2)
+
+	records := serv.DataRecorder.SanctionRecords
+	if len(records) != 3 {
+		t.Fatalf("expected two escalations and a clearing to be recorded, got %+v", records)
+	}
+	if records[0].AgentID != targetID || records[0].Level != "warning" || records[0].TurnNumber != 1 {
+		t.Errorf("expected a warning in turn 1, got %+v", records[0])
+	}
+	if records[1].Level != "expel" || records[2].Change != "cleared" || records[2].TurnNumber != 2 {
+		t.Errorf("expected an expulsion in turn 2, got %+v", records[1:])
+	}
+	if serv.GetAgentMap()[targetID].HasTeam() {
+		t.Errorf("expected the agent to have been expelled")
+	}
+}
+
+// Test that another member rolls for an agent barred from rolling
+func TestSanctionDelegatesRolls(t *testing.T) {
+	sanction := common.Sanction{Restrictions: []common.RoleRestriction{{Role: common.RoleRoller}}}
+	serv, _, targetID, path := createSanctionedTeam(t, sanction)
+	serv.RunTurn(0, 1)
+	serv.RunTurn(0, 2)
+	if err := serv.DataRecorder.CloseEventLog(); err != nil {
+		t.Fatal(err)
+	}
+
+	logged, err := gameRecorder.ReadEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegated := false
+	for _, event := range logged {
+		if event.Type != gameRecorder.EventDiceRolled {
+			continue
+		}
+		var rolled gameRecorder.DiceRolled
+		if err := json.Unmarshal(event.Data, &rolled); err != nil {
+			t.Fatal(err)
+		}
+		if rolled.AgentID != targetID {
+			continue
+		}
+		if event.Turn == 1 && rolled.ControlledBy != uuid.Nil {
+			t.Errorf("expected the agent to roll for itself before it was sanctioned")
+		}
+		if event.Turn == 2 {
+			delegated = rolled.ControlledBy != uuid.Nil && rolled.ControlledBy != targetID
+		}
+	}
+	if !delegated {
+		t.Errorf("expected another member to roll for the agent in turn 2")
+	}
+}
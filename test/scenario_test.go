@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ADimoska/SOMASExtended/scenario"
+)
+
+// Test that a valid scenario is parsed, with missing fields taking the defaults
+func TestParseScenario(t *testing.T) {
+	s, err := scenario.Parse([]byte(`
+seed: 7
+server:
+  turns: 20
+population:
+  - factory: team4
+    count: 3
+    config: {chaoticness: 3, evilness: 1}
+  - factory: team1
+    count: 1
+    config: {agentType: cheat_long_term}
+`))
+	if err != nil {
+		t.Fatalf("expected scenario to parse, got %v", err)
+	}
+
+	if s.Seed != 7 || s.Server.Turns != 20 {
+		t.Errorf("expected seed 7 and 20 turns, got %d and %d", s.Seed, s.Server.Turns)
+	}
+	if s.Server.Iterations != scenario.Default().Server.Iterations {
+		t.Errorf("expected iterations to default to %d, got %d", scenario.Default().Server.Iterations, s.Server.Iterations)
+	}
+	if len(s.Population) != 2 || s.Population[0].Count != 3 {
+		t.Errorf("unexpected population %v", s.Population)
+	}
+}
+
+// Test that JSON scenarios are accepted too
+func TestParseJSONScenario(t *testing.T) {
+	_, err := scenario.Parse([]byte(`{"server": {"maxDuration": "10ms"}, "population": [{"factory": "team2", "count": 2}]}`))
+	if err != nil {
+		t.Fatalf("expected JSON scenario to parse, got %v", err)
+	}
+}
+
+// Test that validation errors name the field that caused them
+func TestScenarioValidationNamesField(t *testing.T) {
+	cases := map[string]string{
+		"population:\n  - {factory: team9, count: 1}":                                                        "population[0].factory",
+		"population:\n  - {factory: team2, count: 0}":                                                        "population[0].count",
+		"population:\n  - {factory: team2, count: 1}\n  - {factory: team4, count: 1, config: {evilness: 5}}": "population[1].config.evilness",
+		"population:\n  - {factory: team1, count: 1, config: {agentType: liar}}":                             "population[0].config.agentType",
+		"population:\n  - {factory: team2, count: 1, config: {chaoticness: 1}}":                              "population[0].config.chaoticness",
+		"threshold: {turns: -1}\npopulation:\n  - {factory: team2, count: 1}":                                "threshold.turns",
+		"server: {bandwidht: 3}\npopulation:\n  - {factory: team2, count: 1}":                                "bandwidht",
+		"server: {teamFormingTimeout: 0s}\npopulation:\n  - {factory: team2, count: 1}":                      "server.teamFormingTimeout",
+		"checkpoint: {every: -1}\npopulation:\n  - {factory: team2, count: 1}":                               "checkpoint.every",
+		"threshold: {policy: steep}\npopulation:\n  - {factory: team2, count: 1}":                            "threshold.policy",
+		"threshold: {params: {low: 4, high: 2}}\npopulation:\n  - {factory: team2, count: 1}":                "threshold.params.high",
+		"threshold: {action: exile}\npopulation:\n  - {factory: team2, count: 1}":                            "threshold.action",
+		"dice: {faces: 1}\npopulation:\n  - {factory: team2, count: 1}":                                      "dice.faces",
+		"dice: {bust: cap}\npopulation:\n  - {factory: team2, count: 1}":                                     "dice.cap",
+		"population:\n  - {factory: team2, count: 1, config: {extraDice: -1}}":                               "population[0].config.extraDice",
+		"population:\n  - {factory: team2, count: 1, config: {diceBonus: -3}}":                               "population[0].config.diceBonus",
+		"population:\n  - {factory: team2, count: 1, config: {extraDice: 8}}":                                "population[0].config.extraDice",
+		"audit: {payer: everyone}\npopulation:\n  - {factory: team2, count: 1}":                              "audit.payer",
+		"audit: {falsePositive: 2}\npopulation:\n  - {factory: team2, count: 1}":                             "audit.falsePositive",
+		"sanctions: {steps: [{level: fine}]}\npopulation:\n  - {factory: team2, count: 1}":                   "sanctions.steps[0].percent",
+	}
+
+	for input, field := range cases {
+		_, err := scenario.Parse([]byte(input))
+		if err == nil {
+			t.Errorf("expected an error for %q", input)
+			continue
+		}
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected error for %q to name %s, got %v", input, field, err)
+		}
+	}
+
+	_, err := scenario.Parse([]byte("population:\n  - {factory: team2, count: -2}"))
+	var fieldErr *scenario.FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "population[0].count" {
+		t.Errorf("expected a FieldError for population[0].count, got %v", err)
+	}
+}
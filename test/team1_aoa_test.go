@@ -8,6 +8,7 @@ import (
 	"bou.ke/monkey"
 	agents "github.com/ADimoska/SOMASExtended/agents"
 	"github.com/ADimoska/SOMASExtended/common"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"math/rand"
 	"reflect"
@@ -23,7 +24,7 @@ func TestCondorcetWinner(t *testing.T) {
 
 	// Force AoA to team 1
 	teamID := serv.CreateAndInitTeamWithAgents(agentIDs)
-	team := serv.GetTeamFromTeamID(teamID)
+	team := serv.Teams[teamID]
 	team.TeamAoA = common.CreateTeam1AoA(team, 5)
 
 	/* Mock function to overwrite the voting of an agent. This particular
@@ -52,3 +53,20 @@ func TestCondorcetWinner(t *testing.T) {
 	res2 := serv.GetAgentMap()[testAgents[1]].Team1_AgreeRankBoundaries()
 	assert.Equal(t, res1, res2)
 }
+
+// Test that one vote for an audit is outweighed by members preferring none
+func TestTeam1AuditVoteNeedsNetSupport(t *testing.T) {
+	serv, agentIDs := CreateTestServer(false)
+	teamID := serv.CreateAndInitTeamWithAgents(agentIDs)
+	aoa := common.CreateTeam1AoA(serv.Teams[teamID], 5)
+
+	target := agentIDs[0]
+	votes := []common.Vote{common.CreateVote(1, agentIDs[1], target)}
+	for _, voterID := range agentIDs[2:5] {
+		votes = append(votes, common.CreateVote(-1, voterID, target))
+	}
+	assert.Equal(t, uuid.Nil, aoa.GetVoteResult(votes))
+
+	votes = append(votes, common.CreateVote(1, agentIDs[5], target), common.CreateVote(1, agentIDs[6], target), common.CreateVote(1, agentIDs[7], target))
+	assert.Equal(t, target, aoa.GetVoteResult(votes))
+}
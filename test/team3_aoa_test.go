@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ADimoska/SOMASExtended/common"
+)
+
+// A vote for a strategy, cast the way Team3 collects them
+func strategyVote(voterID uuid.UUID, strategy common.Strategy, weight int) common.Vote {
+	return common.Vote{IsVote: weight, VoterID: voterID, VotedForID: uuid.NewSHA1(uuid.Nil, []byte{byte(strategy)})}
+}
+
+// Test that every voter's ranked ballot counts, first choices included
+func TestTeam3StrategyVoteCountsFirstChoices(t *testing.T) {
+	aoa := common.CreateTeam3AoA()
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	votes := []common.Vote{
+		strategyVote(a, common.Resolutes, 2), strategyVote(a, common.Moderates, 1),
+		strategyVote(b, common.Resolutes, 2), strategyVote(b, common.Moderates, 1),
+		strategyVote(c, common.Lenient, 2), strategyVote(c, common.Moderates, 1),
+	}
+
+	if strategy := aoa.DetermineStrategy(votes); strategy != common.Resolutes || aoa.PunishmentPeriod != 7 {
+		t.Errorf("expected the first choice of most voters to win, got %v remembering %d rounds", strategy, aoa.PunishmentPeriod)
+	}
+}
+
+// Test that audits under the Lenient strategy, which remembers nothing, are recorded without a panic
+func TestTeam3LenientRemembersNothing(t *testing.T) {
+	aoa := common.CreateTeam3AoA()
+	agentID := uuid.New()
+	if strategy := aoa.DetermineStrategy(nil); strategy != common.Lenient || aoa.PunishmentPeriod != 0 {
+		t.Fatalf("expected no votes to fall back to Lenient, got %v remembering %d rounds", strategy, aoa.PunishmentPeriod)
+	}
+
+	aoa.SetContributionAuditResult(agentID, 10, 5, 10)
+	aoa.SetContributionAuditResult(agentID, 10, 5, 10)
+	if warnings := aoa.AuditMap[agentID].GetWarnings(); warnings != 0 {
+		t.Errorf("expected Lenient to remember no lies, got %d", warnings)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ADimoska/SOMASExtended/common"
+)
+
+// Test that Team5 allocates the pool between its members only, that agents can
+// look up what they were allocated, and that taking more fails the audit
+func TestTeam5AllocationIsEntitlement(t *testing.T) {
+	serv, agentIDs := CreateTestServer(false)
+	teamID := serv.CreateAndInitTeamWithAgents(agentIDs[:3])
+	team := serv.Teams[teamID]
+	aoa := common.CreateTeam5AoA().(*common.Team5AOA)
+	team.TeamAoA = aoa
+	team.SetCommonPool(30)
+	for i, agentID := range agentIDs {
+		serv.GetAgentMap()[agentID].SetTrueScore(10 * i)
+	}
+
+	aoa.RunPreWithdrawalAoaLogic(team, serv.GetAgentMap())
+
+	if _, allocated := aoa.Allocation[agentIDs[5]]; allocated {
+		t.Errorf("expected agents outside the team to get no allocation")
+	}
+	total := 0
+	for _, agentID := range agentIDs[:3] {
+		entitlement, set := serv.GetTeam(agentID).GetWithdrawalEntitlement(agentID)
+		if !set {
+			t.Fatalf("expected agent %v to be able to look up its allocation", agentID)
+		}
+		total += entitlement
+	}
+	if total == 0 || total > 30 {
+		t.Errorf("expected the pool of 30 to be allocated between the members, got %v in total", total)
+	}
+
+	entitlement, _ := serv.GetTeam(agentIDs[0]).GetWithdrawalEntitlement(agentIDs[0])
+	aoa.SetWithdrawalAuditResult(agentIDs[0], 0, entitlement, entitlement, 30)
+	if aoa.GetWithdrawalAuditResult(agentIDs[0]) {
+		t.Errorf("expected withdrawing the allocation to pass the audit")
+	}
+	aoa.SetWithdrawalAuditResult(agentIDs[0], 0, entitlement+1, entitlement+1, 30)
+	if !aoa.GetWithdrawalAuditResult(agentIDs[0]) {
+		t.Errorf("expected withdrawing more than the allocation to fail the audit")
+	}
+
+	if _, set := serv.GetTeam(agentIDs[5]).GetWithdrawalEntitlement(agentIDs[5]); set {
+		t.Errorf("expected no entitlement for an agent without a team")
+	}
+}
+
+// Test that members left nothing by the allocation are still told their
+// entitlement, which the audit holds them to
+func TestTeam5AllocationCoversEveryMember(t *testing.T) {
+	serv, agentIDs := CreateTestServer(false)
+	teamID := serv.CreateAndInitTeamWithAgents(agentIDs[:3])
+	team := serv.Teams[teamID]
+	aoa := common.CreateTeam5AoA().(*common.Team5AOA)
+	team.TeamAoA = aoa
+	team.SetCommonPool(5)
+	for i, agentID := range agentIDs[:3] {
+		serv.GetAgentMap()[agentID].SetTrueScore([]int{0, 10, 100}[i])
+	}
+
+	aoa.RunPreWithdrawalAoaLogic(team, serv.GetAgentMap())
+
+	entitlement, set := serv.GetTeam(agentIDs[2]).GetWithdrawalEntitlement(agentIDs[2])
+	if !set || entitlement != 0 {
+		t.Fatalf("expected the richest member to be entitled to nothing, got %v (set: %v)", entitlement, set)
+	}
+	aoa.SetWithdrawalAuditResult(agentIDs[2], 100, 1, 1, 5)
+	if !aoa.GetWithdrawalAuditResult(agentIDs[2]) {
+		t.Errorf("expected withdrawing more than nothing to fail the audit")
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ADimoska/SOMASExtended/scenario"
+)
+
+// Test that team forming finishes as soon as every agent has signalled, rather
+// than waiting for the timeout
+func TestTeamFormingDoesNotWaitForTimeout(t *testing.T) {
+	s, err := scenario.Parse([]byte(`
+server: {iterations: 1, turns: 1, teamFormingTimeout: 10s}
+population:
+  - {factory: team4, count: 4}
+  - {factory: team2, count: 4}
+`))
+	if err != nil {
+		t.Fatalf("expected scenario to parse, got %v", err)
+	}
+	serv := s.CreateServer()
+	for i, agent := range s.CreatePopulation(serv) {
+		agent.SetName(i)
+		serv.AddAgent(agent)
+	}
+
+	start := time.Now()
+	serv.RunStartOfIteration(0)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("team forming took %v, expected it to finish before the timeout", elapsed)
+	}
+	if len(serv.GetTeamIDs()) == 0 {
+		t.Errorf("expected teams to have been formed")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ADimoska/SOMASExtended/common"
+)
+
+// Test that agents get a copy of the team that cannot be used to change it
+func TestTeamViewIsReadOnly(t *testing.T) {
+	serv, agentIDs := CreateTestServer(false)
+	teamID := serv.CreateAndInitTeamWithAgents(agentIDs[:3])
+	team := serv.Teams[teamID]
+	team.SetCommonPool(40)
+	team.InvalidateThreshold()
+
+	view := serv.GetTeam(agentIDs[0])
+	if view.TeamID != teamID || len(view.Agents) != 3 || view.CommonPool != 40 {
+		t.Fatalf("expected a view of team %v with 3 agents and a pool of 40, got %+v", teamID, view)
+	}
+	if _, known := view.GetKnownThreshold(); known {
+		t.Errorf("expected the threshold to be unknown while it is not exposed")
+	}
+
+	view.Agents[0] = agentIDs[5]
+	view.CommonPool = 0
+	if team.Agents[0] != agentIDs[0] || team.GetCommonPool() != 40 {
+		t.Errorf("changing the view changed the team")
+	}
+
+	if view := serv.GetTeam(agentIDs[5]); view.Exists() {
+		t.Errorf("expected an agent without a team to get the zero view, got %+v", view)
+	}
+}
+
+// Test that the Team1 rank queries cannot be turned back into the AoA
+func TestTeam1RankViewHidesAoA(t *testing.T) {
+	serv, agentIDs := CreateTestServer(false)
+	teamID := serv.CreateAndInitTeamWithAgents(agentIDs[:3])
+	serv.Teams[teamID].TeamAoA = common.CreateTeam1AoA(serv.Teams[teamID], 5)
+
+	ranks, ok := serv.GetTeam(agentIDs[0]).Team1Ranks()
+	if !ok {
+		t.Fatalf("expected rank queries for a team run by the Team1 AoA")
+	}
+	if _, isAoA := ranks.(common.IArticlesOfAssociation); isAoA {
+		t.Errorf("rank queries can be used as the AoA itself")
+	}
+}
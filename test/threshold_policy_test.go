@@ -0,0 +1,72 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+
+	envServer "github.com/ADimoska/SOMASExtended/server"
+)
+
+// Test that the default policy draws the threshold exactly as the original
+// rand.Intn(10) + turn did, so seeded games are unaffected
+func TestDefaultThresholdPolicyMatchesOriginalRule(t *testing.T) {
+	policy := envServer.DefaultThresholdRules().Policy
+	policyRand, originalRand := rand.New(rand.NewSource(9)), rand.New(rand.NewSource(9))
+	for turn := 0; turn < 50; turn++ {
+		got := policy.NextThreshold(envServer.ThresholdContext{Turn: turn, Rand: policyRand})
+		if expected := originalRand.Intn(10) + turn; got != expected {
+			t.Fatalf("turn %d: expected threshold %d, got %d", turn, expected, got)
+		}
+	}
+}
+
+// Test the deterministic built-in policies
+func TestThresholdPolicies(t *testing.T) {
+	cases := []struct {
+		name     string
+		params   envServer.ThresholdPolicyParams
+		ctx      envServer.ThresholdContext
+		expected int
+	}{
+		{"constant", envServer.ThresholdPolicyParams{"value": 12}, envServer.ThresholdContext{Turn: 30}, 12},
+		{"linear", envServer.ThresholdPolicyParams{"base": 2, "slope": 0.5}, envServer.ThresholdContext{Turn: 9}, 7},
+		{"wealth", envServer.ThresholdPolicyParams{"base": 1, "scoreFraction": 0.5, "perAgent": 1}, envServer.ThresholdContext{MeanScore: 20, AliveAgents: 4}, 15},
+		{"random-walk", nil, envServer.ThresholdContext{Turn: 0, Previous: 40}, 5},
+	}
+	for _, c := range cases {
+		policy, err := envServer.NewThresholdPolicy(c.name, c.params)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if got := policy.NextThreshold(c.ctx); got != c.expected {
+			t.Errorf("%s: expected threshold %d, got %d", policy, c.expected, got)
+		}
+	}
+}
+
+// Test that a random walk never moves further than its step or below its minimum
+func TestRandomWalkThresholdStaysInBounds(t *testing.T) {
+	policy, err := envServer.NewThresholdPolicy("random-walk", envServer.ThresholdPolicyParams{"start": 2, "step": 4, "min": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := envServer.ThresholdContext{Rand: rand.New(rand.NewSource(1))}
+	ctx.Previous = policy.NextThreshold(ctx)
+	for ctx.Turn = 1; ctx.Turn < 200; ctx.Turn++ {
+		next := policy.NextThreshold(ctx)
+		if next < 1 || next > ctx.Previous+4 || (next < ctx.Previous-4) {
+			t.Fatalf("turn %d: threshold moved from %d to %d", ctx.Turn, ctx.Previous, next)
+		}
+		ctx.Previous = next
+	}
+}
+
+// Test that parameters a policy does not take are rejected by name
+func TestThresholdPolicyRejectsUnknownParams(t *testing.T) {
+	_, err := envServer.NewThresholdPolicy("constant", envServer.ThresholdPolicyParams{"slope": 1})
+	paramErr, ok := err.(*envServer.ThresholdParamError)
+	if !ok || paramErr.Param != "slope" {
+		t.Errorf("expected a ThresholdParamError for slope, got %v", err)
+	}
+}
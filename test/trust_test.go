@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ADimoska/SOMASExtended/agents"
+	"github.com/ADimoska/SOMASExtended/common"
+	"github.com/ADimoska/SOMASExtended/gameRecorder"
+)
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// Test that the truster's own opinion counts in full, and that others' are
+// discounted by how far it trusts them
+func TestBetaReputation(t *testing.T) {
+	truster, reporter, subject := uuid.New(), uuid.New(), uuid.New()
+	model := common.BetaReputation{}
+
+	own := []common.TrustEdge{{Truster: truster, Opinion: common.NewOpinion(subject, 1, 1, common.EvidenceAudit)}}
+	if trust := model.Trust(truster, subject, own); !closeTo(trust, 2.0/3) {
+		t.Errorf("expected one good observation to give 2/3, got %v", trust)
+	}
+
+	told := append(own, common.TrustEdge{Truster: reporter, Opinion: common.NewOpinion(subject, 0, 1, common.EvidenceHearsay)})
+	if trust := model.Trust(truster, subject, told); !closeTo(trust, 2/3.5) {
+		t.Errorf("expected an unknown reporter to count for half, got %v", trust)
+	}
+
+	distrusted := append(told, common.TrustEdge{Truster: truster, Opinion: common.NewOpinion(reporter, 0, 1, common.EvidenceAudit)})
+	if trust := model.Trust(truster, subject, distrusted); !closeTo(trust, 2/(2+1.0/3+1)) {
+		t.Errorf("expected a distrusted reporter to count for a third, got %v", trust)
+	}
+}
+
+// Test that trust flows to the agent everyone trusts
+func TestEigenTrust(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	edges := []common.TrustEdge{
+		{Truster: a, Opinion: common.NewOpinion(c, 1, 1, common.EvidenceAudit)},
+		{Truster: b, Opinion: common.NewOpinion(c, 1, 1, common.EvidenceAudit)},
+		{Truster: c, Opinion: common.NewOpinion(a, 1, 1, common.EvidenceAudit)},
+	}
+	model, err := common.NewTrustModel(common.TrustEigenTrust)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trustA, trustB, trustC := model.Trust(a, a, edges), model.Trust(a, b, edges), model.Trust(a, c, edges)
+	if !closeTo(trustC, 1) || !(trustA > trustB) {
+		t.Errorf("expected c to be the most trusted and b the least, got a=%v b=%v c=%v", trustA, trustB, trustC)
+	}
+}
+
+// Test that agents pass on their opinions, trust what they are told, and that
+// the server records every opinion in the trust graph
+func TestOpinionExchange(t *testing.T) {
+	serv, _ := CreateTestServer(false)
+	serv.Init(3, false)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	events, err := gameRecorder.CreateEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serv.DataRecorder.SetEventLog(events)
+
+	asker := agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100})
+	witness := agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100})
+	cheater := agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100})
+	for _, agent := range []*agents.ExtendedAgent{asker, witness, cheater} {
+		serv.AddAgent(agent)
+	}
+	serv.CreateAndInitTeamWithAgents([]uuid.UUID{asker.GetID(), witness.GetID(), cheater.GetID()})
+
+	witness.SetAgentContributionAuditResult(cheater.GetID(), true)
+	opinions := witness.GetOpinions()
+	if len(opinions) != 1 || opinions[0].Subject != cheater.GetID() || opinions[0].Trust != 0 {
+		t.Fatalf("expected the witness to distrust the cheater, got %+v", opinions)
+	}
+	if trust := asker.GetTrust(cheater.GetID()); trust != 0.5 {
+		t.Errorf("expected no opinion to leave trust at 0.5, got %v", trust)
+	}
+	asker.HandleAgentOpinionResponseMessage(witness.CreateAgentOpinionResponseMessage(opinions[0]))
+	if trust := asker.GetTrust(cheater.GetID()); trust >= 0.5 {
+		t.Errorf("expected the witness's opinion to lower trust in the cheater, got %v", trust)
+	}
+
+	serv.RunTurn(0, 1)
+	if err := serv.DataRecorder.CloseEventLog(); err != nil {
+		t.Fatal(err)
+	}
+	logged, err := gameRecorder.ReadEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var graph *gameRecorder.TrustGraph
+	for _, event := range logged {
+		if event.Type == gameRecorder.EventTrustGraph {
+			graph = &gameRecorder.TrustGraph{}
+			if err := json.Unmarshal(event.Data, graph); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if graph == nil {
+		t.Fatal("expected the trust graph to be recorded")
+	}
+	found := false
+	for _, opinion := range graph.Opinions {
+		if opinion.TrusterID == witness.GetID() && opinion.SubjectID == cheater.GetID() {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected the witness's opinion in the trust graph, got %+v", graph.Opinions)
+	}
+	for _, reputation := range graph.Reputation {
+		if reputation.AgentID == cheater.GetID() && reputation.Trust >= 0.5 {
+			t.Errorf("expected the cheater's reputation to be below 0.5, got %v", reputation.Trust)
+		}
+	}
+}
+
+// Test that opinions can be noted while the network is being read, as they are
+// when messages arrive during a turn
+func TestTrustNetworkConcurrentUse(t *testing.T) {
+	network := common.NewTrustNetwork()
+	truster, subject := uuid.New(), uuid.New()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				network.Observe(truster, common.NewOpinion(subject, float64(j)/100, 1, common.EvidenceHearsay))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				network.EdgesWith(truster, network.OpinionsOf(truster))
+			}
+		}()
+	}
+	wg.Wait()
+	if edges := network.Edges(); len(edges) != 1 || edges[0].Subject != subject {
+		t.Errorf("expected one opinion of the subject, got %+v", edges)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/ADimoska/SOMASExtended/voting"
+)
+
+// Test that Copeland counts candidates with IDs of 10 and over correctly
+func TestCopelandLargeCandidates(t *testing.T) {
+	ballots := []voting.Ranking[int]{{12, 3, 10}, {12, 10, 3}, {3, 12, 10}}
+	result := voting.Copeland([]int{3, 10, 12}, ballots)
+	if !slices.Equal(result.Winners, []int{12}) || result.Scores[12] != 2 || result.Scores[10] != 0 {
+		t.Errorf("expected 12 to beat both others, got %v", result.Scores)
+	}
+}
+
+// Test that a Borda count with a single candidate still elects it
+func TestBordaSingleCandidate(t *testing.T) {
+	result := voting.Borda([]int{4}, []voting.Ranking[int]{{1, 4, 2}, {4}})
+	if winner, ok := result.Winner(voting.NoTies()); !ok || winner != 4 {
+		t.Errorf("expected the only candidate to win, got %v", result.Winners)
+	}
+	if result := voting.Borda([]int{4}, []voting.Ranking[int]{{1, 2}}); len(result.Winners) != 0 {
+		t.Errorf("expected no winner when no ballot names a candidate, got %v", result.Winners)
+	}
+}
+
+// Test each way of breaking a tie
+func TestTieBreaks(t *testing.T) {
+	result := voting.Plurality([]string{"a", "b", "c"}, []string{"b", "a", "c", "a", "b"})
+	if !slices.Equal(result.Winners, []string{"a", "b"}) {
+		t.Fatalf("expected a and b to tie, got %v", result.Winners)
+	}
+	if winner, _ := result.Winner(voting.FirstListed()); winner != "a" {
+		t.Errorf("expected the first listed to win, got %v", winner)
+	}
+	if _, ok := result.Winner(voting.NoTies()); ok {
+		t.Errorf("expected nobody to win a tie")
+	}
+	first, _ := result.Winner(voting.AtRandom(rand.New(rand.NewSource(7))))
+	again, _ := result.Winner(voting.AtRandom(rand.New(rand.NewSource(7))))
+	if first != again || !slices.Contains(result.Winners, first) {
+		t.Errorf("expected the same seed to break the tie the same way, got %v and %v", first, again)
+	}
+}
+
+// Test that IRV passes on the ballots of the knocked out candidate
+func TestIRVTransfers(t *testing.T) {
+	ballots := []voting.Ranking[string]{{"a"}, {"a"}, {"b"}, {"b"}, {"c", "b"}}
+	result := voting.IRV([]string{"a", "b", "c"}, ballots, voting.FirstListed())
+	if !slices.Equal(result.Winners, []string{"b"}) || result.Scores["b"] != 3 {
+		t.Errorf("expected b to win with c's ballot, got %v", result.Scores)
+	}
+}
+
+// Test approval counting and majority judgement's tie-breaking
+func TestApprovalAndMajorityJudgement(t *testing.T) {
+	approval := voting.Approval([]int{1, 2, 3}, [][]int{{1, 2}, {2}, {3, 2, 2}})
+	if !slices.Equal(approval.Winners, []int{2}) || approval.Scores[2] != 3 {
+		t.Errorf("expected 2 to be approved by every ballot, got %v", approval.Scores)
+	}
+
+	// Both have a median of 2, but once it is set aside 1 is left with 3 against 1
+	ballots := []voting.Grades[int]{{1: 1, 2: 0}, {1: 2, 2: 2}, {1: 3, 2: 4}, {1: 3, 2: 2}}
+	judgement := voting.MajorityJudgement([]int{1, 2}, ballots)
+	if judgement.Scores[1] != 2 || judgement.Scores[2] != 2 || !slices.Equal(judgement.Winners, []int{1}) {
+		t.Errorf("expected 1 to win the tie on medians, got %v with %v", judgement.Winners, judgement.Scores)
+	}
+}
+
+// Test that a Condorcet winner is found only when one exists
+func TestVotingCondorcetWinner(t *testing.T) {
+	ballots := []voting.Grades[int]{{0: 1, 1: 3, 2: 2}, {0: 2, 1: 3, 2: 1}, {0: 3, 1: 1, 2: 2}}
+	if winner, ok := voting.CondorcetWinner([]int{0, 1, 2}, ballots); !ok || winner != 1 {
+		t.Errorf("expected 1 to beat both others, got %v", winner)
+	}
+	cycle := []voting.Ranking[int]{{0, 1, 2}, {1, 2, 0}, {2, 0, 1}}
+	if winner, ok := voting.CondorcetWinner([]int{0, 1, 2}, cycle); ok {
+		t.Errorf("expected no winner in a cycle, got %v", winner)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ADimoska/SOMASExtended/agents"
+	"github.com/ADimoska/SOMASExtended/common"
+	"github.com/ADimoska/SOMASExtended/gameRecorder"
+)
+
+// An AoA that audits nobody on a vote but hears every report, finding only the
+// cheater guilty
+type reportHearingAoA struct {
+	fixedCostAoA
+	cheater uuid.UUID
+}
+
+func (aoa reportHearingAoA) GetVoteResult(votes []common.Vote) uuid.UUID {
+	return uuid.Nil
+}
+
+func (aoa reportHearingAoA) GetContributionAuditResult(agentID uuid.UUID) bool {
+	return agentID == aoa.cheater
+}
+
+func (aoa reportHearingAoA) ReviewReport(report *common.WhistleblowerMessage) bool {
+	return true
+}
+
+func (aoa reportHearingAoA) GetReportReward(report *common.WhistleblowerMessage) int {
+	return 5
+}
+
+func (aoa reportHearingAoA) GetFalseReportSanction(report *common.WhistleblowerMessage, agentScore int) common.Sanction {
+	return common.Sanction{Fine: 7}
+}
+
+// Test that a true report is rewarded, a false one fined, and every report recorded
+func TestWhistleblowing(t *testing.T) {
+	serv, _ := CreateTestServer(false)
+	serv.Init(3, false)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	events, err := gameRecorder.CreateEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serv.DataRecorder.SetEventLog(events)
+
+	whistleblower := agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100})
+	cheater := agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100})
+	innocent := agents.GetBaseAgents(serv, agents.AgentConfig{InitScore: 100})
+	for _, agent := range []*agents.ExtendedAgent{whistleblower, cheater, innocent} {
+		serv.AddAgent(agent)
+	}
+	teamID := serv.CreateAndInitTeamWithAgents([]uuid.UUID{whistleblower.GetID(), cheater.GetID(), innocent.GetID()})
+	serv.Teams[teamID].TeamAoA = reportHearingAoA{fixedCostAoA{common.CreateFixedAoA(1), 2}, cheater.GetID()}
+	serv.Teams[teamID].SetCommonPool(50)
+
+	evidence := common.WhistleblowerEvidence{StatedAmount: 1, ExpectedAmount: 3}
+	serv.ReportCheating(whistleblower.CreateWhistleblowerMessage(cheater.GetID(), common.ContributionPhase, evidence))
+	serv.ReportCheating(whistleblower.CreateWhistleblowerMessage(innocent.GetID(), common.ContributionPhase, evidence))
+	serv.ReportCheating(whistleblower.CreateWhistleblowerMessage(whistleblower.GetID(), common.ContributionPhase, evidence))
+
+	serv.RunTurn(0, 1)
+	if err := serv.DataRecorder.CloseEventLog(); err != nil {
+		t.Fatal(err)
+	}
+	logged, err := gameRecorder.ReadEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outcomes := map[uuid.UUID]string{}
+	rewarded, fined := 0, 0
+	for _, event := range logged {
+		switch event.Type {
+		case gameRecorder.EventWhistleblown:
+			var report gameRecorder.Whistleblown
+			if err := json.Unmarshal(event.Data, &report); err != nil {
+				t.Fatal(err)
+			}
+			outcomes[report.AccusedID] = report.Outcome
+		case gameRecorder.EventReward:
+			var reward gameRecorder.Reward
+			if err := json.Unmarshal(event.Data, &reward); err != nil {
+				t.Fatal(err)
+			}
+			if reward.AgentID == whistleblower.GetID() {
+				rewarded += reward.Amount
+			}
+		case gameRecorder.EventPunishment:
+			var punishment gameRecorder.Punishment
+			if err := json.Unmarshal(event.Data, &punishment); err != nil {
+				t.Fatal(err)
+			}
+			if punishment.AgentID == whistleblower.GetID() {
+				fined += punishment.Amount
+			}
+		}
+	}
+
+	expected := map[uuid.UUID]string{cheater.GetID(): "upheld", innocent.GetID(): "rejected", whistleblower.GetID(): "invalid"}
+	for accusedID, outcome := range expected {
+		if outcomes[accusedID] != outcome {
+			t.Errorf("expected the report on %v to be %s, got %q", accusedID, outcome, outcomes[accusedID])
+		}
+	}
+	if rewarded != 5 || fined != 7 {
+		t.Errorf("expected the whistleblower to be rewarded 5 and fined 7, got %d and %d", rewarded, fined)
+	}
+}
@@ -0,0 +1,75 @@
+package voting
+
+import (
+	"cmp"
+	"slices"
+)
+
+/*
+* Majority judgement: every ballot grades the candidates, and the candidate
+* with the highest median grade wins, taking the lower of the two middle
+* grades when there is an even number. Candidates tied on their median are
+* separated the usual way, by setting aside one median grade from each and
+* comparing the medians of what is left, until one is ahead or they run out of
+* grades. Scores holds each candidate's median grade. Only the grades actually
+* given count, so candidates nobody graded cannot win.
+ */
+func MajorityJudgement[C comparable](candidates []C, ballots []Grades[C]) Result[C] {
+	candidates = distinct(candidates)
+	grades := make(map[C][]int)
+	total := 0.0
+	for _, ballot := range ballots {
+		graded := false
+		for _, candidate := range candidates {
+			if grade, exists := ballot[candidate]; exists {
+				grades[candidate] = append(grades[candidate], grade)
+				graded = true
+			}
+		}
+		if graded {
+			total++
+		}
+	}
+
+	scores := make(map[C]float64)
+	graded := []C{}
+	for _, candidate := range candidates {
+		if len(grades[candidate]) > 0 {
+			slices.Sort(grades[candidate])
+			scores[candidate] = float64(lowerMedian(grades[candidate]))
+			graded = append(graded, candidate)
+		}
+	}
+
+	var winners []C
+	for _, candidate := range graded {
+		if len(winners) == 0 {
+			winners = []C{candidate}
+			continue
+		}
+		switch compareJudgements(grades[candidate], grades[winners[0]]) {
+		case 1:
+			winners = []C{candidate}
+		case 0:
+			winners = append(winners, candidate)
+		}
+	}
+	return Result[C]{Scores: scores, Winners: winners, Total: total}
+}
+
+func lowerMedian(sorted []int) int {
+	return sorted[(len(sorted)-1)/2]
+}
+
+// Compare two candidates' sorted grades by majority judgement
+func compareJudgements(a, b []int) int {
+	a, b = slices.Clone(a), slices.Clone(b)
+	for len(a) > 0 && len(b) > 0 {
+		if order := cmp.Compare(lowerMedian(a), lowerMedian(b)); order != 0 {
+			return order
+		}
+		a = slices.Delete(a, (len(a)-1)/2, (len(a)-1)/2+1)
+		b = slices.Delete(b, (len(b)-1)/2, (len(b)-1)/2+1)
+	}
+	return 0
+}
@@ -0,0 +1,96 @@
+package voting
+
+// A ballot that can compare any two candidates
+type Ballot[C comparable] interface {
+	Prefers(a, b C) bool // whether a is strictly preferred to b
+}
+
+// Grades given to candidates, higher being better. Candidates without a grade
+// rank below every candidate with one, and level with each other.
+type Grades[C comparable] map[C]int
+
+func (g Grades[C]) Prefers(a, b C) bool {
+	gradeA, gradedA := g[a]
+	gradeB, gradedB := g[b]
+	return gradedA && (!gradedB || gradeA > gradeB)
+}
+
+// The margin by which ballots prefer a to b, minus those preferring b to a
+func margin[C comparable, B Ballot[C]](ballots []B, a, b C) int {
+	margin := 0
+	for _, ballot := range ballots {
+		if ballot.Prefers(a, b) {
+			margin++
+		} else if ballot.Prefers(b, a) {
+			margin--
+		}
+	}
+	return margin
+}
+
+/*
+* Copeland: every pair of candidates is compared, and a candidate scores a point
+* for each other candidate more ballots prefer it to, and half a point for each
+* that as many ballots prefer either way. Every candidate scores, so with no
+* ballots all of them tie. Only ballots preferring one candidate to another
+* count towards Total.
+ */
+func Copeland[C comparable, B Ballot[C]](candidates []C, ballots []B) Result[C] {
+	candidates = distinct(candidates)
+	total := 0.0
+	for _, ballot := range ballots {
+		if expressesPreference(ballot, candidates) {
+			total++
+		}
+	}
+
+	scores := make(map[C]float64)
+	for _, candidate := range candidates {
+		scores[candidate] = 0
+	}
+	for i, a := range candidates {
+		for _, b := range candidates[i+1:] {
+			switch m := margin(ballots, a, b); {
+			case m > 0:
+				scores[a]++
+			case m < 0:
+				scores[b]++
+			default:
+				scores[a] += 0.5
+				scores[b] += 0.5
+			}
+		}
+	}
+	return Result[C]{Scores: scores, Winners: topScorers(candidates, scores), Total: total}
+}
+
+// The candidate more ballots prefer to each of the others than the other way
+// round, if there is one
+func CondorcetWinner[C comparable, B Ballot[C]](candidates []C, ballots []B) (C, bool) {
+	candidates = distinct(candidates)
+	for _, a := range candidates {
+		beatsAll := true
+		for _, b := range candidates {
+			if a != b && margin(ballots, a, b) <= 0 {
+				beatsAll = false
+				break
+			}
+		}
+		if beatsAll {
+			return a, true
+		}
+	}
+	var none C
+	return none, false
+}
+
+func expressesPreference[C comparable, B Ballot[C]](ballot B, candidates []C) bool {
+	for _, a := range candidates {
+		for _, b := range candidates {
+			if ballot.Prefers(a, b) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package voting
+
+import "slices"
+
+// A ranked ballot, candidates best first. Candidates left off it rank below
+// every candidate on it, and level with each other.
+type Ranking[C comparable] []C
+
+func (r Ranking[C]) Prefers(a, b C) bool {
+	placeA, placeB := slices.Index(r, a), slices.Index(r, b)
+	return placeA >= 0 && (placeB < 0 || placeA < placeB)
+}
+
+// The ballot with everything that is not a candidate, and every mention of a
+// candidate after the first, left out
+func (r Ranking[C]) among(candidates []C) Ranking[C] {
+	clean := Ranking[C]{}
+	for _, choice := range r {
+		if slices.Contains(candidates, choice) && !slices.Contains(clean, choice) {
+			clean = append(clean, choice)
+		}
+	}
+	return clean
+}
+
+// The candidate with the most votes. Each choice is one vote.
+func Plurality[C comparable](candidates []C, choices []C) Result[C] {
+	return WeightedPlurality(candidates, choices, nil)
+}
+
+// The candidate with the most votes, choices[i] carrying weights[i]. A nil
+// weights gives every choice a weight of one.
+func WeightedPlurality[C comparable](candidates []C, choices []C, weights []float64) Result[C] {
+	candidates = distinct(candidates)
+	scores := make(map[C]float64)
+	total := 0.0
+	for i, choice := range choices {
+		if !slices.Contains(candidates, choice) {
+			continue
+		}
+		weight := 1.0
+		if weights != nil {
+			weight = weights[i]
+		}
+		scores[choice] += weight
+		total += weight
+	}
+	return Result[C]{Scores: scores, Winners: topScorers(candidates, scores), Total: total}
+}
+
+// The candidate approved of by the most ballots. Each ballot lists the
+// candidates it approves of.
+func Approval[C comparable](candidates []C, ballots [][]C) Result[C] {
+	candidates = distinct(candidates)
+	scores := make(map[C]float64)
+	total := 0.0
+	for _, ballot := range ballots {
+		approved := Ranking[C](ballot).among(candidates)
+		if len(approved) == 0 {
+			continue
+		}
+		total++
+		for _, choice := range approved {
+			scores[choice]++
+		}
+	}
+	return Result[C]{Scores: scores, Winners: topScorers(candidates, scores), Total: total}
+}
+
+// The candidate with the most points, each ballot giving its first choice one
+// point fewer than there are candidates, its second one fewer again, and so on.
+// Candidates left off a ballot get nothing from it.
+func Borda[C comparable](candidates []C, ballots []Ranking[C]) Result[C] {
+	candidates = distinct(candidates)
+	scores := make(map[C]float64)
+	total := 0.0
+	for _, ballot := range ballots {
+		ranked := ballot.among(candidates)
+		if len(ranked) == 0 {
+			continue
+		}
+		total++
+		for place, choice := range ranked {
+			scores[choice] += float64(len(candidates) - 1 - place)
+		}
+	}
+	if total == 0 {
+		return Result[C]{Scores: scores}
+	}
+	// every candidate can win on points, even those no ballot mentions
+	for _, candidate := range candidates {
+		if _, scored := scores[candidate]; !scored {
+			scores[candidate] = 0
+		}
+	}
+	return Result[C]{Scores: scores, Winners: topScorers(candidates, scores), Total: total}
+}
+
+/*
+* Instant runoff: the candidate with the fewest first choices is knocked out,
+* and their ballots go to the next choice still standing, until a candidate has
+* more than half of the ballots that have not run out of choices. Ties for the
+* fewest are broken as the policy says, or with TieNone by knocking all of them
+* out together. Scores holds the first choices counted in the final round.
+ */
+func IRV[C comparable](candidates []C, ballots []Ranking[C], tie TieBreak) Result[C] {
+	remaining := distinct(candidates)
+	cleaned := make([]Ranking[C], 0, len(ballots))
+	for _, ballot := range ballots {
+		if ranked := ballot.among(remaining); len(ranked) > 0 {
+			cleaned = append(cleaned, ranked)
+		}
+	}
+
+	for {
+		counts := make(map[C]float64)
+		total := 0.0
+		for _, ballot := range cleaned {
+			for _, choice := range ballot {
+				if slices.Contains(remaining, choice) {
+					counts[choice]++
+					total++
+					break
+				}
+			}
+		}
+		if total == 0 {
+			return Result[C]{Scores: counts}
+		}
+		for _, candidate := range remaining {
+			if counts[candidate]*2 > total {
+				return Result[C]{Scores: counts, Winners: []C{candidate}, Total: total}
+			}
+		}
+
+		var lowest []C
+		fewest := total + 1
+		for _, candidate := range remaining {
+			if counts[candidate] < fewest {
+				lowest, fewest = []C{candidate}, counts[candidate]
+			} else if counts[candidate] == fewest {
+				lowest = append(lowest, candidate)
+			}
+		}
+		if len(lowest) == len(remaining) {
+			return Result[C]{Scores: counts, Winners: lowest, Total: total}
+		}
+		if out, chosen := Choose(tie, lowest); chosen {
+			lowest = []C{out}
+		}
+		remaining = slices.DeleteFunc(remaining, func(candidate C) bool { return slices.Contains(lowest, candidate) })
+	}
+}
@@ -0,0 +1,105 @@
+package voting
+
+import (
+	"math/rand"
+	"slices"
+)
+
+/*
+* Voting methods shared by the server, the AoAs and the agents, so that every
+* vote in the game is counted the same way. Each method takes the candidates
+* standing and the ballots cast, and returns a Result naming every candidate
+* that tied for first place. Choosing between tied candidates is left to the
+* caller, who says how through a TieBreak: the candidate listed first, one
+* drawn from a seeded random stream, or nobody at all.
+*
+* Candidates can be of any comparable type (agent IDs, AoA IDs, strategies).
+* Ballots naming anything that is not a candidate have those names ignored.
+ */
+
+type Result[C comparable] struct {
+	Scores  map[C]float64 // what each candidate scored, in the method's own units
+	Winners []C           // every candidate tied for first place, in candidate order, empty if no ballot named a candidate
+	Total   float64       // the weight of the ballots that named a candidate
+}
+
+// How to choose between candidates tied for first place
+type TiePolicy string
+
+const (
+	TieFirst  TiePolicy = "first"  // the candidate listed first
+	TieRandom TiePolicy = "random" // a candidate drawn at random
+	TieNone   TiePolicy = "none"   // nobody wins a tie
+)
+
+type TieBreak struct {
+	Policy TiePolicy
+	Rng    *rand.Rand // drawn from by TieRandom, which panics without one
+}
+
+// Ties go to the candidate listed first, so pass candidates in a fixed order
+func FirstListed() TieBreak {
+	return TieBreak{Policy: TieFirst}
+}
+
+// Ties are broken by drawing from rng, which should be one of the game's seeded streams
+func AtRandom(rng *rand.Rand) TieBreak {
+	return TieBreak{Policy: TieRandom, Rng: rng}
+}
+
+// Ties have no winner
+func NoTies() TieBreak {
+	return TieBreak{Policy: TieNone}
+}
+
+// Choose one of the tied candidates. Draws from the random stream only when
+// there is a tie to break.
+func Choose[C comparable](tie TieBreak, tied []C) (C, bool) {
+	var none C
+	switch {
+	case len(tied) == 0:
+		return none, false
+	case len(tied) == 1:
+		return tied[0], true
+	case tie.Policy == TieRandom:
+		return tied[tie.Rng.Intn(len(tied))], true
+	case tie.Policy == TieNone:
+		return none, false
+	}
+	return tied[0], true
+}
+
+// The winner of the vote, with any tie broken as the policy says. Returns false
+// if there is none.
+func (r Result[C]) Winner(tie TieBreak) (C, bool) {
+	return Choose(tie, r.Winners)
+}
+
+// Every candidate with the highest score. Candidates without a score cannot win.
+func topScorers[C comparable](candidates []C, scores map[C]float64) []C {
+	var top []C
+	best := 0.0
+	for _, candidate := range candidates {
+		score, scored := scores[candidate]
+		if !scored {
+			continue
+		}
+		if len(top) == 0 || score > best {
+			top, best = []C{candidate}, score
+		} else if score == best {
+			top = append(top, candidate)
+		}
+	}
+	return top
+}
+
+// The candidates without duplicates, keeping the first of each
+func distinct[C comparable](candidates []C) []C {
+	unique := make([]C, 0, len(candidates))
+	for _, candidate := range candidates {
+		if !slices.Contains(unique, candidate) {
+			unique = append(unique, candidate)
+		}
+	}
+	return unique
+}